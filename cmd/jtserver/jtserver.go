@@ -1,21 +1,50 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/service"
 	"jacobo.tarrio.org/jtweb/comments/web"
 	"jacobo.tarrio.org/jtweb/config"
 	"jacobo.tarrio.org/jtweb/config/fromflags"
+	"jacobo.tarrio.org/jtweb/email/newsletter"
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
 	"jacobo.tarrio.org/jtweb/languages"
+	"jacobo.tarrio.org/jtweb/metrics"
+	"jacobo.tarrio.org/jtweb/ratelimit"
 	"jacobo.tarrio.org/jtweb/webcontent"
 
 	"github.com/rs/cors"
 )
 
-var flagServerAddress = flag.String("server_address", "127.0.0.1:8080", "The address where the server will be listening.")
+var flagServerAddress = flag.String("server_address", "127.0.0.1:8080", "The address where the server will be listening, or unix:PATH to listen on a Unix domain socket. Ignored if systemd passes sockets to the server.")
+var flagSocketMode = flag.String("socket_mode", "0660", "The permissions of the Unix domain socket, in octal.")
+var flagTlsCertFile = flag.String("tls_cert_file", "", "The file containing the TLS certificate chain, to serve HTTPS. Reloaded on SIGHUP.")
+var flagTlsKeyFile = flag.String("tls_key_file", "", "The file containing the TLS private key, to serve HTTPS. Reloaded on SIGHUP.")
+var flagHashPassword = flag.Bool("hash_password", false, "Read a password from the standard input, print its hash for an admin account, and exit.")
+var flagNotificationInterval = flag.Duration("notification_interval", 30*time.Second, "How often to check for pending notifications to deliver.")
+var flagNewsletterInterval = flag.Duration("newsletter_interval", time.Minute, "How often to check for newsletter campaigns that are due.")
+var flagReadTimeout = flag.Duration("read_timeout", 30*time.Second, "The maximum time to read a request, including its body.")
+var flagWriteTimeout = flag.Duration("write_timeout", 60*time.Second, "The maximum time to write a response.")
+var flagIdleTimeout = flag.Duration("idle_timeout", 2*time.Minute, "How long to keep an idle connection open.")
+var flagMetricsAddress = flag.String("metrics_address", "", "The address where the server will serve its metrics and health checks, or unix:PATH for a Unix domain socket. If empty, the metrics are served along with everything else, only to administrators.")
+var flagTrustedProxies = flag.String("trusted_proxies", "127.0.0.1,::1", "Comma-separated list of the addresses and networks (in CIDR notation) of the reverse proxies whose X-Forwarded-For headers identify the clients for rate limiting. Proxies that connect through a Unix domain socket are always trusted.")
+var flagShutdownTimeout = flag.Duration("shutdown_timeout", 30*time.Second, "How long to wait for in-flight requests to finish when shutting down.")
 
 func getOrigins(cfg config.Config) ([]string, error) {
 	origins := map[string]bool{}
@@ -37,9 +66,88 @@ func getOrigins(cfg config.Config) ([]string, error) {
 	return keys, nil
 }
 
+// deliverNotifications periodically sends the notifications waiting in the outbox, until the context is done.
+func deliverNotifications(ctx context.Context, commentsService service.CommentsService, interval time.Duration) {
+	const batchSize = 50
+	for {
+		for {
+			count, err := commentsService.DeliverNotifications(context.Background(), batchSize)
+			if err != nil {
+				log.Printf("Error delivering notifications: %s", err)
+			}
+			if count < batchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// deliverNewsletters periodically sends the newsletter campaigns that are due, until the context is done.
+func deliverNewsletters(ctx context.Context, newsletters []*newsletter.Newsletter, interval time.Duration) {
+	for {
+		for _, n := range newsletters {
+			if err := n.SendDue(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Error sending newsletters: %s", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// checkHealth returns a handler that reports whether the database can be reached.
+// If ready is not nil, the handler also reports an error when ready is false.
+func checkHealth(e engine.Engine, ready *atomic.Bool) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		if ready != nil && !ready.Load() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			rw.Write([]byte("shutting down\n"))
+			return
+		}
+		ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+		defer cancel()
+		if err := e.Ping(ctx); err != nil {
+			// The error may reveal details about the database, so it only goes to the log.
+			log.Printf("Health check failed: %s", err)
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			rw.Write([]byte("database unavailable\n"))
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("ok\n"))
+	}
+}
+
+// hashPassword prints the hash of the password in the standard input, to use in the configuration of an admin account.
+func hashPassword() {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		panic(err)
+	}
+	hash, err := web.HashPassword(strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(hash)
+}
+
 func main() {
 	flag.Parse()
 
+	if *flagHashPassword {
+		hashPassword()
+		return
+	}
+
 	cfg, err := fromflags.GetConfig()
 	if err != nil {
 		panic(err)
@@ -56,17 +164,150 @@ func main() {
 	corsChecker := cors.New(cors.Options{
 		AllowedOrigins:   origins,
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
-		AllowCredentials: false,
+		AllowCredentials: true,
 	})
 
-	adminChecker := web.NewAdminChecker(cfg.Comments().AdminPassword())
+	adminChecker := cfg.Comments().AdminChecker()
+
+	proxies, err := ratelimit.ParseProxies(*flagTrustedProxies)
+	if err != nil {
+		panic(err)
+	}
+
+	registry := metrics.NewRegistry()
+	serveOptions := []web.ServeOption{web.WithMetrics(registry), web.WithTimeouts(cfg.Comments().Timeouts()), web.WithTrustedProxies(proxies)}
+	if cfg.Comments().Identities() != nil {
+		serveOptions = append(serveOptions, web.WithIdentities(cfg.Comments().Identities()))
+	}
+	if cfg.Comments().ModerationLinks() != nil {
+		serveOptions = append(serveOptions, web.WithModerationLinks(cfg.Comments().ModerationLinks()))
+	}
+	if cfg.Comments().Subscriptions() != nil {
+		serveOptions = append(serveOptions, web.WithSubscriptions(cfg.Comments().Subscriptions()))
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/_/", http.StripPrefix("/_", web.Serve(cfg.Comments().Service(), adminChecker)))
+	mux.Handle("/_/", http.StripPrefix("/_", web.Serve(cfg.Comments().Service(), adminChecker, serveOptions...)))
 	mux.Handle("/comments.js", webcontent.ServeCommentsJs())
 	mux.Handle("/admin.html", adminChecker.RequiringAdmin(webcontent.ServeAdminHtml()))
 	mux.Handle("/admin.js", adminChecker.RequiringAdmin(webcontent.ServeAdminJs()))
-	server := &http.Server{Addr: *flagServerAddress, Handler: corsChecker.Handler(mux)}
-	log.Printf("Now serving on %s", server.Addr)
-	log.Fatal(server.ListenAndServe())
+	if adminChecker.HasAccounts() {
+		mux.Handle("/login.html", webcontent.ServeLoginHtml())
+	}
+	var ready atomic.Bool
+	ready.Store(true)
+	mux.Handle("/healthz", checkHealth(cfg.Comments().Engine(), nil))
+	mux.Handle("/readyz", checkHealth(cfg.Comments().Engine(), &ready))
+	var metricsServer *http.Server
+	if *flagMetricsAddress == "" {
+		mux.Handle("/metrics", adminChecker.RequiringAdmin(registry.ServeHTTP))
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/healthz", checkHealth(cfg.Comments().Engine(), nil))
+		metricsMux.Handle("/readyz", checkHealth(cfg.Comments().Engine(), &ready))
+		metricsMux.Handle("/metrics", registry)
+		metricsServer = &http.Server{
+			Handler:      metricsMux,
+			ReadTimeout:  *flagReadTimeout,
+			WriteTimeout: *flagWriteTimeout,
+			IdleTimeout:  *flagIdleTimeout,
+		}
+	}
+	if subs := cfg.NewsletterSubscriptions(); subs != nil {
+		mux.Handle("/newsletter/", http.StripPrefix("/newsletter", subs.Handler(subscriptions.WithTrustedProxies(proxies))))
+		mux.Handle("/subscribe.js", webcontent.ServeSubscribeJs())
+	}
+	newsletters := []*newsletter.Newsletter{}
+	for _, mailer := range cfg.Mailers() {
+		if n := mailer.Newsletter(); n != nil {
+			newsletters = append(newsletters, n)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	delivererDone := make(chan struct{})
+	go func() {
+		deliverNotifications(ctx, cfg.Comments().Service(), *flagNotificationInterval)
+		close(delivererDone)
+	}()
+	newsletterDone := make(chan struct{})
+	go func() {
+		deliverNewsletters(ctx, newsletters, *flagNewsletterInterval)
+		close(newsletterDone)
+	}()
+
+	server := &http.Server{
+		Handler:      corsChecker.Handler(mux),
+		ReadTimeout:  *flagReadTimeout,
+		WriteTimeout: *flagWriteTimeout,
+		IdleTimeout:  *flagIdleTimeout,
+	}
+	if (*flagTlsCertFile == "") != (*flagTlsKeyFile == "") {
+		panic("--tls_cert_file and --tls_key_file must be used together")
+	}
+	if *flagTlsCertFile != "" {
+		certs, err := newCertReloader(*flagTlsCertFile, *flagTlsKeyFile)
+		if err != nil {
+			panic(err)
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go certs.watchForReload(hup)
+		server.TLSConfig = &tls.Config{GetCertificate: certs.getCertificate}
+	}
+
+	socketMode, err := parseSocketMode(*flagSocketMode)
+	if err != nil {
+		panic(err)
+	}
+	listeners, err := getListeners(*flagServerAddress, socketMode)
+	if err != nil {
+		panic(err)
+	}
+	serverErr := make(chan error, len(listeners)+1)
+	if metricsServer != nil {
+		metricsListener, err := listen(*flagMetricsAddress, socketMode)
+		if err != nil {
+			panic(err)
+		}
+		go func() {
+			serverErr <- metricsServer.Serve(metricsListener)
+		}()
+		log.Printf("Now serving metrics on %s", metricsListener.Addr())
+	}
+	for _, listener := range listeners {
+		listener := listener
+		go func() {
+			if server.TLSConfig != nil {
+				serverErr <- server.ServeTLS(listener, "", "")
+			} else {
+				serverErr <- server.Serve(listener)
+			}
+		}()
+		log.Printf("Now serving on %s", listener.Addr())
+	}
+
+	select {
+	case err := <-serverErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down")
+	ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *flagShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down: %s", err)
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down the metrics server: %s", err)
+		}
+	}
+	<-delivererDone
+	<-newsletterDone
+	log.Printf("Shut down")
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// The first file descriptor passed by systemd socket activation.
+const systemdFirstFd = 3
+
+// getListeners returns the sockets the server accepts connections on: the ones passed by systemd
+// if the server was started through socket activation, or otherwise a new one at the given address.
+// Addresses that start with "unix:" are paths to Unix domain sockets, which are created with the given mode.
+func getListeners(address string, socketMode os.FileMode) ([]net.Listener, error) {
+	listeners, err := systemdListeners(systemdFirstFd)
+	if err != nil || len(listeners) > 0 {
+		return listeners, err
+	}
+	listener, err := listen(address, socketMode)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{listener}, nil
+}
+
+func listen(address string, socketMode os.FileMode) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, "unix:")
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+	// A socket left over from a previous run would make Listen fail.
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// systemdListeners returns the sockets passed by systemd, following the sd_listen_fds protocol.
+// The first socket is expected at file descriptor firstFd.
+func systemdListeners(firstFd int) ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+	// Child processes must not think that the sockets were passed to them.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	listeners := []net.Listener{}
+	for fd := firstFd; fd < firstFd+count; fd++ {
+		syscall.CloseOnExec(fd)
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("systemd socket %d", fd))
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd passed file descriptor %d, which is not a usable socket: %w", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+func parseSocketMode(mode string) (os.FileMode, error) {
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid socket mode %q: %w", mode, err)
+	}
+	return os.FileMode(value) & os.ModePerm, nil
+}
+
+// certReloader provides the TLS certificate for the server, and reloads it from its files on request,
+// so renewed certificates can be picked up without restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the certificate and key files again. If they can't be loaded, the previous certificate is kept.
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// watchForReload reloads the certificate whenever a value is received on the channel.
+func (r *certReloader) watchForReload(signals <-chan os.Signal) {
+	for range signals {
+		if err := r.reload(); err != nil {
+			log.Printf("Error reloading the TLS certificate, keeping the old one: %s", err)
+		} else {
+			log.Printf("Reloaded the TLS certificate")
+		}
+	}
+}
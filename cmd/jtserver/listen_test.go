@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSocketMode(t *testing.T) {
+	mode, err := parseSocketMode("0660")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0660), mode)
+	// Only the permission bits are kept.
+	mode, err = parseSocketMode("1777")
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0777), mode)
+	_, err = parseSocketMode("0689")
+	assert.NotNil(t, err)
+	_, err = parseSocketMode("rw-rw----")
+	assert.NotNil(t, err)
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jtserver.sock")
+
+	// Leave a stale socket behind, as a server that crashed would.
+	stale, err := net.Listen("unix", path)
+	assert.Nil(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.Nil(t, stale.Close())
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+
+	listener, err := listen("unix:"+path, 0640)
+	assert.Nil(t, err)
+	defer listener.Close()
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestListenUnixKeepsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jtserver.sock")
+	assert.Nil(t, os.WriteFile(path, []byte("not a socket"), 0644))
+	_, err := listen("unix:"+path, 0660)
+	assert.NotNil(t, err)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "not a socket", string(content))
+}
+
+// dupFd returns a new file descriptor for the file, which the caller must close.
+func dupFd(t *testing.T, file *os.File) int {
+	fd, err := syscall.Dup(int(file.Fd()))
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+	return fd
+}
+
+func TestSystemdListeners(t *testing.T) {
+	// Without the environment variables, there are no sockets.
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	listeners, err := systemdListeners(systemdFirstFd)
+	assert.Nil(t, err)
+	assert.Empty(t, listeners)
+
+	// The sockets were passed to another process.
+	t.Setenv("LISTEN_PID", fmt.Sprint(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err = systemdListeners(systemdFirstFd)
+	assert.Nil(t, err)
+	assert.Empty(t, listeners)
+
+	original, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer original.Close()
+	file, err := original.(*net.TCPListener).File()
+	assert.Nil(t, err)
+	fd := dupFd(t, file)
+
+	t.Setenv("LISTEN_PID", fmt.Sprint(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "jtserver")
+	listeners, err = systemdListeners(fd)
+	assert.Nil(t, err)
+	assert.Len(t, listeners, 1)
+	defer listeners[0].Close()
+	assert.Equal(t, original.Addr().String(), listeners[0].Addr().String())
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		_, ok := os.LookupEnv(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestSystemdListenersNotASocket(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	defer w.Close()
+	fd := dupFd(t, r)
+
+	t.Setenv("LISTEN_PID", fmt.Sprint(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	_, err = systemdListeners(fd)
+	assert.NotNil(t, err)
+}
+
+// writeCert writes a new self-signed certificate and its key to the files.
+func writeCert(t *testing.T, certFile string, keyFile string, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+func commonName(t *testing.T, r *certReloader) string {
+	cert, err := r.getCertificate(nil)
+	assert.Nil(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "first")
+	r, err := newCertReloader(certFile, keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "first", commonName(t, r))
+
+	// A failed reload keeps the old certificate.
+	assert.Nil(t, os.WriteFile(certFile, []byte("garbage"), 0600))
+	assert.NotNil(t, r.reload())
+	assert.Equal(t, "first", commonName(t, r))
+
+	writeCert(t, certFile, keyFile, "second")
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		r.watchForReload(signals)
+		close(done)
+	}()
+	signals <- syscall.SIGHUP
+	close(signals)
+	<-done
+	assert.Equal(t, "second", commonName(t, r))
+}
+
+func TestCertReloaderMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	assert.NotNil(t, err)
+}
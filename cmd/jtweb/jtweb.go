@@ -22,6 +22,17 @@ var flagOperations = flag.String("operations", "*",
 		"full name without wildcards. "+
 		"Use 'list' to view all available operations.")
 
+var flagCommentsFile = flag.String("comments_file", "",
+	"The name of the file that the comments-export and comments-import operations write to or read from.")
+var flagCommentsFormat = flag.String("comments_format", "json",
+	"The format of the file read by the comments-import operation: 'json', 'wxr' (WordPress) or 'disqus'.")
+var flagEmailPage = flag.String("email_page", "",
+	"The name of the page that the email-preview and email-test operations use. Default: the next page to be sent.")
+var flagEmailPreviewDir = flag.String("email_preview_dir", ".",
+	"The directory where the email-preview operation writes its files.")
+var flagEmailStatsFile = flag.String("email_stats_file", "",
+	"The name of the file that the email-stats operations write to and the email-report operation reads from.")
+
 type operation struct {
 	name        string
 	description string
@@ -46,6 +57,24 @@ func getAvailableOperations(cfg config.Config) []operation {
 			skipped:     cfg.Comments().SkipOperation(),
 			operate:     lib.OpComments(),
 		})
+		ops = append(ops, operation{
+			name:        "comments-purge",
+			description: "Permanently remove comments that were deleted before the retention period",
+			skipped:     true,
+			operate:     lib.OpCommentsPurge(),
+		})
+		ops = append(ops, operation{
+			name:        "comments-export",
+			description: "Export all comments to a JSON file",
+			skipped:     true,
+			operate:     lib.OpCommentsExport(*flagCommentsFile),
+		})
+		ops = append(ops, operation{
+			name:        "comments-import",
+			description: "Import comments from a JSON, WordPress or Disqus export file",
+			skipped:     true,
+			operate:     lib.OpCommentsImport(*flagCommentsFile, *flagCommentsFormat),
+		})
 	}
 	for _, mailer_iter := range cfg.Mailers() {
 		// Make a copy of the mailer.
@@ -56,6 +85,32 @@ func getAvailableOperations(cfg config.Config) []operation {
 			skipped:     mailer.SkipOperation(),
 			operate:     lib.OpEmail(mailer),
 		})
+		ops = append(ops, operation{
+			name:        fmt.Sprintf("email-preview=%s", mailer.Name()),
+			description: fmt.Sprintf("Write the HTML, text and MIME versions of an email for '%s' to files", mailer.Name()),
+			skipped:     true,
+			operate:     lib.OpEmailPreview(mailer, *flagEmailPage, *flagEmailPreviewDir),
+		})
+		ops = append(ops, operation{
+			name:        fmt.Sprintf("email-test=%s", mailer.Name()),
+			description: fmt.Sprintf("Send an email for '%s' to its test addresses", mailer.Name()),
+			skipped:     true,
+			operate:     lib.OpEmailTest(mailer, *flagEmailPage),
+		})
+		ops = append(ops, operation{
+			name:        fmt.Sprintf("email-stats=%s", mailer.Name()),
+			description: fmt.Sprintf("Store the statistics of the campaigns sent for '%s'", mailer.Name()),
+			skipped:     true,
+			operate:     lib.OpEmailStats(mailer, *flagEmailStatsFile),
+		})
+	}
+	if len(cfg.Mailers()) > 0 {
+		ops = append(ops, operation{
+			name:        "email-report",
+			description: "List the pages by the engagement of their emails",
+			skipped:     true,
+			operate:     lib.OpEmailReport(*flagEmailStatsFile),
+		})
 	}
 	return ops
 }
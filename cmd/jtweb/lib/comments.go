@@ -2,10 +2,16 @@ package lib
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
 
 	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/archive"
 	"jacobo.tarrio.org/jtweb/comments/service"
 	"jacobo.tarrio.org/jtweb/site"
+	"jacobo.tarrio.org/jtweb/uri"
 )
 
 func OpComments() OpFn {
@@ -22,3 +28,92 @@ func OpComments() OpFn {
 		return cfg.Service().SetAvailablePosts(context.Background(), posts)
 	}
 }
+
+func OpCommentsPurge() OpFn {
+	return func(contents *site.RawContents) error {
+		cfg := contents.Config.Comments()
+		deletedBefore := time.Now().Add(-cfg.DeletedRetention())
+		count, err := cfg.Service().PurgeDeletedComments(context.Background(), deletedBefore)
+		if err != nil {
+			return err
+		}
+		log.Printf("Purged %d comments deleted before %s", count, deletedBefore.Format(time.RFC3339))
+		return nil
+	}
+}
+
+func OpCommentsExport(fileName string) OpFn {
+	return func(contents *site.RawContents) error {
+		if fileName == "" {
+			return fmt.Errorf("the --comments_file flag has not been specified")
+		}
+		exported, err := archive.Export(context.Background(), contents.Config.Comments().Engine())
+		if err != nil {
+			return err
+		}
+		file, err := os.Create(fileName)
+		if err != nil {
+			return err
+		}
+		err = exported.Write(file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+}
+
+func OpCommentsImport(fileName string, format string) OpFn {
+	return func(contents *site.RawContents) error {
+		if fileName == "" {
+			return fmt.Errorf("the --comments_file flag has not been specified")
+		}
+		file, err := os.Open(fileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		var imported *archive.Archive
+		switch format {
+		case "json":
+			imported, err = archive.Read(file)
+		case "wxr":
+			imported, err = archive.ReadWxr(file, makeUriResolver(contents))
+		case "disqus":
+			imported, err = archive.ReadDisqus(file, makeUriResolver(contents))
+		default:
+			return fmt.Errorf("unknown comments format: %s", format)
+		}
+		if err != nil {
+			return err
+		}
+		result, err := archive.Import(context.Background(), contents.Config.Comments().Engine(), imported)
+		if err != nil {
+			return err
+		}
+		for _, postId := range result.MissingPosts {
+			log.Printf("Post %s does not exist in the comments database; run the 'comments' operation first", postId)
+		}
+		log.Printf("Imported %d comments; skipped %d already present", result.Imported, result.Duplicates)
+		return nil
+	}
+}
+
+// makeUriResolver maps every page's current and old URIs to its post.
+func makeUriResolver(contents *site.RawContents) *archive.UriResolver {
+	resolver := archive.NewUriResolver()
+	for name, page := range contents.Pages {
+		webRoot := contents.Config.Site(page.Header.Language).WebRoot()
+		postId := comments.PostId(name)
+		if err := resolver.Add(uri.Concat(webRoot, string(name)+".html"), postId); err != nil {
+			log.Printf("Invalid URI for page %s: %v", name, err)
+		}
+		for _, oldUri := range page.Header.OldURI {
+			if err := resolver.Add(uri.Concat(webRoot, oldUri), postId); err != nil {
+				log.Printf("Invalid old URI for page %s: %v", name, err)
+			}
+		}
+	}
+	return resolver
+}
@@ -1,24 +1,235 @@
 package lib
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	email_notification "jacobo.tarrio.org/jtweb/comments/notification/email"
 	"jacobo.tarrio.org/jtweb/config"
+	"jacobo.tarrio.org/jtweb/email"
 	"jacobo.tarrio.org/jtweb/email/generator"
+	"jacobo.tarrio.org/jtweb/email/stats"
+	"jacobo.tarrio.org/jtweb/page"
 	"jacobo.tarrio.org/jtweb/site"
 )
 
+func newEmailGenerator(content *site.Contents, mailer config.MailerConfig, options ...generator.EmailGeneratorOption) *generator.EmailGenerator {
+	options = append(options,
+		generator.NamePrefix(mailer.SubjectPrefix()),
+		generator.SubjectPrefix(mailer.SubjectPrefix()),
+		generator.WithTags(mailer.Tags()...),
+		generator.WithoutTags(mailer.ExcludeTags()...),
+		generator.InSeries(mailer.Series()...),
+		generator.MinInterval(mailer.MinInterval()),
+	)
+	if digest := mailer.Digest(); digest != nil {
+		options = append(options, generator.Digest(generator.DigestSchedule{
+			Monthly:  digest.Monthly(),
+			Weekday:  digest.Weekday(),
+			Hour:     digest.Hour(),
+			Minute:   digest.Minute(),
+			Location: digest.Location(),
+		}))
+	}
+	return generator.NewEmailGenerator(content, mailer.Language(), mailer.Engine()).WithOptions(options...)
+}
+
 func OpEmail(mailer config.MailerConfig) OpFn {
 	return func(rawContent *site.RawContents) error {
 		notBefore := getTimeOrDefault(rawContent.Config.DateFilters().Mail().NotBefore(), rawContent.Config.DateFilters().Now())
 		notAfter := rawContent.Config.DateFilters().Mail().NotAfter()
-		content, err := rawContent.Index(notBefore, notAfter)
+		// The earlier pages are needed to apply the minimum interval between pages.
+		content, err := rawContent.Index(nil, notAfter)
+		if err != nil {
+			return err
+		}
+		return newEmailGenerator(content, mailer, generator.UpdateScheduled(), generator.NotBefore(*notBefore)).SendMails()
+	}
+}
+
+// previewEmail returns the email for the named page, or for the next page to be sent if pageName is empty.
+func previewEmail(rawContent *site.RawContents, mailer config.MailerConfig, pageName string) (*email.Email, error) {
+	content, err := rawContent.Index(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	g := newEmailGenerator(content, mailer)
+	name := page.Name(pageName)
+	if name == "" {
+		name = g.NextPage(rawContent.Config.DateFilters().Now())
+		if name == "" {
+			return nil, fmt.Errorf("there are no pages in %s", mailer.Language().Code())
+		}
+	}
+	return g.Email(name)
+}
+
+// emailMessage returns the full MIME message for an email, addressed to the mailer's test addresses.
+func emailMessage(mailer config.MailerConfig, e *email.Email) (string, error) {
+	from := ""
+	if mailer.TestMailer() != nil {
+		from = mailer.TestMailer().From
+	}
+	return email_notification.HtmlMessage(from, mailer.TestAddresses(), e.Subject, e.Html, e.Plaintext, nil)
+}
+
+func OpEmailPreview(mailer config.MailerConfig, pageName string, outputDir string) OpFn {
+	return func(rawContent *site.RawContents) error {
+		e, err := previewEmail(rawContent, mailer, pageName)
 		if err != nil {
 			return err
 		}
-		return generator.NewEmailGenerator(content, mailer.Language(), mailer.Engine()).
-			WithOptions(
-				generator.NotScheduled(),
-				generator.NamePrefix(mailer.SubjectPrefix()),
-				generator.SubjectPrefix(mailer.SubjectPrefix()),
-			).SendMails()
+		message, err := emailMessage(mailer, e)
+		if err != nil {
+			return err
+		}
+		base := filepath.Join(outputDir, mailer.Name()+"-"+strings.ReplaceAll(e.Name, "/", "-"))
+		for _, file := range []struct {
+			ext     string
+			content string
+		}{
+			{".html", e.Html},
+			{".txt", e.Plaintext},
+			{".eml", message},
+		} {
+			err := os.WriteFile(base+file.ext, []byte(file.content), 0644)
+			if err != nil {
+				return err
+			}
+			log.Printf("Wrote preview of [%s] to %s", e.Name, base+file.ext)
+		}
+		return nil
+	}
+}
+
+func OpEmailTest(mailer config.MailerConfig, pageName string) OpFn {
+	return func(rawContent *site.RawContents) error {
+		if mailer.TestMailer() == nil {
+			return fmt.Errorf("test emails have not been configured for mailer %s", mailer.Name())
+		}
+		e, err := previewEmail(rawContent, mailer, pageName)
+		if err != nil {
+			return err
+		}
+		message, err := emailMessage(mailer, e)
+		if err != nil {
+			return err
+		}
+		err = mailer.TestMailer().SendMessage(mailer.TestAddresses(), message)
+		if err != nil {
+			return err
+		}
+		log.Printf("Sent test email for [%s] to %s", e.Name, strings.Join(mailer.TestAddresses(), ", "))
+		return nil
+	}
+}
+
+// readEmailStats reads the statistics file, or returns empty statistics if it doesn't exist yet.
+func readEmailStats(fileName string) (*stats.Stats, error) {
+	file, err := os.Open(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stats.New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return stats.Read(file)
+}
+
+func OpEmailStats(mailer config.MailerConfig, fileName string) OpFn {
+	return func(rawContent *site.RawContents) error {
+		if fileName == "" {
+			return fmt.Errorf("the --email_stats_file flag has not been specified")
+		}
+		reporter := email.AsStatsReporter(mailer.Engine())
+		if reporter == nil {
+			return fmt.Errorf("the %s engine can't report campaign statistics", mailer.Engine().Name())
+		}
+		content, err := rawContent.Index(nil, nil)
+		if err != nil {
+			return err
+		}
+		pages := newEmailGenerator(content, mailer).CampaignPages()
+		campaigns, err := reporter.CampaignStats()
+		if err != nil {
+			return err
+		}
+		emailStats, err := readEmailStats(fileName)
+		if err != nil {
+			return err
+		}
+		count := 0
+		for _, campaign := range campaigns {
+			name, ok := pages[campaign.Name]
+			if !ok {
+				continue
+			}
+			emailStats.Set(string(name), mailer.Name(), campaign)
+			count++
+		}
+		if rawContent.Config.DryRun() {
+			log.Printf("[Dry run] Storing the statistics of %d campaigns for '%s' in %s", count, mailer.Name(), fileName)
+			return nil
+		}
+		err = writeEmailStats(fileName, emailStats)
+		if err != nil {
+			return err
+		}
+		log.Printf("Stored the statistics of %d campaigns for '%s' in %s", count, mailer.Name(), fileName)
+		return nil
+	}
+}
+
+// writeEmailStats writes the statistics to a temporary file and then renames it over the statistics file,
+// so the previous statistics are kept if the write fails.
+func writeEmailStats(fileName string, emailStats *stats.Stats) error {
+	file, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	// CreateTemp makes the file readable only by its owner; use the same permissions as a newly created file.
+	err = file.Chmod(0644)
+	if err == nil {
+		err = emailStats.Write(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), fileName)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+func OpEmailReport(fileName string) OpFn {
+	return func(rawContent *site.RawContents) error {
+		if fileName == "" {
+			return fmt.Errorf("the --email_stats_file flag has not been specified")
+		}
+		emailStats, err := readEmailStats(fileName)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Opens\tClicks\tRecipients\tPage\tTitle")
+		for _, e := range emailStats.Report() {
+			title := ""
+			if p, ok := rawContent.Pages[page.Name(e.Page)]; ok {
+				title = p.Header.Title
+			}
+			fmt.Fprintf(w, "%.1f%%\t%.1f%%\t%d\t%s\t%s\n", 100*e.OpenRate(), 100*e.ClickRate(), e.Recipients, e.Page, title)
+		}
+		return w.Flush()
 	}
 }
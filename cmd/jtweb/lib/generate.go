@@ -1,6 +1,11 @@
 package lib
 
-import "jacobo.tarrio.org/jtweb/site"
+import (
+	"context"
+	"fmt"
+
+	"jacobo.tarrio.org/jtweb/site"
+)
 
 func OpGenerate() OpFn {
 	return func(rawContent *site.RawContents) error {
@@ -9,6 +14,15 @@ func OpGenerate() OpFn {
 		if err != nil {
 			return err
 		}
+		if rawContent.Config.Generator().PrerenderComments() {
+			if !rawContent.Config.Comments().Present() {
+				return fmt.Errorf("comments must be configured to prerender them")
+			}
+			err = content.LoadComments(context.Background())
+			if err != nil {
+				return err
+			}
+		}
 		return content.Write()
 	}
 }
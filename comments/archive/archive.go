@@ -0,0 +1,232 @@
+// The archive package exports and imports comments to and from a JSON file,
+// and reads the export files produced by other commenting systems so their
+// comments can be imported.
+//
+// The JSON file contains an object with a "Posts" array. Each post is an
+// object with the following fields:
+//
+//   - "PostId": the post's identifier, which is the page's name.
+//   - "Comments": an array of comments.
+//
+// Each comment is an object with the following fields:
+//
+//   - "Id": the comment's identifier in the database it was exported from.
+//     It is ignored on import.
+//   - "Visible": whether the comment has been approved.
+//   - "Author": the name of the comment's author.
+//   - "When": the comment's date in RFC 3339 format.
+//   - "Text": the comment's text in Markdown format.
+//   - "Identity": an optional object containing the verified identity that
+//     posted the comment, with "Email" and "Name" fields.
+
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+// Archive contains the comments for a set of posts.
+type Archive struct {
+	Posts []*Post
+}
+
+// Post contains the comments for a single post.
+type Post struct {
+	PostId   comments.PostId
+	Comments []*Comment
+}
+
+// Comment contains a single comment.
+type Comment struct {
+	Id       comments.CommentId `json:",omitempty"`
+	Visible  bool
+	Author   string
+	When     time.Time
+	Text     comments.Markdown
+	Identity *Identity `json:",omitempty"`
+}
+
+// Identity contains a commenter's verified identity.
+type Identity struct {
+	Email string
+	Name  string
+}
+
+// ImportResult summarizes the outcome of an import.
+type ImportResult struct {
+	// Number of comments that were added to the database.
+	Imported int
+	// Number of comments that were already in the database.
+	Duplicates int
+	// Posts that were not imported because they don't exist in the database.
+	MissingPosts []comments.PostId
+}
+
+const postBatchSize = 100
+
+// Read parses an archive in JSON format.
+func Read(r io.Reader) (*Archive, error) {
+	var a Archive
+	err := json.NewDecoder(r).Decode(&a)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Write outputs an archive in JSON format.
+func (a *Archive) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// Export retrieves all the comments in the database, including the ones that have not been approved.
+func Export(ctx context.Context, e engine.Engine) (*Archive, error) {
+	postIds, err := listPosts(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	out := &Archive{Posts: []*Post{}}
+	for _, postId := range postIds {
+		list, err := e.List(ctx, postId, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			continue
+		}
+		post := &Post{PostId: postId, Comments: []*Comment{}}
+		for _, cmt := range list {
+			comment := &Comment{
+				Id:      cmt.CommentId,
+				Visible: cmt.Visible,
+				Author:  cmt.Author,
+				When:    cmt.When,
+				Text:    cmt.Text,
+			}
+			if cmt.Identity != nil {
+				comment.Identity = &Identity{Email: cmt.Identity.Email, Name: cmt.Identity.Name}
+			}
+			post.Comments = append(post.Comments, comment)
+		}
+		sortComments(post.Comments)
+		out.Posts = append(out.Posts, post)
+	}
+	return out, nil
+}
+
+// Import adds the comments in the archive to the database.
+//
+// The posts must already exist in the database. Comments that are already present, with the same
+// author, date and text, are skipped, so it is safe to import the same archive more than once.
+func Import(ctx context.Context, e engine.Engine, a *Archive) (*ImportResult, error) {
+	postIds, err := listPosts(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	known := map[comments.PostId]bool{}
+	for _, postId := range postIds {
+		known[postId] = true
+	}
+	identities := map[string]comments.IdentityId{}
+	result := &ImportResult{MissingPosts: []comments.PostId{}}
+	for _, post := range a.Posts {
+		if !known[post.PostId] {
+			result.MissingPosts = append(result.MissingPosts, post.PostId)
+			continue
+		}
+		existing, err := e.List(ctx, post.PostId, true)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, cmt := range existing {
+			seen[commentKey(cmt.Author, cmt.When, cmt.Text)] = true
+		}
+		for _, cmt := range post.Comments {
+			key := commentKey(cmt.Author, cmt.When, cmt.Text)
+			if seen[key] {
+				result.Duplicates++
+				continue
+			}
+			newComment := &engine.NewComment{
+				PostId:  post.PostId,
+				Visible: cmt.Visible,
+				Author:  cmt.Author,
+				When:    cmt.When,
+				Text:    cmt.Text,
+			}
+			if cmt.Identity != nil && cmt.Identity.Email != "" {
+				// Addresses are stored in lowercase, as the comments service does, so that they match the identities
+				// that commenters verify later.
+				email := strings.ToLower(cmt.Identity.Email)
+				identityId, ok := identities[email]
+				if !ok {
+					identity, err := e.SaveIdentity(ctx, email, cmt.Identity.Name)
+					if err != nil {
+						return nil, err
+					}
+					identityId = identity.IdentityId
+					identities[email] = identityId
+				}
+				newComment.IdentityId = identityId
+			}
+			_, err := e.Add(ctx, newComment, engine.Outbox{})
+			if err != nil {
+				return nil, fmt.Errorf("error importing comment for post [%s]: %v", post.PostId, err)
+			}
+			seen[key] = true
+			result.Imported++
+		}
+	}
+	return result, nil
+}
+
+func listPosts(ctx context.Context, e engine.Engine) ([]comments.PostId, error) {
+	out := []comments.PostId{}
+	var after *comments.PostId
+	for {
+		configs, err := e.FindPosts(ctx, engine.PostFilter{}, engine.SortByPost, postBatchSize, after)
+		if err != nil {
+			return nil, err
+		}
+		for _, cfg := range configs {
+			out = append(out, cfg.PostId)
+		}
+		if len(configs) < postBatchSize {
+			return out, nil
+		}
+		after = &configs[len(configs)-1].PostId
+	}
+}
+
+func commentKey(author string, when time.Time, text comments.Markdown) string {
+	return fmt.Sprintf("%s\x00%d\x00%s", author, when.Unix(), text)
+}
+
+func sortComments(list []*Comment) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].When.Before(list[j].When)
+	})
+}
+
+// addComment appends a comment to the archive, creating the post if necessary.
+func (a *Archive) addComment(postId comments.PostId, comment *Comment) {
+	for _, post := range a.Posts {
+		if post.PostId == postId {
+			post.Comments = append(post.Comments, comment)
+			return
+		}
+	}
+	a.Posts = append(a.Posts, &Post{PostId: postId, Comments: []*Comment{comment}})
+}
@@ -0,0 +1,233 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/engine/sqlite3"
+)
+
+func newResolver(t *testing.T) *UriResolver {
+	r := NewUriResolver()
+	assert.Nil(t, r.Add("https://example.com/blog/first.html", "first"))
+	assert.Nil(t, r.Add("https://example.com/blog/2010/01/first-post/", "first"))
+	assert.Nil(t, r.Add("https://example.com/blog/second.html", "second"))
+	return r
+}
+
+func TestResolver(t *testing.T) {
+	r := newResolver(t)
+	for _, uri := range []string{
+		"https://example.com/blog/first.html",
+		"http://www.example.com/blog/first.html?utm_source=feed#comments",
+		"http://example.com/blog/2010/01/first-post",
+	} {
+		postId, ok := r.Resolve(uri)
+		assert.True(t, ok, uri)
+		assert.Equal(t, "first", string(postId), uri)
+	}
+	_, ok := r.Resolve("https://example.com/blog/third.html")
+	assert.False(t, ok)
+}
+
+const disqusXml = `<?xml version="1.0" encoding="utf-8"?>
+<disqus xmlns="http://disqus.com" xmlns:dsq="http://disqus.com/disqus-internals">
+  <thread dsq:id="100">
+    <link>http://example.com/blog/2010/01/first-post/</link>
+    <title>First post</title>
+  </thread>
+  <thread dsq:id="200">
+    <link>http://example.com/blog/unknown.html</link>
+  </thread>
+  <post dsq:id="2">
+    <message><![CDATA[<p>Second!</p>]]></message>
+    <createdAt>2010-01-02T10:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name></name><username>bob</username></author>
+    <thread dsq:id="100" />
+  </post>
+  <post dsq:id="1">
+    <message><![CDATA[<p>First!</p>]]></message>
+    <createdAt>2010-01-01T10:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>false</isSpam>
+    <author><name>Alice</name><username>alice</username></author>
+    <thread dsq:id="100" />
+  </post>
+  <post dsq:id="3">
+    <message><![CDATA[Buy things]]></message>
+    <createdAt>2010-01-03T10:00:00Z</createdAt>
+    <isDeleted>false</isDeleted>
+    <isSpam>true</isSpam>
+    <author><name>Spammer</name></author>
+    <thread dsq:id="100" />
+  </post>
+  <post dsq:id="4">
+    <message><![CDATA[Lost]]></message>
+    <createdAt>2010-01-03T10:00:00Z</createdAt>
+    <author><name>Carol</name></author>
+    <thread dsq:id="200" />
+  </post>
+</disqus>`
+
+func TestReadDisqus(t *testing.T) {
+	a, err := ReadDisqus(strings.NewReader(disqusXml), newResolver(t))
+	assert.Nil(t, err)
+	assert.Equal(t, &Archive{Posts: []*Post{{
+		PostId: "first",
+		Comments: []*Comment{
+			{Visible: true, Author: "Alice", When: time.Date(2010, 1, 1, 10, 0, 0, 0, time.UTC), Text: "<p>First!</p>"},
+			{Visible: true, Author: "bob", When: time.Date(2010, 1, 2, 10, 0, 0, 0, time.UTC), Text: "<p>Second!</p>"},
+		},
+	}}}, a)
+}
+
+const wxrXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0" xmlns:wp="http://wordpress.org/export/1.2/">
+  <channel>
+    <item>
+      <title>Second</title>
+      <link>https://example.com/blog/second.html</link>
+      <comments>https://example.com/blog/second.html#comments</comments>
+      <wp:comment>
+        <wp:comment_author><![CDATA[Dave]]></wp:comment_author>
+        <wp:comment_date>2011-05-01 12:00:00</wp:comment_date>
+        <wp:comment_date_gmt>2011-05-01 10:00:00</wp:comment_date_gmt>
+        <wp:comment_content><![CDATA[Nice *post*.]]></wp:comment_content>
+        <wp:comment_approved>1</wp:comment_approved>
+        <wp:comment_type><![CDATA[comment]]></wp:comment_type>
+      </wp:comment>
+      <wp:comment>
+        <wp:comment_author><![CDATA[Eve]]></wp:comment_author>
+        <wp:comment_date>2011-05-02 12:00:00</wp:comment_date>
+        <wp:comment_date_gmt>0000-00-00 00:00:00</wp:comment_date_gmt>
+        <wp:comment_content><![CDATA[Pending.]]></wp:comment_content>
+        <wp:comment_approved>0</wp:comment_approved>
+        <wp:comment_type><![CDATA[]]></wp:comment_type>
+      </wp:comment>
+      <wp:comment>
+        <wp:comment_author><![CDATA[Other blog]]></wp:comment_author>
+        <wp:comment_date_gmt>2011-05-03 10:00:00</wp:comment_date_gmt>
+        <wp:comment_content><![CDATA[Linked.]]></wp:comment_content>
+        <wp:comment_approved>1</wp:comment_approved>
+        <wp:comment_type><![CDATA[pingback]]></wp:comment_type>
+      </wp:comment>
+      <wp:comment>
+        <wp:comment_author><![CDATA[Spammer]]></wp:comment_author>
+        <wp:comment_date_gmt>2011-05-03 10:00:00</wp:comment_date_gmt>
+        <wp:comment_content><![CDATA[Buy things.]]></wp:comment_content>
+        <wp:comment_approved>spam</wp:comment_approved>
+      </wp:comment>
+    </item>
+  </channel>
+</rss>`
+
+func TestReadWxr(t *testing.T) {
+	a, err := ReadWxr(strings.NewReader(wxrXml), newResolver(t))
+	assert.Nil(t, err)
+	assert.Equal(t, &Archive{Posts: []*Post{{
+		PostId: "second",
+		Comments: []*Comment{
+			{Visible: true, Author: "Dave", When: time.Date(2011, 5, 1, 10, 0, 0, 0, time.UTC), Text: "Nice *post*."},
+			{Visible: false, Author: "Eve", When: time.Date(2011, 5, 2, 12, 0, 0, 0, time.UTC), Text: "Pending."},
+		},
+	}}}, a)
+}
+
+func newTestEngine(t *testing.T) engine.Engine {
+	schema, err := os.ReadFile("../engine/sqlite3/schema.sql")
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "comments.db")
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	_, err = db.Exec(string(schema))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+	e, err := sqlite3.NewSqlite3Engine(path)
+	assert.Nil(t, err)
+	return e
+}
+
+func TestExportAndImport(t *testing.T) {
+	ctx := context.Background()
+	source := newTestEngine(t)
+	target := newTestEngine(t)
+	for _, e := range []engine.Engine{source, target} {
+		assert.Nil(t, e.SetAllPostConfigs(ctx, &engine.BulkConfig{Configs: []engine.Config{
+			{PostId: "first", State: engine.CommentsEnabled},
+			{PostId: "second", State: engine.CommentsClosed},
+		}}))
+	}
+	identity, err := source.SaveIdentity(ctx, "alice@example.com", "Alice")
+	assert.Nil(t, err)
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []*engine.NewComment{
+		{PostId: "first", Visible: true, Author: "Alice", When: when, Text: "Hello", IdentityId: identity.IdentityId},
+		{PostId: "first", Visible: false, Author: "Bob", When: when.Add(time.Hour), Text: "Draft"},
+		{PostId: "second", Visible: true, Author: "Carol", When: when, Text: "Hi"},
+	} {
+		_, err := source.Add(ctx, c, engine.Outbox{})
+		assert.Nil(t, err)
+	}
+
+	exported, err := Export(ctx, source)
+	assert.Nil(t, err)
+	buf := bytes.Buffer{}
+	assert.Nil(t, exported.Write(&buf))
+	archive, err := Read(&buf)
+	assert.Nil(t, err)
+	archive.Posts = append(archive.Posts, &Post{PostId: "missing", Comments: []*Comment{{Author: "X", When: when, Text: "Y"}}})
+
+	result, err := Import(ctx, target, archive)
+	assert.Nil(t, err)
+	assert.Equal(t, &ImportResult{Imported: 3, MissingPosts: []engine.PostId{"missing"}}, result)
+
+	list, err := target.List(ctx, "first", true)
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	for _, c := range list {
+		if c.Author == "Alice" {
+			assert.True(t, c.Visible)
+			assert.Equal(t, "alice@example.com", c.Identity.Email)
+		} else {
+			assert.False(t, c.Visible)
+			assert.Nil(t, c.Identity)
+		}
+	}
+
+	result, err = Import(ctx, target, archive)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, 3, result.Duplicates)
+}
+
+func TestImportNormalizesIdentities(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	assert.Nil(t, e.SetAllPostConfigs(ctx, &engine.BulkConfig{Configs: []engine.Config{
+		{PostId: "first", State: engine.CommentsEnabled},
+	}}))
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	archive := &Archive{Posts: []*Post{{PostId: "first", Comments: []*Comment{
+		{Author: "Alice", When: when, Text: "One", Identity: &Identity{Email: "Alice@Example.com", Name: "Alice"}},
+		{Author: "Alice", When: when.Add(time.Hour), Text: "Two", Identity: &Identity{Email: "alice@example.com", Name: "Alice"}},
+	}}}}
+	result, err := Import(ctx, e, archive)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Imported)
+
+	list, err := e.List(ctx, "first", true)
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "alice@example.com", list[0].Identity.Email)
+	assert.Equal(t, list[0].Identity.IdentityId, list[1].Identity.IdentityId)
+}
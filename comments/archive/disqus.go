@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"encoding/xml"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+)
+
+type disqusExport struct {
+	Threads []disqusThread `xml:"thread"`
+	Posts   []disqusPost   `xml:"post"`
+}
+
+type disqusThread struct {
+	Id   string `xml:"http://disqus.com/disqus-internals id,attr"`
+	Link string `xml:"link"`
+}
+
+type disqusPost struct {
+	Message   string    `xml:"message"`
+	CreatedAt time.Time `xml:"createdAt"`
+	IsDeleted bool      `xml:"isDeleted"`
+	IsSpam    bool      `xml:"isSpam"`
+	Author    struct {
+		Name     string `xml:"name"`
+		Username string `xml:"username"`
+	} `xml:"author"`
+	Thread struct {
+		Id string `xml:"http://disqus.com/disqus-internals id,attr"`
+	} `xml:"thread"`
+}
+
+// ReadDisqus parses a Disqus XML export and returns the comments for the threads whose links
+// correspond to known posts. Deleted comments and spam are skipped.
+//
+// Disqus comments are written in HTML, which is kept as is, as the Markdown renderer accepts it.
+func ReadDisqus(r io.Reader, resolver *UriResolver) (*Archive, error) {
+	var export disqusExport
+	err := xml.NewDecoder(r).Decode(&export)
+	if err != nil {
+		return nil, err
+	}
+	threads := map[string]comments.PostId{}
+	for _, thread := range export.Threads {
+		postId, ok := resolver.Resolve(thread.Link)
+		if !ok {
+			log.Printf("No page found for Disqus thread %s; its comments will be skipped", thread.Link)
+			continue
+		}
+		threads[thread.Id] = postId
+	}
+	out := &Archive{Posts: []*Post{}}
+	for _, post := range export.Posts {
+		if post.IsDeleted || post.IsSpam {
+			continue
+		}
+		postId, ok := threads[post.Thread.Id]
+		if !ok {
+			continue
+		}
+		author := post.Author.Name
+		if author == "" {
+			author = post.Author.Username
+		}
+		out.addComment(postId, &Comment{
+			Visible: true,
+			Author:  author,
+			When:    post.CreatedAt,
+			Text:    comments.Markdown(strings.TrimSpace(post.Message)),
+		})
+	}
+	for _, post := range out.Posts {
+		sortComments(post.Comments)
+	}
+	return out, nil
+}
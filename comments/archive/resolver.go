@@ -0,0 +1,53 @@
+package archive
+
+import (
+	"net/url"
+	"strings"
+
+	"jacobo.tarrio.org/jtweb/comments"
+)
+
+// UriResolver finds the post that corresponds to the URI of a page in another commenting system.
+//
+// URIs are compared by their paths only, so it doesn't matter if the other system used a different
+// scheme or host name, or added a query string.
+type UriResolver struct {
+	paths map[string]comments.PostId
+}
+
+// NewUriResolver returns an empty UriResolver.
+func NewUriResolver() *UriResolver {
+	return &UriResolver{paths: map[string]comments.PostId{}}
+}
+
+// Add registers a URI for a post.
+func (r *UriResolver) Add(uri string, postId comments.PostId) error {
+	path, err := normalizePath(uri)
+	if err != nil {
+		return err
+	}
+	r.paths[path] = postId
+	return nil
+}
+
+// Resolve returns the post for the given URI, if there is one.
+func (r *UriResolver) Resolve(uri string) (comments.PostId, bool) {
+	path, err := normalizePath(uri)
+	if err != nil {
+		return "", false
+	}
+	postId, ok := r.paths[path]
+	return postId, ok
+}
+
+func normalizePath(uri string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimRight(parsed.Path, "/")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path, nil
+}
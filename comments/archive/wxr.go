@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+)
+
+const wxrDateFormat = "2006-01-02 15:04:05"
+
+type wxrExport struct {
+	Items []wxrItem `xml:"channel>item"`
+}
+
+type wxrItem struct {
+	Link     string       `xml:"link"`
+	Comments []wxrComment `xml:"comment"`
+}
+
+type wxrComment struct {
+	Author   string `xml:"comment_author"`
+	Date     string `xml:"comment_date"`
+	DateGmt  string `xml:"comment_date_gmt"`
+	Content  string `xml:"comment_content"`
+	Approved string `xml:"comment_approved"`
+	Type     string `xml:"comment_type"`
+}
+
+// ReadWxr parses a WordPress eXtended RSS (WXR) export and returns the comments for the items whose
+// links correspond to known posts. Pingbacks, trackbacks, spam and trashed comments are skipped;
+// comments that were pending approval are imported as drafts.
+func ReadWxr(r io.Reader, resolver *UriResolver) (*Archive, error) {
+	var export wxrExport
+	err := xml.NewDecoder(r).Decode(&export)
+	if err != nil {
+		return nil, err
+	}
+	out := &Archive{Posts: []*Post{}}
+	for _, item := range export.Items {
+		if len(item.Comments) == 0 {
+			continue
+		}
+		postId, ok := resolver.Resolve(item.Link)
+		if !ok {
+			log.Printf("No page found for WordPress post %s; its comments will be skipped", item.Link)
+			continue
+		}
+		for _, cmt := range item.Comments {
+			if cmt.Type != "" && cmt.Type != "comment" {
+				continue
+			}
+			if cmt.Approved != "0" && cmt.Approved != "1" {
+				continue
+			}
+			when, err := parseWxrDate(cmt)
+			if err != nil {
+				return nil, err
+			}
+			out.addComment(postId, &Comment{
+				Visible: cmt.Approved == "1",
+				Author:  strings.TrimSpace(cmt.Author),
+				When:    when,
+				Text:    comments.Markdown(strings.TrimSpace(cmt.Content)),
+			})
+		}
+	}
+	for _, post := range out.Posts {
+		sortComments(post.Comments)
+	}
+	return out, nil
+}
+
+func parseWxrDate(cmt wxrComment) (time.Time, error) {
+	// Comments that were never approved may not have a GMT date; use the local date instead.
+	for _, date := range []string{cmt.DateGmt, cmt.Date} {
+		if date == "" || strings.HasPrefix(date, "0000") {
+			continue
+		}
+		return time.Parse(wxrDateFormat, date)
+	}
+	return time.Time{}, fmt.Errorf("comment by %s has no date", cmt.Author)
+}
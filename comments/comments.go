@@ -2,5 +2,8 @@ package comments
 
 type PostId string
 type CommentId string
+type IdentityId string
+type NotificationId string
+type AuditId string
 type Markdown string
 type Html string
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"jacobo.tarrio.org/jtweb/comments"
@@ -9,6 +10,9 @@ import (
 
 type PostId = comments.PostId
 type CommentId = comments.CommentId
+type IdentityId = comments.IdentityId
+type NotificationId = comments.NotificationId
+type AuditId = comments.AuditId
 type Markdown = comments.Markdown
 
 type CommentState int
@@ -19,6 +23,19 @@ const (
 	CommentsEnabled  = CommentState(2)
 )
 
+func (s CommentState) String() string {
+	switch s {
+	case CommentsDisabled:
+		return "disabled"
+	case CommentsClosed:
+		return "closed"
+	case CommentsEnabled:
+		return "enabled"
+	default:
+		return fmt.Sprintf("unknown state %d", int(s))
+	}
+}
+
 type Config struct {
 	PostId PostId
 	State  CommentState
@@ -31,6 +48,10 @@ type Comment struct {
 	Author    string
 	When      time.Time
 	Text      Markdown
+	// The verified identity that posted the comment, or nil for anonymous comments.
+	Identity *Identity
+	// When the comment was deleted, or nil if it hasn't been deleted.
+	Deleted *time.Time `json:",omitempty"`
 }
 
 type NewComment struct {
@@ -39,6 +60,140 @@ type NewComment struct {
 	Author  string
 	When    time.Time
 	Text    Markdown
+	// The verified identity that posts the comment, or empty for anonymous comments.
+	IdentityId IdentityId
+}
+
+type Identity struct {
+	IdentityId IdentityId
+	Email      string
+	Name       string
+}
+
+type Subscription struct {
+	PostId   PostId
+	Identity Identity
+	// The URI of the page that shows the post's comments.
+	PageUri string
+	// The language of that page, used for the notification emails.
+	Language string
+}
+
+type NewSubscription struct {
+	PostId     PostId
+	IdentityId IdentityId
+	PageUri    string
+	Language   string
+}
+
+type NotificationKind int
+
+const (
+	// A notification for the site's administrators.
+	NotifyAdmin = NotificationKind(0)
+	// A notification for a post's subscriber.
+	NotifySubscriber = NotificationKind(1)
+)
+
+type Notification struct {
+	NotificationId NotificationId
+	Kind           NotificationKind
+	PostId         PostId
+	CommentId      CommentId
+	// The identity to notify, for subscriber notifications.
+	Recipient IdentityId
+	// The position of the notifier that delivers an administrator notification in the list of notifiers.
+	Notifier    int
+	Created     time.Time
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+	// True if delivery was abandoned after too many attempts.
+	Failed bool
+}
+
+// Outbox determines which notifications are queued in the same transaction as a change to comments,
+// so that they are queued if and only if the change is made.
+type Outbox struct {
+	// The number of notifiers for the site's administrators. Each of them gets its own notification
+	// about each new comment, so that a notifier that fails doesn't make the others deliver it again.
+	AdminNotifiers int
+	// Queue a notification for each of the post's subscribers about each comment that is published,
+	// except for the subscriber who wrote the comment.
+	Subscribers bool
+}
+
+type NotificationFilter struct {
+	Failed *bool
+}
+
+// Change identifies who makes a change and when, to record it in the audit log.
+type Change struct {
+	Actor string
+	When  time.Time
+}
+
+type AuditAction string
+
+const (
+	AuditDeleteComment  = AuditAction("delete-comment")
+	AuditRestoreComment = AuditAction("restore-comment")
+	AuditSetVisible     = AuditAction("set-visible")
+	AuditSetPostState   = AuditAction("set-post-state")
+	// Notifications are recorded with the post and comment they are about.
+	AuditRetryNotification  = AuditAction("retry-notification")
+	AuditDeleteNotification = AuditAction("delete-notification")
+)
+
+// Comment states recorded in the audit log. Post states are recorded using CommentState.String().
+const (
+	AuditStateVisible = "visible"
+	AuditStateHidden  = "hidden"
+	AuditStateDeleted = "deleted"
+	// Notification states.
+	AuditStatePending = "pending"
+	AuditStateFailed  = "failed"
+)
+
+type AuditEntry struct {
+	AuditId AuditId
+	When    time.Time
+	Actor   string
+	Action  AuditAction
+	PostId  PostId
+	// The affected comment, or empty for actions on posts.
+	CommentId CommentId
+	// The state of the comment or post before and after the change.
+	OldState string
+	NewState string
+}
+
+type AuditFilter struct {
+	// Only entries for this post, if not empty.
+	PostId PostId
+	// Only entries for changes made by this actor, if not empty.
+	Actor string
+}
+
+// Reaction is a reader's reaction to a post or to one of its comments.
+type Reaction struct {
+	PostId PostId
+	// The comment that the reader reacted to, or empty for reactions to the post itself.
+	CommentId CommentId
+	Reaction  string
+	// Identifies the reader, so that each reader's reaction is counted only once.
+	Client string
+	When   time.Time
+}
+
+// ReactionCount is the number of readers that gave a reaction to a post or comment.
+type ReactionCount struct {
+	// The comment, or empty for reactions to the post itself.
+	CommentId CommentId
+	Reaction  string
+	Count     int
+	// Whether the client passed to ListReactions gave this reaction.
+	Mine bool
 }
 
 type BulkConfig struct {
@@ -47,6 +202,25 @@ type BulkConfig struct {
 
 type CommentFilter struct {
 	Visible *bool
+	// If true, only deleted comments; otherwise, only comments that haven't been deleted.
+	Deleted bool
+	// Only comments for this post, if not empty.
+	PostId PostId
+	// Only comments whose author's name contains this string, if not empty.
+	Author string
+	// Only comments posted at or after this time.
+	NotBefore *time.Time
+	// Only comments posted before this time.
+	NotAfter *time.Time
+	// Only comments whose text contains all the words in this string, if not empty.
+	Text string
+}
+
+// CommentCursor identifies the last comment in a page of results. The next page starts right after it.
+type CommentCursor struct {
+	PostId    PostId
+	CommentId CommentId
+	When      time.Time
 }
 
 type PostFilter struct {
@@ -58,17 +232,56 @@ type Sort int
 
 const (
 	SortNewestFirst = Sort(iota)
+	SortOldestFirst
+	// Sort by post, and then by date, oldest first.
+	SortByPost
 )
 
 type Engine interface {
+	// Ping checks that the database can be reached.
+	Ping(ctx context.Context) error
 	GetConfig(ctx context.Context, postId PostId) (*Config, error)
 	SetConfig(ctx context.Context, newConfig, oldConfig *Config) error
 	SetAllPostConfigs(ctx context.Context, cfg *BulkConfig) error
-	BulkUpdatePostConfigs(ctx context.Context, cfg *BulkConfig) error
+	BulkUpdatePostConfigs(ctx context.Context, cfg *BulkConfig, change Change) error
 	List(ctx context.Context, postId PostId, seeDrafts bool) ([]*Comment, error)
-	Add(ctx context.Context, comment *NewComment) (*Comment, error)
-	FindComments(ctx context.Context, filter CommentFilter, sort Sort, limit int, start int) ([]*Comment, error)
-	DeleteComments(ctx context.Context, ids map[PostId][]*CommentId) error
-	FindPosts(ctx context.Context, filter PostFilter, sort Sort, limit int, start int) ([]*Config, error)
-	BulkSetVisible(ctx context.Context, ids map[PostId][]*CommentId, visible bool) error
+	Add(ctx context.Context, comment *NewComment, outbox Outbox) (*Comment, error)
+	FindComments(ctx context.Context, filter CommentFilter, sort Sort, limit int, after *CommentCursor) ([]*Comment, error)
+	CountComments(ctx context.Context, filter CommentFilter) (int, error)
+	// CountVisibleComments returns the number of published comments for each of the given posts
+	// that has readable comments. Other posts are not included in the result.
+	CountVisibleComments(ctx context.Context, postIds []PostId) (map[PostId]int, error)
+	// DeleteComments marks comments as deleted. They can be restored until they are purged.
+	DeleteComments(ctx context.Context, ids map[PostId][]*CommentId, change Change) error
+	RestoreComments(ctx context.Context, ids map[PostId][]*CommentId, change Change) error
+	// PurgeComments removes the comments that were deleted before the given time for good.
+	PurgeComments(ctx context.Context, deletedBefore time.Time) (int, error)
+	FindPosts(ctx context.Context, filter PostFilter, sort Sort, limit int, after *PostId) ([]*Config, error)
+	CountPosts(ctx context.Context, filter PostFilter) (int, error)
+	// BulkSetVisible shows or hides comments. Only the comments that are made visible are notified through the outbox.
+	BulkSetVisible(ctx context.Context, ids map[PostId][]*CommentId, visible bool, change Change, outbox Outbox) error
+	GetComment(ctx context.Context, postId PostId, commentId CommentId) (*Comment, error)
+	EditComment(ctx context.Context, postId PostId, commentId CommentId, text Markdown) error
+	SaveIdentity(ctx context.Context, email string, name string) (*Identity, error)
+	GetIdentity(ctx context.Context, identityId IdentityId) (*Identity, error)
+	Subscribe(ctx context.Context, subscription *NewSubscription) error
+	Unsubscribe(ctx context.Context, postId PostId, identityId IdentityId) error
+	ListSubscriptions(ctx context.Context, postId PostId) ([]*Subscription, error)
+	PendingNotifications(ctx context.Context, now time.Time, limit int) ([]*Notification, error)
+	UpdateNotification(ctx context.Context, notification *Notification) error
+	FindNotifications(ctx context.Context, filter NotificationFilter, limit int, start int) ([]*Notification, error)
+	// RetryNotifications makes failed notifications pending again, to be delivered at the time of the change.
+	RetryNotifications(ctx context.Context, ids []NotificationId, change Change) error
+	DeleteNotifications(ctx context.Context, ids []NotificationId, change Change) error
+	// RemoveNotification removes a notification from the queue after it was delivered.
+	RemoveNotification(ctx context.Context, id NotificationId) error
+	FindAuditEntries(ctx context.Context, filter AuditFilter, limit int, after *AuditId) ([]*AuditEntry, error)
+	// UseNonce records that a single-use token was used, and fails if it had already been used.
+	// The record is kept until the token expires, and then removed.
+	UseNonce(ctx context.Context, nonce string, expires time.Time, now time.Time) error
+	// AddReaction records a reaction. Adding a reaction that the client already gave does nothing.
+	AddReaction(ctx context.Context, reaction *Reaction) error
+	RemoveReaction(ctx context.Context, reaction *Reaction) error
+	// ListReactions returns the reaction counts for a post and its comments.
+	ListReactions(ctx context.Context, postId PostId, client string) ([]*ReactionCount, error)
 }
@@ -0,0 +1,150 @@
+package genericsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+func int64ToAuditId(id int64) comments.AuditId {
+	return comments.AuditId(strconv.FormatInt(id, 36))
+}
+
+func auditIdToInt64(id comments.AuditId) (int64, error) {
+	return strconv.ParseInt(string(id), 36, 64)
+}
+
+// auditRecorder adds entries to the audit log in the same transaction as the changes they describe.
+type auditRecorder struct {
+	ctx        context.Context
+	insertStmt *sql.Stmt
+	stateStmt  *sql.Stmt
+	change     engine.Change
+}
+
+func newAuditRecorder(ctx context.Context, tx *sql.Tx, change engine.Change) (*auditRecorder, error) {
+	insertStmt, err := tx.PrepareContext(ctx, `INSERT INTO AuditLog (Date, Actor, Action, PostId, CommentId, OldState, NewState) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, sqlError(err)
+	}
+	stateStmt, err := tx.PrepareContext(ctx, `SELECT Visible, Deleted FROM Comments WHERE PostId = ? AND CommentId = ?`)
+	if err != nil {
+		insertStmt.Close()
+		return nil, sqlError(err)
+	}
+	return &auditRecorder{ctx: ctx, insertStmt: insertStmt, stateStmt: stateStmt, change: change}, nil
+}
+
+func (r *auditRecorder) Close() {
+	r.insertStmt.Close()
+	r.stateStmt.Close()
+}
+
+// commentState returns the comment's current state as recorded in the audit log, or an empty string if the comment doesn't exist.
+func (r *auditRecorder) commentState(postId engine.PostId, cid int64) (string, error) {
+	var rowVisible bool
+	var rowDeleted sql.NullTime
+	err := r.stateStmt.QueryRowContext(r.ctx, postId, cid).Scan(&rowVisible, &rowDeleted)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", sqlError(err)
+	}
+	if rowDeleted.Valid {
+		return engine.AuditStateDeleted, nil
+	}
+	if rowVisible {
+		return engine.AuditStateVisible, nil
+	}
+	return engine.AuditStateHidden, nil
+}
+
+func (r *auditRecorder) recordComment(action engine.AuditAction, postId engine.PostId, cid int64, oldState string, newState string) error {
+	_, err := r.insertStmt.ExecContext(r.ctx, r.change.When, r.change.Actor, string(action), postId, sql.NullInt64{Int64: cid, Valid: true}, oldState, newState)
+	return sqlError(err)
+}
+
+func (r *auditRecorder) recordPost(action engine.AuditAction, postId engine.PostId, oldState string, newState string) error {
+	_, err := r.insertStmt.ExecContext(r.ctx, r.change.When, r.change.Actor, string(action), postId, sql.NullInt64{}, oldState, newState)
+	return sqlError(err)
+}
+
+func auditRowFields() string {
+	return `AuditId, Date, Actor, Action, PostId, CommentId, OldState, NewState`
+}
+
+func parseAuditRow(rows *sql.Rows) (*engine.AuditEntry, error) {
+	var rowAuditId int64
+	var rowWhen time.Time
+	var rowActor string
+	var rowAction string
+	var rowPostId string
+	var rowCommentId sql.NullInt64
+	var rowOldState string
+	var rowNewState string
+	if err := rows.Scan(&rowAuditId, &rowWhen, &rowActor, &rowAction, &rowPostId, &rowCommentId, &rowOldState, &rowNewState); err != nil {
+		return nil, sqlError(err)
+	}
+	entry := &engine.AuditEntry{
+		AuditId:  int64ToAuditId(rowAuditId),
+		When:     rowWhen,
+		Actor:    rowActor,
+		Action:   engine.AuditAction(rowAction),
+		PostId:   comments.PostId(rowPostId),
+		OldState: rowOldState,
+		NewState: rowNewState,
+	}
+	if rowCommentId.Valid {
+		entry.CommentId = int64ToCommentId(rowCommentId.Int64)
+	}
+	return entry, nil
+}
+
+func (e *GenericSqlEngine) FindAuditEntries(ctx context.Context, filter engine.AuditFilter, limit int, after *engine.AuditId) ([]*engine.AuditEntry, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.AuditEntry, error) {
+		args := []any{}
+		where := []string{"TRUE"}
+		if filter.PostId != "" {
+			where = append(where, `PostId = ?`)
+			args = append(args, filter.PostId)
+		}
+		if filter.Actor != "" {
+			where = append(where, `Actor = ?`)
+			args = append(args, filter.Actor)
+		}
+		if after != nil {
+			id, err := auditIdToInt64(*after)
+			if err != nil {
+				return nil, err
+			}
+			where = append(where, `AuditId < ?`)
+			args = append(args, id)
+		}
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM AuditLog WHERE %s ORDER BY AuditId DESC LIMIT %d`, auditRowFields(), strings.Join(where, " AND "), limit))
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer stmt.Close()
+		rows, err := stmt.QueryContext(ctx, args...)
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer rows.Close()
+		out := []*engine.AuditEntry{}
+		for rows.Next() {
+			entry, err := parseAuditRow(rows)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, entry)
+		}
+		return out, nil
+	})
+}
@@ -20,6 +20,14 @@ func commentIdToInt64(id comments.CommentId) (int64, error) {
 	return strconv.ParseInt(string(id), 36, 64)
 }
 
+func int64ToIdentityId(id int64) comments.IdentityId {
+	return comments.IdentityId(strconv.FormatInt(id, 36))
+}
+
+func identityIdToInt64(id comments.IdentityId) (int64, error) {
+	return strconv.ParseInt(string(id), 36, 64)
+}
+
 type GenericSqlEngine struct {
 	db *sql.DB
 }
@@ -28,9 +36,13 @@ func NewGenericSqlEngine(db *sql.DB) engine.Engine {
 	return &GenericSqlEngine{db: db}
 }
 
+func (e *GenericSqlEngine) Ping(ctx context.Context) error {
+	return e.db.PingContext(ctx)
+}
+
 func (e *GenericSqlEngine) GetConfig(ctx context.Context, postId comments.PostId) (*engine.Config, error) {
 	return doInReadTx(ctx, e, func(tx *sql.Tx) (*engine.Config, error) {
-		return getConfig(tx, postId)
+		return getConfig(ctx, tx, postId)
 	})
 }
 
@@ -55,13 +67,13 @@ func parsePostRow(rows *sql.Rows) (*engine.Config, error) {
 	return cfg, nil
 }
 
-func getConfig(tx *sql.Tx, postId comments.PostId) (*engine.Config, error) {
-	stmt, err := tx.Prepare(fmt.Sprintf(`SELECT %s FROM Posts WHERE PostId = ?`, postRowFields()))
+func getConfig(ctx context.Context, tx *sql.Tx, postId comments.PostId) (*engine.Config, error) {
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM Posts WHERE PostId = ?`, postRowFields()))
 	if err != nil {
 		return nil, sqlError(err)
 	}
 	defer stmt.Close()
-	rows, err := stmt.Query(string(postId))
+	rows, err := stmt.QueryContext(ctx, string(postId))
 	if err != nil {
 		return nil, sqlError(err)
 	}
@@ -78,19 +90,19 @@ func getConfig(tx *sql.Tx, postId comments.PostId) (*engine.Config, error) {
 
 func (e *GenericSqlEngine) SetConfig(ctx context.Context, newConfig, oldConfig *engine.Config) error {
 	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
-		current, err := getConfig(tx, newConfig.PostId)
+		current, err := getConfig(ctx, tx, newConfig.PostId)
 		if err != nil {
 			return err
 		}
 		if (current == nil) != (oldConfig != nil) || current != oldConfig {
 			return fmt.Errorf("old configuration is different from expected for post [%s]", newConfig.PostId)
 		}
-		stmt, err := tx.Prepare(`UPDATE Posts SET StateFromWeb = ? WHERE PostId = ?`)
+		stmt, err := tx.PrepareContext(ctx, `UPDATE Posts SET StateFromWeb = ? WHERE PostId = ?`)
 		if err != nil {
 			return sqlError(err)
 		}
 		defer stmt.Close()
-		_, err = stmt.Exec(newConfig.State, newConfig.PostId)
+		_, err = stmt.ExecContext(ctx, newConfig.State, newConfig.PostId)
 		return sqlError(err)
 	})
 }
@@ -99,7 +111,7 @@ func (e *GenericSqlEngine) SetAllPostConfigs(ctx context.Context, cfg *engine.Bu
 	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
 		knownPosts := map[engine.PostId]engine.CommentState{}
 		{
-			rows, err := tx.Query(`SELECT PostId, State FROM Posts`)
+			rows, err := tx.QueryContext(ctx, `SELECT PostId, State FROM Posts`)
 			if err != nil {
 				return sqlError(err)
 			}
@@ -131,39 +143,39 @@ func (e *GenericSqlEngine) SetAllPostConfigs(ctx context.Context, cfg *engine.Bu
 			}
 		}
 		{
-			stmt, err := tx.Prepare(`INSERT INTO Posts (PostId, State) VALUES (?, ?)`)
+			stmt, err := tx.PrepareContext(ctx, `INSERT INTO Posts (PostId, State) VALUES (?, ?)`)
 			if err != nil {
 				return sqlError(err)
 			}
 			defer stmt.Close()
 			for id, state := range addConfigs {
-				_, err := stmt.Exec(id, state)
+				_, err := stmt.ExecContext(ctx, id, state)
 				if err != nil {
 					return sqlError(err)
 				}
 			}
 		}
 		{
-			stmt, err := tx.Prepare(`UPDATE Posts SET State = ?, StateFromWeb = NULL WHERE PostId = ?`)
+			stmt, err := tx.PrepareContext(ctx, `UPDATE Posts SET State = ?, StateFromWeb = NULL WHERE PostId = ?`)
 			if err != nil {
 				return sqlError(err)
 			}
 			defer stmt.Close()
 			for id, state := range updateConfigs {
-				_, err := stmt.Exec(state, id)
+				_, err := stmt.ExecContext(ctx, state, id)
 				if err != nil {
 					return sqlError(err)
 				}
 			}
 		}
 		{
-			stmt, err := tx.Prepare(`DELETE FROM Posts WHERE PostId = ?`)
+			stmt, err := tx.PrepareContext(ctx, `DELETE FROM Posts WHERE PostId = ?`)
 			if err != nil {
 				return err
 			}
 			defer stmt.Close()
 			for id := range deleteConfigs {
-				_, err := stmt.Exec(id)
+				_, err := stmt.ExecContext(ctx, id)
 				if err != nil {
 					return sqlError(err)
 				}
@@ -174,7 +186,11 @@ func (e *GenericSqlEngine) SetAllPostConfigs(ctx context.Context, cfg *engine.Bu
 }
 
 func commentRowFields() string {
-	return `PostId, CommentId, Visible, Author, Date, Text`
+	return `Comments.PostId, Comments.CommentId, Comments.Visible, Comments.Author, Comments.Date, Comments.Text, Comments.Deleted, Identities.IdentityId, Identities.Email, Identities.Name`
+}
+
+func commentTables() string {
+	return `Comments LEFT JOIN Identities ON Comments.IdentityId = Identities.IdentityId`
 }
 
 func parseCommentRow(rows *sql.Rows) (*engine.Comment, error) {
@@ -184,27 +200,42 @@ func parseCommentRow(rows *sql.Rows) (*engine.Comment, error) {
 	var rowAuthor string
 	var rowWhen time.Time
 	var rowText string
-	if err := rows.Scan(&rowPostId, &rowCommentId, &rowVisible, &rowAuthor, &rowWhen, &rowText); err != nil {
+	var rowDeleted sql.NullTime
+	var rowIdentityId sql.NullInt64
+	var rowEmail sql.NullString
+	var rowName sql.NullString
+	if err := rows.Scan(&rowPostId, &rowCommentId, &rowVisible, &rowAuthor, &rowWhen, &rowText, &rowDeleted, &rowIdentityId, &rowEmail, &rowName); err != nil {
 		return nil, sqlError(err)
 	}
-	return &engine.Comment{
+	cmt := &engine.Comment{
 		PostId:    comments.PostId(rowPostId),
 		CommentId: int64ToCommentId(rowCommentId),
 		Visible:   rowVisible,
 		Author:    rowAuthor,
 		When:      rowWhen,
 		Text:      comments.Markdown(rowText),
-	}, nil
+	}
+	if rowDeleted.Valid {
+		cmt.Deleted = &rowDeleted.Time
+	}
+	if rowIdentityId.Valid {
+		cmt.Identity = &engine.Identity{
+			IdentityId: int64ToIdentityId(rowIdentityId.Int64),
+			Email:      rowEmail.String,
+			Name:       rowName.String,
+		}
+	}
+	return cmt, nil
 }
 
 func (e *GenericSqlEngine) List(ctx context.Context, postId comments.PostId, seeDrafts bool) ([]*engine.Comment, error) {
 	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.Comment, error) {
-		stmt, err := tx.Prepare(fmt.Sprintf(`SELECT %s FROM Comments WHERE PostId = ? AND (Visible OR ?)`, commentRowFields()))
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE Comments.PostId = ? AND (Comments.Visible OR ?) AND Comments.Deleted IS NULL`, commentRowFields(), commentTables()))
 		if err != nil {
 			return nil, sqlError(err)
 		}
 		defer stmt.Close()
-		rows, err := stmt.Query(postId, seeDrafts)
+		rows, err := stmt.QueryContext(ctx, postId, seeDrafts)
 		if err != nil {
 			return nil, sqlError(err)
 		}
@@ -221,14 +252,27 @@ func (e *GenericSqlEngine) List(ctx context.Context, postId comments.PostId, see
 	})
 }
 
-func (e *GenericSqlEngine) Add(ctx context.Context, newComment *engine.NewComment) (*engine.Comment, error) {
+func (e *GenericSqlEngine) Add(ctx context.Context, newComment *engine.NewComment, outbox engine.Outbox) (*engine.Comment, error) {
 	return doInWriteTx(ctx, e, func(tx *sql.Tx) (*engine.Comment, error) {
-		stmt, err := tx.Prepare(`INSERT INTO Comments (PostId, Visible, Author, Date, Text) VALUES (?, ?, ?, ?, ?)`)
+		var identity *engine.Identity
+		var identityId sql.NullInt64
+		if newComment.IdentityId != "" {
+			id, err := identityIdToInt64(newComment.IdentityId)
+			if err != nil {
+				return nil, err
+			}
+			identity, err = getIdentity(ctx, tx, id)
+			if err != nil {
+				return nil, err
+			}
+			identityId = sql.NullInt64{Int64: id, Valid: true}
+		}
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO Comments (PostId, Visible, Author, Date, Text, IdentityId) VALUES (?, ?, ?, ?, ?, ?)`)
 		if err != nil {
 			return nil, sqlError(err)
 		}
 		defer stmt.Close()
-		result, err := stmt.Exec(newComment.PostId, newComment.Visible, newComment.Author, newComment.When, newComment.Text)
+		result, err := stmt.ExecContext(ctx, newComment.PostId, newComment.Visible, newComment.Author, newComment.When, newComment.Text, identityId)
 		if err != nil {
 			return nil, sqlError(err)
 		}
@@ -236,6 +280,15 @@ func (e *GenericSqlEngine) Add(ctx context.Context, newComment *engine.NewCommen
 		if err != nil {
 			return nil, sqlError(err)
 		}
+		queue, err := newOutboxQueue(ctx, tx, outbox, newComment.When)
+		if err != nil {
+			return nil, err
+		}
+		defer queue.Close()
+		err = queue.added(newComment.PostId, newId, newComment.Visible)
+		if err != nil {
+			return nil, err
+		}
 		return &engine.Comment{
 			PostId:    newComment.PostId,
 			CommentId: int64ToCommentId(newId),
@@ -243,20 +296,29 @@ func (e *GenericSqlEngine) Add(ctx context.Context, newComment *engine.NewCommen
 			Author:    newComment.Author,
 			When:      newComment.When,
 			Text:      newComment.Text,
+			Identity:  identity,
 		}, nil
 	})
 }
 
-func (e *GenericSqlEngine) FindComments(ctx context.Context, filter engine.CommentFilter, sort engine.Sort, limit int, start int) ([]*engine.Comment, error) {
+func (e *GenericSqlEngine) FindComments(ctx context.Context, filter engine.CommentFilter, sort engine.Sort, limit int, after *engine.CommentCursor) ([]*engine.Comment, error) {
 	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.Comment, error) {
 		where, args := whereStrComments(filter)
+		if after != nil {
+			afterWhere, afterArgs, err := afterStrComments(sort, after)
+			if err != nil {
+				return nil, err
+			}
+			where = where + " AND " + afterWhere
+			args = append(args, afterArgs...)
+		}
 		order := orderStrComments(sort)
-		stmt, err := tx.Prepare(fmt.Sprintf(`SELECT %s FROM Comments WHERE %s ORDER BY %s LIMIT %d OFFSET %d`, commentRowFields(), where, order, limit, start))
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT %d`, commentRowFields(), commentTables(), where, order, limit))
 		if err != nil {
 			return nil, sqlError(err)
 		}
 		defer stmt.Close()
-		rows, err := stmt.Query(args...)
+		rows, err := stmt.QueryContext(ctx, args...)
 		if err != nil {
 			return nil, sqlError(err)
 		}
@@ -273,29 +335,186 @@ func (e *GenericSqlEngine) FindComments(ctx context.Context, filter engine.Comme
 	})
 }
 
+func (e *GenericSqlEngine) CountComments(ctx context.Context, filter engine.CommentFilter) (int, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) (int, error) {
+		where, args := whereStrComments(filter)
+		return countRows(ctx, tx, commentTables(), where, args)
+	})
+}
+
+func (e *GenericSqlEngine) CountVisibleComments(ctx context.Context, postIds []engine.PostId) (map[engine.PostId]int, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) (map[engine.PostId]int, error) {
+		out := map[engine.PostId]int{}
+		if len(postIds) == 0 {
+			return out, nil
+		}
+		placeholders := make([]string, len(postIds))
+		args := []any{engine.CommentsDisabled, engine.CommentsDisabled}
+		for i, postId := range postIds {
+			placeholders[i] = "?"
+			args = append(args, postId)
+		}
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT Posts.PostId, COUNT(Comments.CommentId) FROM Posts LEFT JOIN Comments ON Comments.PostId = Posts.PostId AND Comments.Visible AND Comments.Deleted IS NULL WHERE ((Posts.StateFromWeb IS NULL AND Posts.State <> ?) OR Posts.StateFromWeb <> ?) AND Posts.PostId IN (%s) GROUP BY Posts.PostId`, strings.Join(placeholders, ", ")), args...)
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var postId engine.PostId
+			var count int
+			if err := rows.Scan(&postId, &count); err != nil {
+				return nil, sqlError(err)
+			}
+			out[postId] = count
+		}
+		return out, nil
+	})
+}
+
+func countRows(ctx context.Context, tx *sql.Tx, tables string, where string, args []any) (int, error) {
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE %s`, tables, where))
+	if err != nil {
+		return 0, sqlError(err)
+	}
+	defer stmt.Close()
+	var count int
+	err = stmt.QueryRowContext(ctx, args...).Scan(&count)
+	if err != nil {
+		return 0, sqlError(err)
+	}
+	return count, nil
+}
+
 func whereStrComments(filter engine.CommentFilter) (string, []any) {
 	args := []any{}
 	where := []string{}
+	if filter.Deleted {
+		where = append(where, `Comments.Deleted IS NOT NULL`)
+	} else {
+		where = append(where, `Comments.Deleted IS NULL`)
+	}
 	if filter.Visible != nil {
-		where = append(where, `Visible = ?`)
+		where = append(where, `Comments.Visible = ?`)
 		args = append(args, filter.Visible)
 	}
-	if len(where) == 0 {
-		return "TRUE", []any{}
+	if filter.PostId != "" {
+		where = append(where, `Comments.PostId = ?`)
+		args = append(args, filter.PostId)
+	}
+	if filter.Author != "" {
+		where = append(where, `LOWER(Comments.Author) LIKE ? ESCAPE '!'`)
+		args = append(args, likeContains(filter.Author))
+	}
+	if filter.NotBefore != nil {
+		where = append(where, `Comments.Date >= ?`)
+		args = append(args, *filter.NotBefore)
+	}
+	if filter.NotAfter != nil {
+		where = append(where, `Comments.Date < ?`)
+		args = append(args, *filter.NotAfter)
+	}
+	for _, word := range strings.Fields(filter.Text) {
+		where = append(where, `LOWER(Comments.Text) LIKE ? ESCAPE '!'`)
+		args = append(args, likeContains(word))
 	}
 	return strings.Join(where, " AND "), args
 }
 
+// likeContains returns a case-insensitive LIKE pattern that matches strings containing the given text.
+func likeContains(text string) string {
+	text = strings.ToLower(text)
+	text = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(text)
+	return "%" + text + "%"
+}
+
+// afterStrComments returns a condition that selects the comments that come after the cursor in the given sort order.
+// Ties on the date are broken by the comment ID, so the order is stable even when new comments are added.
+func afterStrComments(sort engine.Sort, after *engine.CommentCursor) (string, []any, error) {
+	cid, err := commentIdToInt64(after.CommentId)
+	if err != nil {
+		return "", nil, err
+	}
+	switch sort {
+	case engine.SortOldestFirst:
+		return `(Comments.Date > ? OR (Comments.Date = ? AND Comments.CommentId > ?))`,
+			[]any{after.When, after.When, cid}, nil
+	case engine.SortByPost:
+		return `(Comments.PostId > ? OR (Comments.PostId = ? AND (Comments.Date > ? OR (Comments.Date = ? AND Comments.CommentId > ?))))`,
+			[]any{after.PostId, after.PostId, after.When, after.When, cid}, nil
+	default:
+		return `(Comments.Date < ? OR (Comments.Date = ? AND Comments.CommentId < ?))`,
+			[]any{after.When, after.When, cid}, nil
+	}
+}
+
 func orderStrComments(sort engine.Sort) string {
 	switch sort {
+	case engine.SortOldestFirst:
+		return "Comments.Date ASC, Comments.CommentId ASC"
+	case engine.SortByPost:
+		return "Comments.PostId ASC, Comments.Date ASC, Comments.CommentId ASC"
 	default:
-		return "Date DESC"
+		return "Comments.Date DESC, Comments.CommentId DESC"
 	}
 }
 
-func (e *GenericSqlEngine) DeleteComments(ctx context.Context, ids map[engine.PostId][]*engine.CommentId) error {
+func (e *GenericSqlEngine) DeleteComments(ctx context.Context, ids map[engine.PostId][]*engine.CommentId, change engine.Change) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		audit, err := newAuditRecorder(ctx, tx, change)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		stmt, err := tx.PrepareContext(ctx, `UPDATE Comments SET Deleted = ? WHERE PostId = ? AND CommentId = ?`)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer stmt.Close()
+		// Pending notifications about deleted comments can't be delivered anymore.
+		notifStmt, err := tx.PrepareContext(ctx, `DELETE FROM Notifications WHERE PostId = ? AND CommentId = ?`)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer notifStmt.Close()
+		for postId, commentIds := range ids {
+			for _, commentId := range commentIds {
+				cid, err := commentIdToInt64(*commentId)
+				if err != nil {
+					return err
+				}
+				state, err := audit.commentState(postId, cid)
+				if err != nil {
+					return err
+				}
+				if state == "" || state == engine.AuditStateDeleted {
+					continue
+				}
+				_, err = stmt.ExecContext(ctx, change.When, postId, cid)
+				if err != nil {
+					return sqlError(err)
+				}
+				_, err = notifStmt.ExecContext(ctx, postId, cid)
+				if err != nil {
+					return sqlError(err)
+				}
+				err = audit.recordComment(engine.AuditDeleteComment, postId, cid, state, engine.AuditStateDeleted)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (e *GenericSqlEngine) RestoreComments(ctx context.Context, ids map[engine.PostId][]*engine.CommentId, change engine.Change) error {
 	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
-		stmt, err := tx.Prepare(`DELETE FROM Comments WHERE PostId = ? AND CommentId = ?`)
+		audit, err := newAuditRecorder(ctx, tx, change)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		stmt, err := tx.PrepareContext(ctx, `UPDATE Comments SET Deleted = NULL WHERE PostId = ? AND CommentId = ?`)
 		if err != nil {
 			return sqlError(err)
 		}
@@ -306,26 +525,63 @@ func (e *GenericSqlEngine) DeleteComments(ctx context.Context, ids map[engine.Po
 				if err != nil {
 					return err
 				}
-				_, err = stmt.Exec(postId, cid)
+				state, err := audit.commentState(postId, cid)
+				if err != nil {
+					return err
+				}
+				if state != engine.AuditStateDeleted {
+					continue
+				}
+				_, err = stmt.ExecContext(ctx, postId, cid)
 				if err != nil {
 					return sqlError(err)
 				}
+				newState, err := audit.commentState(postId, cid)
+				if err != nil {
+					return err
+				}
+				err = audit.recordComment(engine.AuditRestoreComment, postId, cid, state, newState)
+				if err != nil {
+					return err
+				}
 			}
 		}
 		return nil
 	})
 }
 
-func (e *GenericSqlEngine) FindPosts(ctx context.Context, filter engine.PostFilter, sort engine.Sort, limit int, start int) ([]*engine.Config, error) {
+func (e *GenericSqlEngine) PurgeComments(ctx context.Context, deletedBefore time.Time) (int, error) {
+	return doInWriteTx(ctx, e, func(tx *sql.Tx) (int, error) {
+		_, err := tx.ExecContext(ctx, `DELETE FROM Reactions WHERE CommentId IN (SELECT CommentId FROM Comments WHERE Deleted IS NOT NULL AND Deleted < ?)`, deletedBefore)
+		if err != nil {
+			return 0, sqlError(err)
+		}
+		result, err := tx.ExecContext(ctx, `DELETE FROM Comments WHERE Deleted IS NOT NULL AND Deleted < ?`, deletedBefore)
+		if err != nil {
+			return 0, sqlError(err)
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return 0, sqlError(err)
+		}
+		return int(count), nil
+	})
+}
+
+func (e *GenericSqlEngine) FindPosts(ctx context.Context, filter engine.PostFilter, sort engine.Sort, limit int, after *engine.PostId) ([]*engine.Config, error) {
 	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.Config, error) {
 		where, args := whereStrPosts(filter)
+		if after != nil {
+			where = where + " AND " + afterStrPosts(sort)
+			args = append(args, *after)
+		}
 		order := orderStrPosts(sort)
-		stmt, err := tx.Prepare(fmt.Sprintf(`SELECT %s FROM Posts WHERE %s ORDER BY %s LIMIT %d OFFSET %d`, postRowFields(), where, order, limit, start))
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM Posts WHERE %s ORDER BY %s LIMIT %d`, postRowFields(), where, order, limit))
 		if err != nil {
 			return nil, sqlError(err)
 		}
 		defer stmt.Close()
-		rows, err := stmt.Query(args...)
+		rows, err := stmt.QueryContext(ctx, args...)
 		if err != nil {
 			return nil, sqlError(err)
 		}
@@ -378,49 +634,333 @@ func whereStrPosts(filter engine.PostFilter) (string, []any) {
 	return strings.Join(where, " AND "), args
 }
 
+func (e *GenericSqlEngine) CountPosts(ctx context.Context, filter engine.PostFilter) (int, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) (int, error) {
+		where, args := whereStrPosts(filter)
+		return countRows(ctx, tx, "Posts", where, args)
+	})
+}
+
+func afterStrPosts(sort engine.Sort) string {
+	switch sort {
+	case engine.SortOldestFirst, engine.SortByPost:
+		return "PostId > ?"
+	default:
+		return "PostId < ?"
+	}
+}
+
 func orderStrPosts(sort engine.Sort) string {
 	switch sort {
+	case engine.SortOldestFirst, engine.SortByPost:
+		return "PostId ASC"
 	default:
 		return "PostId DESC"
 	}
 }
 
-func (e *GenericSqlEngine) BulkSetVisible(ctx context.Context, ids map[engine.PostId][]*engine.CommentId, visible bool) error {
+func (e *GenericSqlEngine) BulkSetVisible(ctx context.Context, ids map[engine.PostId][]*engine.CommentId, visible bool, change engine.Change, outbox engine.Outbox) error {
 	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
-		stmt, err := tx.Prepare(`UPDATE Comments SET Visible = ? WHERE PostId = ? AND CommentId = ?`)
+		audit, err := newAuditRecorder(ctx, tx, change)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		queue, err := newOutboxQueue(ctx, tx, outbox, change.When)
+		if err != nil {
+			return err
+		}
+		defer queue.Close()
+		stmt, err := tx.PrepareContext(ctx, `UPDATE Comments SET Visible = ? WHERE PostId = ? AND CommentId = ?`)
 		if err != nil {
 			return sqlError(err)
 		}
 		defer stmt.Close()
+		newState := engine.AuditStateHidden
+		if visible {
+			newState = engine.AuditStateVisible
+		}
 		for postId, commentIds := range ids {
 			for _, commentId := range commentIds {
 				cid, err := commentIdToInt64(*commentId)
 				if err != nil {
 					return err
 				}
-				_, err = stmt.Exec(visible, postId, cid)
+				state, err := audit.commentState(postId, cid)
+				if err != nil {
+					return err
+				}
+				if state != engine.AuditStateVisible && state != engine.AuditStateHidden {
+					continue
+				}
+				_, err = stmt.ExecContext(ctx, visible, postId, cid)
 				if err != nil {
 					return sqlError(err)
 				}
+				if state != newState {
+					err = audit.recordComment(engine.AuditSetVisible, postId, cid, state, newState)
+					if err != nil {
+						return err
+					}
+					if visible {
+						err = queue.published(postId, cid)
+						if err != nil {
+							return err
+						}
+					}
+				}
 			}
 		}
 		return nil
 	})
 }
 
-func (e *GenericSqlEngine) BulkUpdatePostConfigs(ctx context.Context, cfg *engine.BulkConfig) error {
+func (e *GenericSqlEngine) BulkUpdatePostConfigs(ctx context.Context, cfg *engine.BulkConfig, change engine.Change) error {
 	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
-		stmt, err := tx.Prepare(`UPDATE Posts SET StateFromWeb = ? WHERE PostId = ?`)
+		audit, err := newAuditRecorder(ctx, tx, change)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		stmt, err := tx.PrepareContext(ctx, `UPDATE Posts SET StateFromWeb = ? WHERE PostId = ?`)
 		if err != nil {
 			return sqlError(err)
 		}
 		defer stmt.Close()
 		for _, cfg := range cfg.Configs {
-			_, err := stmt.Exec(cfg.State, cfg.PostId)
+			current, err := getConfig(ctx, tx, cfg.PostId)
+			if err != nil {
+				return err
+			}
+			_, err = stmt.ExecContext(ctx, cfg.State, cfg.PostId)
 			if err != nil {
 				return sqlError(err)
 			}
+			if current.State != cfg.State {
+				err = audit.recordPost(engine.AuditSetPostState, cfg.PostId, current.State.String(), cfg.State.String())
+				if err != nil {
+					return err
+				}
+			}
 		}
 		return nil
 	})
 }
+
+func (e *GenericSqlEngine) GetComment(ctx context.Context, postId engine.PostId, commentId engine.CommentId) (*engine.Comment, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) (*engine.Comment, error) {
+		cid, err := commentIdToInt64(commentId)
+		if err != nil {
+			return nil, err
+		}
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE Comments.PostId = ? AND Comments.CommentId = ? AND Comments.Deleted IS NULL`, commentRowFields(), commentTables()))
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer stmt.Close()
+		rows, err := stmt.QueryContext(ctx, postId, cid)
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			return nil, fmt.Errorf("comment not found [%s/%s]", postId, commentId)
+		}
+		return parseCommentRow(rows)
+	})
+}
+
+func (e *GenericSqlEngine) EditComment(ctx context.Context, postId engine.PostId, commentId engine.CommentId, text engine.Markdown) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		cid, err := commentIdToInt64(commentId)
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx, `UPDATE Comments SET Text = ? WHERE PostId = ? AND CommentId = ?`)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer stmt.Close()
+		_, err = stmt.ExecContext(ctx, text, postId, cid)
+		return sqlError(err)
+	})
+}
+
+func identityRowFields() string {
+	return `IdentityId, Email, Name`
+}
+
+func parseIdentityRow(rows *sql.Rows) (*engine.Identity, error) {
+	var rowIdentityId int64
+	var rowEmail string
+	var rowName string
+	if err := rows.Scan(&rowIdentityId, &rowEmail, &rowName); err != nil {
+		return nil, sqlError(err)
+	}
+	return &engine.Identity{
+		IdentityId: int64ToIdentityId(rowIdentityId),
+		Email:      rowEmail,
+		Name:       rowName,
+	}, nil
+}
+
+func getIdentity(ctx context.Context, tx *sql.Tx, identityId int64) (*engine.Identity, error) {
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`SELECT %s FROM Identities WHERE IdentityId = ?`, identityRowFields()))
+	if err != nil {
+		return nil, sqlError(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, identityId)
+	if err != nil {
+		return nil, sqlError(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, fmt.Errorf("identity not found [%s]", int64ToIdentityId(identityId))
+	}
+	return parseIdentityRow(rows)
+}
+
+func (e *GenericSqlEngine) SaveIdentity(ctx context.Context, email string, name string) (*engine.Identity, error) {
+	return doInWriteTx(ctx, e, func(tx *sql.Tx) (*engine.Identity, error) {
+		var identityId int64
+		{
+			stmt, err := tx.PrepareContext(ctx, `SELECT IdentityId FROM Identities WHERE Email = ?`)
+			if err != nil {
+				return nil, sqlError(err)
+			}
+			defer stmt.Close()
+			rows, err := stmt.QueryContext(ctx, email)
+			if err != nil {
+				return nil, sqlError(err)
+			}
+			defer rows.Close()
+			if rows.Next() {
+				if err := rows.Scan(&identityId); err != nil {
+					return nil, sqlError(err)
+				}
+			}
+		}
+		if identityId == 0 {
+			stmt, err := tx.PrepareContext(ctx, `INSERT INTO Identities (Email, Name) VALUES (?, ?)`)
+			if err != nil {
+				return nil, sqlError(err)
+			}
+			defer stmt.Close()
+			result, err := stmt.ExecContext(ctx, email, name)
+			if err != nil {
+				return nil, sqlError(err)
+			}
+			identityId, err = result.LastInsertId()
+			if err != nil {
+				return nil, sqlError(err)
+			}
+		} else {
+			stmt, err := tx.PrepareContext(ctx, `UPDATE Identities SET Name = ? WHERE IdentityId = ?`)
+			if err != nil {
+				return nil, sqlError(err)
+			}
+			defer stmt.Close()
+			_, err = stmt.ExecContext(ctx, name, identityId)
+			if err != nil {
+				return nil, sqlError(err)
+			}
+		}
+		return &engine.Identity{
+			IdentityId: int64ToIdentityId(identityId),
+			Email:      email,
+			Name:       name,
+		}, nil
+	})
+}
+
+func (e *GenericSqlEngine) GetIdentity(ctx context.Context, identityId engine.IdentityId) (*engine.Identity, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) (*engine.Identity, error) {
+		id, err := identityIdToInt64(identityId)
+		if err != nil {
+			return nil, err
+		}
+		return getIdentity(ctx, tx, id)
+	})
+}
+
+func (e *GenericSqlEngine) Subscribe(ctx context.Context, subscription *engine.NewSubscription) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		id, err := identityIdToInt64(subscription.IdentityId)
+		if err != nil {
+			return err
+		}
+		{
+			stmt, err := tx.PrepareContext(ctx, `DELETE FROM Subscriptions WHERE PostId = ? AND IdentityId = ?`)
+			if err != nil {
+				return sqlError(err)
+			}
+			defer stmt.Close()
+			_, err = stmt.ExecContext(ctx, subscription.PostId, id)
+			if err != nil {
+				return sqlError(err)
+			}
+		}
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO Subscriptions (PostId, IdentityId, PageUri, Language) VALUES (?, ?, ?, ?)`)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer stmt.Close()
+		_, err = stmt.ExecContext(ctx, subscription.PostId, id, subscription.PageUri, subscription.Language)
+		return sqlError(err)
+	})
+}
+
+func (e *GenericSqlEngine) Unsubscribe(ctx context.Context, postId engine.PostId, identityId engine.IdentityId) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		id, err := identityIdToInt64(identityId)
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx, `DELETE FROM Subscriptions WHERE PostId = ? AND IdentityId = ?`)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer stmt.Close()
+		_, err = stmt.ExecContext(ctx, postId, id)
+		return sqlError(err)
+	})
+}
+
+func (e *GenericSqlEngine) ListSubscriptions(ctx context.Context, postId engine.PostId) ([]*engine.Subscription, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.Subscription, error) {
+		stmt, err := tx.PrepareContext(ctx, `SELECT Subscriptions.PostId, Subscriptions.PageUri, Subscriptions.Language, Identities.IdentityId, Identities.Email, Identities.Name FROM Subscriptions INNER JOIN Identities ON Subscriptions.IdentityId = Identities.IdentityId WHERE Subscriptions.PostId = ?`)
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer stmt.Close()
+		rows, err := stmt.QueryContext(ctx, postId)
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer rows.Close()
+		out := []*engine.Subscription{}
+		for rows.Next() {
+			var rowPostId string
+			var rowPageUri string
+			var rowLanguage string
+			var rowIdentityId int64
+			var rowEmail string
+			var rowName string
+			if err := rows.Scan(&rowPostId, &rowPageUri, &rowLanguage, &rowIdentityId, &rowEmail, &rowName); err != nil {
+				return nil, sqlError(err)
+			}
+			out = append(out, &engine.Subscription{
+				PostId: comments.PostId(rowPostId),
+				Identity: engine.Identity{
+					IdentityId: int64ToIdentityId(rowIdentityId),
+					Email:      rowEmail,
+					Name:       rowName,
+				},
+				PageUri:  rowPageUri,
+				Language: rowLanguage,
+			})
+		}
+		return out, nil
+	})
+}
@@ -0,0 +1,27 @@
+package genericsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+func (e *GenericSqlEngine) UseNonce(ctx context.Context, nonce string, expires time.Time, now time.Time) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM UsedNonces WHERE Expires < ?`, now)
+		if err != nil {
+			return sqlError(err)
+		}
+		var count int
+		err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM UsedNonces WHERE Nonce = ?`, nonce).Scan(&count)
+		if err != nil {
+			return sqlError(err)
+		}
+		if count > 0 {
+			return fmt.Errorf("this link was already used")
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO UsedNonces (Nonce, Expires) VALUES (?, ?)`, nonce, expires)
+		return sqlError(err)
+	})
+}
@@ -0,0 +1,265 @@
+package genericsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+func int64ToNotificationId(id int64) comments.NotificationId {
+	return comments.NotificationId(strconv.FormatInt(id, 36))
+}
+
+func notificationIdToInt64(id comments.NotificationId) (int64, error) {
+	return strconv.ParseInt(string(id), 36, 64)
+}
+
+// outboxQueue adds notifications to the queue in the same transaction as the changes that cause them.
+type outboxQueue struct {
+	ctx        context.Context
+	outbox     engine.Outbox
+	insertStmt *sql.Stmt
+	recipStmt  *sql.Stmt
+	when       time.Time
+}
+
+func newOutboxQueue(ctx context.Context, tx *sql.Tx, outbox engine.Outbox, when time.Time) (*outboxQueue, error) {
+	insertStmt, err := tx.PrepareContext(ctx, `INSERT INTO Notifications (Kind, PostId, CommentId, Recipient, Notifier, Created, Attempts, NextAttempt, LastError, Failed) VALUES (?, ?, ?, ?, ?, ?, 0, ?, '', ?)`)
+	if err != nil {
+		return nil, sqlError(err)
+	}
+	// The subscribers of the comment's post, except for the comment's author.
+	recipStmt, err := tx.PrepareContext(ctx, `SELECT Subscriptions.IdentityId FROM Subscriptions INNER JOIN Comments ON Comments.CommentId = ? WHERE Subscriptions.PostId = ? AND (Comments.IdentityId IS NULL OR Comments.IdentityId <> Subscriptions.IdentityId)`)
+	if err != nil {
+		insertStmt.Close()
+		return nil, sqlError(err)
+	}
+	return &outboxQueue{ctx: ctx, outbox: outbox, insertStmt: insertStmt, recipStmt: recipStmt, when: when}, nil
+}
+
+func (q *outboxQueue) Close() {
+	q.insertStmt.Close()
+	q.recipStmt.Close()
+}
+
+func (q *outboxQueue) insert(kind engine.NotificationKind, postId engine.PostId, cid int64, recipient sql.NullInt64, notifier int) error {
+	_, err := q.insertStmt.ExecContext(q.ctx, kind, postId, cid, recipient, notifier, q.when, q.when, false)
+	return sqlError(err)
+}
+
+// added queues the notifications for a new comment.
+func (q *outboxQueue) added(postId engine.PostId, cid int64, visible bool) error {
+	for notifier := 0; notifier < q.outbox.AdminNotifiers; notifier++ {
+		err := q.insert(engine.NotifyAdmin, postId, cid, sql.NullInt64{}, notifier)
+		if err != nil {
+			return err
+		}
+	}
+	if visible {
+		return q.published(postId, cid)
+	}
+	return nil
+}
+
+// published queues the notifications for a comment that was just made visible.
+func (q *outboxQueue) published(postId engine.PostId, cid int64) error {
+	if !q.outbox.Subscribers {
+		return nil
+	}
+	rows, err := q.recipStmt.QueryContext(q.ctx, cid, postId)
+	if err != nil {
+		return sqlError(err)
+	}
+	var recipients []int64
+	for rows.Next() {
+		var rowIdentityId int64
+		if err := rows.Scan(&rowIdentityId); err != nil {
+			rows.Close()
+			return sqlError(err)
+		}
+		recipients = append(recipients, rowIdentityId)
+	}
+	rows.Close()
+	for _, recipient := range recipients {
+		err = q.insert(engine.NotifySubscriber, postId, cid, sql.NullInt64{Int64: recipient, Valid: true}, 0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notificationRowFields() string {
+	return `NotificationId, Kind, PostId, CommentId, Recipient, Notifier, Created, Attempts, NextAttempt, LastError, Failed`
+}
+
+func parseNotificationRow(rows *sql.Rows) (*engine.Notification, error) {
+	var rowNotificationId int64
+	var rowKind int
+	var rowPostId string
+	var rowCommentId int64
+	var rowRecipient sql.NullInt64
+	var rowNotifier int
+	var rowCreated time.Time
+	var rowAttempts int
+	var rowNextAttempt time.Time
+	var rowLastError string
+	var rowFailed bool
+	if err := rows.Scan(&rowNotificationId, &rowKind, &rowPostId, &rowCommentId, &rowRecipient, &rowNotifier, &rowCreated, &rowAttempts, &rowNextAttempt, &rowLastError, &rowFailed); err != nil {
+		return nil, sqlError(err)
+	}
+	n := &engine.Notification{
+		NotificationId: int64ToNotificationId(rowNotificationId),
+		Kind:           engine.NotificationKind(rowKind),
+		PostId:         comments.PostId(rowPostId),
+		CommentId:      int64ToCommentId(rowCommentId),
+		Notifier:       rowNotifier,
+		Created:        rowCreated,
+		Attempts:       rowAttempts,
+		NextAttempt:    rowNextAttempt,
+		LastError:      rowLastError,
+		Failed:         rowFailed,
+	}
+	if rowRecipient.Valid {
+		n.Recipient = int64ToIdentityId(rowRecipient.Int64)
+	}
+	return n, nil
+}
+
+func queryNotifications(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]*engine.Notification, error) {
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, sqlError(err)
+	}
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, sqlError(err)
+	}
+	defer rows.Close()
+	out := []*engine.Notification{}
+	for rows.Next() {
+		n, err := parseNotificationRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func (e *GenericSqlEngine) PendingNotifications(ctx context.Context, now time.Time, limit int) ([]*engine.Notification, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.Notification, error) {
+		return queryNotifications(ctx, tx,
+			fmt.Sprintf(`SELECT %s FROM Notifications WHERE Failed = ? AND NextAttempt <= ? ORDER BY NextAttempt LIMIT ?`, notificationRowFields()),
+			false, now, limit)
+	})
+}
+
+func (e *GenericSqlEngine) UpdateNotification(ctx context.Context, notification *engine.Notification) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		id, err := notificationIdToInt64(notification.NotificationId)
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx, `UPDATE Notifications SET Attempts = ?, NextAttempt = ?, LastError = ?, Failed = ? WHERE NotificationId = ?`)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer stmt.Close()
+		_, err = stmt.ExecContext(ctx, notification.Attempts, notification.NextAttempt, notification.LastError, notification.Failed, id)
+		return sqlError(err)
+	})
+}
+
+func (e *GenericSqlEngine) FindNotifications(ctx context.Context, filter engine.NotificationFilter, limit int, start int) ([]*engine.Notification, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.Notification, error) {
+		where := ""
+		args := []any{}
+		if filter.Failed != nil {
+			where = "WHERE Failed = ?"
+			args = append(args, *filter.Failed)
+		}
+		args = append(args, limit, start)
+		return queryNotifications(ctx, tx,
+			fmt.Sprintf(`SELECT %s FROM Notifications %s ORDER BY Created DESC LIMIT ? OFFSET ?`, notificationRowFields(), where),
+			args...)
+	})
+}
+
+func (e *GenericSqlEngine) RetryNotifications(ctx context.Context, ids []engine.NotificationId, change engine.Change) error {
+	return e.updateNotifications(ctx, ids, change, engine.AuditRetryNotification, engine.AuditStatePending,
+		`UPDATE Notifications SET Failed = ?, Attempts = 0, NextAttempt = ? WHERE NotificationId = ?`, false, change.When)
+}
+
+func (e *GenericSqlEngine) DeleteNotifications(ctx context.Context, ids []engine.NotificationId, change engine.Change) error {
+	return e.updateNotifications(ctx, ids, change, engine.AuditDeleteNotification, engine.AuditStateDeleted,
+		`DELETE FROM Notifications WHERE NotificationId = ?`)
+}
+
+func (e *GenericSqlEngine) RemoveNotification(ctx context.Context, id engine.NotificationId) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		nid, err := notificationIdToInt64(id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM Notifications WHERE NotificationId = ?`, nid)
+		return sqlError(err)
+	})
+}
+
+// updateNotifications runs the query for every notification, and records the change in the audit log.
+func (e *GenericSqlEngine) updateNotifications(ctx context.Context, ids []engine.NotificationId, change engine.Change, action engine.AuditAction, newState string, query string, args ...any) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		audit, err := newAuditRecorder(ctx, tx, change)
+		if err != nil {
+			return err
+		}
+		defer audit.Close()
+		stateStmt, err := tx.PrepareContext(ctx, `SELECT PostId, CommentId, Failed FROM Notifications WHERE NotificationId = ?`)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer stateStmt.Close()
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return sqlError(err)
+		}
+		defer stmt.Close()
+		for _, id := range ids {
+			nid, err := notificationIdToInt64(id)
+			if err != nil {
+				return err
+			}
+			var rowPostId string
+			var rowCommentId int64
+			var rowFailed bool
+			err = stateStmt.QueryRowContext(ctx, nid).Scan(&rowPostId, &rowCommentId, &rowFailed)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return sqlError(err)
+			}
+			params := append([]any{}, args...)
+			_, err = stmt.ExecContext(ctx, append(params, nid)...)
+			if err != nil {
+				return sqlError(err)
+			}
+			oldState := engine.AuditStatePending
+			if rowFailed {
+				oldState = engine.AuditStateFailed
+			}
+			err = audit.recordComment(action, comments.PostId(rowPostId), rowCommentId, oldState, newState)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
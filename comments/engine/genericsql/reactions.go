@@ -0,0 +1,74 @@
+package genericsql
+
+import (
+	"context"
+	"database/sql"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+// Reactions to the post itself are stored with this comment id.
+const postReactionId = int64(0)
+
+func reactionCommentId(commentId comments.CommentId) (int64, error) {
+	if commentId == "" {
+		return postReactionId, nil
+	}
+	return commentIdToInt64(commentId)
+}
+
+func (e *GenericSqlEngine) AddReaction(ctx context.Context, reaction *engine.Reaction) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		cid, err := reactionCommentId(reaction.CommentId)
+		if err != nil {
+			return err
+		}
+		var count int
+		err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM Reactions WHERE PostId = ? AND CommentId = ? AND Reaction = ? AND Client = ?`, reaction.PostId, cid, reaction.Reaction, reaction.Client).Scan(&count)
+		if err != nil {
+			return sqlError(err)
+		}
+		if count > 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO Reactions (PostId, CommentId, Reaction, Client, Date) VALUES (?, ?, ?, ?, ?)`, reaction.PostId, cid, reaction.Reaction, reaction.Client, reaction.When)
+		return sqlError(err)
+	})
+}
+
+func (e *GenericSqlEngine) RemoveReaction(ctx context.Context, reaction *engine.Reaction) error {
+	return doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		cid, err := reactionCommentId(reaction.CommentId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM Reactions WHERE PostId = ? AND CommentId = ? AND Reaction = ? AND Client = ?`, reaction.PostId, cid, reaction.Reaction, reaction.Client)
+		return sqlError(err)
+	})
+}
+
+func (e *GenericSqlEngine) ListReactions(ctx context.Context, postId comments.PostId, client string) ([]*engine.ReactionCount, error) {
+	return doInReadTx(ctx, e, func(tx *sql.Tx) ([]*engine.ReactionCount, error) {
+		rows, err := tx.QueryContext(ctx, `SELECT CommentId, Reaction, COUNT(*), SUM(CASE WHEN Client = ? THEN 1 ELSE 0 END) FROM Reactions WHERE PostId = ? GROUP BY CommentId, Reaction ORDER BY CommentId, Reaction`, client, postId)
+		if err != nil {
+			return nil, sqlError(err)
+		}
+		defer rows.Close()
+		out := []*engine.ReactionCount{}
+		for rows.Next() {
+			var cid int64
+			var mine int
+			count := &engine.ReactionCount{}
+			if err := rows.Scan(&cid, &count.Reaction, &count.Count, &mine); err != nil {
+				return nil, sqlError(err)
+			}
+			if cid != postReactionId {
+				count.CommentId = int64ToCommentId(cid)
+			}
+			count.Mine = mine > 0
+			out = append(out, count)
+		}
+		return out, nil
+	})
+}
@@ -34,7 +34,8 @@ func doInWriteTx[R any](ctx context.Context, e *GenericSqlEngine, op func(tx *sq
 	retries_left := 1
 	for {
 		ret, err := doInTx(ctx, e, sql.LevelSerializable, op)
-		if !IsSqlError(err) {
+		// A cancelled or expired context would make the retry fail too.
+		if !IsSqlError(err) || ctx.Err() != nil {
 			return ret, err
 		}
 		if retries_left <= 0 {
@@ -52,6 +53,8 @@ func doInWriteTxNoReturn(ctx context.Context, e *GenericSqlEngine, op func(tx *s
 	return err
 }
 
+// doInTx runs the operation in a transaction. The transaction is rolled back if the operation
+// fails, or if the context is cancelled or its deadline expires before it is committed.
 func doInTx[R any](ctx context.Context, e *GenericSqlEngine, level sql.IsolationLevel, op func(tx *sql.Tx) (R, error)) (R, error) {
 	var zero R
 	tx, err := e.db.BeginTx(ctx, &sql.TxOptions{Isolation: level})
@@ -59,9 +62,19 @@ func doInTx[R any](ctx context.Context, e *GenericSqlEngine, level sql.Isolation
 		return zero, err
 	}
 	ret, err := op(tx)
+	if err == nil {
+		err = ctx.Err()
+	}
 	if err != nil {
 		tx.Rollback()
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
 		return zero, err
 	}
-	return ret, sqlError(tx.Commit())
+	err = tx.Commit()
+	if err != nil && ctx.Err() != nil {
+		return zero, ctx.Err()
+	}
+	return ret, sqlError(err)
 }
@@ -0,0 +1,69 @@
+package genericsql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEngine(t *testing.T) *GenericSqlEngine {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(`CREATE TABLE Items (Value INTEGER NOT NULL)`)
+	assert.Nil(t, err)
+	return &GenericSqlEngine{db: db}
+}
+
+func countItems(t *testing.T, e *GenericSqlEngine) int {
+	var count int
+	assert.Nil(t, e.db.QueryRow(`SELECT COUNT(*) FROM Items`).Scan(&count))
+	return count
+}
+
+func TestCommit(t *testing.T) {
+	e := newTestEngine(t)
+	err := doInWriteTxNoReturn(context.Background(), e, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO Items (Value) VALUES (1)`)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countItems(t, e))
+}
+
+func TestCancelledTransactionRollsBack(t *testing.T) {
+	e := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		calls++
+		_, err := tx.ExecContext(ctx, `INSERT INTO Items (Value) VALUES (1)`)
+		// The client goes away after the change is made, but before it is committed.
+		cancel()
+		return err
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, countItems(t, e))
+}
+
+func TestExpiredDeadlineIsNotRetried(t *testing.T) {
+	e := newTestEngine(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := doInWriteTxNoReturn(ctx, e, func(tx *sql.Tx) error {
+		calls++
+		time.Sleep(10 * time.Millisecond)
+		_, err := tx.ExecContext(ctx, `INSERT INTO Items (Value) VALUES (1)`)
+		return sqlError(err)
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, countItems(t, e))
+}
@@ -5,6 +5,14 @@ CREATE TABLE `Posts` (
   PRIMARY KEY (`PostId`)
 ) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
 
+CREATE TABLE `Identities` (
+  `IdentityId` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Email` varchar(255) NOT NULL,
+  `Name` varchar(255) NOT NULL,
+  PRIMARY KEY (`IdentityId`),
+  UNIQUE KEY `Email` (`Email`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
 CREATE TABLE `Comments` (
   `PostId` varchar(255) NOT NULL,
   `CommentId` bigint(20) NOT NULL AUTO_INCREMENT,
@@ -12,7 +20,65 @@ CREATE TABLE `Comments` (
   `Author` varchar(255) NOT NULL,
   `Date` datetime NOT NULL,
   `Text` text NOT NULL,
+  `IdentityId` bigint(20) DEFAULT NULL,
+  `Deleted` datetime DEFAULT NULL,
   PRIMARY KEY (`CommentId`),
   KEY `PostId` (`PostId`),
-  CONSTRAINT `Comments_ibfk_1` FOREIGN KEY (`PostId`) REFERENCES `Posts` (`PostId`)
+  CONSTRAINT `Comments_ibfk_1` FOREIGN KEY (`PostId`) REFERENCES `Posts` (`PostId`),
+  CONSTRAINT `Comments_ibfk_2` FOREIGN KEY (`IdentityId`) REFERENCES `Identities` (`IdentityId`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `Subscriptions` (
+  `PostId` varchar(255) NOT NULL,
+  `IdentityId` bigint(20) NOT NULL,
+  `PageUri` varchar(1024) NOT NULL,
+  `Language` varchar(16) NOT NULL,
+  PRIMARY KEY (`PostId`, `IdentityId`),
+  CONSTRAINT `Subscriptions_ibfk_1` FOREIGN KEY (`PostId`) REFERENCES `Posts` (`PostId`),
+  CONSTRAINT `Subscriptions_ibfk_2` FOREIGN KEY (`IdentityId`) REFERENCES `Identities` (`IdentityId`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `Notifications` (
+  `NotificationId` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Kind` tinyint(1) NOT NULL,
+  `PostId` varchar(255) NOT NULL,
+  `CommentId` bigint(20) NOT NULL,
+  `Recipient` bigint(20) DEFAULT NULL,
+  `Notifier` int(11) NOT NULL DEFAULT 0,
+  `Created` datetime NOT NULL,
+  `Attempts` int(11) NOT NULL,
+  `NextAttempt` datetime NOT NULL,
+  `LastError` text NOT NULL,
+  `Failed` boolean NOT NULL,
+  PRIMARY KEY (`NotificationId`),
+  KEY `NextAttempt` (`Failed`, `NextAttempt`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `AuditLog` (
+  `AuditId` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Date` datetime NOT NULL,
+  `Actor` varchar(255) NOT NULL,
+  `Action` varchar(32) NOT NULL,
+  `PostId` varchar(255) NOT NULL,
+  `CommentId` bigint(20) DEFAULT NULL,
+  `OldState` varchar(32) NOT NULL,
+  `NewState` varchar(32) NOT NULL,
+  PRIMARY KEY (`AuditId`),
+  KEY `PostId` (`PostId`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `UsedNonces` (
+  `Nonce` varchar(64) NOT NULL,
+  `Expires` datetime NOT NULL,
+  PRIMARY KEY (`Nonce`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `Reactions` (
+  `PostId` varchar(255) NOT NULL,
+  `CommentId` bigint(20) NOT NULL,
+  `Reaction` varchar(32) NOT NULL,
+  `Client` varchar(128) NOT NULL,
+  `Date` datetime NOT NULL,
+  PRIMARY KEY (`PostId`, `CommentId`, `Reaction`, `Client`),
+  CONSTRAINT `Reactions_ibfk_1` FOREIGN KEY (`PostId`) REFERENCES `Posts` (`PostId`)
 ) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
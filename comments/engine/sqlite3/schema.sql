@@ -3,6 +3,11 @@ CREATE TABLE Posts (
     State INTEGER NOT NULL,
     StateFromWeb INTEGER NULL);
 
+CREATE TABLE Identities (
+    IdentityId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    Email TEXT NOT NULL UNIQUE,
+    Name TEXT NOT NULL);
+
 CREATE TABLE Comments (
     PostId TEXT NOT NULL,
     CommentId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
@@ -10,4 +15,54 @@ CREATE TABLE Comments (
     Author TEXT NOT NULL,
     Date DATETIME NOT NULL,
     Text TEXT NOT NULL,
+    IdentityId INTEGER NULL,
+    Deleted DATETIME NULL,
+    FOREIGN KEY (PostId) REFERENCES Posts(PostId),
+    FOREIGN KEY (IdentityId) REFERENCES Identities(IdentityId));
+
+CREATE TABLE Subscriptions (
+    PostId TEXT NOT NULL,
+    IdentityId INTEGER NOT NULL,
+    PageUri TEXT NOT NULL,
+    Language TEXT NOT NULL,
+    PRIMARY KEY (PostId, IdentityId),
+    FOREIGN KEY (PostId) REFERENCES Posts(PostId),
+    FOREIGN KEY (IdentityId) REFERENCES Identities(IdentityId));
+
+CREATE TABLE Notifications (
+    NotificationId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    Kind INTEGER NOT NULL,
+    PostId TEXT NOT NULL,
+    CommentId INTEGER NOT NULL,
+    Recipient INTEGER NULL,
+    Notifier INTEGER NOT NULL DEFAULT 0,
+    Created DATETIME NOT NULL,
+    Attempts INTEGER NOT NULL,
+    NextAttempt DATETIME NOT NULL,
+    LastError TEXT NOT NULL,
+    Failed BOOLEAN NOT NULL);
+
+CREATE INDEX NotificationsByNextAttempt ON Notifications(Failed, NextAttempt);
+
+CREATE TABLE AuditLog (
+    AuditId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    Date DATETIME NOT NULL,
+    Actor TEXT NOT NULL,
+    Action TEXT NOT NULL,
+    PostId TEXT NOT NULL,
+    CommentId INTEGER NULL,
+    OldState TEXT NOT NULL,
+    NewState TEXT NOT NULL);
+
+CREATE TABLE UsedNonces (
+    Nonce TEXT NOT NULL PRIMARY KEY,
+    Expires DATETIME NOT NULL);
+
+CREATE TABLE Reactions (
+    PostId TEXT NOT NULL,
+    CommentId INTEGER NOT NULL,
+    Reaction TEXT NOT NULL,
+    Client TEXT NOT NULL,
+    Date DATETIME NOT NULL,
+    PRIMARY KEY (PostId, CommentId, Reaction, Client),
     FOREIGN KEY (PostId) REFERENCES Posts(PostId));
@@ -0,0 +1,396 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+func newTestEngine(t *testing.T) engine.Engine {
+	schema, err := os.ReadFile("schema.sql")
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "comments.db")
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	_, err = db.Exec(string(schema))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+	e, err := NewSqlite3Engine(path)
+	assert.Nil(t, err)
+	assert.Nil(t, e.SetAllPostConfigs(context.Background(), &engine.BulkConfig{Configs: []engine.Config{
+		{PostId: "a", State: engine.CommentsEnabled},
+		{PostId: "b", State: engine.CommentsEnabled},
+		{PostId: "c", State: engine.CommentsClosed},
+	}}))
+	return e
+}
+
+var baseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func addComment(t *testing.T, e engine.Engine, postId engine.PostId, author string, hours int, text string) *engine.Comment {
+	c, err := e.Add(context.Background(), &engine.NewComment{
+		PostId:  postId,
+		Visible: true,
+		Author:  author,
+		When:    baseTime.Add(time.Duration(hours) * time.Hour),
+		Text:    engine.Markdown(text),
+	}, engine.Outbox{})
+	assert.Nil(t, err)
+	return c
+}
+
+func authors(list []*engine.Comment) []string {
+	out := []string{}
+	for _, c := range list {
+		out = append(out, c.Author)
+	}
+	return out
+}
+
+func TestFindCommentsFilters(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	addComment(t, e, "a", "Alice", 0, "The quick brown fox")
+	addComment(t, e, "a", "Bob", 1, "jumps over 100% of")
+	addComment(t, e, "b", "alicia", 2, "the lazy dog")
+
+	notBefore := baseTime.Add(time.Hour)
+	for _, tc := range []struct {
+		filter   engine.CommentFilter
+		expected []string
+	}{
+		{engine.CommentFilter{}, []string{"alicia", "Bob", "Alice"}},
+		{engine.CommentFilter{PostId: "a"}, []string{"Bob", "Alice"}},
+		{engine.CommentFilter{Author: "ALI"}, []string{"alicia", "Alice"}},
+		{engine.CommentFilter{NotBefore: &notBefore}, []string{"alicia", "Bob"}},
+		{engine.CommentFilter{NotAfter: &notBefore}, []string{"Alice"}},
+		{engine.CommentFilter{Text: "the DOG"}, []string{"alicia"}},
+		{engine.CommentFilter{Text: "100%"}, []string{"Bob"}},
+		{engine.CommentFilter{Text: "1%0"}, []string{}},
+	} {
+		list, err := e.FindComments(ctx, tc.filter, engine.SortNewestFirst, 10, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, authors(list), "%+v", tc.filter)
+		count, err := e.CountComments(ctx, tc.filter)
+		assert.Nil(t, err)
+		assert.Equal(t, len(tc.expected), count, "%+v", tc.filter)
+	}
+}
+
+func TestFindCommentsCursor(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	addComment(t, e, "b", "1", 0, "text")
+	addComment(t, e, "a", "2", 1, "text")
+	addComment(t, e, "b", "3", 1, "text")
+	addComment(t, e, "a", "4", 2, "text")
+
+	for _, tc := range []struct {
+		sort     engine.Sort
+		expected []string
+	}{
+		{engine.SortNewestFirst, []string{"4", "3", "2", "1"}},
+		{engine.SortOldestFirst, []string{"1", "2", "3", "4"}},
+		{engine.SortByPost, []string{"2", "4", "1", "3"}},
+	} {
+		actual := []string{}
+		var after *engine.CommentCursor
+		for {
+			list, err := e.FindComments(ctx, engine.CommentFilter{}, tc.sort, 1, after)
+			assert.Nil(t, err)
+			if len(list) == 0 {
+				break
+			}
+			if list[0].Author != "new" {
+				actual = append(actual, list[0].Author)
+			}
+			after = &engine.CommentCursor{PostId: list[0].PostId, CommentId: list[0].CommentId, When: list[0].When}
+			if len(actual) == 2 {
+				// Adding comments while paginating doesn't make the next pages skip or repeat any comments.
+				addComment(t, e, "a", "new", 1, "text")
+			}
+		}
+		assert.Equal(t, tc.expected, actual, "sort %d", tc.sort)
+	}
+}
+
+func TestFindPostsCursor(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	actual := []engine.PostId{}
+	var after *engine.PostId
+	for {
+		list, err := e.FindPosts(ctx, engine.PostFilter{}, engine.SortByPost, 2, after)
+		assert.Nil(t, err)
+		for _, cfg := range list {
+			actual = append(actual, cfg.PostId)
+		}
+		if len(list) < 2 {
+			break
+		}
+		after = &list[len(list)-1].PostId
+	}
+	assert.Equal(t, []engine.PostId{"a", "b", "c"}, actual)
+	count, err := e.CountPosts(ctx, engine.PostFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestCountVisibleComments(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	addComment(t, e, "a", "Alice", 0, "First")
+	addComment(t, e, "a", "Bob", 1, "Second")
+	carol := addComment(t, e, "a", "Carol", 2, "Third")
+	dave := addComment(t, e, "a", "Dave", 3, "Fourth")
+	addComment(t, e, "b", "Erin", 4, "Fifth")
+	change := engine.Change{Actor: "admin", When: baseTime}
+	assert.Nil(t, e.BulkSetVisible(ctx, map[engine.PostId][]*engine.CommentId{"a": {&carol.CommentId}}, false, change, engine.Outbox{}))
+	assert.Nil(t, e.DeleteComments(ctx, map[engine.PostId][]*engine.CommentId{"a": {&dave.CommentId}}, change))
+	assert.Nil(t, e.BulkUpdatePostConfigs(ctx, &engine.BulkConfig{Configs: []engine.Config{{PostId: "b", State: engine.CommentsDisabled}}}, change))
+
+	counts, err := e.CountVisibleComments(ctx, []engine.PostId{"a", "b", "c", "d"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[engine.PostId]int{"a": 2, "c": 0}, counts)
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	alice := addComment(t, e, "a", "Alice", 0, "First")
+	addComment(t, e, "a", "Bob", 1, "Second")
+	ids := map[engine.PostId][]*engine.CommentId{"a": {&alice.CommentId}}
+
+	deleted := baseTime.Add(24 * time.Hour)
+	assert.Nil(t, e.DeleteComments(ctx, ids, engine.Change{Actor: "admin", When: deleted}))
+	list, err := e.List(ctx, "a", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Bob"}, authors(list))
+	_, err = e.GetComment(ctx, "a", alice.CommentId)
+	assert.NotNil(t, err)
+	list, err = e.FindComments(ctx, engine.CommentFilter{Deleted: true}, engine.SortNewestFirst, 10, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Alice"}, authors(list))
+	assert.Equal(t, deleted, list[0].Deleted.UTC())
+
+	assert.Nil(t, e.RestoreComments(ctx, ids, engine.Change{Actor: "admin", When: deleted.Add(time.Hour)}))
+	list, err = e.List(ctx, "a", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Alice", "Bob"}, authors(list))
+}
+
+func TestPurgeComments(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	alice := addComment(t, e, "a", "Alice", 0, "First")
+	bob := addComment(t, e, "a", "Bob", 1, "Second")
+	addComment(t, e, "a", "Carol", 2, "Third")
+	assert.Nil(t, e.DeleteComments(ctx, map[engine.PostId][]*engine.CommentId{"a": {&alice.CommentId}}, engine.Change{Actor: "admin", When: baseTime.Add(24 * time.Hour)}))
+	assert.Nil(t, e.DeleteComments(ctx, map[engine.PostId][]*engine.CommentId{"a": {&bob.CommentId}}, engine.Change{Actor: "admin", When: baseTime.Add(72 * time.Hour)}))
+
+	count, err := e.PurgeComments(ctx, baseTime.Add(48*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	list, err := e.FindComments(ctx, engine.CommentFilter{Deleted: true}, engine.SortNewestFirst, 10, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Bob"}, authors(list))
+	list, err = e.List(ctx, "a", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Carol"}, authors(list))
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	alice := addComment(t, e, "a", "Alice", 0, "First")
+	bob := addComment(t, e, "b", "Bob", 1, "Second")
+	change := engine.Change{Actor: "admin", When: baseTime.Add(24 * time.Hour)}
+
+	assert.Nil(t, e.BulkSetVisible(ctx, map[engine.PostId][]*engine.CommentId{"a": {&alice.CommentId}, "b": {&bob.CommentId}}, false, change, engine.Outbox{}))
+	// Comments that are already hidden don't generate entries.
+	assert.Nil(t, e.BulkSetVisible(ctx, map[engine.PostId][]*engine.CommentId{"a": {&alice.CommentId}}, false, change, engine.Outbox{}))
+	assert.Nil(t, e.DeleteComments(ctx, map[engine.PostId][]*engine.CommentId{"a": {&alice.CommentId}}, engine.Change{Actor: "commenter:1", When: change.When}))
+	assert.Nil(t, e.RestoreComments(ctx, map[engine.PostId][]*engine.CommentId{"a": {&alice.CommentId}}, change))
+	assert.Nil(t, e.BulkUpdatePostConfigs(ctx, &engine.BulkConfig{Configs: []engine.Config{{PostId: "c", State: engine.CommentsDisabled}}}, change))
+
+	entries, err := e.FindAuditEntries(ctx, engine.AuditFilter{}, 10, nil)
+	assert.Nil(t, err)
+	type summary struct {
+		actor     string
+		action    engine.AuditAction
+		postId    engine.PostId
+		commentId engine.CommentId
+		oldState  string
+		newState  string
+	}
+	summaries := []summary{}
+	for _, entry := range entries {
+		summaries = append(summaries, summary{entry.Actor, entry.Action, entry.PostId, entry.CommentId, entry.OldState, entry.NewState})
+	}
+	assert.ElementsMatch(t, []summary{
+		{"admin", engine.AuditSetPostState, "c", "", "closed", "disabled"},
+		{"admin", engine.AuditRestoreComment, "a", alice.CommentId, "deleted", "hidden"},
+		{"commenter:1", engine.AuditDeleteComment, "a", alice.CommentId, "hidden", "deleted"},
+		{"admin", engine.AuditSetVisible, "a", alice.CommentId, "visible", "hidden"},
+		{"admin", engine.AuditSetVisible, "b", bob.CommentId, "visible", "hidden"},
+	}, summaries)
+	assert.Equal(t, summary{"admin", engine.AuditSetPostState, "c", "", "closed", "disabled"}, summaries[0])
+
+	page, err := e.FindAuditEntries(ctx, engine.AuditFilter{PostId: "a"}, 2, nil)
+	assert.Nil(t, err)
+	assert.Len(t, page, 2)
+	rest, err := e.FindAuditEntries(ctx, engine.AuditFilter{PostId: "a"}, 10, &page[1].AuditId)
+	assert.Nil(t, err)
+	assert.Len(t, rest, 1)
+	assert.Equal(t, engine.AuditSetVisible, rest[0].Action)
+
+	byActor, err := e.FindAuditEntries(ctx, engine.AuditFilter{Actor: "commenter:1"}, 10, nil)
+	assert.Nil(t, err)
+	assert.Len(t, byActor, 1)
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	alice, err := e.SaveIdentity(ctx, "alice@example.com", "Alice")
+	assert.Nil(t, err)
+	bob, err := e.SaveIdentity(ctx, "bob@example.com", "Bob")
+	assert.Nil(t, err)
+	for _, identity := range []*engine.Identity{alice, bob} {
+		assert.Nil(t, e.Subscribe(ctx, &engine.NewSubscription{PostId: "a", IdentityId: identity.IdentityId, PageUri: "https://example.com/a", Language: "en"}))
+	}
+	outbox := engine.Outbox{AdminNotifiers: 2, Subscribers: true}
+
+	type summary struct {
+		kind      engine.NotificationKind
+		commentId engine.CommentId
+		recipient engine.IdentityId
+		notifier  int
+	}
+	queued := func() []summary {
+		list, err := e.FindNotifications(ctx, engine.NotificationFilter{}, 10, 0)
+		assert.Nil(t, err)
+		out := []summary{}
+		for _, n := range list {
+			out = append(out, summary{n.Kind, n.CommentId, n.Recipient, n.Notifier})
+			assert.Nil(t, e.RemoveNotification(ctx, n.NotificationId))
+		}
+		return out
+	}
+
+	// A published comment is notified to the administrators through each notifier, and to the other subscribers.
+	visible, err := e.Add(ctx, &engine.NewComment{PostId: "a", Visible: true, Author: "Alice", When: baseTime, Text: "First", IdentityId: alice.IdentityId}, outbox)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []summary{
+		{engine.NotifyAdmin, visible.CommentId, "", 0},
+		{engine.NotifyAdmin, visible.CommentId, "", 1},
+		{engine.NotifySubscriber, visible.CommentId, bob.IdentityId, 0},
+	}, queued())
+
+	// A draft is only notified to the subscribers when it is approved.
+	draft, err := e.Add(ctx, &engine.NewComment{PostId: "a", Visible: false, Author: "Carol", When: baseTime, Text: "Second"}, outbox)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []summary{
+		{engine.NotifyAdmin, draft.CommentId, "", 0},
+		{engine.NotifyAdmin, draft.CommentId, "", 1},
+	}, queued())
+	change := engine.Change{Actor: "admin", When: baseTime.Add(time.Hour)}
+	ids := map[engine.PostId][]*engine.CommentId{"a": {&draft.CommentId, &visible.CommentId}}
+	assert.Nil(t, e.BulkSetVisible(ctx, ids, true, change, outbox))
+	assert.ElementsMatch(t, []summary{
+		{engine.NotifySubscriber, draft.CommentId, alice.IdentityId, 0},
+		{engine.NotifySubscriber, draft.CommentId, bob.IdentityId, 0},
+	}, queued())
+
+	// Nothing is queued if the change fails.
+	_, err = e.Add(ctx, &engine.NewComment{PostId: "a", Visible: true, Author: "Dave", When: baseTime, Text: "Third", IdentityId: "zzzz"}, outbox)
+	assert.NotNil(t, err)
+	assert.Equal(t, []summary{}, queued())
+}
+
+func TestNotificationAudit(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	alice, err := e.Add(ctx, &engine.NewComment{PostId: "a", Visible: true, Author: "Alice", When: baseTime, Text: "First"}, engine.Outbox{AdminNotifiers: 1})
+	assert.Nil(t, err)
+	bob, err := e.Add(ctx, &engine.NewComment{PostId: "a", Visible: true, Author: "Bob", When: baseTime.Add(time.Minute), Text: "Second"}, engine.Outbox{AdminNotifiers: 1})
+	assert.Nil(t, err)
+	list, err := e.FindNotifications(ctx, engine.NotificationFilter{}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+	list[0].Failed = true
+	assert.Nil(t, e.UpdateNotification(ctx, list[0]))
+
+	change := engine.Change{Actor: "admin", When: baseTime.Add(time.Hour)}
+	assert.Nil(t, e.RetryNotifications(ctx, []engine.NotificationId{list[0].NotificationId}, change))
+	assert.Nil(t, e.DeleteNotifications(ctx, []engine.NotificationId{list[1].NotificationId, "zzz"}, change))
+	// Removing a delivered notification is not recorded.
+	assert.Nil(t, e.RemoveNotification(ctx, list[0].NotificationId))
+
+	entries, err := e.FindAuditEntries(ctx, engine.AuditFilter{}, 10, nil)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, engine.AuditDeleteNotification, entries[0].Action)
+	assert.Equal(t, alice.CommentId, entries[0].CommentId)
+	assert.Equal(t, engine.AuditStatePending, entries[0].OldState)
+	assert.Equal(t, engine.AuditStateDeleted, entries[0].NewState)
+	assert.Equal(t, engine.AuditRetryNotification, entries[1].Action)
+	assert.Equal(t, "admin", entries[1].Actor)
+	assert.Equal(t, bob.CommentId, entries[1].CommentId)
+	assert.Equal(t, engine.AuditStateFailed, entries[1].OldState)
+	assert.Equal(t, engine.AuditStatePending, entries[1].NewState)
+
+	list, err = e.FindNotifications(ctx, engine.NotificationFilter{}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, list, 0)
+}
+
+func TestUseNonce(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	expires := baseTime.Add(time.Hour)
+	assert.Nil(t, e.UseNonce(ctx, "abc", expires, baseTime))
+	assert.NotNil(t, e.UseNonce(ctx, "abc", expires, baseTime))
+	assert.Nil(t, e.UseNonce(ctx, "def", expires, baseTime))
+	// Expired nonces are forgotten.
+	assert.Nil(t, e.UseNonce(ctx, "abc", baseTime.Add(3*time.Hour), baseTime.Add(2*time.Hour)))
+}
+
+func TestReactions(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	alice := addComment(t, e, "a", "Alice", 0, "First")
+	react := func(commentId engine.CommentId, reaction string, client string) *engine.Reaction {
+		return &engine.Reaction{PostId: "a", CommentId: commentId, Reaction: reaction, Client: client, When: baseTime}
+	}
+	assert.Nil(t, e.AddReaction(ctx, react("", "+1", "x")))
+	assert.Nil(t, e.AddReaction(ctx, react("", "+1", "y")))
+	// Adding the same reaction twice counts it once.
+	assert.Nil(t, e.AddReaction(ctx, react("", "+1", "y")))
+	assert.Nil(t, e.AddReaction(ctx, react(alice.CommentId, "+1", "x")))
+	assert.Nil(t, e.AddReaction(ctx, react(alice.CommentId, "heart", "y")))
+	assert.Nil(t, e.AddReaction(ctx, react(alice.CommentId, "heart", "z")))
+	assert.Nil(t, e.RemoveReaction(ctx, react(alice.CommentId, "heart", "z")))
+
+	counts, err := e.ListReactions(ctx, "a", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, []*engine.ReactionCount{
+		{CommentId: "", Reaction: "+1", Count: 2, Mine: true},
+		{CommentId: alice.CommentId, Reaction: "+1", Count: 1, Mine: true},
+		{CommentId: alice.CommentId, Reaction: "heart", Count: 1, Mine: false},
+	}, counts)
+
+	// Purging a comment removes its reactions.
+	assert.Nil(t, e.DeleteComments(ctx, map[engine.PostId][]*engine.CommentId{"a": {&alice.CommentId}}, engine.Change{Actor: "admin", When: baseTime}))
+	_, err = e.PurgeComments(ctx, baseTime.Add(time.Hour))
+	assert.Nil(t, err)
+	counts, err = e.ListReactions(ctx, "a", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []*engine.ReactionCount{{CommentId: "", Reaction: "+1", Count: 2, Mine: false}}, counts)
+}
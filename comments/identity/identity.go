@@ -0,0 +1,190 @@
+// The identity package lets commenters prove that they own an email address
+// and stay signed in afterwards.
+//
+// A commenter asks for a verification link, which is sent to their email
+// address. When they open that link, the comments server records their
+// identity and gives them a signed cookie that identifies them in later
+// requests.
+
+package identity
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/notification"
+	"jacobo.tarrio.org/jtweb/tokens"
+)
+
+const cookieName = "jtcomments_identity"
+const verificationPurpose = "identity-verification"
+const cookiePurpose = "identity-cookie"
+
+// Manager sends verification links and issues and checks identity cookies.
+type Manager struct {
+	signer    *tokens.Signer
+	mailer    notification.Mailer
+	verifyUri string
+	linkTtl   time.Duration
+	cookieTtl time.Duration
+	// The origins of the pages that commenters may return to after verifying their address.
+	returnOrigins map[string]bool
+}
+
+// Verification contains the data carried in a verification link.
+type Verification struct {
+	Email     string
+	Name      string
+	ReturnUri string
+}
+
+type ManagerOption func(*Manager)
+
+// NewManager creates a Manager that signs tokens with the given key, sends
+// verification emails with the given mailer, and builds links from verifyUri.
+func NewManager(key string, mailer notification.Mailer, verifyUri string, options ...ManagerOption) *Manager {
+	m := &Manager{
+		signer:        tokens.NewSigner(key),
+		mailer:        mailer,
+		verifyUri:     verifyUri,
+		linkTtl:       24 * time.Hour,
+		cookieTtl:     365 * 24 * time.Hour,
+		returnOrigins: map[string]bool{},
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// LinkTtl sets for how long verification links are valid.
+func LinkTtl(ttl time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.linkTtl = ttl
+	}
+}
+
+// CookieTtl sets for how long a commenter stays signed in.
+func CookieTtl(ttl time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.cookieTtl = ttl
+	}
+}
+
+// ReturnTo lets commenters return to pages under the given web roots after verifying their address.
+// Any other return URIs are rejected.
+func ReturnTo(webRoots ...string) ManagerOption {
+	return func(m *Manager) {
+		for _, webRoot := range webRoots {
+			if uri, err := url.Parse(webRoot); err == nil {
+				m.returnOrigins[origin(uri)] = true
+			}
+		}
+	}
+}
+
+func origin(uri *url.URL) string {
+	return uri.Scheme + "://" + strings.ToLower(uri.Host)
+}
+
+// NormalizeEmail checks that the address is valid and returns it in a canonical form.
+func NormalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// SendVerification emails a verification link to the given address.
+func (m *Manager) SendVerification(email string, name string, returnUri string, language string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("a name is required")
+	}
+	ret, err := url.Parse(returnUri)
+	if err != nil {
+		return err
+	}
+	if (ret.Scheme != "http" && ret.Scheme != "https") || !m.returnOrigins[origin(ret)] {
+		return fmt.Errorf("invalid return URI: %s", returnUri)
+	}
+	token, err := m.signer.Sign(verificationPurpose, &Verification{Email: email, Name: name, ReturnUri: returnUri}, time.Now().Add(m.linkTtl))
+	if err != nil {
+		return err
+	}
+	link, err := url.Parse(m.verifyUri)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	msg := getMessages(language)
+	return m.mailer.SendMail(email, msg.subject, fmt.Sprintf(msg.body, name, link.String()))
+}
+
+// ParseVerification checks a token from a verification link and returns its data.
+func (m *Manager) ParseVerification(token string) (*Verification, error) {
+	var v Verification
+	err := m.signer.Verify(verificationPurpose, token, &v)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// SetCookie adds a signed cookie for the given identity to the response.
+func (m *Manager) SetCookie(rw http.ResponseWriter, identityId comments.IdentityId) error {
+	expires := time.Now().Add(m.cookieTtl)
+	token, err := m.signer.Sign(cookiePurpose, identityId, expires)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(rw, &http.Cookie{
+		Name:     cookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+	return nil
+}
+
+// ClearCookie removes the identity cookie.
+func (m *Manager) ClearCookie(rw http.ResponseWriter) {
+	http.SetCookie(rw, &http.Cookie{
+		Name:     cookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// GetIdentityId returns the identity in the request's cookie, or an empty string if there is none or it's not valid.
+func (m *Manager) GetIdentityId(req *http.Request) comments.IdentityId {
+	cookie, err := req.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	var identityId comments.IdentityId
+	if m.signer.Verify(cookiePurpose, cookie.Value, &identityId) != nil {
+		return ""
+	}
+	return identityId
+}
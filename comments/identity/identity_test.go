@@ -0,0 +1,28 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMailer struct {
+	sent []string
+}
+
+func (m *fakeMailer) SendMail(to string, subject string, body string) error {
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+func TestSendVerificationChecksReturnUri(t *testing.T) {
+	mailer := &fakeMailer{}
+	m := NewManager("key", mailer, "https://comments.example.com/_/verify", ReturnTo("https://example.com/", "https://example.es/blog/"))
+
+	assert.Nil(t, m.SendVerification("a@example.com", "A", "https://example.com/post.html", "en"))
+	assert.Nil(t, m.SendVerification("a@example.com", "A", "https://EXAMPLE.es/blog/post.html#comments", "en"))
+	assert.NotNil(t, m.SendVerification("a@example.com", "A", "https://evil.example.net/", "en"))
+	assert.NotNil(t, m.SendVerification("a@example.com", "A", "http://example.com/post.html", "en"))
+	assert.NotNil(t, m.SendVerification("a@example.com", "A", "javascript:alert(1)", "en"))
+	assert.Equal(t, []string{"a@example.com", "a@example.com"}, mailer.sent)
+}
@@ -0,0 +1,54 @@
+package identity
+
+type messages struct {
+	subject string
+	// The body receives the commenter's name and the verification link.
+	body string
+}
+
+var allMessages = map[string]messages{
+	"en": {
+		subject: "Confirm your email address to comment",
+		body: `Hello %[1]s,
+
+Somebody (hopefully you) asked to use this email address to post comments.
+To confirm it, please open the following link:
+
+%[2]s
+
+If you didn't ask for this, you can ignore this message.
+`,
+	},
+	"es": {
+		subject: "Confirma tu dirección de correo para comentar",
+		body: `Hola %[1]s:
+
+Alguien (esperamos que tú) ha pedido usar esta dirección de correo para publicar comentarios.
+Para confirmarla, abre el siguiente enlace:
+
+%[2]s
+
+Si no lo has pedido tú, puedes ignorar este mensaje.
+`,
+	},
+	"gl": {
+		subject: "Confirma o teu enderezo de correo para comentar",
+		body: `Ola %[1]s:
+
+Alguén (agardamos que ti) pediu usar este enderezo de correo para publicar comentarios.
+Para confirmalo, abre a seguinte ligazón:
+
+%[2]s
+
+Se non o pediches ti, podes ignorar esta mensaxe.
+`,
+	},
+}
+
+func getMessages(language string) messages {
+	msg, ok := allMessages[language]
+	if !ok {
+		return allMessages["en"]
+	}
+	return msg
+}
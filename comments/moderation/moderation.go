@@ -0,0 +1,140 @@
+// The moderation package creates and checks the signed links that let an
+// administrator approve, reject or delete a comment straight from a
+// notification email, without signing in to the admin page.
+//
+// Every link carries a random nonce and expires after a while. A link can
+// only be used once: the nonces of used links are recorded until they expire.
+
+package moderation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/tokens"
+)
+
+const linkPurpose = "moderation-link"
+
+// Action is what a moderation link does to a comment.
+type Action string
+
+const (
+	ActionApprove Action = "approve"
+	ActionReject  Action = "reject"
+	ActionDelete  Action = "delete"
+)
+
+// Link contains the data carried in a moderation link.
+type Link struct {
+	Action    Action
+	PostId    comments.PostId
+	CommentId comments.CommentId
+	Nonce     string
+	Expires   time.Time
+}
+
+// NonceStore records the nonces of the links that were used.
+type NonceStore interface {
+	UseNonce(ctx context.Context, nonce string, expires time.Time, now time.Time) error
+}
+
+// Linker creates and checks moderation links.
+type Linker struct {
+	signer  *tokens.Signer
+	store   NonceStore
+	baseUri string
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+type LinkerOption func(*Linker)
+
+// LinkTtl sets for how long moderation links are valid.
+func LinkTtl(ttl time.Duration) LinkerOption {
+	return func(l *Linker) {
+		l.ttl = ttl
+	}
+}
+
+// NewLinker creates a Linker that signs links with the given key, records used links in the
+// store, and builds links from baseUri.
+func NewLinker(key string, store NonceStore, baseUri string, options ...LinkerOption) *Linker {
+	l := &Linker{
+		signer:  tokens.NewSigner(key),
+		store:   store,
+		baseUri: baseUri,
+		ttl:     7 * 24 * time.Hour,
+		now:     time.Now,
+	}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+// Uri returns a link that performs the action on the given comment.
+func (l *Linker) Uri(action Action, postId comments.PostId, commentId comments.CommentId) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// Truncated to seconds, which is the precision of the token's expiration.
+	expires := l.now().Add(l.ttl).Truncate(time.Second)
+	link := &Link{
+		Action:    action,
+		PostId:    postId,
+		CommentId: commentId,
+		Nonce:     base64.RawURLEncoding.EncodeToString(nonce),
+		Expires:   expires,
+	}
+	token, err := l.signer.Sign(linkPurpose, link, expires)
+	if err != nil {
+		return "", err
+	}
+	uri, err := url.Parse(l.baseUri)
+	if err != nil {
+		return "", err
+	}
+	query := uri.Query()
+	query.Set("token", token)
+	uri.RawQuery = query.Encode()
+	return uri.String(), nil
+}
+
+// Parse checks a token from a moderation link and returns its data, without using it up.
+func (l *Linker) Parse(token string) (*Link, error) {
+	var link Link
+	err := l.signer.Verify(linkPurpose, token, &link)
+	if err != nil {
+		return nil, err
+	}
+	if l.now().After(link.Expires) {
+		return nil, fmt.Errorf("link has expired")
+	}
+	switch link.Action {
+	case ActionApprove, ActionReject, ActionDelete:
+	default:
+		return nil, fmt.Errorf("unknown moderation action: %s", link.Action)
+	}
+	return &link, nil
+}
+
+// Use checks a token from a moderation link, records that it was used, and returns its data.
+// It fails if the link was already used.
+func (l *Linker) Use(ctx context.Context, token string) (*Link, error) {
+	link, err := l.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	err = l.store.UseNonce(ctx, link.Nonce, link.Expires, l.now())
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
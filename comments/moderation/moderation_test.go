@@ -0,0 +1,85 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStore struct {
+	used map[string]time.Time
+}
+
+func (s *fakeStore) UseNonce(ctx context.Context, nonce string, expires time.Time, now time.Time) error {
+	if _, found := s.used[nonce]; found {
+		return fmt.Errorf("already used")
+	}
+	s.used[nonce] = expires
+	return nil
+}
+
+func newTestLinker(key string, now time.Time) *Linker {
+	l := NewLinker(key, &fakeStore{used: map[string]time.Time{}}, "https://comments.example.com/_/moderate", LinkTtl(time.Hour))
+	l.now = func() time.Time { return now }
+	return l
+}
+
+func tokenFrom(t *testing.T, uri string) string {
+	u, err := url.Parse(uri)
+	assert.Nil(t, err)
+	assert.Equal(t, "/_/moderate", u.Path)
+	return u.Query().Get("token")
+}
+
+func TestUseLink(t *testing.T) {
+	now := time.Now()
+	l := newTestLinker("key", now)
+	uri, err := l.Uri(ActionApprove, "post", "1a")
+	assert.Nil(t, err)
+	token := tokenFrom(t, uri)
+
+	link, err := l.Parse(token)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionApprove, link.Action)
+	assert.Equal(t, "post", string(link.PostId))
+	assert.Equal(t, "1a", string(link.CommentId))
+
+	link, err = l.Use(context.Background(), token)
+	assert.Nil(t, err)
+	assert.Equal(t, ActionApprove, link.Action)
+
+	// A link can be viewed but not used again.
+	_, err = l.Parse(token)
+	assert.Nil(t, err)
+	_, err = l.Use(context.Background(), token)
+	assert.NotNil(t, err)
+}
+
+func TestLinksAreUnique(t *testing.T) {
+	l := newTestLinker("key", time.Now())
+	first, err := l.Uri(ActionDelete, "post", "1a")
+	assert.Nil(t, err)
+	second, err := l.Uri(ActionDelete, "post", "1a")
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, second)
+	_, err = l.Use(context.Background(), tokenFrom(t, first))
+	assert.Nil(t, err)
+	_, err = l.Use(context.Background(), tokenFrom(t, second))
+	assert.Nil(t, err)
+}
+
+func TestRejectsExpiredOrForeignLinks(t *testing.T) {
+	now := time.Now()
+	uri, err := newTestLinker("key", now).Uri(ActionReject, "post", "1a")
+	assert.Nil(t, err)
+	token := tokenFrom(t, uri)
+
+	_, err = newTestLinker("key", now.Add(2*time.Hour)).Use(context.Background(), token)
+	assert.NotNil(t, err)
+	_, err = newTestLinker("other key", now).Use(context.Background(), token)
+	assert.NotNil(t, err)
+}
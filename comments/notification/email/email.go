@@ -3,32 +3,57 @@ package email
 import (
 	"fmt"
 	"net"
-	"net/smtp"
+	netmail "net/mail"
 	"strings"
 
 	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/moderation"
 	"jacobo.tarrio.org/jtweb/comments/notification"
 
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
-func NewEmailNotificationEngine(adminUri string, from string, to string, options ...NotificationEngineOption) notification.NotificationEngine {
-	engine := &emailEngine{
-		AdminUri: adminUri,
-		Target:   mail.NewSMTPClient(),
-		From:     from,
-		To:       to,
+// NewEmailNotificationEngine returns a NotificationEngine that emails new comments to an administrator.
+// If links is not nil, the messages contain signed links to approve, reject or delete the comment;
+// otherwise, they contain links to the admin page.
+func NewEmailNotificationEngine(adminUri string, links *moderation.Linker, from string, to string, options ...NotificationEngineOption) notification.NotificationEngine {
+	return &emailEngine{
+		smtpSender: newSmtpSender(options...),
+		AdminUri:   adminUri,
+		Links:      links,
+		From:       from,
+		To:         to,
 	}
-	SetHostPort("localhost:25")(engine)
+}
+
+// NewEmailMailer returns a Mailer that sends messages through the SMTP server configured with the given options.
+func NewEmailMailer(from string, options ...NotificationEngineOption) notification.Mailer {
+	return &emailMailer{
+		smtpSender: newSmtpSender(options...),
+		From:       from,
+	}
+}
+
+// NewHtmlMailer returns an HtmlMailer that sends messages through the SMTP server configured with the given options.
+func NewHtmlMailer(from string, options ...NotificationEngineOption) *HtmlMailer {
+	return &HtmlMailer{
+		smtpSender: newSmtpSender(options...),
+		From:       from,
+	}
+}
+
+func newSmtpSender(options ...NotificationEngineOption) smtpSender {
+	sender := smtpSender{Target: mail.NewSMTPClient()}
+	SetHostPort("localhost:25")(&sender)
 
 	for _, option := range options {
-		option(engine)
+		option(&sender)
 	}
-	return engine
+	return sender
 }
 
 func SetHostPort(hostport string) NotificationEngineOption {
-	return func(e *emailEngine) {
+	return func(e *smtpSender) {
 		e.ConnProvider = func() (net.Conn, error) {
 			return net.Dial("tcp", hostport)
 		}
@@ -36,7 +61,7 @@ func SetHostPort(hostport string) NotificationEngineOption {
 }
 
 func SetUnixSocket(socket string) NotificationEngineOption {
-	return func(e *emailEngine) {
+	return func(e *smtpSender) {
 		e.ConnProvider = func() (net.Conn, error) {
 			return net.Dial("unix", socket)
 		}
@@ -44,20 +69,20 @@ func SetUnixSocket(socket string) NotificationEngineOption {
 }
 
 func SetAuth(user string, pass string) NotificationEngineOption {
-	return func(e *emailEngine) {
+	return func(e *smtpSender) {
 		e.Target.Username = user
 		e.Target.Password = pass
 	}
 }
 
 func SetAuthType(auth mail.AuthType) NotificationEngineOption {
-	return func(e *emailEngine) {
+	return func(e *smtpSender) {
 		e.Target.Authentication = auth
 	}
 }
 
 func SetEncryption(enc mail.Encryption) NotificationEngineOption {
-	return func(e *emailEngine) {
+	return func(e *smtpSender) {
 		e.Target.Encryption = enc
 	}
 }
@@ -96,19 +121,38 @@ func Encryption(enc string) (mail.Encryption, error) {
 	return mail.EncryptionNone, fmt.Errorf("unknown encryption type: %s", enc)
 }
 
-type NotificationEngineOption = func(*emailEngine)
+type NotificationEngineOption = func(*smtpSender)
 
-type emailEngine struct {
-	AdminUri     string
+type smtpSender struct {
 	Target       *mail.SMTPServer
-	From         string
-	To           string
-	Auth         smtp.Auth
 	ConnProvider func() (net.Conn, error)
 }
 
+type emailEngine struct {
+	smtpSender
+	AdminUri string
+	Links    *moderation.Linker
+	From     string
+	To       string
+}
+
+type emailMailer struct {
+	smtpSender
+	From string
+}
+
+// HtmlMailer sends messages with HTML and plain text bodies, such as newsletters.
+type HtmlMailer struct {
+	smtpSender
+	From string
+}
+
 // Notify implements notification.NotificationEngine.
 func (e *emailEngine) Notify(comment *engine.Comment) error {
+	actions, err := e.actions(comment)
+	if err != nil {
+		return err
+	}
 	email := mail.NewMSG().
 		SetFrom(e.From).
 		AddTo(e.To).
@@ -116,26 +160,108 @@ func (e *emailEngine) Notify(comment *engine.Comment) error {
 		SetBody(mail.TextPlain, fmt.Sprintf(
 			`A new comment was received.
 
-Author: %[4]s
+Author: %[1]s
 Text:
-%[5]s
+%[2]s
+
+%[3]s`, comment.Author, comment.Text, actions))
 
-Approve: %[1]s#ApproveComment=approve,%[2]s,%[3]s
+	return e.send(email)
+}
+
+// actions returns the links to moderate the comment.
+func (e *emailEngine) actions(comment *engine.Comment) (string, error) {
+	if e.Links == nil {
+		return fmt.Sprintf(`Approve: %[1]s#ApproveComment=approve,%[2]s,%[3]s
 Reject: %[1]s#ApproveComment=reject,%[2]s,%[3]s
-`, e.AdminUri, comment.PostId, comment.CommentId, comment.Author, comment.Text))
+`, e.AdminUri, comment.PostId, comment.CommentId), nil
+	}
+	out := ""
+	for _, action := range []struct {
+		label  string
+		action moderation.Action
+	}{
+		{"Approve", moderation.ActionApprove},
+		{"Reject", moderation.ActionReject},
+		{"Delete", moderation.ActionDelete},
+	} {
+		uri, err := e.Links.Uri(action.action, comment.PostId, comment.CommentId)
+		if err != nil {
+			return "", err
+		}
+		out += fmt.Sprintf("%s: %s\n", action.label, uri)
+	}
+	return out + fmt.Sprintf("Admin page: %s\n", e.AdminUri), nil
+}
 
+// SendMail implements notification.Mailer.
+func (m *emailMailer) SendMail(to string, subject string, body string) error {
+	email := mail.NewMSG().
+		SetFrom(m.From).
+		AddTo(to).
+		SetSubject(subject).
+		SetBody(mail.TextPlain, body)
+	return m.send(email)
+}
+
+// SendHtmlMail sends a message with HTML and plain text bodies and some extra headers.
+func (m *HtmlMailer) SendHtmlMail(to string, subject string, html string, plaintext string, headers map[string]string) error {
+	return m.send(newHtmlMessage(m.From, []string{to}, subject, html, plaintext, headers))
+}
+
+// SendMessage sends a complete MIME message, such as one returned by HtmlMessage, to the given addresses.
+func (m *HtmlMailer) SendMessage(to []string, message string) error {
+	from, err := netmail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+	client, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return mail.SendMessage(from.Address, to, message, client)
+}
+
+// HtmlMessage returns a MIME message with HTML and plain text bodies and some extra headers.
+func HtmlMessage(from string, to []string, subject string, html string, plaintext string, headers map[string]string) (string, error) {
+	email := newHtmlMessage(from, to, subject, html, plaintext, headers)
 	if email.Error != nil {
-		return email.Error
+		return "", email.Error
 	}
+	return email.GetMessage(), nil
+}
 
-	conn, err := e.ConnProvider()
+func newHtmlMessage(from string, to []string, subject string, html string, plaintext string, headers map[string]string) *mail.Email {
+	email := mail.NewMSG().
+		SetFrom(from).
+		AddTo(to...).
+		SetSubject(subject).
+		SetBody(mail.TextPlain, plaintext).
+		AddAlternative(mail.TextHTML, html)
+	for name, value := range headers {
+		email.AddHeader(name, value)
+	}
+	return email
+}
+
+func (s *smtpSender) connect() (*mail.SMTPClient, error) {
+	conn, err := s.ConnProvider()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	target := *e.Target
+	target := *s.Target
 	target.CustomConn = conn
-	client, err := target.Connect()
+	return target.Connect()
+}
+
+func (s *smtpSender) send(email *mail.Email) error {
+	if email.Error != nil {
+		return email.Error
+	}
+
+	client, err := s.connect()
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,16 @@ type NotificationEngine interface {
 	Notify(comment *engine.Comment) error
 }
 
+// Mailer sends plain-text email messages to arbitrary recipients.
+type Mailer interface {
+	SendMail(to string, subject string, body string) error
+}
+
+// SubscriberNotifier tells the subscribers of a post that a new comment was published there.
+type SubscriberNotifier interface {
+	NotifySubscribers(comment *engine.Comment, subscriptions []*engine.Subscription) error
+}
+
 type nullEngine struct{}
 
 func NullNotificationEngine() NotificationEngine {
@@ -0,0 +1,70 @@
+package subscribers
+
+type messages struct {
+	// The subject receives the comment's author.
+	subject string
+	// The body receives the subscriber's name, the comment's author, a link to the comment,
+	// the comment's text, and the unsubscription link.
+	body string
+	// Shown after following the unsubscription link.
+	unsubscribed string
+}
+
+var allMessages = map[string]messages{
+	"en": {
+		subject: "New comment by %[1]s",
+		body: `Hello %[1]s,
+
+%[2]s has posted a new comment in a conversation you are following:
+
+%[4]s
+
+You can read it and reply here:
+%[3]s
+
+To stop receiving notifications about this post, open this link:
+%[5]s
+`,
+		unsubscribed: "You won't receive any more notifications about new comments on this post.",
+	},
+	"es": {
+		subject: "Nuevo comentario de %[1]s",
+		body: `Hola %[1]s:
+
+%[2]s ha publicado un nuevo comentario en una conversación que sigues:
+
+%[4]s
+
+Puedes leerlo y responder aquí:
+%[3]s
+
+Para dejar de recibir avisos sobre esta entrada, abre este enlace:
+%[5]s
+`,
+		unsubscribed: "Ya no recibirás más avisos de nuevos comentarios en esta entrada.",
+	},
+	"gl": {
+		subject: "Novo comentario de %[1]s",
+		body: `Ola %[1]s:
+
+%[2]s publicou un novo comentario nunha conversa que segues:
+
+%[4]s
+
+Podes lelo e responder aquí:
+%[3]s
+
+Para deixar de recibir avisos sobre esta entrada, abre esta ligazón:
+%[5]s
+`,
+		unsubscribed: "Xa non vas recibir máis avisos de novos comentarios nesta entrada.",
+	},
+}
+
+func getMessages(language string) messages {
+	msg, ok := allMessages[language]
+	if !ok {
+		return allMessages["en"]
+	}
+	return msg
+}
@@ -0,0 +1,120 @@
+// The subscribers package emails the commenters who subscribed to a post
+// when a new comment is published there.
+//
+// Every email contains a signed link that unsubscribes the recipient from the
+// post without requiring them to sign in.
+
+package subscribers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/notification"
+	"jacobo.tarrio.org/jtweb/tokens"
+)
+
+const unsubscribePurpose = "unsubscribe"
+
+// Notifier sends notifications to subscribers and checks unsubscription links.
+type Notifier struct {
+	signer         *tokens.Signer
+	mailer         notification.Mailer
+	unsubscribeUri string
+	linkTtl        time.Duration
+}
+
+// Unsubscription contains the data carried in an unsubscription link.
+type Unsubscription struct {
+	PostId     comments.PostId
+	IdentityId comments.IdentityId
+	Language   string
+}
+
+type NotifierOption func(*Notifier)
+
+// NewNotifier creates a Notifier that signs links with the given key, sends emails
+// with the given mailer, and builds unsubscription links from unsubscribeUri.
+func NewNotifier(key string, mailer notification.Mailer, unsubscribeUri string, options ...NotifierOption) *Notifier {
+	n := &Notifier{
+		signer:         tokens.NewSigner(key),
+		mailer:         mailer,
+		unsubscribeUri: unsubscribeUri,
+		linkTtl:        5 * 365 * 24 * time.Hour,
+	}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// LinkTtl sets for how long unsubscription links are valid.
+func LinkTtl(ttl time.Duration) NotifierOption {
+	return func(n *Notifier) {
+		n.linkTtl = ttl
+	}
+}
+
+// NotifySubscribers implements notification.SubscriberNotifier.
+func (n *Notifier) NotifySubscribers(comment *engine.Comment, subscriptions []*engine.Subscription) error {
+	var errs []error
+	for _, sub := range subscriptions {
+		if comment.Identity != nil && comment.Identity.IdentityId == sub.Identity.IdentityId {
+			continue
+		}
+		err := n.notify(comment, sub)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error notifying %s: %w", sub.Identity.Email, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) notify(comment *engine.Comment, sub *engine.Subscription) error {
+	commentLink, err := url.Parse(sub.PageUri)
+	if err != nil {
+		return err
+	}
+	commentLink.Fragment = "comment_" + string(comment.CommentId)
+
+	token, err := n.signer.Sign(unsubscribePurpose, &Unsubscription{
+		PostId:     sub.PostId,
+		IdentityId: sub.Identity.IdentityId,
+		Language:   sub.Language,
+	}, time.Now().Add(n.linkTtl))
+	if err != nil {
+		return err
+	}
+	unsubscribeLink, err := url.Parse(n.unsubscribeUri)
+	if err != nil {
+		return err
+	}
+	query := unsubscribeLink.Query()
+	query.Set("token", token)
+	unsubscribeLink.RawQuery = query.Encode()
+
+	msg := getMessages(sub.Language)
+	return n.mailer.SendMail(
+		sub.Identity.Email,
+		fmt.Sprintf(msg.subject, comment.Author),
+		fmt.Sprintf(msg.body, sub.Identity.Name, comment.Author, commentLink.String(), comment.Text, unsubscribeLink.String()))
+}
+
+// ParseUnsubscription checks a token from an unsubscription link and returns its data.
+func (n *Notifier) ParseUnsubscription(token string) (*Unsubscription, error) {
+	var u Unsubscription
+	err := n.signer.Verify(unsubscribePurpose, token, &u)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UnsubscribedMessage returns the text shown after somebody follows an unsubscription link.
+func UnsubscribedMessage(language string) string {
+	return getMessages(language).unsubscribed
+}
@@ -0,0 +1,81 @@
+package subscribers
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+type sentMail struct {
+	to      string
+	subject string
+	body    string
+}
+
+type fakeMailer struct {
+	sent []sentMail
+}
+
+func (m *fakeMailer) SendMail(to string, subject string, body string) error {
+	m.sent = append(m.sent, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+func subscription(id engine.IdentityId, email string, language string) *engine.Subscription {
+	return &engine.Subscription{
+		PostId:   "post",
+		Identity: engine.Identity{IdentityId: id, Email: email, Name: "Name " + string(id)},
+		PageUri:  "https://example.com/post.html",
+		Language: language,
+	}
+}
+
+func TestNotifySkipsAuthor(t *testing.T) {
+	mailer := &fakeMailer{}
+	n := NewNotifier("key", mailer, "https://comments.example.com/_/unsubscribe")
+	comment := &engine.Comment{
+		PostId:    "post",
+		CommentId: "1a",
+		Author:    "Name 1",
+		Text:      "Hello!",
+		Identity:  &engine.Identity{IdentityId: "1", Email: "one@example.com", Name: "Name 1"},
+	}
+	err := n.NotifySubscribers(comment, []*engine.Subscription{
+		subscription("1", "one@example.com", "en"),
+		subscription("2", "two@example.com", "es"),
+	})
+	assert.Nil(t, err)
+	assert.Len(t, mailer.sent, 1)
+	assert.Equal(t, "two@example.com", mailer.sent[0].to)
+	assert.Equal(t, "Nuevo comentario de Name 1", mailer.sent[0].subject)
+	assert.Contains(t, mailer.sent[0].body, "https://example.com/post.html#comment_1a")
+	assert.Contains(t, mailer.sent[0].body, "Hello!")
+}
+
+func TestUnsubscribeLink(t *testing.T) {
+	mailer := &fakeMailer{}
+	n := NewNotifier("key", mailer, "https://comments.example.com/_/unsubscribe")
+	comment := &engine.Comment{PostId: "post", CommentId: "1a", Author: "Anonymous", Text: "Hello!"}
+	err := n.NotifySubscribers(comment, []*engine.Subscription{subscription("2", "two@example.com", "gl")})
+	assert.Nil(t, err)
+	assert.Len(t, mailer.sent, 1)
+
+	var link *url.URL
+	for _, line := range strings.Split(mailer.sent[0].body, "\n") {
+		if strings.HasPrefix(line, "https://comments.example.com/_/unsubscribe?") {
+			link, err = url.Parse(line)
+			assert.Nil(t, err)
+		}
+	}
+	assert.NotNil(t, link)
+
+	unsubscription, err := n.ParseUnsubscription(link.Query().Get("token"))
+	assert.Nil(t, err)
+	assert.Equal(t, Unsubscription{PostId: "post", IdentityId: "2", Language: "gl"}, *unsubscription)
+
+	_, err = NewNotifier("other key", mailer, "").ParseUnsubscription(link.Query().Get("token"))
+	assert.NotNil(t, err)
+}
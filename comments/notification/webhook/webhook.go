@@ -0,0 +1,189 @@
+// The webhook package sends notifications about new comments to HTTP endpoints.
+//
+// Besides a generic JSON payload, it can produce the payloads expected by Slack
+// incoming webhooks and by Matrix webhook bridges such as matrix-hookshot. If a
+// secret is configured, every request carries an HMAC-SHA256 signature of its
+// body in the X-Jtweb-Signature header.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/notification"
+)
+
+const SignatureHeader = "X-Jtweb-Signature"
+
+type Format int
+
+const (
+	FormatGeneric = Format(iota)
+	FormatSlack
+	FormatMatrix
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch strings.ToLower(format) {
+	case "":
+		fallthrough
+	case "generic":
+		return FormatGeneric, nil
+	case "slack":
+		return FormatSlack, nil
+	case "matrix":
+		return FormatMatrix, nil
+	}
+	return FormatGeneric, fmt.Errorf("unknown webhook format: %s", format)
+}
+
+type WebhookOption func(*webhookEngine)
+
+func NewWebhookNotificationEngine(uri string, adminUri string, options ...WebhookOption) notification.NotificationEngine {
+	e := &webhookEngine{
+		Uri:      uri,
+		AdminUri: adminUri,
+		Format:   FormatGeneric,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+func SetFormat(format Format) WebhookOption {
+	return func(e *webhookEngine) {
+		e.Format = format
+	}
+}
+
+func SetSecret(secret string) WebhookOption {
+	return func(e *webhookEngine) {
+		e.Secret = []byte(secret)
+	}
+}
+
+func SetHttpClient(client *http.Client) WebhookOption {
+	return func(e *webhookEngine) {
+		e.Client = client
+	}
+}
+
+type webhookEngine struct {
+	Uri      string
+	AdminUri string
+	Format   Format
+	Secret   []byte
+	Client   *http.Client
+}
+
+type genericPayload struct {
+	Event     string
+	PostId    engine.PostId
+	CommentId engine.CommentId
+	Visible   bool
+	Author    string
+	When      time.Time
+	Text      engine.Markdown
+	AdminUri  string
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+type matrixPayload struct {
+	Text     string `json:"text"`
+	Html     string `json:"html"`
+	Username string `json:"username"`
+}
+
+// Notify implements notification.NotificationEngine. It makes a single request; failed
+// notifications are retried later by the comments service.
+func (e *webhookEngine) Notify(comment *engine.Comment) error {
+	body, err := json.Marshal(e.payload(comment))
+	if err != nil {
+		return err
+	}
+	return e.send(body)
+}
+
+func (e *webhookEngine) payload(comment *engine.Comment) any {
+	switch e.Format {
+	case FormatSlack:
+		return &slackPayload{Text: e.message(comment, slackEscaper.Replace)}
+	case FormatMatrix:
+		return &matrixPayload{
+			Text:     e.message(comment, noEscape),
+			Html:     strings.ReplaceAll(html.EscapeString(e.message(comment, noEscape)), "\n", "<br>"),
+			Username: "jtweb",
+		}
+	default:
+		return &genericPayload{
+			Event:     "new_comment",
+			PostId:    comment.PostId,
+			CommentId: comment.CommentId,
+			Visible:   comment.Visible,
+			Author:    comment.Author,
+			When:      comment.When,
+			Text:      comment.Text,
+			AdminUri:  e.AdminUri,
+		}
+	}
+}
+
+// slackEscaper escapes the characters that Slack uses for mentions and links, so that commenters can't add them.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func noEscape(s string) string {
+	return s
+}
+
+// message returns the text of the notification, passing the parts written by the commenter through escape.
+func (e *webhookEngine) message(comment *engine.Comment, escape func(string) string) string {
+	return fmt.Sprintf(`New comment received from %[4]s on %[2]s:
+%[5]s
+
+Approve: %[1]s#ApproveComment=approve,%[2]s,%[3]s
+Reject: %[1]s#ApproveComment=reject,%[2]s,%[3]s`, e.AdminUri, comment.PostId, comment.CommentId, escape(comment.Author), escape(string(comment.Text)))
+}
+
+func (e *webhookEngine) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, e.Uri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(e.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(e.Secret, body))
+	}
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return fmt.Errorf("webhook returned status %s", resp.Status)
+}
+
+// Sign returns the value of the signature header for the given body.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
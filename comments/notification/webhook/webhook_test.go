@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+type received struct {
+	body      []byte
+	signature string
+}
+
+func newStub(t *testing.T, statuses ...int) (*httptest.Server, *[]received) {
+	requests := &[]received{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		assert.Nil(t, err)
+		*requests = append(*requests, received{body: body, signature: req.Header.Get(SignatureHeader)})
+		status := http.StatusOK
+		if len(*requests) <= len(statuses) {
+			status = statuses[len(*requests)-1]
+		}
+		rw.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+var comment = &engine.Comment{
+	PostId:    "post",
+	CommentId: "1a",
+	Visible:   false,
+	Author:    "John",
+	When:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	Text:      "Hello!",
+}
+
+func TestGenericPayloadIsSigned(t *testing.T) {
+	server, requests := newStub(t)
+	e := NewWebhookNotificationEngine(server.URL, "https://example.com/admin.html", SetSecret("secret"))
+	assert.Nil(t, e.Notify(comment))
+	assert.Len(t, *requests, 1)
+
+	r := (*requests)[0]
+	assert.Equal(t, Sign([]byte("secret"), r.body), r.signature)
+	var payload genericPayload
+	assert.Nil(t, json.Unmarshal(r.body, &payload))
+	assert.Equal(t, "new_comment", payload.Event)
+	assert.Equal(t, engine.PostId("post"), payload.PostId)
+	assert.Equal(t, engine.CommentId("1a"), payload.CommentId)
+	assert.Equal(t, "John", payload.Author)
+	assert.Equal(t, engine.Markdown("Hello!"), payload.Text)
+}
+
+func TestSlackPayload(t *testing.T) {
+	server, requests := newStub(t)
+	e := NewWebhookNotificationEngine(server.URL, "https://example.com/admin.html", SetFormat(FormatSlack))
+	assert.Nil(t, e.Notify(comment))
+	assert.Len(t, *requests, 1)
+	assert.Equal(t, "", (*requests)[0].signature)
+
+	var payload map[string]string
+	assert.Nil(t, json.Unmarshal((*requests)[0].body, &payload))
+	assert.Contains(t, payload["text"], "New comment received from John on post")
+	assert.Contains(t, payload["text"], "https://example.com/admin.html#ApproveComment=approve,post,1a")
+}
+
+func TestSlackPayloadIsEscaped(t *testing.T) {
+	server, requests := newStub(t)
+	e := NewWebhookNotificationEngine(server.URL, "https://example.com/admin.html", SetFormat(FormatSlack))
+	assert.Nil(t, e.Notify(&engine.Comment{
+		PostId:    "post",
+		CommentId: "1a",
+		Author:    "<!channel>",
+		Text:      "Click <https://evil.example.com|Approve> & see",
+	}))
+
+	var payload map[string]string
+	assert.Nil(t, json.Unmarshal((*requests)[0].body, &payload))
+	assert.Contains(t, payload["text"], "New comment received from &lt;!channel&gt; on post")
+	assert.Contains(t, payload["text"], "Click &lt;https://evil.example.com|Approve&gt; &amp; see")
+	assert.NotContains(t, payload["text"], "<!channel>")
+	assert.NotContains(t, payload["text"], "<https://evil")
+}
+
+func TestMakesASingleAttempt(t *testing.T) {
+	// Failed notifications are retried by the comments service, not by the webhook.
+	server, requests := newStub(t, http.StatusInternalServerError)
+	e := NewWebhookNotificationEngine(server.URL, "")
+	assert.NotNil(t, e.Notify(comment))
+	assert.Len(t, *requests, 1)
+
+	assert.Nil(t, e.Notify(comment))
+	assert.Len(t, *requests, 2)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments"
+)
+
+// Cursors are opaque to clients: they contain the sort order and the position of the last result in a page, encoded in JSON and base64.
+
+type commentCursor struct {
+	PostId    PostId
+	CommentId CommentId
+	When      time.Time
+}
+
+type postCursor struct {
+	PostId PostId
+}
+
+type auditCursor struct {
+	AuditId comments.AuditId
+}
+
+type cursorEnvelope struct {
+	Sort     Sort
+	Position json.RawMessage
+}
+
+func encodeCursor(sort Sort, position any) (string, error) {
+	pos, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(&cursorEnvelope{Sort: sort, Position: pos})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, sort Sort, position any) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("invalid cursor: %v", err)
+	}
+	var envelope cursorEnvelope
+	err = json.Unmarshal(data, &envelope)
+	if err != nil {
+		return fmt.Errorf("invalid cursor: %v", err)
+	}
+	if envelope.Sort != sort {
+		return fmt.Errorf("the cursor was created for a different sort order")
+	}
+	err = json.Unmarshal(envelope.Position, position)
+	if err != nil {
+		return fmt.Errorf("invalid cursor: %v", err)
+	}
+	return nil
+}
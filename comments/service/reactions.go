@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments/engine"
+)
+
+// reactionClient returns the key that identifies a reader's reactions. Verified readers are
+// identified by their identity; anonymous readers by a hash of the token their browser sends.
+func reactionClient(viewer IdentityId, client string) string {
+	if viewer != "" {
+		return "identity:" + string(viewer)
+	}
+	if client == "" {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(client))
+	return "client:" + hex.EncodeToString(hash[:])
+}
+
+func (s *commentsServiceImpl) isReaction(reaction string) bool {
+	for _, r := range s.reactions {
+		if r == reaction {
+			return true
+		}
+	}
+	return false
+}
+
+// reactionCounts returns the counts for all the available reactions, in order, including those nobody gave.
+func (s *commentsServiceImpl) reactionCounts(counts map[string]*engine.ReactionCount) []*ReactionCount {
+	out := make([]*ReactionCount, len(s.reactions))
+	for i, reaction := range s.reactions {
+		out[i] = &ReactionCount{Reaction: reaction}
+		if count, ok := counts[reaction]; ok {
+			out[i].Count = count.Count
+			out[i].Mine = count.Mine
+		}
+	}
+	return out
+}
+
+func (s *commentsServiceImpl) addReactions(ctx context.Context, list *CommentList, client string) error {
+	counts, err := s.engine.ListReactions(ctx, list.PostId, client)
+	if err != nil {
+		return err
+	}
+	byComment := map[CommentId]map[string]*engine.ReactionCount{}
+	for _, count := range counts {
+		if _, ok := byComment[count.CommentId]; !ok {
+			byComment[count.CommentId] = map[string]*engine.ReactionCount{}
+		}
+		byComment[count.CommentId][count.Reaction] = count
+	}
+	list.Reactions = s.reactionCounts(byComment[""])
+	for _, comment := range list.List {
+		comment.Reactions = s.reactionCounts(byComment[comment.Id])
+	}
+	return nil
+}
+
+func (s *commentsServiceImpl) React(ctx context.Context, reaction *NewReaction) error {
+	if len(s.reactions) == 0 {
+		return fmt.Errorf("reactions are not enabled")
+	}
+	if !s.isReaction(reaction.Reaction) {
+		return fmt.Errorf("invalid reaction: %s", reaction.Reaction)
+	}
+	client := reactionClient(reaction.IdentityId, reaction.Client)
+	if client == "" {
+		return fmt.Errorf("a client token is required to react")
+	}
+	cfg, err := s.engine.GetConfig(ctx, reaction.PostId)
+	if err != nil {
+		return err
+	}
+	if cfg.State != engine.CommentsEnabled {
+		return fmt.Errorf("comments are closed for post [%s]", reaction.PostId)
+	}
+	if reaction.CommentId != "" {
+		comment, err := s.engine.GetComment(ctx, reaction.PostId, reaction.CommentId)
+		if err != nil {
+			return err
+		}
+		if !comment.Visible {
+			return fmt.Errorf("comment not found [%s/%s]", reaction.PostId, reaction.CommentId)
+		}
+	}
+	r := &engine.Reaction{
+		PostId:    reaction.PostId,
+		CommentId: reaction.CommentId,
+		Reaction:  reaction.Reaction,
+		Client:    client,
+		When:      time.Now(),
+	}
+	if reaction.Add {
+		return s.engine.AddReaction(ctx, r)
+	}
+	return s.engine.RemoveReaction(ctx, r)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
 	"time"
 
 	"jacobo.tarrio.org/jtweb/comments"
@@ -13,25 +15,48 @@ import (
 
 type PostId = comments.PostId
 type CommentId = comments.CommentId
+type IdentityId = comments.IdentityId
+type NotificationId = comments.NotificationId
 type Markdown = comments.Markdown
 type Html = comments.Html
 
 type CommentsService interface {
-	List(ctx context.Context, id PostId, seeDrafts bool) (*CommentList, error)
+	List(ctx context.Context, id PostId, seeDrafts bool, viewer IdentityId, client string) (*CommentList, error)
 	Add(ctx context.Context, comment *NewComment) (*Comment, error)
+	EditOwnComment(ctx context.Context, viewer IdentityId, postId PostId, commentId CommentId, text Markdown) (*Comment, error)
+	DeleteOwnComment(ctx context.Context, viewer IdentityId, postId PostId, commentId CommentId) error
+	SaveIdentity(ctx context.Context, email string, name string) (*Identity, error)
+	GetIdentity(ctx context.Context, id IdentityId) (*Identity, error)
+	Subscribe(ctx context.Context, viewer IdentityId, postId PostId, pageUri string, language string) error
+	Unsubscribe(ctx context.Context, viewer IdentityId, postId PostId) error
+	DeliverNotifications(ctx context.Context, limit int) (int, error)
+	FindNotifications(ctx context.Context, filter NotificationFilter, limit int, start int) (*FoundNotifications, error)
+	RetryNotifications(ctx context.Context, actor string, ids []NotificationId) error
+	DeleteNotifications(ctx context.Context, actor string, ids []NotificationId) error
 	Render(ctx context.Context, text Markdown) (Html, error)
-	FindComments(ctx context.Context, filter CommentFilter, sort Sort, limit int, start int) (*FoundComments, error)
-	DeleteComments(ctx context.Context, ids map[PostId][]*CommentId) error
-	FindPosts(ctx context.Context, filter PostFilter, sort Sort, limit int, start int) (*FoundPosts, error)
-	BulkSetVisible(ctx context.Context, ids map[PostId][]*CommentId, visible bool) error
+	FindComments(ctx context.Context, filter CommentFilter, sort Sort, limit int, cursor string) (*FoundComments, error)
+	GetComment(ctx context.Context, postId PostId, commentId CommentId) (*RawComment, error)
+	// CountComments returns the number of published comments for each of the given posts that has readable comments.
+	CountComments(ctx context.Context, ids []PostId) (map[PostId]int, error)
+	DeleteComments(ctx context.Context, actor string, ids map[PostId][]*CommentId) error
+	RestoreComments(ctx context.Context, actor string, ids map[PostId][]*CommentId) error
+	PurgeDeletedComments(ctx context.Context, deletedBefore time.Time) (int, error)
+	FindPosts(ctx context.Context, filter PostFilter, sort Sort, limit int, cursor string) (*FoundPosts, error)
+	BulkSetVisible(ctx context.Context, actor string, ids map[PostId][]*CommentId, visible bool) error
 	SetAvailablePosts(ctx context.Context, posts *AvailablePosts) error
-	BulkUpdatePostConfigs(ctx context.Context, ids []PostId, config CommentConfig) error
+	BulkUpdatePostConfigs(ctx context.Context, actor string, ids []PostId, config CommentConfig) error
+	FindAuditEntries(ctx context.Context, filter AuditFilter, limit int, cursor string) (*FoundAuditEntries, error)
+	React(ctx context.Context, reaction *NewReaction) error
 }
 
 type CommentList struct {
 	PostId PostId
 	Config CommentConfig
 	List   []*Comment
+	// Whether the viewer is subscribed to new comments; nil if subscriptions are not available to them.
+	Subscribed *bool `json:",omitempty"`
+	// The reactions to the post itself; empty if reactions are disabled.
+	Reactions []*ReactionCount `json:",omitempty"`
 }
 
 type Comment struct {
@@ -40,6 +65,36 @@ type Comment struct {
 	Author  string
 	When    time.Time
 	Text    Html
+	// True if the comment was posted by a verified identity.
+	Verified bool
+	// A badge to display next to the author's name, such as "Author".
+	Badge string `json:",omitempty"`
+	// True if the viewer can still edit or delete this comment.
+	Editable bool
+	// The comment's source text, only provided if it is editable.
+	Source Markdown `json:",omitempty"`
+	// The reactions to this comment; empty if reactions are disabled.
+	Reactions []*ReactionCount `json:",omitempty"`
+}
+
+// ReactionCount is the number of readers that gave one of the available reactions.
+type ReactionCount struct {
+	Reaction string
+	Count    int
+	// True if the viewer gave this reaction.
+	Mine bool
+}
+
+type NewReaction struct {
+	PostId PostId
+	// The comment to react to, or empty to react to the post itself.
+	CommentId CommentId
+	Reaction  string
+	// True to add the reaction, false to remove it.
+	Add bool
+	// A random token that identifies an anonymous reader.
+	Client     string
+	IdentityId IdentityId `json:"-"`
 }
 
 type RawComment = engine.Comment
@@ -49,6 +104,14 @@ type NewComment struct {
 	Author string
 	When   time.Time
 	Text   Markdown
+	// The verified identity posting this comment. Never read from the client.
+	IdentityId IdentityId `json:"-"`
+}
+
+type Identity struct {
+	Id    IdentityId
+	Name  string
+	Badge string `json:",omitempty"`
 }
 
 type CommentFilter = engine.CommentFilter
@@ -56,15 +119,43 @@ type PostFilter = engine.PostFilter
 type Sort = engine.Sort
 
 const SortNewestFirst = engine.SortNewestFirst
+const SortOldestFirst = engine.SortOldestFirst
+const SortByPost = engine.SortByPost
 
 type FoundComments struct {
 	List []*RawComment
 	More bool
+	// The cursor to retrieve the next page of results, if there are more.
+	Next string `json:",omitempty"`
+	// The total number of comments that match the filter.
+	Total int
+}
+
+type Notification = engine.Notification
+type NotificationFilter = engine.NotificationFilter
+
+type FoundNotifications struct {
+	List []*Notification
+	More bool
 }
 
 type FoundPosts struct {
 	List []*FoundPost
 	More bool
+	// The cursor to retrieve the next page of results, if there are more.
+	Next string `json:",omitempty"`
+	// The total number of posts that match the filter.
+	Total int
+}
+
+type AuditEntry = engine.AuditEntry
+type AuditFilter = engine.AuditFilter
+
+type FoundAuditEntries struct {
+	List []*AuditEntry
+	More bool
+	// The cursor to retrieve the next page of results, if there are more.
+	Next string `json:",omitempty"`
 }
 
 type FoundPost struct {
@@ -84,15 +175,23 @@ type CommentConfig struct {
 func NewCommentsService(engine engine.Engine, options ...CommentsServiceOptions) CommentsService {
 	service := &commentsServiceImpl{
 		engine:         engine,
-		notifications:  notification.NullNotificationEngine(),
 		renderer:       NewEscapeRenderer(),
-		defaultVisible: true}
+		defaultVisible: true,
+		badges:         map[string]string{},
+		editWindow:     0,
+		maxAttempts:    10,
+		retryDelay:     time.Minute}
 	for _, option := range options {
 		option(service)
 	}
 	return service
 }
 
+const maxRetryDelay = 24 * time.Hour
+
+// The maximum number of posts whose comments can be counted at once.
+const maxCountedPosts = 500
+
 type CommentsServiceOptions func(*commentsServiceImpl)
 
 func PostAsDraft() CommentsServiceOptions {
@@ -107,17 +206,65 @@ func WithRenderer(renderer Renderer) CommentsServiceOptions {
 	}
 }
 
-func WithNotificationEngine(notifications notification.NotificationEngine) CommentsServiceOptions {
+// WithNotificationEngine tells the administrators about new comments through the given notifiers.
+// Each notifier has its own queued notifications, which are delivered and retried independently.
+func WithNotificationEngine(notifiers ...notification.NotificationEngine) CommentsServiceOptions {
+	return func(s *commentsServiceImpl) {
+		s.notifiers = append(s.notifiers, notifiers...)
+	}
+}
+
+// WithNotificationRetries sets how many times the delivery of a notification is attempted,
+// and the delay before the first retry. The delay is doubled for every subsequent retry.
+func WithNotificationRetries(maxAttempts int, initialDelay time.Duration) CommentsServiceOptions {
+	return func(s *commentsServiceImpl) {
+		s.maxAttempts = maxAttempts
+		s.retryDelay = initialDelay
+	}
+}
+
+// WithBadges sets the badges shown next to comments by the identities with the given email addresses.
+func WithBadges(badges map[string]string) CommentsServiceOptions {
+	return func(s *commentsServiceImpl) {
+		for email, badge := range badges {
+			s.badges[strings.ToLower(email)] = badge
+		}
+	}
+}
+
+// WithSubscriberNotifier lets verified commenters subscribe to posts and uses the given notifier
+// to tell them about new comments when they are published.
+func WithSubscriberNotifier(subscribers notification.SubscriberNotifier) CommentsServiceOptions {
 	return func(s *commentsServiceImpl) {
-		s.notifications = notifications
+		s.subscribers = subscribers
+	}
+}
+
+// WithReactions lets readers react to posts and comments with the given reactions, usually emoji.
+func WithReactions(reactions []string) CommentsServiceOptions {
+	return func(s *commentsServiceImpl) {
+		s.reactions = reactions
+	}
+}
+
+// WithEditWindow lets verified commenters edit or delete their own comments for the given time after posting them.
+func WithEditWindow(window time.Duration) CommentsServiceOptions {
+	return func(s *commentsServiceImpl) {
+		s.editWindow = window
 	}
 }
 
 type commentsServiceImpl struct {
 	engine         engine.Engine
-	notifications  notification.NotificationEngine
+	notifiers      []notification.NotificationEngine
+	subscribers    notification.SubscriberNotifier
 	renderer       Renderer
 	defaultVisible bool
+	badges         map[string]string
+	editWindow     time.Duration
+	maxAttempts    int
+	retryDelay     time.Duration
+	reactions      []string
 }
 
 func commentConfigToState(cfg CommentConfig) engine.CommentState {
@@ -137,7 +284,7 @@ func commentStateToConfig(state engine.CommentState) CommentConfig {
 	}
 }
 
-func (s *commentsServiceImpl) List(ctx context.Context, id PostId, seeDrafts bool) (*CommentList, error) {
+func (s *commentsServiceImpl) List(ctx context.Context, id PostId, seeDrafts bool, viewer IdentityId, client string) (*CommentList, error) {
 	cfg, err := s.engine.GetConfig(ctx, id)
 	if err != nil {
 		return nil, err
@@ -154,20 +301,43 @@ func (s *commentsServiceImpl) List(ctx context.Context, id PostId, seeDrafts boo
 		return nil, err
 	}
 	for _, comment := range list {
-		cmt, err := s.parseComment(comment)
+		cmt, err := s.parseComment(comment, viewer)
 		if err != nil {
 			return nil, err
 		}
 		out.List = append(out.List, cmt)
 	}
+	if viewer != "" && s.subscribers != nil {
+		subscribed, err := s.isSubscribed(ctx, viewer, id)
+		if err != nil {
+			return nil, err
+		}
+		out.Subscribed = &subscribed
+	}
+	if len(s.reactions) > 0 {
+		err = s.addReactions(ctx, out, reactionClient(viewer, client))
+		if err != nil {
+			return nil, err
+		}
+	}
 	return out, nil
 }
 
-func (s *commentsServiceImpl) FindComments(ctx context.Context, filter CommentFilter, sort Sort, limit int, start int) (*FoundComments, error) {
-	if start < 0 {
-		start = 0
+func (s *commentsServiceImpl) FindComments(ctx context.Context, filter CommentFilter, sort Sort, limit int, cursor string) (*FoundComments, error) {
+	var after *engine.CommentCursor
+	if cursor != "" {
+		var c commentCursor
+		err := decodeCursor(cursor, sort, &c)
+		if err != nil {
+			return nil, err
+		}
+		after = &engine.CommentCursor{PostId: c.PostId, CommentId: c.CommentId, When: c.When}
+	}
+	list, err := s.engine.FindComments(ctx, filter, sort, limit+1, after)
+	if err != nil {
+		return nil, err
 	}
-	list, err := s.engine.FindComments(ctx, filter, sort, limit+1, start)
+	total, err := s.engine.CountComments(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -176,42 +346,118 @@ func (s *commentsServiceImpl) FindComments(ctx context.Context, filter CommentFi
 		last = len(list)
 	}
 	out := &FoundComments{
-		List: list[0:last],
-		More: len(list) > limit,
+		List:  list[0:last],
+		More:  len(list) > limit,
+		Total: total,
+	}
+	if out.More && last > 0 {
+		lastComment := list[last-1]
+		out.Next, err = encodeCursor(sort, &commentCursor{PostId: lastComment.PostId, CommentId: lastComment.CommentId, When: lastComment.When})
+		if err != nil {
+			return nil, err
+		}
 	}
 	return out, nil
 }
 
-func (s *commentsServiceImpl) DeleteComments(ctx context.Context, ids map[PostId][]*CommentId) error {
-	return s.engine.DeleteComments(ctx, ids)
+func (s *commentsServiceImpl) CountComments(ctx context.Context, ids []PostId) (map[PostId]int, error) {
+	if len(ids) > maxCountedPosts {
+		return nil, fmt.Errorf("too many posts: %d, maximum %d", len(ids), maxCountedPosts)
+	}
+	return s.engine.CountVisibleComments(ctx, ids)
 }
 
-func (s *commentsServiceImpl) FindPosts(ctx context.Context, filter PostFilter, sort Sort, limit int, start int) (*FoundPosts, error) {
-	if start < 0 {
-		start = 0
+func (s *commentsServiceImpl) GetComment(ctx context.Context, postId PostId, commentId CommentId) (*RawComment, error) {
+	return s.engine.GetComment(ctx, postId, commentId)
+}
+
+func (s *commentsServiceImpl) DeleteComments(ctx context.Context, actor string, ids map[PostId][]*CommentId) error {
+	return s.engine.DeleteComments(ctx, ids, engine.Change{Actor: actor, When: time.Now()})
+}
+
+func (s *commentsServiceImpl) RestoreComments(ctx context.Context, actor string, ids map[PostId][]*CommentId) error {
+	return s.engine.RestoreComments(ctx, ids, engine.Change{Actor: actor, When: time.Now()})
+}
+
+func (s *commentsServiceImpl) PurgeDeletedComments(ctx context.Context, deletedBefore time.Time) (int, error) {
+	return s.engine.PurgeComments(ctx, deletedBefore)
+}
+
+func (s *commentsServiceImpl) FindAuditEntries(ctx context.Context, filter AuditFilter, limit int, cursor string) (*FoundAuditEntries, error) {
+	var after *comments.AuditId
+	if cursor != "" {
+		var c auditCursor
+		err := decodeCursor(cursor, SortNewestFirst, &c)
+		if err != nil {
+			return nil, err
+		}
+		after = &c.AuditId
 	}
-	list, err := s.engine.FindPosts(ctx, filter, sort, limit+1, start)
+	list, err := s.engine.FindAuditEntries(ctx, filter, limit+1, after)
 	if err != nil {
 		return nil, err
 	}
-	out := &FoundPosts{
-		List: []*FoundPost{},
+	last := limit
+	if last > len(list) {
+		last = len(list)
+	}
+	out := &FoundAuditEntries{
+		List: list[0:last],
 		More: len(list) > limit,
 	}
+	if out.More && last > 0 {
+		out.Next, err = encodeCursor(SortNewestFirst, &auditCursor{AuditId: list[last-1].AuditId})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *commentsServiceImpl) FindPosts(ctx context.Context, filter PostFilter, sort Sort, limit int, cursor string) (*FoundPosts, error) {
+	var after *PostId
+	if cursor != "" {
+		var c postCursor
+		err := decodeCursor(cursor, sort, &c)
+		if err != nil {
+			return nil, err
+		}
+		after = &c.PostId
+	}
+	list, err := s.engine.FindPosts(ctx, filter, sort, limit+1, after)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.engine.CountPosts(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := &FoundPosts{
+		List:  []*FoundPost{},
+		More:  len(list) > limit,
+		Total: total,
+	}
 	for i := 0; i < len(list) && i < limit; i++ {
 		out.List = append(out.List, &FoundPost{
 			PostId: list[i].PostId,
 			Config: commentStateToConfig(list[i].State),
 		})
 	}
+	if out.More && len(out.List) > 0 {
+		out.Next, err = encodeCursor(sort, &postCursor{PostId: out.List[len(out.List)-1].PostId})
+		if err != nil {
+			return nil, err
+		}
+	}
 	return out, nil
 }
 
-func (s *commentsServiceImpl) BulkSetVisible(ctx context.Context, ids map[PostId][]*CommentId, visible bool) error {
-	return s.engine.BulkSetVisible(ctx, ids, visible)
+func (s *commentsServiceImpl) BulkSetVisible(ctx context.Context, actor string, ids map[PostId][]*CommentId, visible bool) error {
+	// Subscribers are only told about comments when they are approved.
+	return s.engine.BulkSetVisible(ctx, ids, visible, engine.Change{Actor: actor, When: time.Now()}, engine.Outbox{Subscribers: s.subscribers != nil})
 }
 
-func (s *commentsServiceImpl) parseComment(comment *engine.Comment) (*Comment, error) {
+func (s *commentsServiceImpl) parseComment(comment *engine.Comment, viewer IdentityId) (*Comment, error) {
 	html, err := s.renderer.Render(comment.Text)
 	if err != nil {
 		return nil, err
@@ -223,9 +469,24 @@ func (s *commentsServiceImpl) parseComment(comment *engine.Comment) (*Comment, e
 		When:    comment.When,
 		Text:    html,
 	}
+	if comment.Identity != nil {
+		cmt.Verified = true
+		cmt.Badge = s.badges[comment.Identity.Email]
+		if s.canEdit(comment, viewer) {
+			cmt.Editable = true
+			cmt.Source = comment.Text
+		}
+	}
 	return cmt, nil
 }
 
+func (s *commentsServiceImpl) canEdit(comment *engine.Comment, viewer IdentityId) bool {
+	if viewer == "" || comment.Identity == nil || comment.Identity.IdentityId != viewer {
+		return false
+	}
+	return time.Now().Before(comment.When.Add(s.editWindow))
+}
+
 func (s *commentsServiceImpl) Add(ctx context.Context, comment *NewComment) (*Comment, error) {
 	cfg, err := s.engine.GetConfig(ctx, comment.PostId)
 	if err != nil {
@@ -234,21 +495,233 @@ func (s *commentsServiceImpl) Add(ctx context.Context, comment *NewComment) (*Co
 	if cfg.State != engine.CommentsEnabled {
 		return nil, fmt.Errorf("comments are closed for post [%s]", comment.PostId)
 	}
+	author := comment.Author
+	if comment.IdentityId != "" {
+		identity, err := s.engine.GetIdentity(ctx, comment.IdentityId)
+		if err != nil {
+			return nil, err
+		}
+		author = identity.Name
+	}
 	nc, err := s.engine.Add(ctx, &engine.NewComment{
-		PostId:  comment.PostId,
-		Visible: s.defaultVisible,
-		Author:  comment.Author,
-		When:    comment.When,
-		Text:    comment.Text,
-	})
+		PostId:     comment.PostId,
+		Visible:    s.defaultVisible,
+		Author:     author,
+		When:       comment.When,
+		Text:       comment.Text,
+		IdentityId: comment.IdentityId,
+	}, engine.Outbox{AdminNotifiers: len(s.notifiers), Subscribers: s.subscribers != nil})
 	if err != nil {
 		return nil, err
 	}
-	err = s.notifications.Notify(nc)
+	return s.parseComment(nc, comment.IdentityId)
+}
+
+func (s *commentsServiceImpl) DeliverNotifications(ctx context.Context, limit int) (int, error) {
+	now := time.Now()
+	pending, err := s.engine.PendingNotifications(ctx, now, limit)
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range pending {
+		err := s.deliver(ctx, n)
+		if err == nil {
+			err = s.engine.RemoveNotification(ctx, n.NotificationId)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		log.Printf("error delivering notification [%s]: %s", n.NotificationId, err)
+		n.Attempts++
+		n.LastError = err.Error()
+		if n.Attempts >= s.maxAttempts {
+			n.Failed = true
+		} else {
+			n.NextAttempt = now.Add(s.backoff(n.Attempts))
+		}
+		err = s.engine.UpdateNotification(ctx, n)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
+}
+
+// backoff returns how long to wait before retrying after the given number of failed attempts.
+func (s *commentsServiceImpl) backoff(attempts int) time.Duration {
+	delay := s.retryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// deliver sends a queued notification. Subscriber notifications are silently dropped
+// if the recipient unsubscribed after they were queued.
+func (s *commentsServiceImpl) deliver(ctx context.Context, n *engine.Notification) error {
+	comment, err := s.engine.GetComment(ctx, n.PostId, n.CommentId)
+	if err != nil {
+		return err
+	}
+	switch n.Kind {
+	case engine.NotifyAdmin:
+		if n.Notifier < 0 || n.Notifier >= len(s.notifiers) {
+			return fmt.Errorf("unknown notifier %d", n.Notifier)
+		}
+		return s.notifiers[n.Notifier].Notify(comment)
+	case engine.NotifySubscriber:
+		if s.subscribers == nil {
+			return nil
+		}
+		subscriptions, err := s.engine.ListSubscriptions(ctx, n.PostId)
+		if err != nil {
+			return err
+		}
+		for _, sub := range subscriptions {
+			if sub.Identity.IdentityId == n.Recipient {
+				return s.subscribers.NotifySubscribers(comment, []*engine.Subscription{sub})
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown notification kind %d", n.Kind)
+}
+
+func (s *commentsServiceImpl) FindNotifications(ctx context.Context, filter NotificationFilter, limit int, start int) (*FoundNotifications, error) {
+	if start < 0 {
+		start = 0
+	}
+	list, err := s.engine.FindNotifications(ctx, filter, limit+1, start)
 	if err != nil {
-		log.Printf("error sending notification: %s", err)
+		return nil, err
+	}
+	last := limit
+	if last > len(list) {
+		last = len(list)
+	}
+	out := &FoundNotifications{
+		List: list[0:last],
+		More: len(list) > limit,
+	}
+	return out, nil
+}
+
+func (s *commentsServiceImpl) RetryNotifications(ctx context.Context, actor string, ids []NotificationId) error {
+	return s.engine.RetryNotifications(ctx, ids, engine.Change{Actor: actor, When: time.Now()})
+}
+
+func (s *commentsServiceImpl) DeleteNotifications(ctx context.Context, actor string, ids []NotificationId) error {
+	return s.engine.DeleteNotifications(ctx, ids, engine.Change{Actor: actor, When: time.Now()})
+}
+
+func (s *commentsServiceImpl) getOwnComment(ctx context.Context, viewer IdentityId, postId PostId, commentId CommentId) (*engine.Comment, error) {
+	comment, err := s.engine.GetComment(ctx, postId, commentId)
+	if err != nil {
+		return nil, err
+	}
+	if !s.canEdit(comment, viewer) {
+		return nil, fmt.Errorf("comment [%s/%s] cannot be modified by this user", postId, commentId)
+	}
+	return comment, nil
+}
+
+func (s *commentsServiceImpl) EditOwnComment(ctx context.Context, viewer IdentityId, postId PostId, commentId CommentId, text Markdown) (*Comment, error) {
+	comment, err := s.getOwnComment(ctx, viewer, postId, commentId)
+	if err != nil {
+		return nil, err
+	}
+	err = s.engine.EditComment(ctx, postId, commentId, text)
+	if err != nil {
+		return nil, err
+	}
+	comment.Text = text
+	return s.parseComment(comment, viewer)
+}
+
+func (s *commentsServiceImpl) DeleteOwnComment(ctx context.Context, viewer IdentityId, postId PostId, commentId CommentId) error {
+	_, err := s.getOwnComment(ctx, viewer, postId, commentId)
+	if err != nil {
+		return err
+	}
+	return s.engine.DeleteComments(ctx, map[PostId][]*CommentId{postId: {&commentId}}, engine.Change{Actor: "commenter:" + string(viewer), When: time.Now()})
+}
+
+func (s *commentsServiceImpl) SaveIdentity(ctx context.Context, email string, name string) (*Identity, error) {
+	identity, err := s.engine.SaveIdentity(ctx, strings.ToLower(email), name)
+	if err != nil {
+		return nil, err
+	}
+	return s.parseIdentity(identity), nil
+}
+
+func (s *commentsServiceImpl) GetIdentity(ctx context.Context, id IdentityId) (*Identity, error) {
+	identity, err := s.engine.GetIdentity(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.parseIdentity(identity), nil
+}
+
+func (s *commentsServiceImpl) Subscribe(ctx context.Context, viewer IdentityId, postId PostId, pageUri string, language string) error {
+	if s.subscribers == nil {
+		return fmt.Errorf("subscriptions are not enabled")
+	}
+	if viewer == "" {
+		return fmt.Errorf("only verified commenters can subscribe")
+	}
+	uri, err := url.Parse(pageUri)
+	if err != nil {
+		return err
+	}
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return fmt.Errorf("invalid page URI: %s", pageUri)
+	}
+	uri.Fragment = ""
+	cfg, err := s.engine.GetConfig(ctx, postId)
+	if err != nil {
+		return err
+	}
+	if cfg.State == engine.CommentsDisabled {
+		return fmt.Errorf("comments are disabled for post [%s]", postId)
+	}
+	return s.engine.Subscribe(ctx, &engine.NewSubscription{
+		PostId:     postId,
+		IdentityId: viewer,
+		PageUri:    uri.String(),
+		Language:   language,
+	})
+}
+
+func (s *commentsServiceImpl) Unsubscribe(ctx context.Context, viewer IdentityId, postId PostId) error {
+	if viewer == "" {
+		return fmt.Errorf("only verified commenters can unsubscribe")
+	}
+	return s.engine.Unsubscribe(ctx, postId, viewer)
+}
+
+func (s *commentsServiceImpl) isSubscribed(ctx context.Context, viewer IdentityId, postId PostId) (bool, error) {
+	subscriptions, err := s.engine.ListSubscriptions(ctx, postId)
+	if err != nil {
+		return false, err
+	}
+	for _, sub := range subscriptions {
+		if sub.Identity.IdentityId == viewer {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *commentsServiceImpl) parseIdentity(identity *engine.Identity) *Identity {
+	return &Identity{
+		Id:    identity.IdentityId,
+		Name:  identity.Name,
+		Badge: s.badges[identity.Email],
 	}
-	return s.parseComment(nc)
 }
 
 func (s *commentsServiceImpl) Render(ctx context.Context, text Markdown) (Html, error) {
@@ -263,7 +736,7 @@ func (s *commentsServiceImpl) SetAvailablePosts(ctx context.Context, posts *Avai
 	return s.engine.SetAllPostConfigs(ctx, cfg)
 }
 
-func (s *commentsServiceImpl) BulkUpdatePostConfigs(ctx context.Context, ids []PostId, config CommentConfig) error {
+func (s *commentsServiceImpl) BulkUpdatePostConfigs(ctx context.Context, actor string, ids []PostId, config CommentConfig) error {
 	cfg := &engine.BulkConfig{
 		Configs: []engine.Config{},
 	}
@@ -274,5 +747,5 @@ func (s *commentsServiceImpl) BulkUpdatePostConfigs(ctx context.Context, ids []P
 			State:  state,
 		})
 	}
-	return s.engine.BulkUpdatePostConfigs(ctx, cfg)
+	return s.engine.BulkUpdatePostConfigs(ctx, cfg, engine.Change{Actor: actor, When: time.Now()})
 }
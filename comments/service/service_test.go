@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/engine/sqlite3"
+)
+
+func newTestEngine(t *testing.T) engine.Engine {
+	schema, err := os.ReadFile("../engine/sqlite3/schema.sql")
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "comments.db")
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	_, err = db.Exec(string(schema))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+	e, err := sqlite3.NewSqlite3Engine(path)
+	assert.Nil(t, err)
+	assert.Nil(t, e.SetAllPostConfigs(context.Background(), &engine.BulkConfig{Configs: []engine.Config{
+		{PostId: "a", State: engine.CommentsEnabled},
+	}}))
+	return e
+}
+
+func saveIdentity(t *testing.T, s CommentsService, email string, name string) IdentityId {
+	identity, err := s.SaveIdentity(context.Background(), email, name)
+	assert.Nil(t, err)
+	return identity.Id
+}
+
+func addComment(t *testing.T, s CommentsService, identityId IdentityId, when time.Time) CommentId {
+	c, err := s.Add(context.Background(), &NewComment{PostId: "a", Author: "Someone", When: when, Text: "Hello", IdentityId: identityId})
+	assert.Nil(t, err)
+	return c.Id
+}
+
+func TestCanEditWithinWindow(t *testing.T) {
+	s := &commentsServiceImpl{editWindow: time.Hour}
+	own := &engine.Identity{IdentityId: "alice"}
+	now := time.Now()
+
+	assert.True(t, s.canEdit(&engine.Comment{Identity: own, When: now.Add(-59 * time.Minute)}, "alice"))
+	// The window ends exactly one edit window after the comment was posted.
+	assert.False(t, s.canEdit(&engine.Comment{Identity: own, When: now.Add(-time.Hour)}, "alice"))
+	assert.False(t, s.canEdit(&engine.Comment{Identity: own, When: now.Add(-2 * time.Hour)}, "alice"))
+	// Only the comment's author can edit it, and anonymous comments can't be edited.
+	assert.False(t, s.canEdit(&engine.Comment{Identity: own, When: now}, "bob"))
+	assert.False(t, s.canEdit(&engine.Comment{Identity: own, When: now}, ""))
+	assert.False(t, s.canEdit(&engine.Comment{When: now}, ""))
+
+	// Without an edit window, no comment can be edited.
+	s.editWindow = 0
+	assert.False(t, s.canEdit(&engine.Comment{Identity: own, When: now}, "alice"))
+}
+
+func TestEditOwnComment(t *testing.T) {
+	ctx := context.Background()
+	s := NewCommentsService(newTestEngine(t), WithEditWindow(time.Hour))
+	alice := saveIdentity(t, s, "alice@example.com", "Alice")
+	bob := saveIdentity(t, s, "bob@example.com", "Bob")
+	recent := addComment(t, s, alice, time.Now().Add(-50*time.Minute))
+	old := addComment(t, s, alice, time.Now().Add(-70*time.Minute))
+
+	edited, err := s.EditOwnComment(ctx, alice, "a", recent, "Goodbye")
+	assert.Nil(t, err)
+	assert.Equal(t, Markdown("Goodbye"), edited.Source)
+	assert.True(t, edited.Editable)
+	raw, err := s.GetComment(ctx, "a", recent)
+	assert.Nil(t, err)
+	assert.Equal(t, Markdown("Goodbye"), raw.Text)
+
+	// Nobody else can edit the comment, and it can't be edited once the window is over.
+	_, err = s.EditOwnComment(ctx, bob, "a", recent, "Hijacked")
+	assert.NotNil(t, err)
+	_, err = s.EditOwnComment(ctx, "", "a", recent, "Hijacked")
+	assert.NotNil(t, err)
+	_, err = s.EditOwnComment(ctx, alice, "a", old, "Too late")
+	assert.NotNil(t, err)
+	raw, err = s.GetComment(ctx, "a", recent)
+	assert.Nil(t, err)
+	assert.Equal(t, Markdown("Goodbye"), raw.Text)
+	raw, err = s.GetComment(ctx, "a", old)
+	assert.Nil(t, err)
+	assert.Equal(t, Markdown("Hello"), raw.Text)
+}
+
+func TestDeleteOwnComment(t *testing.T) {
+	ctx := context.Background()
+	s := NewCommentsService(newTestEngine(t), WithEditWindow(time.Hour))
+	alice := saveIdentity(t, s, "alice@example.com", "Alice")
+	bob := saveIdentity(t, s, "bob@example.com", "Bob")
+	recent := addComment(t, s, alice, time.Now().Add(-50*time.Minute))
+	old := addComment(t, s, alice, time.Now().Add(-70*time.Minute))
+
+	assert.NotNil(t, s.DeleteOwnComment(ctx, bob, "a", recent))
+	assert.NotNil(t, s.DeleteOwnComment(ctx, alice, "a", old))
+	assert.Nil(t, s.DeleteOwnComment(ctx, alice, "a", recent))
+
+	list, err := s.List(ctx, "a", false, alice, "")
+	assert.Nil(t, err)
+	assert.Len(t, list.List, 1)
+	assert.Equal(t, old, list.List[0].Id)
+	assert.False(t, list.List[0].Editable)
+}
+
+// fakeNotifier counts the notifications it is asked to deliver, and fails them if err is set.
+type fakeNotifier struct {
+	calls int
+	err   error
+}
+
+func (n *fakeNotifier) Notify(comment *engine.Comment) error {
+	n.calls++
+	return n.err
+}
+
+func TestBackoff(t *testing.T) {
+	s := &commentsServiceImpl{retryDelay: time.Minute}
+	assert.Equal(t, time.Minute, s.backoff(1))
+	assert.Equal(t, 2*time.Minute, s.backoff(2))
+	assert.Equal(t, 4*time.Minute, s.backoff(3))
+	assert.Equal(t, 512*time.Minute, s.backoff(10))
+	assert.Equal(t, maxRetryDelay, s.backoff(12))
+	assert.Equal(t, maxRetryDelay, s.backoff(1000))
+
+	s.retryDelay = 48 * time.Hour
+	assert.Equal(t, maxRetryDelay, s.backoff(1))
+}
+
+func TestDeliverNotificationsRetries(t *testing.T) {
+	ctx := context.Background()
+	e := newTestEngine(t)
+	working := &fakeNotifier{}
+	failing := &fakeNotifier{err: errors.New("server down")}
+	s := NewCommentsService(e, WithNotificationEngine(working, failing), WithNotificationRetries(3, time.Minute))
+	addComment(t, s, "", time.Now())
+
+	queued := func() []*Notification {
+		found, err := s.FindNotifications(ctx, NotificationFilter{}, 10, 0)
+		assert.Nil(t, err)
+		return found.List
+	}
+	// makeDue moves the next attempt of the queued notifications to the past, as if time had passed.
+	makeDue := func() {
+		for _, n := range queued() {
+			n.NextAttempt = time.Now().Add(-time.Second)
+			assert.Nil(t, e.UpdateNotification(ctx, n))
+		}
+	}
+
+	// Each notifier has its own notification, so the one that works doesn't deliver it again
+	// when the other one is retried.
+	for attempt := 1; attempt <= 2; attempt++ {
+		makeDue()
+		start := time.Now()
+		count, err := s.DeliverNotifications(ctx, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 3-attempt, count)
+		assert.Equal(t, 1, working.calls)
+		assert.Equal(t, attempt, failing.calls)
+
+		list := queued()
+		assert.Len(t, list, 1)
+		assert.Equal(t, 1, list[0].Notifier)
+		assert.Equal(t, attempt, list[0].Attempts)
+		assert.Equal(t, "server down", list[0].LastError)
+		assert.False(t, list[0].Failed)
+		assert.WithinDuration(t, start.Add(s.(*commentsServiceImpl).backoff(attempt)), list[0].NextAttempt, 2*time.Second)
+
+		// Nothing is delivered until the next attempt is due.
+		count, err = s.DeliverNotifications(ctx, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+	}
+
+	// The notification is given up after the maximum number of attempts.
+	makeDue()
+	count, err := s.DeliverNotifications(ctx, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	list := queued()
+	assert.Len(t, list, 1)
+	assert.Equal(t, 3, list[0].Attempts)
+	assert.True(t, list[0].Failed)
+
+	makeDue()
+	count, err = s.DeliverNotifications(ctx, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 3, failing.calls)
+}
@@ -1,26 +1,146 @@
 package web
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
 	"net/http"
+	"time"
 
 	auth "github.com/abbot/go-http-auth"
+	"golang.org/x/crypto/bcrypt"
+
+	"jacobo.tarrio.org/jtweb/tokens"
 )
 
 const adminUser = "admin"
+const sessionCookieName = "jtcomments_admin"
+const csrfCookieName = "jtcomments_csrf"
+const csrfHeaderName = "X-CSRF-Token"
+const sessionPurpose = "admin-session"
+
+// Role determines which administrative actions an account can perform.
+type Role int
+
+const (
+	RoleNone Role = iota
+	// Moderators can review comments and approve or hide them.
+	RoleModerator
+	// Admins can also delete and restore comments, change post configurations, and manage notifications.
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleModerator:
+		return "moderator"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// ParseRole returns the role with the given name.
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case "moderator":
+		return RoleModerator, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleNone, fmt.Errorf("invalid role: %s", name)
+	}
+}
+
+// Account is an administrative account that signs in with a password.
+type Account struct {
+	Name string
+	// A bcrypt hash of the account's password.
+	PasswordHash string
+	Role         Role
+}
 
+// HashPassword returns a bcrypt hash of the password, suitable for an Account.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPasswordHash verifies that the string is a valid bcrypt hash.
+func CheckPasswordHash(hash string) error {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err
+}
+
+// AdminChecker authenticates administrators, either with HTTP Digest authentication for the
+// legacy "admin" user, or with a session cookie after signing in with an account's password.
 type AdminChecker struct {
-	digest *auth.DigestAuth
+	digest     *auth.DigestAuth
+	accounts   map[string]*Account
+	signer     *tokens.Signer
+	sessionTtl time.Duration
+	// Compared against when an account doesn't exist, so that signing in takes the same time.
+	dummyHash []byte
+}
+
+type session struct {
+	Name string
+	Csrf string
 }
 
-func NewAdminChecker(secret string) *AdminChecker {
-	return &AdminChecker{
-		digest: auth.NewDigestAuthenticator("Comments admin", func(user, realm string) string {
+type AdminCheckerOption func(*AdminChecker)
+
+// WithDigestPassword lets the "admin" user authenticate with HTTP Digest authentication.
+// The secret is the MD5 hash of "admin:Comments admin:password".
+func WithDigestPassword(secret string) AdminCheckerOption {
+	return func(c *AdminChecker) {
+		c.digest = auth.NewDigestAuthenticator("Comments admin", func(user, realm string) string {
 			if user == adminUser {
 				return secret
 			}
 			return ""
-		}),
+		})
+	}
+}
+
+// WithAccounts lets the given accounts sign in with their passwords, using session cookies signed with the given key.
+func WithAccounts(key string, accounts []Account) AdminCheckerOption {
+	return func(c *AdminChecker) {
+		c.signer = tokens.NewSigner(key)
+		for _, account := range accounts {
+			account := account
+			c.accounts[account.Name] = &account
+		}
+	}
+}
+
+// SessionTtl sets for how long an administrator stays signed in.
+func SessionTtl(ttl time.Duration) AdminCheckerOption {
+	return func(c *AdminChecker) {
+		c.sessionTtl = ttl
+	}
+}
+
+func NewAdminChecker(options ...AdminCheckerOption) *AdminChecker {
+	c := &AdminChecker{
+		accounts:   map[string]*Account{},
+		sessionTtl: 12 * time.Hour,
+	}
+	for _, option := range options {
+		option(c)
 	}
+	c.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return c
+}
+
+// HasAccounts returns whether administrators can sign in with an account and password.
+func (c *AdminChecker) HasAccounts() bool {
+	return c.signer != nil && len(c.accounts) > 0
 }
 
 func (c *AdminChecker) RequiringAdmin(handler http.HandlerFunc) http.HandlerFunc {
@@ -32,14 +152,129 @@ func (c *AdminChecker) RequiringAdmin(handler http.HandlerFunc) http.HandlerFunc
 }
 
 func (c *AdminChecker) HasAdmin(req *http.Request) bool {
-	user, _ := c.digest.CheckAuth(req)
-	return user == adminUser
+	return c.Admin(req) != nil
+}
+
+// Admin returns the account that made the request, or nil if it was not made by an administrator.
+// Requests that are authenticated with a session cookie must carry the CSRF token unless they are GET or HEAD requests.
+func (c *AdminChecker) Admin(req *http.Request) *Account {
+	if account := c.sessionAccount(req); account != nil {
+		return account
+	}
+	if c.digest != nil {
+		user, _ := c.digest.CheckAuth(req)
+		if user == adminUser {
+			return &Account{Name: adminUser, Role: RoleAdmin}
+		}
+	}
+	return nil
 }
 
+type accountKey struct{}
+
+func withAccount(ctx context.Context, account *Account) context.Context {
+	return context.WithValue(ctx, accountKey{}, account)
+}
+
+// requestAccount returns the administrator account that made the request, as found when it was
+// received, or nil if it was not made by an administrator.
+func requestAccount(req *http.Request) *Account {
+	account, _ := req.Context().Value(accountKey{}).(*Account)
+	return account
+}
+
+// RequireAdmin returns true if the request was made by an administrator. Otherwise, it sends the
+// user to the sign-in page or asks for Digest authentication, and returns false.
 func (c *AdminChecker) RequireAdmin(rw http.ResponseWriter, req *http.Request) bool {
 	if c.HasAdmin(req) {
 		return true
 	}
-	c.digest.RequireAuth(rw, req)
+	if c.HasAccounts() {
+		redirect("login.html", rw)
+		return false
+	}
+	if c.digest != nil {
+		c.digest.RequireAuth(rw, req)
+		return false
+	}
+	http.Error(rw, "Forbidden", http.StatusForbidden)
 	return false
 }
+
+func (c *AdminChecker) sessionAccount(req *http.Request) *Account {
+	if c.signer == nil {
+		return nil
+	}
+	cookie, err := req.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+	var s session
+	if c.signer.Verify(sessionPurpose, cookie.Value, &s) != nil {
+		return nil
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead && req.Header.Get(csrfHeaderName) != s.Csrf {
+		return nil
+	}
+	// Looking the account up again means that removing it from the configuration signs it out.
+	return c.accounts[s.Name]
+}
+
+// SignIn checks an account's password and, if it is correct, adds the session cookies to the response.
+func (c *AdminChecker) SignIn(rw http.ResponseWriter, name string, password string) error {
+	if !c.HasAccounts() {
+		return fmt.Errorf("accounts are not enabled")
+	}
+	account, ok := c.accounts[name]
+	if !ok {
+		bcrypt.CompareHashAndPassword(c.dummyHash, []byte(password))
+		return fmt.Errorf("invalid name or password")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)) != nil {
+		return fmt.Errorf("invalid name or password")
+	}
+	csrf := make([]byte, 16)
+	if _, err := rand.Read(csrf); err != nil {
+		return err
+	}
+	s := session{Name: account.Name, Csrf: base64.RawURLEncoding.EncodeToString(csrf)}
+	expires := time.Now().Add(c.sessionTtl)
+	token, err := c.signer.Sign(sessionPurpose, &s, expires)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// The admin page reads this cookie and sends its value in a header with every request.
+	http.SetCookie(rw, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    s.Csrf,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// SignOut removes the session cookies.
+func (c *AdminChecker) SignOut(rw http.ResponseWriter) {
+	for _, name := range []string{sessionCookieName, csrfCookieName} {
+		http.SetCookie(rw, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			Secure:   true,
+			HttpOnly: name == sessionCookieName,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
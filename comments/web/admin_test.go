@@ -0,0 +1,127 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestChecker(t *testing.T) *AdminChecker {
+	hash, err := HashPassword("secret")
+	assert.Nil(t, err)
+	return NewAdminChecker(WithAccounts("key", []Account{
+		{Name: "alice", PasswordHash: hash, Role: RoleAdmin},
+		{Name: "bob", PasswordHash: hash, Role: RoleModerator},
+	}))
+}
+
+// signIn returns the cookies set after signing in.
+func signIn(t *testing.T, c *AdminChecker, name string, password string) []*http.Cookie {
+	rec := httptest.NewRecorder()
+	err := c.SignIn(rec, name, password)
+	if err != nil {
+		return nil
+	}
+	return rec.Result().Cookies()
+}
+
+func requestWithCookies(method string, cookies []*http.Cookie, csrf bool) *http.Request {
+	req := httptest.NewRequest(method, "/_/findComments", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+		if csrf && cookie.Name == csrfCookieName {
+			req.Header.Set(csrfHeaderName, cookie.Value)
+		}
+	}
+	return req
+}
+
+func TestSignIn(t *testing.T) {
+	c := newTestChecker(t)
+	assert.Nil(t, signIn(t, c, "alice", "wrong"))
+	assert.Nil(t, signIn(t, c, "carol", "secret"))
+
+	cookies := signIn(t, c, "alice", "secret")
+	assert.Len(t, cookies, 2)
+	account := c.Admin(requestWithCookies(http.MethodPost, cookies, true))
+	assert.NotNil(t, account)
+	assert.Equal(t, "alice", account.Name)
+	assert.Equal(t, RoleAdmin, account.Role)
+}
+
+func TestSessionRequiresCsrfToken(t *testing.T) {
+	c := newTestChecker(t)
+	cookies := signIn(t, c, "alice", "secret")
+	assert.Nil(t, c.Admin(requestWithCookies(http.MethodPost, cookies, false)))
+	assert.NotNil(t, c.Admin(requestWithCookies(http.MethodGet, cookies, false)))
+
+	req := requestWithCookies(http.MethodPost, cookies, false)
+	req.Header.Set(csrfHeaderName, "forged")
+	assert.Nil(t, c.Admin(req))
+}
+
+func TestSessionFromOtherKey(t *testing.T) {
+	cookies := signIn(t, newTestChecker(t), "alice", "secret")
+	hash, err := HashPassword("secret")
+	assert.Nil(t, err)
+	other := NewAdminChecker(WithAccounts("other key", []Account{{Name: "alice", PasswordHash: hash, Role: RoleAdmin}}))
+	assert.Nil(t, other.Admin(requestWithCookies(http.MethodPost, cookies, true)))
+}
+
+func TestRoles(t *testing.T) {
+	c := newTestChecker(t)
+	called := ""
+	caller := ""
+	s := &webService{
+		adminChecker: c,
+		handlers: map[handlerPath]http.HandlerFunc{
+			moderatorPost("/find"): func(rw http.ResponseWriter, req *http.Request) {
+				called = "find"
+				caller = requestAccount(req).Name
+			},
+			adminPost("/delete"): func(rw http.ResponseWriter, req *http.Request) {
+				called = "delete"
+				caller = requestAccount(req).Name
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"alice", "/find", http.StatusOK},
+		{"alice", "/delete", http.StatusOK},
+		{"bob", "/find", http.StatusOK},
+		{"bob", "/delete", http.StatusForbidden},
+		{"", "/find", http.StatusForbidden},
+	} {
+		called = ""
+		caller = ""
+		var cookies []*http.Cookie
+		if tc.name != "" {
+			cookies = signIn(t, c, tc.name, "secret")
+		}
+		req := requestWithCookies(http.MethodPost, cookies, true)
+		req.URL.Path = tc.path
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, tc.expected, rec.Code, "%s %s", tc.name, tc.path)
+		if tc.expected == http.StatusOK {
+			assert.Equal(t, tc.path[1:], called)
+			assert.Equal(t, tc.name, caller)
+		} else {
+			assert.Equal(t, "", called)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("moderator")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleModerator, role)
+	_, err = ParseRole("owner")
+	assert.NotNil(t, err)
+}
@@ -1,68 +1,339 @@
 package web
 
 import (
-	"context"
+	"log"
 	"net/http"
 	"time"
 
 	"golang.org/x/time/rate"
 
 	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/comments/identity"
+	"jacobo.tarrio.org/jtweb/comments/moderation"
+	"jacobo.tarrio.org/jtweb/comments/notification/subscribers"
 	"jacobo.tarrio.org/jtweb/comments/service"
+	"jacobo.tarrio.org/jtweb/metrics"
+	"jacobo.tarrio.org/jtweb/ratelimit"
 )
 
 type apiService struct {
 	webService
 	service       service.CommentsService
+	identities    *identity.Manager
+	subscriptions *subscribers.Notifier
 	renderLimiter *rate.Limiter
+	verifyLimiter *ratelimit.Limiter
+	// Limits the verification emails sent to each address, whoever asks for them.
+	verifyEmailLimiter *ratelimit.Limiter
+	signInLimiter      *ratelimit.Limiter
+	reactLimiter       *ratelimit.Limiter
+	// Reverse proxies whose X-Forwarded-For headers identify the clients for the rate limits.
+	proxies *ratelimit.Proxies
+	// Signed links to moderate comments from notification emails; nil if they are disabled.
+	moderationLinks *moderation.Linker
 }
 
-func Serve(service service.CommentsService, adminChecker *AdminChecker) http.Handler {
+type ServeOption func(*apiService)
+
+// WithIdentities enables verified commenter identities.
+func WithIdentities(identities *identity.Manager) ServeOption {
+	return func(s *apiService) {
+		s.identities = identities
+	}
+}
+
+// WithSubscriptions lets verified commenters subscribe to new comments on posts.
+func WithSubscriptions(subscriptions *subscribers.Notifier) ServeOption {
+	return func(s *apiService) {
+		s.subscriptions = subscriptions
+	}
+}
+
+// WithTrustedProxies identifies the clients behind the given reverse proxies by their X-Forwarded-For headers.
+func WithTrustedProxies(proxies *ratelimit.Proxies) ServeOption {
+	return func(s *apiService) {
+		s.proxies = proxies
+	}
+}
+
+func Serve(service service.CommentsService, adminChecker *AdminChecker, options ...ServeOption) http.Handler {
 	out := &apiService{
-		service:       service,
-		renderLimiter: rate.NewLimiter(1, 10),
+		service:            service,
+		renderLimiter:      rate.NewLimiter(1, 10),
+		verifyLimiter:      ratelimit.NewLimiter(0.1, 5),
+		verifyEmailLimiter: ratelimit.NewLimiter(rate.Every(10*time.Minute), 3),
+		signInLimiter:      ratelimit.NewLimiter(0.1, 5),
+		reactLimiter:       ratelimit.NewLimiter(2, 20),
+	}
+	out.metrics = newServerMetrics(metrics.NewRegistry())
+	for _, option := range options {
+		option(out)
 	}
 	out.adminChecker = adminChecker
 	out.handlers = map[handlerPath]http.HandlerFunc{
 		userPost("/list"):   out.list,
 		userPost("/add"):    out.add,
 		userPost("/render"): out.render,
+		userPost("/react"):  out.react,
+		userPost("/counts"): out.counts,
+
+		moderatorPost("/whoami"):            out.whoami,
+		moderatorPost("/findComments"):      out.findComments,
+		moderatorPost("/findPosts"):         out.findPosts,
+		moderatorPost("/bulkSetVisible"):    out.bulkSetVisible,
+		moderatorPost("/findNotifications"): out.findNotifications,
+		moderatorPost("/findAuditEntries"):  out.findAuditEntries,
 
-		adminPost("/findComments"):          out.findComments,
 		adminPost("/deleteComments"):        out.deleteComments,
-		adminPost("/findPosts"):             out.findPosts,
-		adminPost("/bulkSetVisible"):        out.bulkSetVisible,
+		adminPost("/restoreComments"):       out.restoreComments,
 		adminPost("/bulkUpdatePostConfigs"): out.bulkUpdatePostConfigs,
+		adminPost("/retryNotifications"):    out.retryNotifications,
+		adminPost("/deleteNotifications"):   out.deleteNotifications,
+	}
+	if out.moderationLinks != nil {
+		out.handlers[userGet("/moderate")] = out.confirmModeration
+		out.handlers[userPost("/moderate")] = out.moderate
+	}
+	if adminChecker.HasAccounts() {
+		out.handlers[userPost("/signIn")] = out.signIn
+		out.handlers[userPost("/signOutAdmin")] = out.signOutAdmin
+	}
+	if out.identities != nil {
+		out.handlers[userPost("/identity")] = out.getIdentity
+		out.handlers[userPost("/requestVerification")] = out.requestVerification
+		out.handlers[userGet("/verify")] = out.verify
+		out.handlers[userPost("/signOut")] = out.signOut
+		out.handlers[userPost("/editOwn")] = out.editOwn
+		out.handlers[userPost("/deleteOwn")] = out.deleteOwn
+		if out.subscriptions != nil {
+			out.handlers[userPost("/subscribe")] = out.subscribe
+			out.handlers[userPost("/unsubscribe")] = out.unsubscribe
+			out.handlers[userGet("/unsubscribe")] = out.unsubscribeFromLink
+		}
 	}
 	return out
 }
 
+// limitRate applies a rate limit to the request, and counts it if it is rejected.
+func (s *apiService) limitRate(name string, limiter *rate.Limiter, rw http.ResponseWriter) error {
+	err := limitRate(limiter, rw)
+	if err != nil {
+		s.metrics.rateLimited.Inc(name)
+	}
+	return err
+}
+
+func (s *apiService) viewer(req *http.Request) comments.IdentityId {
+	if s.identities == nil {
+		return ""
+	}
+	return s.identities.GetIdentityId(req)
+}
+
 func (s *apiService) list(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
+	ctx := req.Context()
 	var params struct {
 		PostId service.PostId
+		// Identifies the reactions given by an anonymous reader.
+		Client string
 	}
 	if input(req, &params, rw) != nil {
 		return
 	}
-	list, err := s.service.List(ctx, params.PostId, false)
+	list, err := s.service.List(ctx, params.PostId, false, s.viewer(req), params.Client)
 	output(list, err, rw)
 }
 
+func (s *apiService) counts(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		PostIds []service.PostId
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	counts, err := s.service.CountComments(ctx, params.PostIds)
+	output(counts, err, rw)
+}
+
 func (s *apiService) add(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
+	ctx := req.Context()
 	var newComment service.NewComment
 	if input(req, &newComment, rw) != nil {
 		return
 	}
 	newComment.When = time.Now()
+	newComment.IdentityId = s.viewer(req)
 	comment, err := s.service.Add(ctx, &newComment)
+	if err != nil {
+		s.metrics.comments.Inc("failed")
+	} else if comment.Visible {
+		s.metrics.comments.Inc("published")
+	} else {
+		s.metrics.comments.Inc("draft")
+	}
 	output(comment, err, rw)
 }
 
+func (s *apiService) react(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var reaction service.NewReaction
+	if input(req, &reaction, rw) != nil {
+		return
+	}
+	reaction.IdentityId = s.viewer(req)
+	if s.limitRate("react", s.reactLimiter.Get(s.reactionLimitKey(req, &reaction)), rw) != nil {
+		return
+	}
+	err := s.service.React(ctx, &reaction)
+	output("Success", err, rw)
+}
+
+// reactionLimitKey identifies the reader for the reaction rate limit: verified readers by their identity, and
+// other readers by their address. The Client token is chosen by the reader, so it is never used.
+func (s *apiService) reactionLimitKey(req *http.Request, reaction *service.NewReaction) string {
+	if reaction.IdentityId != "" {
+		return "identity:" + string(reaction.IdentityId)
+	}
+	return "address:" + s.proxies.ClientAddress(req)
+}
+
+func (s *apiService) getIdentity(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	viewer := s.viewer(req)
+	if viewer == "" {
+		output(nil, nil, rw)
+		return
+	}
+	identity, err := s.service.GetIdentity(ctx, viewer)
+	if err != nil {
+		// The identity might have been removed from the database.
+		s.identities.ClearCookie(rw)
+		output(nil, nil, rw)
+		return
+	}
+	output(identity, nil, rw)
+}
+
+func (s *apiService) requestVerification(rw http.ResponseWriter, req *http.Request) {
+	var params struct {
+		Email     string
+		Name      string
+		ReturnUri string
+		Language  string
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	if s.limitRate("verify", s.verifyLimiter.Get(s.proxies.ClientAddress(req)), rw) != nil {
+		return
+	}
+	email, err := identity.NormalizeEmail(params.Email)
+	if err != nil {
+		badRequest(err.Error(), rw)
+		return
+	}
+	if s.limitRate("verify-email", s.verifyEmailLimiter.Get(email), rw) != nil {
+		return
+	}
+	err = s.identities.SendVerification(params.Email, params.Name, params.ReturnUri, params.Language)
+	output("Success", err, rw)
+}
+
+func (s *apiService) verify(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	verification, err := s.identities.ParseVerification(req.URL.Query().Get("token"))
+	if err != nil {
+		badRequest(err.Error(), rw)
+		return
+	}
+	identity, err := s.service.SaveIdentity(ctx, verification.Email, verification.Name)
+	if err == nil {
+		err = s.identities.SetCookie(rw, identity.Id)
+	}
+	if err != nil {
+		output(nil, err, rw)
+		return
+	}
+	http.Redirect(rw, req, verification.ReturnUri, http.StatusSeeOther)
+}
+
+func (s *apiService) signOut(rw http.ResponseWriter, req *http.Request) {
+	s.identities.ClearCookie(rw)
+	output("Success", nil, rw)
+}
+
+func (s *apiService) editOwn(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		PostId    service.PostId
+		CommentId service.CommentId
+		Text      service.Markdown
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	comment, err := s.service.EditOwnComment(ctx, s.viewer(req), params.PostId, params.CommentId, params.Text)
+	output(comment, err, rw)
+}
+
+func (s *apiService) deleteOwn(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		PostId    service.PostId
+		CommentId service.CommentId
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	err := s.service.DeleteOwnComment(ctx, s.viewer(req), params.PostId, params.CommentId)
+	output("Success", err, rw)
+}
+
+func (s *apiService) subscribe(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		PostId   service.PostId
+		PageUri  string
+		Language string
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	err := s.service.Subscribe(ctx, s.viewer(req), params.PostId, params.PageUri, params.Language)
+	output("Success", err, rw)
+}
+
+func (s *apiService) unsubscribe(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		PostId service.PostId
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	err := s.service.Unsubscribe(ctx, s.viewer(req), params.PostId)
+	output("Success", err, rw)
+}
+
+func (s *apiService) unsubscribeFromLink(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	unsubscription, err := s.subscriptions.ParseUnsubscription(req.URL.Query().Get("token"))
+	if err != nil {
+		badRequest(err.Error(), rw)
+		return
+	}
+	err = s.service.Unsubscribe(ctx, unsubscription.IdentityId, unsubscription.PostId)
+	if err != nil {
+		output(nil, err, rw)
+		return
+	}
+	htmlPage(unsubscription.Language, subscribers.UnsubscribedMessage(unsubscription.Language), rw)
+}
+
 func (s *apiService) render(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
-	if limitRate(s.renderLimiter, rw) != nil {
+	ctx := req.Context()
+	if s.limitRate("render", s.renderLimiter, rw) != nil {
 		return
 	}
 	var inputData struct{ Text comments.Markdown }
@@ -73,50 +344,94 @@ func (s *apiService) render(rw http.ResponseWriter, req *http.Request) {
 	output(struct{ Text comments.Html }{Text: outputData}, err, rw)
 }
 
+func (s *apiService) whoami(rw http.ResponseWriter, req *http.Request) {
+	account := requestAccount(req)
+	if account == nil {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return
+	}
+	output(struct {
+		Name string
+		Role string
+	}{Name: account.Name, Role: account.Role.String()}, nil, rw)
+}
+
+// signIn receives the administrator sign-in form and redirects to the admin page on success.
+func (s *apiService) signIn(rw http.ResponseWriter, req *http.Request) {
+	// Limited by address and not by account, so that nobody can lock an administrator out by guessing their password.
+	if s.limitRate("sign-in", s.signInLimiter.Get(s.proxies.ClientAddress(req)), rw) != nil {
+		return
+	}
+	err := s.adminChecker.SignIn(rw, req.PostFormValue("name"), req.PostFormValue("password"))
+	if err != nil {
+		log.Printf("Failed administrator sign-in for %q: %s", req.PostFormValue("name"), err)
+		redirect("../login.html#failed", rw)
+		return
+	}
+	redirect("../admin.html", rw)
+}
+
+func (s *apiService) signOutAdmin(rw http.ResponseWriter, req *http.Request) {
+	s.adminChecker.SignOut(rw)
+	output("Success", nil, rw)
+}
+
 func (s *apiService) findComments(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
+	ctx := req.Context()
 	var params struct {
 		Filter service.CommentFilter
 		Sort   service.Sort
 		Limit  int
-		Start  int
+		Cursor string
 	}
 	if input(req, &params, rw) != nil {
 		return
 	}
-	result, err := s.service.FindComments(ctx, params.Filter, params.Sort, params.Limit, params.Start)
+	result, err := s.service.FindComments(ctx, params.Filter, params.Sort, params.Limit, params.Cursor)
 	output(result, err, rw)
 }
 
 func (s *apiService) deleteComments(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
+	ctx := req.Context()
 	var params struct {
 		Ids map[service.PostId][]*service.CommentId
 	}
 	if input(req, &params, rw) != nil {
 		return
 	}
-	err := s.service.DeleteComments(ctx, params.Ids)
+	err := s.service.DeleteComments(ctx, s.adminName(req), params.Ids)
+	output("Success", err, rw)
+}
+
+func (s *apiService) restoreComments(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		Ids map[service.PostId][]*service.CommentId
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	err := s.service.RestoreComments(ctx, s.adminName(req), params.Ids)
 	output("Success", err, rw)
 }
 
 func (s *apiService) findPosts(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
+	ctx := req.Context()
 	var params struct {
 		Filter service.PostFilter
 		Sort   service.Sort
 		Limit  int
-		Start  int
+		Cursor string
 	}
 	if input(req, &params, rw) != nil {
 		return
 	}
-	result, err := s.service.FindPosts(ctx, params.Filter, params.Sort, params.Limit, params.Start)
+	result, err := s.service.FindPosts(ctx, params.Filter, params.Sort, params.Limit, params.Cursor)
 	output(result, err, rw)
 }
 
 func (s *apiService) bulkSetVisible(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
+	ctx := req.Context()
 	var params struct {
 		Ids     map[service.PostId][]*service.CommentId
 		Visible bool
@@ -124,12 +439,12 @@ func (s *apiService) bulkSetVisible(rw http.ResponseWriter, req *http.Request) {
 	if input(req, &params, rw) != nil {
 		return
 	}
-	err := s.service.BulkSetVisible(ctx, params.Ids, params.Visible)
+	err := s.service.BulkSetVisible(ctx, s.adminName(req), params.Ids, params.Visible)
 	output("Success", err, rw)
 }
 
 func (s *apiService) bulkUpdatePostConfigs(rw http.ResponseWriter, req *http.Request) {
-	ctx := context.Background()
+	ctx := req.Context()
 	var params struct {
 		PostIds []service.PostId
 		Config  service.CommentConfig
@@ -137,6 +452,58 @@ func (s *apiService) bulkUpdatePostConfigs(rw http.ResponseWriter, req *http.Req
 	if input(req, &params, rw) != nil {
 		return
 	}
-	err := s.service.BulkUpdatePostConfigs(ctx, params.PostIds, params.Config)
+	err := s.service.BulkUpdatePostConfigs(ctx, s.adminName(req), params.PostIds, params.Config)
+	output("Success", err, rw)
+}
+
+func (s *apiService) findAuditEntries(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		Filter service.AuditFilter
+		Limit  int
+		Cursor string
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	result, err := s.service.FindAuditEntries(ctx, params.Filter, params.Limit, params.Cursor)
+	output(result, err, rw)
+}
+
+func (s *apiService) findNotifications(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		Filter service.NotificationFilter
+		Limit  int
+		Start  int
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	result, err := s.service.FindNotifications(ctx, params.Filter, params.Limit, params.Start)
+	output(result, err, rw)
+}
+
+func (s *apiService) retryNotifications(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		Ids []service.NotificationId
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	err := s.service.RetryNotifications(ctx, s.adminName(req), params.Ids)
+	output("Success", err, rw)
+}
+
+func (s *apiService) deleteNotifications(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var params struct {
+		Ids []service.NotificationId
+	}
+	if input(req, &params, rw) != nil {
+		return
+	}
+	err := s.service.DeleteNotifications(ctx, s.adminName(req), params.Ids)
 	output("Success", err, rw)
 }
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/comments/identity"
+	"jacobo.tarrio.org/jtweb/comments/service"
+	"jacobo.tarrio.org/jtweb/ratelimit"
+)
+
+// fakeService records the reactions it receives; the other operations are not implemented.
+type fakeService struct {
+	service.CommentsService
+	reactions []*service.NewReaction
+}
+
+func (s *fakeService) React(ctx context.Context, reaction *service.NewReaction) error {
+	s.reactions = append(s.reactions, reaction)
+	return nil
+}
+
+// postJson sends a request from remoteAddr through a proxy that forwards it for the given address, if not empty.
+func postJson(h http.Handler, path string, remoteAddr string, forwardedFor string, body string) int {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestReactionLimitIgnoresClient(t *testing.T) {
+	proxies, err := ratelimit.ParseProxies("127.0.0.1")
+	assert.Nil(t, err)
+	svc := &fakeService{}
+	h := Serve(svc, newTestChecker(t), WithTrustedProxies(proxies))
+	react := func(remoteAddr string, forwardedFor string, client string) int {
+		return postJson(h, "/react", remoteAddr, forwardedFor, `{"PostId":"p","Reaction":"like","Add":true,"Client":"`+client+`"}`)
+	}
+
+	// A reader that changes their client token every time still runs out of reactions.
+	limited := false
+	for i := 0; i < 30 && !limited; i++ {
+		limited = react("127.0.0.1:1234", "198.51.100.1", strings.Repeat("x", i+1)) == http.StatusTooManyRequests
+	}
+	assert.True(t, limited)
+	assert.Less(t, len(svc.reactions), 30)
+	// Other readers behind the same proxy, or connecting directly, are not affected.
+	assert.Equal(t, http.StatusOK, react("127.0.0.1:1234", "198.51.100.2", "x"))
+	assert.Equal(t, http.StatusOK, react("192.0.2.1:1234", "", "x"))
+	// Untrusted peers can't pick their address.
+	for i := 0; i < 30; i++ {
+		react("192.0.2.2:1234", "", "y")
+	}
+	assert.Equal(t, http.StatusTooManyRequests, react("192.0.2.2:1234", "198.51.100.3", "y"))
+}
+
+type fakeMailer struct {
+	sent []string
+}
+
+func (m *fakeMailer) SendMail(to string, subject string, body string) error {
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+func TestVerificationLimit(t *testing.T) {
+	mailer := &fakeMailer{}
+	identities := identity.NewManager("key", mailer, "https://comments.example.com/_/verify", identity.ReturnTo("https://example.com/"))
+	h := Serve(&fakeService{}, newTestChecker(t), WithIdentities(identities))
+	request := func(remoteAddr string, email string) int {
+		return postJson(h, "/requestVerification", remoteAddr, "", `{"Email":"`+email+`","Name":"A","ReturnUri":"https://example.com/post.html"}`)
+	}
+
+	// Nobody can send too many verification emails to the same address, even from several clients.
+	assert.Equal(t, http.StatusOK, request("192.0.2.1:1234", "victim@example.com"))
+	assert.Equal(t, http.StatusOK, request("192.0.2.2:1234", "Victim@example.com"))
+	assert.Equal(t, http.StatusOK, request("192.0.2.3:1234", "victim@example.com"))
+	assert.Equal(t, http.StatusTooManyRequests, request("192.0.2.4:1234", "victim@EXAMPLE.com"))
+	assert.Len(t, mailer.sent, 3)
+
+	// A client that asks for too many verification emails is stopped.
+	limited := false
+	for i := 0; i < 10 && !limited; i++ {
+		limited = request("192.0.2.5:1234", fmt.Sprintf("reader%d@example.com", i)) == http.StatusTooManyRequests
+	}
+	assert.True(t, limited)
+	// Other clients can still ask for theirs.
+	assert.Equal(t, http.StatusOK, request("192.0.2.6:1234", "reader@example.com"))
+}
+
+func TestSignInLimitIsPerClient(t *testing.T) {
+	h := Serve(&fakeService{}, newTestChecker(t))
+	signIn := func(remoteAddr string, password string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/signIn", strings.NewReader(url.Values{"name": {"alice"}, "password": {password}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	for i := 0; i < 10; i++ {
+		signIn("192.0.2.1:1234", "wrong")
+	}
+	// The client that made too many attempts can't sign in even with the right password.
+	assert.Equal(t, http.StatusTooManyRequests, signIn("192.0.2.1:1234", "secret").StatusCode)
+	// Other clients can still sign in.
+	res := signIn("192.0.2.2:1234", "secret")
+	assert.Equal(t, http.StatusSeeOther, res.StatusCode)
+	assert.Equal(t, "../admin.html", res.Header.Get("Location"))
+}
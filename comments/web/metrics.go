@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/metrics"
+)
+
+// serverMetrics are the metrics reported by the comments API.
+type serverMetrics struct {
+	requests    *metrics.Counter
+	latency     *metrics.Histogram
+	rateLimited *metrics.Counter
+	comments    *metrics.Counter
+}
+
+func newServerMetrics(registry *metrics.Registry) *serverMetrics {
+	return &serverMetrics{
+		requests:    registry.Counter("jtcomments_requests_total", "Requests to the comments API.", "handler", "method", "code"),
+		latency:     registry.Histogram("jtcomments_request_duration_seconds", "Time taken to serve requests to the comments API.", metrics.DefaultBuckets, "handler"),
+		rateLimited: registry.Counter("jtcomments_rate_limited_total", "Requests rejected because of rate limits.", "limiter"),
+		comments:    registry.Counter("jtcomments_comments_submitted_total", "Comments submitted, by outcome.", "outcome"),
+	}
+}
+
+// WithMetrics reports the API's request counts, latencies, rate limit rejections and comment submissions to the registry.
+func WithMetrics(registry *metrics.Registry) ServeOption {
+	return func(s *apiService) {
+		s.metrics = newServerMetrics(registry)
+	}
+}
+
+func (m *serverMetrics) observe(handler string, method string, code int, start time.Time) {
+	m.requests.Inc(handler, method, strconv.Itoa(code))
+	m.latency.Observe(time.Since(start).Seconds(), handler)
+}
+
+// statusRecorder remembers the status code sent in a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
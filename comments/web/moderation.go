@@ -0,0 +1,95 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"jacobo.tarrio.org/jtweb/comments/moderation"
+	"jacobo.tarrio.org/jtweb/comments/service"
+)
+
+// The actor recorded in the audit log for actions taken through moderation links.
+const moderationLinkActor = "moderation-link"
+
+var moderationLabels = map[moderation.Action]string{
+	moderation.ActionApprove: "Approve",
+	moderation.ActionReject:  "Reject",
+	moderation.ActionDelete:  "Delete",
+}
+
+var moderationResults = map[moderation.Action]string{
+	moderation.ActionApprove: "The comment was approved.",
+	moderation.ActionReject:  "The comment was rejected.",
+	moderation.ActionDelete:  "The comment was deleted.",
+}
+
+// Links in emails may be opened by scanners and previews, so the link shows a confirmation
+// page and the action only happens when its form is submitted.
+var moderationPageTemplate = template.Must(template.New("moderation").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Comment moderation</title></head>
+<body>
+<h1>{{.Label}} this comment?</h1>
+<p>By {{.Comment.Author}} on {{.Comment.PostId}}, {{.Comment.When.Format "2006-01-02 15:04 MST"}}{{if .Comment.Visible}} (visible){{else}} (not visible){{end}}</p>
+<pre style="white-space: pre-wrap">{{.Comment.Text}}</pre>
+<form method="post" action="moderate">
+<input type="hidden" name="token" value="{{.Token}}">
+<input type="submit" value="{{.Label}}">
+</form>
+</body>
+</html>
+`))
+
+// WithModerationLinks enables the signed links to moderate comments from notification emails.
+func WithModerationLinks(links *moderation.Linker) ServeOption {
+	return func(s *apiService) {
+		s.moderationLinks = links
+	}
+}
+
+func (s *apiService) confirmModeration(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	token := req.URL.Query().Get("token")
+	link, err := s.moderationLinks.Parse(token)
+	if err != nil {
+		badRequest(err.Error(), rw)
+		return
+	}
+	comment, err := s.service.GetComment(ctx, link.PostId, link.CommentId)
+	if err != nil {
+		htmlPage("en", "This comment does not exist anymore.", rw)
+		return
+	}
+	rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	moderationPageTemplate.Execute(rw, struct {
+		Label   string
+		Comment *service.RawComment
+		Token   string
+	}{Label: moderationLabels[link.Action], Comment: comment, Token: token})
+}
+
+func (s *apiService) moderate(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	link, err := s.moderationLinks.Use(ctx, req.PostFormValue("token"))
+	if err != nil {
+		badRequest(err.Error(), rw)
+		return
+	}
+	ids := map[service.PostId][]*service.CommentId{link.PostId: {&link.CommentId}}
+	switch link.Action {
+	case moderation.ActionApprove:
+		err = s.service.BulkSetVisible(ctx, moderationLinkActor, ids, true)
+	case moderation.ActionReject:
+		err = s.service.BulkSetVisible(ctx, moderationLinkActor, ids, false)
+	case moderation.ActionDelete:
+		err = s.service.DeleteComments(ctx, moderationLinkActor, ids)
+	}
+	if err != nil {
+		log.Printf("Error in moderation link for comment %s on post %s: %s", link.CommentId, link.PostId, err)
+		htmlPage("en", "There was an error while moderating the comment.", rw)
+		return
+	}
+	htmlPage("en", moderationResults[link.Action], rw)
+}
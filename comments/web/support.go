@@ -3,12 +3,15 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
 	"math"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -16,23 +19,52 @@ import (
 type webService struct {
 	handlers     map[handlerPath]http.HandlerFunc
 	adminChecker *AdminChecker
+	// Where to report request metrics; nil to not report them.
+	metrics  *serverMetrics
+	timeouts Timeouts
 }
 
 type handlerPath struct {
 	prefix string
 	method string
-	admin  bool
+	// The role required to call this handler.
+	role Role
 }
 
 func userPost(path string) handlerPath {
-	return handlerPath{prefix: path, method: http.MethodPost, admin: false}
+	return handlerPath{prefix: path, method: http.MethodPost, role: RoleNone}
+}
+
+func userGet(path string) handlerPath {
+	return handlerPath{prefix: path, method: http.MethodGet, role: RoleNone}
+}
+
+func moderatorPost(path string) handlerPath {
+	return handlerPath{prefix: path, method: http.MethodPost, role: RoleModerator}
 }
 
 func adminPost(path string) handlerPath {
-	return handlerPath{prefix: path, method: http.MethodPost, admin: true}
+	return handlerPath{prefix: path, method: http.MethodPost, role: RoleAdmin}
 }
 
 func (s *webService) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if s.metrics == nil {
+		s.serve(rw, req)
+		return
+	}
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: rw, code: http.StatusOK}
+	handler := s.serve(recorder, req)
+	s.metrics.observe(handler, req.Method, recorder.code, start)
+}
+
+// serve calls the handler for the request, and returns the handler's path, or "unknown" if there was none.
+func (s *webService) serve(rw http.ResponseWriter, req *http.Request) string {
+	// The administrator is looked up at most once, because Digest authentication rejects
+	// a nonce count it has already seen. Handlers read the account from the context.
+	var account *Account
+	accountChecked := false
+	forbidden := false
 	for path, handler := range s.handlers {
 		if req.Method != path.method {
 			continue
@@ -41,28 +73,49 @@ func (s *webService) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		if !found {
 			continue
 		}
-		if path.admin && !s.isAdmin(req) {
-			continue
+		if path.role != RoleNone {
+			if !accountChecked {
+				account = s.adminChecker.Admin(req)
+				accountChecked = true
+			}
+			if account == nil || account.Role < path.role {
+				forbidden = true
+				continue
+			}
 		}
-		handler(rw, newReq)
-		return
+		ctx, cancel := s.timeouts.context(withAccount(req.Context(), account), path.prefix)
+		defer cancel()
+		handler(rw, newReq.WithContext(ctx))
+		return path.prefix
+	}
+	if forbidden {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return "unknown"
 	}
 	badRequest("invalid URL", rw)
+	return "unknown"
 }
 
-func (s *webService) isAdmin(req *http.Request) bool {
-	return s.adminChecker.HasAdmin(req)
+// adminName returns the name of the administrator that made the request, to record in the audit log.
+func (s *webService) adminName(req *http.Request) string {
+	account := requestAccount(req)
+	if account == nil {
+		return ""
+	}
+	return account.Name
 }
 
+// limitRate rejects the request if the limiter has run out. If a request is checked against several
+// limiters, the rate limit headers describe the last one.
 func limitRate(limiter *rate.Limiter, rw http.ResponseWriter) error {
 	allowed := float64(limiter.Limit() * 60)
 	remaining := limiter.Tokens()
-	rw.Header().Add("X-RateLimit-Limit", fmt.Sprintf("%.0f", math.Floor(allowed)))
-	rw.Header().Add("X-RateLimit-Remaining", fmt.Sprintf("%.0f", math.Floor(remaining)))
+	rw.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%.0f", math.Floor(allowed)))
+	rw.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%.0f", math.Floor(remaining)))
 	resv := limiter.Reserve()
 	delay := resv.Delay()
 	if delay.Nanoseconds() != 0 {
-		rw.Header().Add("Retry-After", fmt.Sprint(int(math.Ceil(delay.Seconds()))))
+		rw.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(delay.Seconds()))))
 		rw.WriteHeader(http.StatusTooManyRequests)
 		resv.Cancel()
 		return fmt.Errorf("rate limit exceeded, retry after %f seconds", delay.Seconds())
@@ -87,8 +140,17 @@ func output(what any, err error, rw http.ResponseWriter) {
 	log.Printf("Error: %s", err)
 }
 
+// input reads the request's JSON body into v. Requests that don't declare a JSON body are rejected:
+// browsers let other sites send form and plain text requests with the user's cookies, but not JSON ones
+// unless CORS allows it.
 func input(req *http.Request, v any, rw http.ResponseWriter) error {
 	defer req.Body.Close()
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "application/json" {
+		rw.Header().Add("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusUnsupportedMediaType)
+		rw.Write([]byte("the request must have a JSON body"))
+		return fmt.Errorf("invalid content type: %s", req.Header.Get("Content-Type"))
+	}
 	body, err := io.ReadAll(req.Body)
 	if err == nil {
 		err = json.Unmarshal(body, v)
@@ -102,20 +164,47 @@ func input(req *http.Request, v any, rw http.ResponseWriter) error {
 	return nil
 }
 
+var htmlPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"></head>
+<body><p>{{.Message}}</p></body>
+</html>
+`))
+
+func htmlPage(language string, message string, rw http.ResponseWriter) {
+	rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	htmlPageTemplate.Execute(rw, struct {
+		Language string
+		Message  string
+	}{Language: language, Message: message})
+}
+
+// redirect sends the browser to a location relative to the URL it requested.
+// Unlike http.Redirect, it doesn't resolve the location against the request's path,
+// which may have had a prefix removed.
+func redirect(location string, rw http.ResponseWriter) {
+	rw.Header().Set("Location", location)
+	rw.WriteHeader(http.StatusSeeOther)
+}
+
 func badRequest(text string, rw http.ResponseWriter) {
 	rw.WriteHeader(http.StatusBadRequest)
 	rw.Header().Add("Content-Type", "text/plain")
 	rw.Write([]byte(text))
 }
 
+// stripPathPrefix removes the prefix from the request's path. The prefix must be followed by
+// the end of the path or a slash, so that "/signOut" doesn't match "/signOutAdmin".
 func stripPathPrefix(req *http.Request, prefix string) (newReq *http.Request, found bool) {
 	after, ok := strings.CutPrefix(req.URL.Path, prefix)
-	if !ok {
+	if !ok || (after != "" && !strings.HasPrefix(after, "/")) {
 		return req, false
 	}
 	newReq = new(http.Request)
 	*newReq = *req
 	newReq.URL = new(url.URL)
+	*newReq.URL = *req.URL
 	newReq.URL.Path = after
 	newReq.URL.RawPath = strings.TrimPrefix(req.URL.RawPath, prefix)
 	return newReq, true
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPrefixes(t *testing.T) {
+	called := ""
+	s := &webService{
+		handlers: map[handlerPath]http.HandlerFunc{
+			userPost("/signOut"):      func(rw http.ResponseWriter, req *http.Request) { called = "signOut" },
+			userPost("/signOutAdmin"): func(rw http.ResponseWriter, req *http.Request) { called = "signOutAdmin" },
+		},
+	}
+	for _, path := range []string{"/signOut", "/signOutAdmin"} {
+		// Map iteration order is random, so try several times.
+		for i := 0; i < 20; i++ {
+			called = ""
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+			assert.Equal(t, path[1:], called)
+		}
+	}
+
+	called = ""
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/signOutNow", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "", called)
+}
+
+func TestInputRequiresJson(t *testing.T) {
+	var params struct{ PostId string }
+
+	req := httptest.NewRequest(http.MethodPost, "/list", strings.NewReader(`{"PostId":"a"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	assert.NotNil(t, input(req, &params, rec))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Equal(t, "", params.PostId)
+
+	req = httptest.NewRequest(http.MethodPost, "/list", strings.NewReader(`{"PostId":"a"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	assert.Nil(t, input(req, &params, httptest.NewRecorder()))
+	assert.Equal(t, "a", params.PostId)
+}
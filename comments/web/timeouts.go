@@ -0,0 +1,34 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Timeouts sets how long the API's operations can take before they are cancelled.
+type Timeouts struct {
+	// The deadline for the operations that are not in Operations; zero for no deadline.
+	Default time.Duration
+	// Deadlines for specific operations, by name, such as "add" or "findComments".
+	Operations map[string]time.Duration
+}
+
+// WithTimeouts cancels the operations that take longer than the given timeouts.
+func WithTimeouts(timeouts Timeouts) ServeOption {
+	return func(s *apiService) {
+		s.timeouts = timeouts
+	}
+}
+
+// context returns the context for an operation, given the context of its request.
+func (t Timeouts) context(parent context.Context, path string) (context.Context, context.CancelFunc) {
+	timeout, ok := t.Operations[strings.TrimPrefix(path, "/")]
+	if !ok {
+		timeout = t.Default
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
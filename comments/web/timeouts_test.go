@@ -0,0 +1,49 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeouts(t *testing.T) {
+	var remaining time.Duration
+	var hasDeadline bool
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		var deadline time.Time
+		deadline, hasDeadline = req.Context().Deadline()
+		remaining = time.Until(deadline)
+	}
+	s := &webService{
+		handlers: map[handlerPath]http.HandlerFunc{
+			userPost("/list"): handler,
+			userPost("/add"):  handler,
+		},
+		timeouts: Timeouts{Default: time.Second, Operations: map[string]time.Duration{"add": time.Hour}},
+	}
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/list", nil))
+	assert.True(t, hasDeadline)
+	assert.LessOrEqual(t, remaining, time.Second)
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/add", nil))
+	assert.True(t, hasDeadline)
+	assert.Greater(t, remaining, time.Minute)
+}
+
+func TestCancelledRequest(t *testing.T) {
+	var err error
+	s := &webService{
+		handlers: map[handlerPath]http.HandlerFunc{
+			userPost("/list"): func(rw http.ResponseWriter, req *http.Request) { err = req.Context().Err() },
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/list", nil).WithContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -3,8 +3,16 @@ package config
 import (
 	"time"
 
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/identity"
+	"jacobo.tarrio.org/jtweb/comments/moderation"
+	email_notification "jacobo.tarrio.org/jtweb/comments/notification/email"
+	"jacobo.tarrio.org/jtweb/comments/notification/subscribers"
 	comments "jacobo.tarrio.org/jtweb/comments/service"
+	"jacobo.tarrio.org/jtweb/comments/web"
 	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/newsletter"
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
 	"jacobo.tarrio.org/jtweb/io"
 	"jacobo.tarrio.org/jtweb/languages"
 	"jacobo.tarrio.org/jtweb/page"
@@ -16,8 +24,12 @@ type Config interface {
 	Author() AuthorConfig
 	Generator() GeneratorConfig
 	Mailers() []MailerConfig
+	// NewsletterSubscriptions returns the service that manages newsletter subscriptions, or nil if it's not configured.
+	NewsletterSubscriptions() *subscriptions.Service
 	Comments() CommentsConfig
 	DateFilters() DateFilterConfig
+	// DryRun returns true if operations must only log the files they would write.
+	DryRun() bool
 }
 
 type FileConfig interface {
@@ -40,6 +52,7 @@ type AuthorConfig interface {
 type GeneratorConfig interface {
 	Output() io.File
 	HideUntranslated() bool
+	PrerenderComments() bool
 	SkipOperation() bool
 	Present() bool
 }
@@ -50,13 +63,50 @@ type MailerConfig interface {
 	Engine() email.Engine
 	SubjectPrefix() string
 	SkipOperation() bool
+	// Tags returns the tags of the pages to send; if empty, pages are sent regardless of their tags.
+	Tags() []string
+	// ExcludeTags returns the tags of the pages not to send.
+	ExcludeTags() []string
+	// Series returns the series of the pages to send; if empty, pages are sent regardless of their series.
+	Series() []string
+	// MinInterval returns the minimum time between the publication dates of two pages that are sent.
+	MinInterval() time.Duration
+	// Digest returns the schedule of digest emails, or nil if the mailer sends an email per page.
+	Digest() DigestConfig
+	// Newsletter returns the self-hosted newsletter engine, or nil if the mailer uses another engine.
+	Newsletter() *newsletter.Newsletter
+	// TestMailer returns the mailer for test emails, or nil if it has not been configured.
+	TestMailer() *email_notification.HtmlMailer
+	// TestAddresses returns the addresses that test emails are sent to.
+	TestAddresses() []string
+}
+
+type DigestConfig interface {
+	// Monthly returns whether digests are sent monthly instead of weekly.
+	Monthly() bool
+	// Weekday returns the day of the week weekly digests are sent on.
+	Weekday() time.Weekday
+	// Hour and Minute return the time of the day digests are sent at.
+	Hour() int
+	Minute() int
+	// Location returns the timezone for the day and time digests are sent at.
+	Location() *time.Location
 }
 
 type CommentsConfig interface {
 	DefaultConfig() *page.CommentConfig
 	JsUri() string
 	Service() comments.CommentsService
-	AdminPassword() string
+	Engine() engine.Engine
+	Identities() *identity.Manager
+	Subscriptions() *subscribers.Notifier
+	AdminChecker() *web.AdminChecker
+	// Creates and checks the signed links in notification emails; nil if they are not enabled.
+	ModerationLinks() *moderation.Linker
+	// For how long deleted comments are kept before they can be purged.
+	DeletedRetention() time.Duration
+	// How long the comments API's operations can take before they are cancelled.
+	Timeouts() web.Timeouts
 	SkipOperation() bool
 	Present() bool
 }
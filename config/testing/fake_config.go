@@ -3,10 +3,18 @@ package testing
 import (
 	"time"
 
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/identity"
+	"jacobo.tarrio.org/jtweb/comments/moderation"
+	"jacobo.tarrio.org/jtweb/comments/notification/subscribers"
+	comments "jacobo.tarrio.org/jtweb/comments/service"
+	"jacobo.tarrio.org/jtweb/comments/web"
 	"jacobo.tarrio.org/jtweb/config"
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
 	"jacobo.tarrio.org/jtweb/io"
 	"jacobo.tarrio.org/jtweb/io/testing"
 	"jacobo.tarrio.org/jtweb/languages"
+	"jacobo.tarrio.org/jtweb/page"
 )
 
 type FakeConfig struct {
@@ -21,6 +29,7 @@ type FakeConfig struct {
 	HideUntranslated bool
 	Now              time.Time
 	GenerateNotAfter *time.Time
+	CommentsService  comments.CommentsService
 }
 
 func NewFakeConfig() *FakeConfig {
@@ -124,6 +133,10 @@ func (gc *generatorConfig) HideUntranslated() bool {
 	return gc.cfg.HideUntranslated
 }
 
+func (gc *generatorConfig) PrerenderComments() bool {
+	return false
+}
+
 func (gc *generatorConfig) SkipOperation() bool {
 	return false
 }
@@ -132,14 +145,74 @@ func (gc *generatorConfig) Present() bool {
 	return gc != nil
 }
 
+type commentsConfig struct {
+	cfg *FakeConfig
+}
+
 func (c *FakeConfig) Comments() config.CommentsConfig {
+	return &commentsConfig{c}
+}
+
+func (cc *commentsConfig) DefaultConfig() *page.CommentConfig {
+	return &page.CommentConfig{Enabled: false, Writable: false}
+}
+
+func (cc *commentsConfig) JsUri() string {
+	return ""
+}
+
+func (cc *commentsConfig) Service() comments.CommentsService {
+	return cc.cfg.CommentsService
+}
+
+func (cc *commentsConfig) Engine() engine.Engine {
+	return nil
+}
+
+func (cc *commentsConfig) Identities() *identity.Manager {
+	return nil
+}
+
+func (cc *commentsConfig) Subscriptions() *subscribers.Notifier {
+	return nil
+}
+
+func (cc *commentsConfig) AdminChecker() *web.AdminChecker {
+	return nil
+}
+
+func (cc *commentsConfig) ModerationLinks() *moderation.Linker {
 	return nil
 }
 
+func (cc *commentsConfig) DeletedRetention() time.Duration {
+	return 30 * 24 * time.Hour
+}
+
+func (cc *commentsConfig) Timeouts() web.Timeouts {
+	return web.Timeouts{}
+}
+
+func (cc *commentsConfig) SkipOperation() bool {
+	return false
+}
+
+func (cc *commentsConfig) Present() bool {
+	return false
+}
+
 func (c *FakeConfig) Mailers() []config.MailerConfig {
 	return []config.MailerConfig{}
 }
 
+func (c *FakeConfig) NewsletterSubscriptions() *subscriptions.Service {
+	return nil
+}
+
+func (c *FakeConfig) DryRun() bool {
+	return false
+}
+
 type dateFilterConfig struct {
 	cfg *FakeConfig
 }
@@ -3,22 +3,32 @@ package yamlconfig
 import (
 	"time"
 
+	"jacobo.tarrio.org/jtweb/comments/engine"
+	"jacobo.tarrio.org/jtweb/comments/identity"
+	"jacobo.tarrio.org/jtweb/comments/moderation"
+	email_notification "jacobo.tarrio.org/jtweb/comments/notification/email"
+	"jacobo.tarrio.org/jtweb/comments/notification/subscribers"
 	comments "jacobo.tarrio.org/jtweb/comments/service"
+	"jacobo.tarrio.org/jtweb/comments/web"
 	"jacobo.tarrio.org/jtweb/config"
 	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/newsletter"
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
 	"jacobo.tarrio.org/jtweb/io"
 	"jacobo.tarrio.org/jtweb/languages"
 	"jacobo.tarrio.org/jtweb/page"
 )
 
 type parsedConfig struct {
-	files       fileConfig
-	site        allSiteConfig
-	author      authorConfig
-	generator   *generatorConfig
-	mailers     []config.MailerConfig
-	comments    *commentsConfig
-	dateFilters dateFilterConfig
+	files                   fileConfig
+	site                    allSiteConfig
+	author                  authorConfig
+	generator               *generatorConfig
+	mailers                 []config.MailerConfig
+	comments                *commentsConfig
+	dateFilters             dateFilterConfig
+	newsletterSubscriptions *subscriptions.Service
+	dryRun                  bool
 }
 
 type fileConfig struct {
@@ -43,9 +53,10 @@ type authorConfig struct {
 }
 
 type generatorConfig struct {
-	output           io.File
-	hideUntranslated bool
-	skipOperation    bool
+	output            io.File
+	hideUntranslated  bool
+	prerenderComments bool
+	skipOperation     bool
 }
 
 type mailerConfig struct {
@@ -54,14 +65,36 @@ type mailerConfig struct {
 	subjectPrefix string
 	engine        email.Engine
 	skipOperation bool
+	tags          []string
+	excludeTags   []string
+	series        []string
+	minInterval   time.Duration
+	digest        *digestConfig
+	newsletter    *newsletter.Newsletter
+	testMailer    *email_notification.HtmlMailer
+	testAddresses []string
+}
+
+type digestConfig struct {
+	monthly  bool
+	weekday  time.Weekday
+	hour     int
+	minute   int
+	location *time.Location
 }
 
 type commentsConfig struct {
-	defaultConfig *page.CommentConfig
-	jsUri         string
-	service       comments.CommentsService
-	adminPassword string
-	skipOperation bool
+	defaultConfig    *page.CommentConfig
+	jsUri            string
+	service          comments.CommentsService
+	engine           engine.Engine
+	identities       *identity.Manager
+	subscriptions    *subscribers.Notifier
+	adminChecker     *web.AdminChecker
+	moderationLinks  *moderation.Linker
+	deletedRetention time.Duration
+	timeouts         web.Timeouts
+	skipOperation    bool
 }
 
 type dateFilterConfig struct {
@@ -140,6 +173,10 @@ func (gc *generatorConfig) HideUntranslated() bool {
 	return gc.hideUntranslated
 }
 
+func (gc *generatorConfig) PrerenderComments() bool {
+	return gc.prerenderComments
+}
+
 func (gc *generatorConfig) SkipOperation() bool {
 	return gc.skipOperation
 }
@@ -172,6 +209,69 @@ func (mc *mailerConfig) SkipOperation() bool {
 	return mc.skipOperation
 }
 
+func (mc *mailerConfig) Tags() []string {
+	return mc.tags
+}
+
+func (mc *mailerConfig) ExcludeTags() []string {
+	return mc.excludeTags
+}
+
+func (mc *mailerConfig) Series() []string {
+	return mc.series
+}
+
+func (mc *mailerConfig) MinInterval() time.Duration {
+	return mc.minInterval
+}
+
+func (mc *mailerConfig) Digest() config.DigestConfig {
+	if mc.digest == nil {
+		return nil
+	}
+	return mc.digest
+}
+
+func (dc *digestConfig) Monthly() bool {
+	return dc.monthly
+}
+
+func (dc *digestConfig) Weekday() time.Weekday {
+	return dc.weekday
+}
+
+func (dc *digestConfig) Hour() int {
+	return dc.hour
+}
+
+func (dc *digestConfig) Minute() int {
+	return dc.minute
+}
+
+func (dc *digestConfig) Location() *time.Location {
+	return dc.location
+}
+
+func (mc *mailerConfig) Newsletter() *newsletter.Newsletter {
+	return mc.newsletter
+}
+
+func (mc *mailerConfig) TestMailer() *email_notification.HtmlMailer {
+	return mc.testMailer
+}
+
+func (mc *mailerConfig) TestAddresses() []string {
+	return mc.testAddresses
+}
+
+func (c *parsedConfig) NewsletterSubscriptions() *subscriptions.Service {
+	return c.newsletterSubscriptions
+}
+
+func (c *parsedConfig) DryRun() bool {
+	return c.dryRun
+}
+
 func (c *parsedConfig) Comments() config.CommentsConfig {
 	return c.comments
 }
@@ -194,8 +294,32 @@ func (cc *commentsConfig) Service() comments.CommentsService {
 	return cc.service
 }
 
-func (cc *commentsConfig) AdminPassword() string {
-	return cc.adminPassword
+func (cc *commentsConfig) Engine() engine.Engine {
+	return cc.engine
+}
+
+func (cc *commentsConfig) Identities() *identity.Manager {
+	return cc.identities
+}
+
+func (cc *commentsConfig) Subscriptions() *subscribers.Notifier {
+	return cc.subscriptions
+}
+
+func (cc *commentsConfig) AdminChecker() *web.AdminChecker {
+	return cc.adminChecker
+}
+
+func (cc *commentsConfig) ModerationLinks() *moderation.Linker {
+	return cc.moderationLinks
+}
+
+func (cc *commentsConfig) DeletedRetention() time.Duration {
+	return cc.deletedRetention
+}
+
+func (cc *commentsConfig) Timeouts() web.Timeouts {
+	return cc.timeouts
 }
 
 func (cc *commentsConfig) SkipOperation() bool {
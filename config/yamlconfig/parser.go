@@ -3,6 +3,8 @@ package yamlconfig
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,11 +12,24 @@ import (
 	comments_engine "jacobo.tarrio.org/jtweb/comments/engine"
 	"jacobo.tarrio.org/jtweb/comments/engine/mysql"
 	"jacobo.tarrio.org/jtweb/comments/engine/sqlite3"
+	"jacobo.tarrio.org/jtweb/comments/identity"
+	"jacobo.tarrio.org/jtweb/comments/moderation"
+	"jacobo.tarrio.org/jtweb/comments/notification"
 	email_notification "jacobo.tarrio.org/jtweb/comments/notification/email"
+	"jacobo.tarrio.org/jtweb/comments/notification/subscribers"
+	"jacobo.tarrio.org/jtweb/comments/notification/webhook"
 	comments_service "jacobo.tarrio.org/jtweb/comments/service"
+	"jacobo.tarrio.org/jtweb/comments/web"
 	"jacobo.tarrio.org/jtweb/config"
 	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/buttondown"
+	"jacobo.tarrio.org/jtweb/email/listmonk"
+	"jacobo.tarrio.org/jtweb/email/mailchimp"
 	"jacobo.tarrio.org/jtweb/email/mailerlite"
+	"jacobo.tarrio.org/jtweb/email/newsletter"
+	newsletter_mysql "jacobo.tarrio.org/jtweb/email/newsletter/mysql"
+	newsletter_sqlite3 "jacobo.tarrio.org/jtweb/email/newsletter/sqlite3"
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
 	"jacobo.tarrio.org/jtweb/io"
 	"jacobo.tarrio.org/jtweb/languages"
 	"jacobo.tarrio.org/jtweb/page"
@@ -41,27 +56,111 @@ type yamlConfig struct {
 		Uri  string
 	}
 	Generator *struct {
-		Output           string
-		HideUntranslated bool `yaml:"hide_untranslated"`
-		SkipOperation    bool `yaml:"skip_operation"`
+		Output            string
+		HideUntranslated  bool `yaml:"hide_untranslated"`
+		PrerenderComments bool `yaml:"prerender_comments"`
+		SkipOperation     bool `yaml:"skip_operation"`
 	}
 	Mailers []struct {
-		Name          string
-		Language      string
-		SubjectPrefix string `yaml:"subject_prefix"`
-		SkipOperation bool   `yaml:"skip_operation"`
-		Mailerlite    *struct {
+		Name             string
+		Language         string
+		SubjectPrefix    string `yaml:"subject_prefix"`
+		SkipOperation    bool   `yaml:"skip_operation"`
+		Tags             []string
+		ExcludeTags      []string `yaml:"exclude_tags"`
+		Series           []string
+		MinIntervalHours int `yaml:"min_interval_hours"`
+		Digest           *struct {
+			Period   string
+			Weekday  string
+			Time     string
+			Timezone string
+		}
+		Mailerlite *struct {
+			ApikeySecret string `yaml:"apikey_secret"`
+			// A number in the configuration files for the classic MailerLite API.
+			Group    numberOrString
+			From     string
+			FromName string `yaml:"from_name"`
+			Timezone string
+		}
+		Mailchimp *struct {
+			ApikeySecret     string `yaml:"apikey_secret"`
+			ListId           string `yaml:"list_id"`
+			FromName         string `yaml:"from_name"`
+			ReplyTo          string `yaml:"reply_to"`
+			FilterByLanguage bool   `yaml:"filter_by_language"`
+		}
+		Buttondown *struct {
 			ApikeySecret string `yaml:"apikey_secret"`
-			Group        int
 		}
-	}
+		Listmonk *struct {
+			Uri         string
+			User        string
+			TokenSecret string `yaml:"token_secret"`
+			Lists       []int
+			FromEmail   string `yaml:"from_email"`
+		}
+		Test *struct {
+			From           string
+			To             []string
+			Server         string
+			User           string
+			PasswordSecret string `yaml:"password_secret"`
+			Authentication string
+			Encryption     string
+		}
+		Newsletter *struct {
+			Sqlite3 *struct {
+				ConnectionStringSecret string `yaml:"connection_string_secret"`
+			}
+			Mysql *struct {
+				ConnectionStringSecret string `yaml:"connection_string_secret"`
+			}
+			From              string
+			Server            string
+			User              string
+			PasswordSecret    string `yaml:"password_secret"`
+			Authentication    string
+			Encryption        string
+			BatchSize         int `yaml:"batch_size"`
+			MessagesPerMinute int `yaml:"messages_per_minute"`
+			MaxAttempts       int `yaml:"max_attempts"`
+		}
+	}
+	NewsletterSubscriptions *struct {
+		SigningKeySecret string `yaml:"signing_key_secret"`
+		ServerUri        string `yaml:"server_uri"`
+		From             string
+		Server           string
+		User             string
+		PasswordSecret   string `yaml:"password_secret"`
+		Authentication   string
+		Encryption       string
+	} `yaml:"newsletter_subscriptions"`
 	Comments *struct {
-		DefaultSetting      string `yaml:"default_setting"`
-		PostAsDraft         bool   `yaml:"post_as_draft"`
-		WidgetUri           string `yaml:"widget_uri"`
-		AdminPasswordSecret string `yaml:"admin_password_secret"`
-		SkipOperation       bool   `yaml:"skip_operation"`
-		Sqlite3             *struct {
+		DefaultSetting       string `yaml:"default_setting"`
+		PostAsDraft          bool   `yaml:"post_as_draft"`
+		WidgetUri            string `yaml:"widget_uri"`
+		AdminPasswordSecret  string `yaml:"admin_password_secret"`
+		SkipOperation        bool   `yaml:"skip_operation"`
+		DeletedRetentionDays *int   `yaml:"deleted_retention_days"`
+		Reactions            []string
+		ModerationLinks      *struct {
+			SigningKeySecret string `yaml:"signing_key_secret"`
+			ValidHours       int    `yaml:"valid_hours"`
+		} `yaml:"moderation_links"`
+		AdminAccounts *struct {
+			SessionKeySecret string `yaml:"session_key_secret"`
+			SessionHours     int    `yaml:"session_hours"`
+			Accounts         []struct {
+				Name               string
+				Role               string
+				PasswordHash       string `yaml:"password_hash"`
+				PasswordHashSecret string `yaml:"password_hash_secret"`
+			}
+		} `yaml:"admin_accounts"`
+		Sqlite3 *struct {
 			ConnectionStringSecret string `yaml:"connection_string_secret"`
 		}
 		Mysql *struct {
@@ -77,6 +176,26 @@ type yamlConfig struct {
 				Authentication string
 				Encryption     string
 			}
+			Webhooks []struct {
+				Uri              string
+				UriSecret        string `yaml:"uri_secret"`
+				Format           string
+				SigningKeySecret string `yaml:"signing_key_secret"`
+				// Ignored: failed notifications are retried as set by max_attempts.
+				Attempts int
+			}
+			MaxAttempts       int `yaml:"max_attempts"`
+			RetryDelayMinutes int `yaml:"retry_delay_minutes"`
+		}
+		Identities *struct {
+			SigningKeySecret  string            `yaml:"signing_key_secret"`
+			EditWindowMinutes int               `yaml:"edit_window_minutes"`
+			Badges            map[string]string `yaml:"badges"`
+			Subscriptions     bool
+		}
+		Timeouts *struct {
+			DefaultSeconds *int `yaml:"default_seconds"`
+			Operations     map[string]int
 		}
 	}
 	DateFilters struct {
@@ -180,7 +299,7 @@ func (r *configParser) Parse() (config.Config, error) {
 		option(&cfg)
 	}
 
-	var out = &parsedConfig{}
+	var out = &parsedConfig{dryRun: cfg.Debug.DryRun}
 	if cfg.Files.Templates == "" {
 		return nil, fmt.Errorf("the template path has not been set")
 	}
@@ -241,14 +360,33 @@ func (r *configParser) Parse() (config.Config, error) {
 			return nil, fmt.Errorf("the output path has not been set")
 		}
 		out.generator = &generatorConfig{
-			output:           io.OsFile(cfg.Generator.Output),
-			hideUntranslated: cfg.Generator.HideUntranslated,
-			skipOperation:    cfg.Generator.SkipOperation,
+			output:            io.OsFile(cfg.Generator.Output),
+			hideUntranslated:  cfg.Generator.HideUntranslated,
+			prerenderComments: cfg.Generator.PrerenderComments,
+			skipOperation:     cfg.Generator.SkipOperation,
 		}
 		if cfg.Debug.DryRun {
 			out.generator.output = io.DryRunFile(out.generator.output)
 		}
 	}
+	// The subscriptions' links are needed first, because the self-hosted newsletters use their unsubscribe links.
+	var subscriptionLinks *subscriptions.Links
+	if cfg.NewsletterSubscriptions != nil {
+		subs := cfg.NewsletterSubscriptions
+		serverUri := subs.ServerUri
+		if serverUri == "" && cfg.Comments != nil {
+			serverUri = cfg.Comments.WidgetUri
+		}
+		if serverUri == "" {
+			return nil, fmt.Errorf("the newsletter subscriptions' server_uri has not been set")
+		}
+		key, err := r.secretSupplier.GetSecret(subs.SigningKeySecret)
+		if err != nil {
+			return nil, err
+		}
+		subscriptionLinks = subscriptions.NewLinks(key, appendToUri(serverUri, "newsletter/"))
+	}
+	subscriptionLists := []*subscriptions.List{}
 	for _, mailer := range cfg.Mailers {
 		if mailer.Name == "" {
 			return nil, fmt.Errorf("the mailer's name has not been set")
@@ -257,6 +395,10 @@ func (r *configParser) Parse() (config.Config, error) {
 			name:          mailer.Name,
 			subjectPrefix: mailer.SubjectPrefix,
 			skipOperation: mailer.SkipOperation,
+			tags:          mailer.Tags,
+			excludeTags:   mailer.ExcludeTags,
+			series:        mailer.Series,
+			minInterval:   time.Duration(mailer.MinIntervalHours) * time.Hour,
 		}
 		if mailer.Language == "" {
 			return nil, fmt.Errorf("the mailer's language has not been set")
@@ -266,20 +408,166 @@ func (r *configParser) Parse() (config.Config, error) {
 			return nil, err
 		}
 		outMailer.language = lang
+		engines := []string{}
+		for name, present := range map[string]bool{
+			"mailerlite": mailer.Mailerlite != nil,
+			"mailchimp":  mailer.Mailchimp != nil,
+			"buttondown": mailer.Buttondown != nil,
+			"listmonk":   mailer.Listmonk != nil,
+			"newsletter": mailer.Newsletter != nil,
+		} {
+			if present {
+				engines = append(engines, name)
+			}
+		}
+		if len(engines) > 1 {
+			sort.Strings(engines)
+			return nil, fmt.Errorf("mailer %s has more than one email engine: %s", mailer.Name, strings.Join(engines, ", "))
+		}
 		if mailer.Mailerlite != nil {
-			apikey, err := r.secretSupplier.GetSecret(mailer.Mailerlite.ApikeySecret)
+			ml := mailer.Mailerlite
+			apikey, err := r.secretSupplier.GetSecret(ml.ApikeySecret)
+			if err != nil {
+				return nil, err
+			}
+			if ml.Group == "" {
+				return nil, fmt.Errorf("the MailerLite group has not been set")
+			}
+			if ml.From == "" {
+				return nil, fmt.Errorf("mailerlite: `from` is now required; set it to the campaigns' sender address, which must be in a verified domain")
+			}
+			opts := []mailerlite.MailerliteOption{}
+			if ml.Timezone != "" {
+				opts = append(opts, mailerlite.WithTimezone(ml.Timezone))
+			}
+			engine, err := mailerlite.ConnectMailerlite(apikey, string(ml.Group), ml.FromName, ml.From, opts...)
+			if err != nil {
+				return nil, err
+			}
+			outMailer.engine = engine
+		}
+		if mailer.Mailchimp != nil {
+			mc := mailer.Mailchimp
+			apikey, err := r.secretSupplier.GetSecret(mc.ApikeySecret)
+			if err != nil {
+				return nil, err
+			}
+			if mc.ListId == "" {
+				return nil, fmt.Errorf("the Mailchimp list_id has not been set")
+			}
+			engine, err := mailchimp.ConnectMailchimp(apikey, mc.ListId, mc.FromName, mc.ReplyTo, mailchimp.FilterByLanguage(mc.FilterByLanguage))
+			if err != nil {
+				return nil, err
+			}
+			outMailer.engine = engine
+		}
+		if mailer.Buttondown != nil {
+			apikey, err := r.secretSupplier.GetSecret(mailer.Buttondown.ApikeySecret)
+			if err != nil {
+				return nil, err
+			}
+			engine, err := buttondown.ConnectButtondown(apikey)
+			if err != nil {
+				return nil, err
+			}
+			outMailer.engine = engine
+		}
+		if mailer.Listmonk != nil {
+			lm := mailer.Listmonk
+			if lm.Uri == "" {
+				return nil, fmt.Errorf("the Listmonk uri has not been set")
+			}
+			if len(lm.Lists) == 0 {
+				return nil, fmt.Errorf("no Listmonk lists were specified")
+			}
+			token, err := r.secretSupplier.GetSecret(lm.TokenSecret)
 			if err != nil {
 				return nil, err
 			}
-			engine, err := mailerlite.ConnectMailerlite(apikey, mailer.Mailerlite.Group, false)
+			opts := []listmonk.ListmonkOption{}
+			if lm.FromEmail != "" {
+				opts = append(opts, listmonk.WithFromEmail(lm.FromEmail))
+			}
+			engine, err := listmonk.ConnectListmonk(lm.Uri, lm.User, token, lm.Lists, opts...)
 			if err != nil {
 				return nil, err
 			}
 			outMailer.engine = engine
 		}
+		if mailer.Newsletter != nil {
+			nl := mailer.Newsletter
+			var store *newsletter.Store = nil
+			if nl.Sqlite3 != nil {
+				connString, err := r.secretSupplier.GetSecret(nl.Sqlite3.ConnectionStringSecret)
+				if err != nil {
+					return nil, err
+				}
+				store, err = newsletter_sqlite3.NewSqlite3Store(connString)
+				if err != nil {
+					return nil, err
+				}
+			} else if nl.Mysql != nil {
+				connString, err := r.secretSupplier.GetSecret(nl.Mysql.ConnectionStringSecret)
+				if err != nil {
+					return nil, err
+				}
+				store, err = newsletter_mysql.NewMysqlStore(connString)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if store == nil {
+				return nil, fmt.Errorf("no newsletter database was defined")
+			}
+			if nl.From == "" {
+				return nil, fmt.Errorf("no newsletter 'from' address was specified")
+			}
+			smtpOpts, err := r.parseSmtpOptions(nl.Server, nl.User, nl.PasswordSecret, nl.Authentication, nl.Encryption)
+			if err != nil {
+				return nil, err
+			}
+			if subscriptionLinks == nil {
+				return nil, fmt.Errorf("self-hosted newsletters need newsletter_subscriptions for their unsubscribe links")
+			}
+			opts := []newsletter.NewsletterOption{newsletter.MessagesPerMinute(nl.MessagesPerMinute)}
+			if nl.BatchSize > 0 {
+				opts = append(opts, newsletter.BatchSize(nl.BatchSize))
+			}
+			if nl.MaxAttempts > 0 {
+				opts = append(opts, newsletter.MaxAttempts(nl.MaxAttempts))
+			}
+			engine := newsletter.NewNewsletter(store, lang, email_notification.NewHtmlMailer(nl.From, smtpOpts...), subscriptionLinks, opts...)
+			outMailer.engine = engine
+			outMailer.newsletter = engine
+		}
+		if mailer.Digest != nil {
+			digest, err := parseDigest(mailer.Digest.Period, mailer.Digest.Weekday, mailer.Digest.Time, mailer.Digest.Timezone)
+			if err != nil {
+				return nil, err
+			}
+			outMailer.digest = digest
+		}
+		if mailer.Test != nil {
+			test := mailer.Test
+			if test.From == "" {
+				return nil, fmt.Errorf("no 'from' address was specified for the test emails")
+			}
+			if len(test.To) == 0 {
+				return nil, fmt.Errorf("no addresses were specified for the test emails")
+			}
+			smtpOpts, err := r.parseSmtpOptions(test.Server, test.User, test.PasswordSecret, test.Authentication, test.Encryption)
+			if err != nil {
+				return nil, err
+			}
+			outMailer.testMailer = email_notification.NewHtmlMailer(test.From, smtpOpts...)
+			outMailer.testAddresses = test.To
+		}
 		if outMailer.engine == nil {
 			return nil, fmt.Errorf("no email engine was defined")
 		}
+		if manager, ok := outMailer.engine.(email.SubscriberManager); ok {
+			subscriptionLists = append(subscriptionLists, &subscriptions.List{Name: mailer.Name, Language: lang, Subscribers: manager})
+		}
 		if cfg.Debug.DryRun {
 			outMailer.engine = email.DryRunEngine(outMailer.engine)
 		}
@@ -319,13 +607,28 @@ func (r *configParser) Parse() (config.Config, error) {
 		if cfg.Comments.PostAsDraft {
 			options = append(options, comments_service.PostAsDraft())
 		}
-		adminPassword, err := r.secretSupplier.GetSecret(cfg.Comments.AdminPasswordSecret)
+		adminChecker, err := r.parseAdminChecker(&cfg)
 		if err != nil {
 			return nil, err
 		}
 		if cfg.Comments.WidgetUri == "" {
 			return nil, fmt.Errorf("no comments widget URI was defined")
 		}
+		var moderationLinks *moderation.Linker
+		if cfg.Comments.ModerationLinks != nil {
+			key, err := r.secretSupplier.GetSecret(cfg.Comments.ModerationLinks.SigningKeySecret)
+			if err != nil {
+				return nil, err
+			}
+			var linkOpts []moderation.LinkerOption
+			if cfg.Comments.ModerationLinks.ValidHours > 0 {
+				linkOpts = append(linkOpts, moderation.LinkTtl(time.Duration(cfg.Comments.ModerationLinks.ValidHours)*time.Hour))
+			}
+			moderationLinks = moderation.NewLinker(key, engine, appendToUri(cfg.Comments.WidgetUri, "_/moderate"), linkOpts...)
+		}
+		var smtpOptions []email_notification.NotificationEngineOption = nil
+		smtpFrom := ""
+		notifiers := []notification.NotificationEngine{}
 		if cfg.Comments.Notify != nil {
 			if cfg.Comments.Notify.Email != nil {
 				email := cfg.Comments.Notify.Email
@@ -335,48 +638,143 @@ func (r *configParser) Parse() (config.Config, error) {
 				if email.To == "" {
 					return nil, fmt.Errorf("no email 'to' address was specified")
 				}
-				enc, err := email_notification.Encryption(email.Encryption)
+				notifyOpts, err := r.parseSmtpOptions(email.Server, email.User, email.PasswordSecret, email.Authentication, email.Encryption)
 				if err != nil {
 					return nil, err
 				}
-				auth, err := email_notification.AuthType(email.Authentication)
+				notify := email_notification.NewEmailNotificationEngine(
+					appendToUri(cfg.Comments.WidgetUri, "admin.html"),
+					moderationLinks,
+					email.From,
+					email.To,
+					notifyOpts...)
+				notifiers = append(notifiers, notify)
+				smtpOptions = notifyOpts
+				smtpFrom = email.From
+			}
+			for _, hook := range cfg.Comments.Notify.Webhooks {
+				uri := hook.Uri
+				if hook.UriSecret != "" {
+					uri, err = r.secretSupplier.GetSecret(hook.UriSecret)
+					if err != nil {
+						return nil, err
+					}
+				}
+				if uri == "" {
+					return nil, fmt.Errorf("no webhook URI was specified")
+				}
+				format, err := webhook.ParseFormat(hook.Format)
 				if err != nil {
 					return nil, err
 				}
-				notifyOpts := []email_notification.NotificationEngineOption{
-					email_notification.SetAuthType(auth),
-					email_notification.SetEncryption(enc),
-				}
-				if email.User != "" {
-					pass, err := r.secretSupplier.GetSecret(email.PasswordSecret)
+				hookOpts := []webhook.WebhookOption{webhook.SetFormat(format)}
+				if hook.SigningKeySecret != "" {
+					key, err := r.secretSupplier.GetSecret(hook.SigningKeySecret)
 					if err != nil {
 						return nil, err
 					}
-					notifyOpts = append(notifyOpts, email_notification.SetAuth(email.User, pass))
-				}
-				if email.Server != "" {
-					socket, found := strings.CutPrefix(email.Server, "unix:/")
-					if found {
-						notifyOpts = append(notifyOpts, email_notification.SetUnixSocket("/"+socket))
-					} else {
-						notifyOpts = append(notifyOpts, email_notification.SetHostPort(email.Server))
-					}
+					hookOpts = append(hookOpts, webhook.SetSecret(key))
 				}
-				notify := email_notification.NewEmailNotificationEngine(
+				notifiers = append(notifiers, webhook.NewWebhookNotificationEngine(
+					uri,
 					appendToUri(cfg.Comments.WidgetUri, "admin.html"),
-					email.From,
-					email.To,
-					notifyOpts...)
-				options = append(options, comments_service.WithNotificationEngine(notify))
+					hookOpts...))
+			}
+		}
+		if cfg.Comments.Notify != nil && cfg.Comments.Notify.MaxAttempts > 0 {
+			delay := time.Minute
+			if cfg.Comments.Notify.RetryDelayMinutes > 0 {
+				delay = time.Duration(cfg.Comments.Notify.RetryDelayMinutes) * time.Minute
+			}
+			options = append(options, comments_service.WithNotificationRetries(cfg.Comments.Notify.MaxAttempts, delay))
+		}
+		options = append(options, comments_service.WithNotificationEngine(notifiers...))
+		var identities *identity.Manager = nil
+		var subscriptions *subscribers.Notifier = nil
+		if cfg.Comments.Identities != nil {
+			if smtpOptions == nil {
+				return nil, fmt.Errorf("commenter identities require email notifications to be configured")
+			}
+			key, err := r.secretSupplier.GetSecret(cfg.Comments.Identities.SigningKeySecret)
+			if err != nil {
+				return nil, err
+			}
+			mailer := email_notification.NewEmailMailer(smtpFrom, smtpOptions...)
+			webRoots := []string{cfg.Site.Webroot}
+			for _, siteCfg := range cfg.Site.ByLanguage {
+				if siteCfg.Webroot != "" {
+					webRoots = append(webRoots, siteCfg.Webroot)
+				}
+			}
+			identities = identity.NewManager(
+				key,
+				mailer,
+				appendToUri(cfg.Comments.WidgetUri, "_/verify"),
+				identity.ReturnTo(webRoots...))
+			options = append(options,
+				comments_service.WithBadges(cfg.Comments.Identities.Badges),
+				comments_service.WithEditWindow(time.Duration(cfg.Comments.Identities.EditWindowMinutes)*time.Minute))
+			if cfg.Comments.Identities.Subscriptions {
+				subscriptions = subscribers.NewNotifier(
+					key,
+					mailer,
+					appendToUri(cfg.Comments.WidgetUri, "_/unsubscribe"))
+				options = append(options, comments_service.WithSubscriberNotifier(subscriptions))
+			}
+		}
+		if len(cfg.Comments.Reactions) > 0 {
+			seen := map[string]bool{}
+			for _, reaction := range cfg.Comments.Reactions {
+				if reaction == "" || len(reaction) > 32 {
+					return nil, fmt.Errorf("invalid reaction: %q", reaction)
+				}
+				if seen[reaction] {
+					return nil, fmt.Errorf("duplicate reaction: %s", reaction)
+				}
+				seen[reaction] = true
+			}
+			options = append(options, comments_service.WithReactions(cfg.Comments.Reactions))
+		}
+		deletedRetention := 30 * 24 * time.Hour
+		if cfg.Comments.DeletedRetentionDays != nil {
+			deletedRetention = time.Duration(*cfg.Comments.DeletedRetentionDays) * 24 * time.Hour
+		}
+		timeouts := web.Timeouts{Default: 30 * time.Second, Operations: map[string]time.Duration{}}
+		if cfg.Comments.Timeouts != nil {
+			if cfg.Comments.Timeouts.DefaultSeconds != nil {
+				timeouts.Default = time.Duration(*cfg.Comments.Timeouts.DefaultSeconds) * time.Second
+			}
+			for operation, seconds := range cfg.Comments.Timeouts.Operations {
+				timeouts.Operations[operation] = time.Duration(seconds) * time.Second
 			}
 		}
 		out.comments = &commentsConfig{
-			defaultConfig: defCfg,
-			jsUri:         appendToUri(cfg.Comments.WidgetUri, "comments.js"),
-			service:       comments_service.NewCommentsService(engine, options...),
-			adminPassword: adminPassword,
-			skipOperation: cfg.Comments.SkipOperation,
+			defaultConfig:    defCfg,
+			jsUri:            appendToUri(cfg.Comments.WidgetUri, "comments.js"),
+			service:          comments_service.NewCommentsService(engine, options...),
+			engine:           engine,
+			identities:       identities,
+			subscriptions:    subscriptions,
+			adminChecker:     adminChecker,
+			moderationLinks:  moderationLinks,
+			deletedRetention: deletedRetention,
+			timeouts:         timeouts,
+			skipOperation:    cfg.Comments.SkipOperation,
+		}
+	}
+	if cfg.NewsletterSubscriptions != nil {
+		subs := cfg.NewsletterSubscriptions
+		if subs.From == "" {
+			return nil, fmt.Errorf("no newsletter subscriptions 'from' address was specified")
+		}
+		smtpOpts, err := r.parseSmtpOptions(subs.Server, subs.User, subs.PasswordSecret, subs.Authentication, subs.Encryption)
+		if err != nil {
+			return nil, err
 		}
+		out.newsletterSubscriptions = subscriptions.NewService(
+			subscriptionLinks,
+			email_notification.NewEmailMailer(subs.From, smtpOpts...),
+			subscriptionLists)
 	}
 	now := time.Now()
 	out.dateFilters = dateFilterConfig{
@@ -394,6 +792,85 @@ func (r *configParser) Parse() (config.Config, error) {
 	return out, nil
 }
 
+// parseSmtpOptions returns the options to connect to an SMTP server.
+func (r *configParser) parseSmtpOptions(server string, user string, passwordSecret string, authentication string, encryption string) ([]email_notification.NotificationEngineOption, error) {
+	enc, err := email_notification.Encryption(encryption)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := email_notification.AuthType(authentication)
+	if err != nil {
+		return nil, err
+	}
+	opts := []email_notification.NotificationEngineOption{
+		email_notification.SetAuthType(auth),
+		email_notification.SetEncryption(enc),
+	}
+	if user != "" {
+		pass, err := r.secretSupplier.GetSecret(passwordSecret)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, email_notification.SetAuth(user, pass))
+	}
+	if server != "" {
+		socket, found := strings.CutPrefix(server, "unix:/")
+		if found {
+			opts = append(opts, email_notification.SetUnixSocket("/"+socket))
+		} else {
+			opts = append(opts, email_notification.SetHostPort(server))
+		}
+	}
+	return opts, nil
+}
+
+func (r *configParser) parseAdminChecker(cfg *yamlConfig) (*web.AdminChecker, error) {
+	var options []web.AdminCheckerOption
+	if cfg.Comments.AdminPasswordSecret != "" {
+		adminPassword, err := r.secretSupplier.GetSecret(cfg.Comments.AdminPasswordSecret)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, web.WithDigestPassword(adminPassword))
+	}
+	if accts := cfg.Comments.AdminAccounts; accts != nil && len(accts.Accounts) > 0 {
+		key, err := r.secretSupplier.GetSecret(accts.SessionKeySecret)
+		if err != nil {
+			return nil, err
+		}
+		accounts := []web.Account{}
+		for _, acct := range accts.Accounts {
+			if acct.Name == "" {
+				return nil, fmt.Errorf("an admin account has no name")
+			}
+			role, err := web.ParseRole(acct.Role)
+			if err != nil {
+				return nil, fmt.Errorf("invalid role for admin account %s: %w", acct.Name, err)
+			}
+			hash := acct.PasswordHash
+			if acct.PasswordHashSecret != "" {
+				hash, err = r.secretSupplier.GetSecret(acct.PasswordHashSecret)
+				if err != nil {
+					return nil, err
+				}
+			}
+			hash = strings.TrimSpace(hash)
+			if err := web.CheckPasswordHash(hash); err != nil {
+				return nil, fmt.Errorf("invalid password hash for admin account %s: %w", acct.Name, err)
+			}
+			accounts = append(accounts, web.Account{Name: acct.Name, Role: role, PasswordHash: hash})
+		}
+		options = append(options, web.WithAccounts(key, accounts))
+		if accts.SessionHours > 0 {
+			options = append(options, web.SessionTtl(time.Duration(accts.SessionHours)*time.Hour))
+		}
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no admin password or admin accounts were defined for comments")
+	}
+	return web.NewAdminChecker(options...), nil
+}
+
 func parseRelDate(when *time.Time, days *int, now time.Time) *time.Time {
 	if when != nil {
 		return when
@@ -406,6 +883,45 @@ func parseRelDate(when *time.Time, days *int, now time.Time) *time.Time {
 	return nil
 }
 
+func parseDigest(period string, weekday string, timeOfDay string, timezone string) (*digestConfig, error) {
+	out := &digestConfig{weekday: time.Monday, location: time.UTC}
+	switch period {
+	case "weekly":
+	case "monthly":
+		out.monthly = true
+	default:
+		return nil, fmt.Errorf("invalid digest period: %s", period)
+	}
+	if weekday != "" {
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(weekday, d.String()) {
+				out.weekday = d
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid digest weekday: %s", weekday)
+		}
+	}
+	if timeOfDay != "" {
+		t, err := time.Parse("15:04", timeOfDay)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest time: %s", timeOfDay)
+		}
+		out.hour = t.Hour()
+		out.minute = t.Minute()
+	}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		out.location = loc
+	}
+	return out, nil
+}
+
 func appendToUri(uri string, path string) string {
 	if uri == "" {
 		return path
@@ -415,3 +931,23 @@ func appendToUri(uri string, path string) string {
 	}
 	return uri + "/" + path
 }
+
+// numberOrString is a YAML value that can be written either as a number or as a string,
+// for settings that used to be numbers.
+type numberOrString string
+
+func (v *numberOrString) UnmarshalYAML(node *yaml.Node) error {
+	switch node.ShortTag() {
+	case "!!int":
+		var n int64
+		if err := node.Decode(&n); err != nil {
+			return err
+		}
+		*v = numberOrString(strconv.FormatInt(n, 10))
+		return nil
+	case "!!str":
+		*v = numberOrString(node.Value)
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a number or a string, found %s", node.Line, node.Value)
+}
@@ -0,0 +1,88 @@
+package yamlconfig
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+type fakeSecrets map[string]string
+
+func (s fakeSecrets) GetSecret(key string) (string, error) {
+	secret, ok := s[key]
+	if !ok {
+		return "", fmt.Errorf("no secret %s", key)
+	}
+	return secret, nil
+}
+
+// parseMailer parses a configuration with a single mailer, given as YAML indented for the mailer's block.
+func parseMailer(mailer string) error {
+	source := `
+files:
+  templates: "/templates"
+  content: "/content"
+site:
+  webroot: "https://example.com/"
+  name: "Example"
+author:
+  name: "Author"
+mailers:
+  - name: "english"
+    language: "en"
+` + mailer
+	_, err := NewConfigParser([]byte(source)).WithSecretSupplier(fakeSecrets{"key": "secret"}).Parse()
+	return err
+}
+
+func TestNumberOrString(t *testing.T) {
+	var v struct{ Group numberOrString }
+	assert.Nil(t, yaml.Unmarshal([]byte("group: 123456789012345678"), &v))
+	assert.Equal(t, numberOrString("123456789012345678"), v.Group)
+	assert.Nil(t, yaml.Unmarshal([]byte(`group: "0123"`), &v))
+	assert.Equal(t, numberOrString("0123"), v.Group)
+	assert.NotNil(t, yaml.Unmarshal([]byte("group: [1, 2]"), &v))
+	assert.NotNil(t, yaml.Unmarshal([]byte("group: 1.5"), &v))
+}
+
+func TestMailerlite(t *testing.T) {
+	// The group can be a number, as in the configurations for the classic API.
+	assert.Nil(t, parseMailer(`
+    mailerlite:
+      apikey_secret: "key"
+      group: 12345
+      from: "newsletter@example.com"
+`))
+	assert.Nil(t, parseMailer(`
+    mailerlite:
+      apikey_secret: "key"
+      group: "12345"
+      from: "newsletter@example.com"
+`))
+	err := parseMailer(`
+    mailerlite:
+      apikey_secret: "key"
+      group: 12345
+`)
+	assert.ErrorContains(t, err, "mailerlite: `from` is now required")
+}
+
+func TestOneEnginePerMailer(t *testing.T) {
+	err := parseMailer(`
+    mailerlite:
+      apikey_secret: "key"
+      group: 12345
+      from: "newsletter@example.com"
+    buttondown:
+      apikey_secret: "key"
+`)
+	assert.EqualError(t, err, "mailer english has more than one email engine: buttondown, mailerlite")
+
+	err = parseMailer(`
+    buttondown:
+      apikey_secret: "key"
+`)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,188 @@
+// The buttondown package is an email engine for Buttondown.
+//
+// Buttondown has one newsletter per API key, so each mailer needs its own
+// newsletter. Buttondown creates the plain text version of each email from
+// the HTML version, so the plain text content is not used.
+//
+// Buttondown emails don't have a name separate from their subject, so the
+// name, language and content hash are kept in the email's metadata.
+
+package buttondown
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi"
+)
+
+const defaultBaseUri = "https://api.buttondown.com/v1/"
+
+type buttondown struct {
+	api *httpapi.Client
+}
+
+type ButtondownOption func(*buttondownOptions)
+
+type buttondownOptions struct {
+	httpClient *http.Client
+	baseUri    string
+}
+
+// WithHttpClient sets the HTTP client used to talk to Buttondown.
+func WithHttpClient(client *http.Client) ButtondownOption {
+	return func(o *buttondownOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithBaseUri sets the URI of the API.
+func WithBaseUri(uri string) ButtondownOption {
+	return func(o *buttondownOptions) {
+		o.baseUri = uri
+	}
+}
+
+// ConnectButtondown returns an engine that sends emails to the newsletter for the given API key.
+func ConnectButtondown(apikey string, options ...ButtondownOption) (email.Engine, error) {
+	opts := &buttondownOptions{baseUri: defaultBaseUri}
+	for _, option := range options {
+		option(opts)
+	}
+	if !strings.HasSuffix(opts.baseUri, "/") {
+		opts.baseUri += "/"
+	}
+	api := httpapi.NewClient(opts.baseUri, opts.httpClient, func(req *http.Request) {
+		req.Header.Set("Authorization", "Token "+apikey)
+	})
+	return &buttondown{api: api}, nil
+}
+
+type metadata struct {
+	Name     string `json:"jtweb_name,omitempty"`
+	Language string `json:"jtweb_language,omitempty"`
+	Hash     string `json:"jtweb_hash,omitempty"`
+}
+
+type campaign struct {
+	id   string
+	when time.Time
+	bd   *buttondown
+}
+
+func (b *buttondown) Name() string {
+	return "buttondown"
+}
+
+func (b *buttondown) ScheduledCampaigns() ([]email.ScheduledCampaign, error) {
+	type emailResp struct {
+		Id          string   `json:"id"`
+		Subject     string   `json:"subject"`
+		PublishDate string   `json:"publish_date"`
+		Metadata    metadata `json:"metadata"`
+	}
+
+	var ret []email.ScheduledCampaign
+	next := "emails?status=scheduled"
+	for next != "" {
+		var resp struct {
+			Results []emailResp `json:"results"`
+			Next    *string     `json:"next"`
+		}
+		err := b.api.Do(context.Background(), "GET", next, nil, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range resp.Results {
+			when, err := time.Parse(time.RFC3339Nano, e.PublishDate)
+			if err != nil {
+				return nil, err
+			}
+			name := e.Metadata.Name
+			if name == "" {
+				name = e.Subject
+			}
+			ret = append(ret, email.ScheduledCampaign{Id: e.Id, Name: name, When: when, Hash: e.Metadata.Hash})
+		}
+		next = ""
+		if resp.Next != nil {
+			next = *resp.Next
+		}
+	}
+	return ret, nil
+}
+
+type emailReq struct {
+	Subject     string   `json:"subject"`
+	Body        string   `json:"body"`
+	Status      string   `json:"status,omitempty"`
+	PublishDate string   `json:"publish_date,omitempty"`
+	Metadata    metadata `json:"metadata"`
+}
+
+func newEmailReq(e *email.Email) *emailReq {
+	return &emailReq{
+		Subject:  e.Subject,
+		Body:     e.Html,
+		Metadata: metadata{Name: e.Name, Language: e.Language.Code(), Hash: email.ContentHash(e)},
+	}
+}
+
+func (b *buttondown) CreateCampaign(e *email.Email) (email.Campaign, error) {
+	req := newEmailReq(e)
+	req.Status = "draft"
+	var resp struct {
+		Id string `json:"id"`
+	}
+	err := b.api.Do(context.Background(), "POST", "emails", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign{id: resp.Id, when: e.Date, bd: b}, nil
+}
+
+func (b *buttondown) ScheduleTime(date time.Time) time.Time {
+	return date
+}
+
+func (b *buttondown) RescheduleCampaign(id string, date time.Time) error {
+	return (&campaign{id: id, when: date, bd: b}).Schedule()
+}
+
+// UpdateCampaign implements email.CampaignUpdater.
+func (b *buttondown) UpdateCampaign(id string, e *email.Email) error {
+	req := newEmailReq(e)
+	req.PublishDate = e.Date.UTC().Format(time.RFC3339)
+	return b.api.Do(context.Background(), "PATCH", fmt.Sprintf("emails/%s", url.PathEscape(id)), req, nil)
+}
+
+func (b *buttondown) CancelCampaign(id string) error {
+	return b.api.Do(context.Background(), "DELETE", fmt.Sprintf("emails/%s", url.PathEscape(id)), nil, nil)
+}
+
+func (c *campaign) Id() string {
+	return c.id
+}
+
+func (c *campaign) Schedule() error {
+	type scheduleReq struct {
+		Status      string `json:"status"`
+		PublishDate string `json:"publish_date"`
+	}
+
+	req := scheduleReq{Status: "scheduled", PublishDate: c.when.UTC().Format(time.RFC3339)}
+	return c.bd.api.Do(context.Background(), "PATCH", fmt.Sprintf("emails/%s", c.id), &req, nil)
+}
+
+func (c *campaign) Send() error {
+	type sendReq struct {
+		Status string `json:"status"`
+	}
+
+	return c.bd.api.Do(context.Background(), "PATCH", fmt.Sprintf("emails/%s", c.id), &sendReq{Status: "about_to_send"}, nil)
+}
@@ -0,0 +1,95 @@
+package buttondown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi/httpapitest"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+func newTestServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]httpapitest.Request) {
+	return httpapitest.NewServer(t, httpapitest.Bodies(responses), httpapitest.CheckRequest(func(req *http.Request) {
+		assert.Equal(t, "Token key", req.Header.Get("Authorization"))
+	}))
+}
+
+func TestScheduledCampaigns(t *testing.T) {
+	var server *httptest.Server
+	server, _ = newTestServer(t, map[string]string{
+		"GET /v1/emails?status=scheduled": `{"results":[{"id":"e1","subject":"Subject 1","publish_date":"2024-05-01T10:00:00Z","metadata":{"jtweb_name":"post-1","jtweb_hash":"abc"}}],` +
+			`"next":"/v1/emails?status=scheduled&page=2"}`,
+		"GET /v1/emails?status=scheduled&page=2": `{"results":[{"id":"e2","subject":"Created elsewhere","publish_date":"2024-05-02T10:00:00Z","metadata":{}}],"next":null}`,
+	})
+	e, err := ConnectButtondown("key", WithBaseUri(server.URL+"/v1"))
+	assert.Nil(t, err)
+	campaigns, err := e.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Equal(t, []email.ScheduledCampaign{
+		{Id: "e1", Name: "post-1", When: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Hash: "abc"},
+		{Id: "e2", Name: "Created elsewhere", When: time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)},
+	}, campaigns)
+}
+
+func TestCreateAndScheduleCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{
+		"POST /v1/emails": `{"id":"abc-123"}`,
+	})
+	b, err := ConnectButtondown("key", WithBaseUri(server.URL+"/v1/"))
+	assert.Nil(t, err)
+	e := &email.Email{
+		Name:      "post-1",
+		Language:  languages.LanguageEn,
+		Date:      time.Date(2024, 5, 1, 10, 0, 0, 0, time.FixedZone("CEST", 7200)),
+		Subject:   "Subject",
+		Html:      "<p>Hello</p>",
+		Plaintext: "Hello",
+	}
+	c, err := b.CreateCampaign(e)
+	assert.Nil(t, err)
+	assert.Equal(t, "abc-123", c.Id())
+	assert.Nil(t, c.Schedule())
+	assert.Nil(t, c.Send())
+
+	assert.Equal(t, []httpapitest.Request{
+		{Method: "POST", Path: "/v1/emails", Body: map[string]any{
+			"subject":  "Subject",
+			"body":     "<p>Hello</p>",
+			"status":   "draft",
+			"metadata": map[string]any{"jtweb_name": "post-1", "jtweb_language": "en", "jtweb_hash": email.ContentHash(e)},
+		}},
+		{Method: "PATCH", Path: "/v1/emails/abc-123", Body: map[string]any{"status": "scheduled", "publish_date": "2024-05-01T08:00:00Z"}},
+		{Method: "PATCH", Path: "/v1/emails/abc-123", Body: map[string]any{"status": "about_to_send"}},
+	}, *requests)
+}
+
+func TestChangeCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{})
+	b, err := ConnectButtondown("key", WithBaseUri(server.URL+"/v1/"))
+	assert.Nil(t, err)
+	e := &email.Email{
+		Name:     "post-1",
+		Language: languages.LanguageEn,
+		Date:     time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC),
+		Subject:  "New subject",
+		Html:     "<p>Hello again</p>",
+	}
+	assert.Nil(t, b.RescheduleCampaign("abc-123", time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)))
+	assert.Nil(t, b.(email.CampaignUpdater).UpdateCampaign("abc-123", e))
+	assert.Nil(t, b.CancelCampaign("abc-123"))
+
+	assert.Equal(t, []httpapitest.Request{
+		{Method: "PATCH", Path: "/v1/emails/abc-123", Body: map[string]any{"status": "scheduled", "publish_date": "2024-05-03T10:00:00Z"}},
+		{Method: "PATCH", Path: "/v1/emails/abc-123", Body: map[string]any{
+			"subject":      "New subject",
+			"body":         "<p>Hello again</p>",
+			"publish_date": "2024-05-02T10:00:00Z",
+			"metadata":     map[string]any{"jtweb_name": "post-1", "jtweb_language": "en", "jtweb_hash": email.ContentHash(e)},
+		}},
+		{Method: "DELETE", Path: "/v1/emails/abc-123"},
+	}, *requests)
+}
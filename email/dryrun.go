@@ -3,6 +3,7 @@ package email
 import (
 	"fmt"
 	"log"
+	"time"
 )
 
 type dryRunEngine struct {
@@ -10,8 +11,30 @@ type dryRunEngine struct {
 	lastId int64
 }
 
+// dryRunUpdater is a dryRunEngine for an engine that can update campaigns.
+type dryRunUpdater struct {
+	*dryRunEngine
+}
+
 func DryRunEngine(engine Engine) Engine {
-	return &dryRunEngine{engine: engine, lastId: 1000}
+	e := &dryRunEngine{engine: engine, lastId: 1000}
+	if _, ok := engine.(CampaignUpdater); ok {
+		return &dryRunUpdater{e}
+	}
+	return e
+}
+
+// AsStatsReporter returns the engine as a StatsReporter, or nil if it can't report statistics.
+// Engines wrapped by DryRunEngine still report statistics, since reading them changes nothing.
+func AsStatsReporter(engine Engine) StatsReporter {
+	switch e := engine.(type) {
+	case *dryRunEngine:
+		engine = e.engine
+	case *dryRunUpdater:
+		engine = e.engine
+	}
+	reporter, _ := engine.(StatsReporter)
+	return reporter
 }
 
 func (e *dryRunEngine) Name() string {
@@ -29,6 +52,25 @@ func (e *dryRunEngine) CreateCampaign(email *Email) (Campaign, error) {
 	return &nullCampaign{id: id, email: email}, nil
 }
 
+func (e *dryRunEngine) ScheduleTime(date time.Time) time.Time {
+	return e.engine.ScheduleTime(date)
+}
+
+func (e *dryRunEngine) RescheduleCampaign(id string, date time.Time) error {
+	log.Printf("[Dry run] Rescheduling campaign %s for %s", id, date.String())
+	return nil
+}
+
+func (e *dryRunEngine) CancelCampaign(id string) error {
+	log.Printf("[Dry run] Cancelling campaign %s", id)
+	return nil
+}
+
+func (e *dryRunUpdater) UpdateCampaign(id string, email *Email) error {
+	log.Printf("[Dry run] Updating campaign %s: %s", id, email.Name)
+	return nil
+}
+
 type nullCampaign struct {
 	id    int64
 	email *Email
@@ -5,10 +5,18 @@
 // language, subject line, and HTML and plain text contents.
 // Then you create a "campaign" for that email.
 // Finally, you can send that campaign immediately or schedule it campaign to go out on a particular day and time.
+//
+// Scheduled campaigns can be rescheduled or cancelled later, and some engines
+// can also update their content, so that edits to a post after its campaign
+// was scheduled don't go unnoticed.
 
 package email
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"time"
 
 	"jacobo.tarrio.org/jtweb/languages"
@@ -30,15 +38,52 @@ type Email struct {
 	Plaintext string
 }
 
+// ContentHash returns a hash of the email's subject and contents, to find out if a campaign's content is out of date.
+func ContentHash(email *Email) string {
+	h := sha256.New()
+	for _, s := range []string{email.Subject, email.Html, email.Plaintext} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// nameHashLength is the number of hexadecimal digits of the content hash that NameWithHash adds to the name.
+const nameHashLength = 16
+
+// NameWithHash returns the email's name followed by a shortened ContentHash,
+// for the engines that can only store the hash in the campaign's name.
+func NameWithHash(email *Email) string {
+	return email.Name + " #" + ContentHash(email)[:nameHashLength]
+}
+
+// SplitNameHash splits a campaign name made by NameWithHash into the email's name and the shortened hash.
+// If the name doesn't end in a hash, it is returned unchanged, with an empty hash.
+func SplitNameHash(name string) (string, string) {
+	i := strings.LastIndex(name, " #")
+	if i < 0 || len(name)-i-2 != nameHashLength {
+		return name, ""
+	}
+	hash := name[i+2:]
+	if _, err := hex.DecodeString(hash); err != nil {
+		return name, ""
+	}
+	return name[:i], hash
+}
+
 // ScheduledCampaign contains the data for a scheduled campaign.
 type ScheduledCampaign struct {
+	// The campaign's identifier.
+	Id string
 	// The campaign's name.
 	Name string
 	// The time the campaign is scheduled for.
 	When time.Time
+	// The ContentHash of the campaign's email, or a prefix of it, or empty if the engine can't tell.
+	Hash string
 }
 
-// Engine provides methods to create new campaigns and list existing campaigns.
+// Engine provides methods to create new campaigns and list and modify existing campaigns.
 type Engine interface {
 	// Returns the engine's name.
 	Name() string
@@ -46,6 +91,19 @@ type Engine interface {
 	ScheduledCampaigns() ([]ScheduledCampaign, error)
 	// Creates a new campaign.
 	CreateCampaign(email *Email) (Campaign, error)
+	// Returns the time a campaign for the given publish date will be scheduled for.
+	// Some engines can only schedule campaigns at certain times.
+	ScheduleTime(date time.Time) time.Time
+	// Changes the publish date of a scheduled campaign.
+	RescheduleCampaign(id string, date time.Time) error
+	// Cancels a scheduled campaign and deletes it.
+	CancelCampaign(id string) error
+}
+
+// CampaignUpdater is implemented by the engines that can change the content of a scheduled campaign.
+type CampaignUpdater interface {
+	// Replaces the subject, contents and publish date of a scheduled campaign with the email's.
+	UpdateCampaign(id string, email *Email) error
 }
 
 // Campaign provides methods to send or schedule a campaign.
@@ -57,3 +115,37 @@ type Campaign interface {
 	// Schedules the campaign for the email's publish date. It is undefined what happens if the date has already passed.
 	Schedule() error
 }
+
+// CampaignStats contains the engagement statistics of a sent campaign.
+type CampaignStats struct {
+	// The campaign's identifier.
+	Id string
+	// The campaign's name.
+	Name string
+	// When the campaign was sent.
+	Sent time.Time
+	// How many subscribers the campaign was sent to.
+	Recipients int
+	// How many recipients opened the email.
+	Opens int
+	// How many recipients clicked on a link in the email.
+	Clicks int
+	// How many recipients unsubscribed from the email.
+	Unsubscribes int
+}
+
+// StatsReporter is implemented by the engines that can report the engagement statistics of sent campaigns.
+type StatsReporter interface {
+	// Returns the statistics of the campaigns that have been sent.
+	CampaignStats() ([]CampaignStats, error)
+}
+
+// SubscriberManager is implemented by the engines that can add and remove subscribers.
+type SubscriberManager interface {
+	// Subscribe adds a subscriber, or updates their name if they were already subscribed.
+	Subscribe(ctx context.Context, email string, name string) error
+	// Unsubscribe removes a subscriber. It does nothing if they weren't subscribed.
+	Unsubscribe(ctx context.Context, email string) error
+	// IsSubscribed returns whether an address is subscribed.
+	IsSubscribed(ctx context.Context, email string) (bool, error)
+}
@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/page"
+)
+
+// DigestSchedule defines the periods whose pages are grouped into a digest email.
+// Each period ends when the next one starts, and its digest is scheduled for that moment.
+type DigestSchedule struct {
+	// If true, periods start on the first day of each month; otherwise, they start every week.
+	Monthly bool
+	// For weekly digests, the day of the week when periods start.
+	Weekday time.Weekday
+	// The time of the day when periods start.
+	Hour   int
+	Minute int
+	// The timezone for the day and time when periods start. The default is UTC.
+	Location *time.Location
+}
+
+// periodEnd returns the end of the period that contains the given time.
+func (d *DigestSchedule) periodEnd(t time.Time) time.Time {
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	if d.Monthly {
+		end := time.Date(t.Year(), t.Month(), 1, d.Hour, d.Minute, 0, 0, loc)
+		if !end.After(t) {
+			end = time.Date(t.Year(), t.Month()+1, 1, d.Hour, d.Minute, 0, 0, loc)
+		}
+		return end
+	}
+	days := (int(d.Weekday) - int(t.Weekday()) + 7) % 7
+	end := time.Date(t.Year(), t.Month(), t.Day()+days, d.Hour, d.Minute, 0, 0, loc)
+	if !end.After(t) {
+		end = time.Date(t.Year(), t.Month(), t.Day()+days+7, d.Hour, d.Minute, 0, 0, loc)
+	}
+	return end
+}
+
+// periodStart returns the start of the period that ends at the given time.
+func (d *DigestSchedule) periodStart(end time.Time) time.Time {
+	if d.Monthly {
+		return time.Date(end.Year(), end.Month()-1, 1, d.Hour, d.Minute, 0, 0, end.Location())
+	}
+	return time.Date(end.Year(), end.Month(), end.Day()-7, d.Hour, d.Minute, 0, 0, end.Location())
+}
+
+// Groups the pages published in each period into a single digest email, instead of sending an email per page.
+func Digest(schedule DigestSchedule) EmailGeneratorOption {
+	return func(g *EmailGenerator) {
+		g.digest = &schedule
+	}
+}
+
+// digestEmails returns the digest emails for the periods that end after the not-before time, newest first.
+// The digest for a period that hasn't ended yet contains the pages that exist so far; when more pages are added,
+// its content hash changes, and Plan updates its campaign.
+func (g *EmailGenerator) digestEmails() ([]*email.Email, error) {
+	var ends []time.Time
+	pages := map[time.Time][]*page.Page{}
+	for _, p := range g.selectedPages() {
+		end := g.digest.periodEnd(p.Header.PublishDate)
+		if g.notBefore != nil && end.Before(*g.notBefore) {
+			continue
+		}
+		if _, ok := pages[end]; !ok {
+			ends = append(ends, end)
+		}
+		pages[end] = append(pages[end], p)
+	}
+
+	var emails []*email.Email
+	for _, end := range ends {
+		e, err := g.makeDigest(end, pages[end])
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, nil
+}
+
+// digestFor returns the digest email for the period that contains the given page.
+func (g *EmailGenerator) digestFor(p *page.Page) (*email.Email, error) {
+	end := g.digest.periodEnd(p.Header.PublishDate)
+	var pages []*page.Page
+	for _, sp := range g.selectedPages() {
+		if g.digest.periodEnd(sp.Header.PublishDate).Equal(end) {
+			pages = append(pages, sp)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("page %s is not sent in a digest", p.Name)
+	}
+	return g.makeDigest(end, pages)
+}
+
+// makeDigest returns the digest email for a period. The pages are listed oldest first.
+func (g *EmailGenerator) makeDigest(end time.Time, pages []*page.Page) (*email.Email, error) {
+	start := g.digest.periodStart(end)
+	ordered := make([]*page.Page, len(pages))
+	for i, p := range pages {
+		ordered[len(pages)-1-i] = p
+	}
+
+	var e email.Email
+	e.Name = g.makeDigestName(end)
+	e.Subject = g.makeDigestSubject(start, end)
+	e.Language = g.language
+	e.Date = end
+	{
+		sb := strings.Builder{}
+		err := g.contents.OutputAsDigest(&sb, g.language, start, end, ordered)
+		if err != nil {
+			return nil, err
+		}
+		e.Html = sb.String()
+	}
+	{
+		sb := strings.Builder{}
+		err := g.contents.OutputAsPlainDigest(&sb, g.language, start, end, ordered)
+		if err != nil {
+			return nil, err
+		}
+		e.Plaintext = sb.String()
+	}
+	return &e, nil
+}
+
+func defaultMakeDigestName(end time.Time) string {
+	return "digest-" + end.Format("2006-01-02")
+}
+
+func (g *EmailGenerator) defaultMakeDigestSubject(start time.Time, end time.Time) string {
+	msg := getMessages(g.language.Code())
+	// The period's last day is the day of its last instant.
+	last := end.Add(-time.Nanosecond)
+	return fmt.Sprintf(msg.digestSubject, g.language.FormatDate(start), g.language.FormatDate(last))
+}
@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/languages"
+	"jacobo.tarrio.org/jtweb/site"
+)
+
+func TestPeriodEnd(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	assert.Nil(t, err)
+	weekly := &DigestSchedule{Weekday: time.Monday, Hour: 8, Location: madrid}
+	monthly := &DigestSchedule{Monthly: true, Hour: 8, Minute: 30}
+
+	// Wednesday.
+	end := weekly.periodEnd(time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2024, 5, 13, 8, 0, 0, 0, madrid).Equal(end))
+	assert.True(t, time.Date(2024, 5, 6, 8, 0, 0, 0, madrid).Equal(weekly.periodStart(end)))
+	// Monday, before and at the time.
+	assert.True(t, time.Date(2024, 5, 13, 8, 0, 0, 0, madrid).Equal(weekly.periodEnd(time.Date(2024, 5, 13, 7, 59, 0, 0, madrid))))
+	assert.True(t, time.Date(2024, 5, 20, 8, 0, 0, 0, madrid).Equal(weekly.periodEnd(time.Date(2024, 5, 13, 8, 0, 0, 0, madrid))))
+
+	end = monthly.periodEnd(time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2025, 1, 1, 8, 30, 0, 0, time.UTC).Equal(end))
+	assert.True(t, time.Date(2024, 12, 1, 8, 30, 0, 0, time.UTC).Equal(monthly.periodStart(end)))
+	assert.True(t, time.Date(2024, 12, 1, 8, 30, 0, 0, time.UTC).Equal(monthly.periodEnd(time.Date(2024, 12, 1, 8, 0, 0, 0, time.UTC))))
+}
+
+func TestDigest(t *testing.T) {
+	contents := makeContents(t, map[string]string{
+		"a": "2024-05-06 10:00 +0000",
+		"b": "2024-05-08 10:00 +0000",
+		"c": "2024-05-13 09:00 +0000",
+		"d": "2024-05-15 10:00 +0000",
+	})
+	schedule := DigestSchedule{Weekday: time.Monday, Hour: 8}
+	g := NewEmailGenerator(contents, languages.LanguageEn, &fakeEngine{}).WithOptions(
+		Digest(schedule), NamePrefix("Prefix"), SubjectPrefix("Prefix"))
+
+	changes, err := g.Plan()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(changes))
+	assert.Equal(t, Create, changes[0].Action)
+	assert.Equal(t, "Prefix digest-2024-05-20", changes[0].Email.Name)
+	assert.Equal(t, "Prefix: Posts from May 13, 2024 to May 20, 2024", changes[0].Email.Subject)
+	assert.Equal(t, "Title of c;Title of d;", changes[0].Email.Plaintext)
+	assert.True(t, time.Date(2024, 5, 20, 8, 0, 0, 0, time.UTC).Equal(changes[0].Email.Date))
+	assert.Equal(t, "Prefix digest-2024-05-13", changes[1].Email.Name)
+	assert.Equal(t, "Title of a;Title of b;", changes[1].Email.Plaintext)
+	assert.Contains(t, changes[1].Email.Html, "<p>Title of a</p><p>Title of b</p>")
+
+	e, err := g.Email("b")
+	assert.Nil(t, err)
+	assert.Equal(t, "Prefix digest-2024-05-13", e.Name)
+
+	// Periods that end before the not-before time are skipped, but all the pages in the other periods are sent.
+	g = NewEmailGenerator(contents, languages.LanguageEn, &fakeEngine{}).WithOptions(
+		Digest(schedule), NotBefore(time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)))
+	changes, err = g.Plan()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, "Title of c;Title of d;", changes[0].Email.Plaintext)
+}
+
+func TestDigestForCurrentPeriod(t *testing.T) {
+	schedule := DigestSchedule{Weekday: time.Monday, Hour: 8}
+	before := makeContents(t, map[string]string{
+		"c": "2024-05-13 09:00 +0000",
+	})
+	g := NewEmailGenerator(before, languages.LanguageEn, &fakeEngine{}).WithOptions(Digest(schedule))
+	changes, err := g.Plan()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(changes))
+	// The engine only reports a prefix of the hash, like the engines that store it in the campaign's name.
+	scheduled := email.ScheduledCampaign{Id: "1", Name: "digest-2024-05-20", When: changes[0].Email.Date, Hash: email.ContentHash(changes[0].Email)[:16]}
+
+	// The digest for the current period was created with the pages it had then.
+	// It is created again when a page is added to the period.
+	after := makeContents(t, map[string]string{
+		"c": "2024-05-13 09:00 +0000",
+		"d": "2024-05-15 10:00 +0000",
+	})
+	for _, tc := range []struct {
+		contents *site.Contents
+		actions  []string
+	}{
+		{before, nil},
+		{after, []string{"cancel 1", "create digest-2024-05-20", "schedule digest-2024-05-20 2024-05-20T08:00:00Z"}},
+	} {
+		engine := &fakeEngine{scheduled: []email.ScheduledCampaign{scheduled}}
+		g := NewEmailGenerator(tc.contents, languages.LanguageEn, engine).WithOptions(Digest(schedule), UpdateScheduled())
+		g.now = func() time.Time { return time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC) }
+		assert.Nil(t, g.SendMails())
+		assert.Equal(t, tc.actions, engine.actions)
+	}
+}
@@ -1,8 +1,11 @@
 package generator
 
 import (
+	"fmt"
 	"log"
+	"slices"
 	"strings"
+	"time"
 
 	"jacobo.tarrio.org/jtweb/email"
 	"jacobo.tarrio.org/jtweb/languages"
@@ -12,20 +15,85 @@ import (
 
 // EmailGenerator extracts pages and converts them into emails.
 type EmailGenerator struct {
-	contents    *site.Contents
-	language    languages.Language
-	engine      email.Engine
-	filter      func(*page.Page) bool
-	makeName    func(*page.Page) string
-	makeSubject func(*page.Page) string
-	err         error
+	contents          *site.Contents
+	language          languages.Language
+	engine            email.Engine
+	filter            func(*page.Page) bool
+	rules             func(*page.Page) bool
+	minInterval       time.Duration
+	notBefore         *time.Time
+	makeName          func(*page.Page) string
+	makeSubject       func(*page.Page) string
+	digest            *DigestSchedule
+	makeDigestName    func(end time.Time) string
+	makeDigestSubject func(start time.Time, end time.Time) string
+	scheduled         map[string]*email.ScheduledCampaign
+	now               func() time.Time
+	err               error
 }
 
 type EmailGeneratorOption func(*EmailGenerator)
 
 // Creates a new EmailGenerator that will generate emails for the pages in the given site and language.
 func NewEmailGenerator(c *site.Contents, language languages.Language, engine email.Engine) *EmailGenerator {
-	return &EmailGenerator{c, language, engine, defaultFilter, defaultMakeName, defaultMakeSubject, nil}
+	g := &EmailGenerator{
+		contents:       c,
+		language:       language,
+		engine:         engine,
+		filter:         defaultFilter,
+		rules:          defaultRules,
+		makeName:       defaultMakeName,
+		makeSubject:    defaultMakeSubject,
+		makeDigestName: defaultMakeDigestName,
+		now:            time.Now,
+	}
+	g.makeDigestSubject = g.defaultMakeDigestSubject
+	return g
+}
+
+// Action is a change to a campaign.
+type Action int
+
+const (
+	// Create a campaign for a page that doesn't have one.
+	Create Action = iota
+	// Change the date of a campaign whose page's publish date changed.
+	Reschedule
+	// Replace the content and date of a campaign whose page's content changed.
+	Update
+	// Cancel a campaign whose page's content changed and create it again, for engines that can't update campaigns.
+	Recreate
+)
+
+func (a Action) String() string {
+	switch a {
+	case Create:
+		return "create"
+	case Reschedule:
+		return "reschedule"
+	case Update:
+		return "update"
+	case Recreate:
+		return "recreate"
+	default:
+		return fmt.Sprintf("action %d", int(a))
+	}
+}
+
+// Change is a change to a campaign that the EmailGenerator plans to make.
+type Change struct {
+	Action Action
+	// The email for the page.
+	Email *email.Email
+	// The page's current campaign, or nil if it doesn't have one.
+	Campaign *email.ScheduledCampaign
+}
+
+func (c *Change) String() string {
+	if c.Campaign == nil {
+		return fmt.Sprintf("%s [%s] for %s", c.Action, c.Email.Name, c.Email.Date.String())
+	}
+	return fmt.Sprintf("%s [%s] (id %s) scheduled for %s to %s", c.Action, c.Email.Name, c.Campaign.Id, c.Campaign.When.String(), c.Email.Date.String())
 }
 
 // Adds options to the EmailGenerator.
@@ -56,6 +124,77 @@ func NotScheduled() EmailGeneratorOption {
 	}
 }
 
+// Updates the pages' scheduled campaigns when the pages' publish dates or contents change,
+// and creates campaigns for the pages that don't have one.
+// Campaigns whose scheduled time has already passed are left alone.
+func UpdateScheduled() EmailGeneratorOption {
+	return func(g *EmailGenerator) {
+		campaigns, err := g.engine.ScheduledCampaigns()
+		if err != nil {
+			g.err = err
+			return
+		}
+		g.scheduled = map[string]*email.ScheduledCampaign{}
+		for i := range campaigns {
+			g.scheduled[campaigns[i].Name] = &campaigns[i]
+		}
+	}
+}
+
+// Only sends the pages that have at least one of the given tags.
+func WithTags(tags ...string) EmailGeneratorOption {
+	return func(g *EmailGenerator) {
+		if len(tags) == 0 {
+			return
+		}
+		prev := g.rules
+		g.rules = func(p *page.Page) bool {
+			return hasAny(p.Header.Tags, tags) && prev(p)
+		}
+	}
+}
+
+// Doesn't send the pages that have any of the given tags.
+func WithoutTags(tags ...string) EmailGeneratorOption {
+	return func(g *EmailGenerator) {
+		if len(tags) == 0 {
+			return
+		}
+		prev := g.rules
+		g.rules = func(p *page.Page) bool {
+			return !hasAny(p.Header.Tags, tags) && prev(p)
+		}
+	}
+}
+
+// Only sends the pages that belong to one of the given series.
+func InSeries(series ...string) EmailGeneratorOption {
+	return func(g *EmailGenerator) {
+		if len(series) == 0 {
+			return
+		}
+		prev := g.rules
+		g.rules = func(p *page.Page) bool {
+			return hasAny([]string{p.Header.Series}, series) && prev(p)
+		}
+	}
+}
+
+// Doesn't send a page if it was published less than the given interval after the previous page that was sent.
+func MinInterval(interval time.Duration) EmailGeneratorOption {
+	return func(g *EmailGenerator) {
+		g.minInterval = interval
+	}
+}
+
+// Doesn't send the pages published before the given time.
+// Those pages are still taken into account to apply the minimum interval between pages.
+func NotBefore(t time.Time) EmailGeneratorOption {
+	return func(g *EmailGenerator) {
+		g.notBefore = &t
+	}
+}
+
 // Adds a prefix to the email names.
 func NamePrefix(prefix string) EmailGeneratorOption {
 	return func(g *EmailGenerator) {
@@ -63,6 +202,10 @@ func NamePrefix(prefix string) EmailGeneratorOption {
 		g.makeName = func(p *page.Page) string {
 			return prefix + " " + prev(p)
 		}
+		prevDigest := g.makeDigestName
+		g.makeDigestName = func(end time.Time) string {
+			return prefix + " " + prevDigest(end)
+		}
 	}
 }
 
@@ -77,61 +220,241 @@ func SubjectPrefix(prefix string) EmailGeneratorOption {
 				return prefix + " " + prev(p)
 			}
 		}
+		prevDigest := g.makeDigestSubject
+		g.makeDigestSubject = func(start time.Time, end time.Time) string {
+			return prefix + ": " + prevDigest(start, end)
+		}
 	}
 }
 
-func (g *EmailGenerator) SendMails() error {
+// Plan returns the changes to the campaigns that SendMails would make.
+func (g *EmailGenerator) Plan() ([]*Change, error) {
 	if g.err != nil {
-		return g.err
+		return nil, g.err
 	}
 
-	toc, ok := g.contents.Toc[g.language]
-	if !ok {
-		return nil
+	emails, err := g.emails()
+	if err != nil {
+		return nil, err
+	}
+
+	_, canUpdate := g.engine.(email.CampaignUpdater)
+	var changes []*Change
+	for _, e := range emails {
+		campaign, ok := g.scheduled[e.Name]
+		if !ok {
+			changes = append(changes, &Change{Action: Create, Email: e})
+			continue
+		}
+		if !campaign.When.After(g.now()) {
+			continue
+		}
+		contentChanged := campaign.Hash != "" && !strings.HasPrefix(email.ContentHash(e), campaign.Hash)
+		dateChanged := !sameTime(campaign.When, g.engine.ScheduleTime(e.Date))
+		if contentChanged && canUpdate {
+			changes = append(changes, &Change{Action: Update, Email: e, Campaign: campaign})
+		} else if contentChanged {
+			changes = append(changes, &Change{Action: Recreate, Email: e, Campaign: campaign})
+		} else if dateChanged {
+			changes = append(changes, &Change{Action: Reschedule, Email: e, Campaign: campaign})
+		}
 	}
+	return changes, nil
+}
 
+// emails returns the emails to send, newest first: one per page, or one per period in digest mode.
+func (g *EmailGenerator) emails() ([]*email.Email, error) {
+	if g.digest != nil {
+		return g.digestEmails()
+	}
 	var emails []*email.Email
-	for _, name := range toc.All {
-		page := g.contents.Pages[name]
-		if !g.filter(page) {
+	for _, page := range g.pagesToSend() {
+		e, err := g.makeEmail(page)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, nil
+}
+
+// pagesToSend returns the pages to send one by one, newest first.
+func (g *EmailGenerator) pagesToSend() []*page.Page {
+	var pages []*page.Page
+	for _, p := range g.selectedPages() {
+		if g.notBefore != nil && p.Header.PublishDate.Before(*g.notBefore) {
 			continue
 		}
-		var e email.Email
-		e.Name = g.makeName(page)
-		e.Subject = g.makeSubject(page)
-		e.Language = page.Header.Language
-		e.Date = page.Header.PublishDate
-		{
-			sb := strings.Builder{}
-			err := g.contents.OutputAsEmail(&sb, page)
-			if err != nil {
-				return err
-			}
-			e.Html = sb.String()
+		if !g.filter(p) {
+			continue
 		}
-		{
-			sb := strings.Builder{}
-			err := g.contents.OutputAsPlainEmail(&sb, page)
-			if err != nil {
-				return err
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+// selectedPages returns the pages that the selection rules and the minimum interval choose to send, newest first.
+func (g *EmailGenerator) selectedPages() []*page.Page {
+	toc := g.contents.Toc[g.language]
+	var selected []*page.Page
+	var last *time.Time
+	for i := len(toc.All) - 1; i >= 0; i-- {
+		p := g.contents.Pages[toc.All[i]]
+		if !g.rules(p) {
+			continue
+		}
+		if last != nil && p.Header.PublishDate.Before(last.Add(g.minInterval)) {
+			continue
+		}
+		last = &p.Header.PublishDate
+		selected = append(selected, p)
+	}
+	slices.Reverse(selected)
+	return selected
+}
+
+// hasAny returns whether any of the values is in the wanted list, ignoring case.
+func hasAny(values []string, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if strings.EqualFold(value, w) {
+				return true
 			}
-			e.Plaintext = sb.String()
 		}
-		emails = append(emails, &e)
+	}
+	return false
+}
+
+// sameTime returns whether two times are the same, ignoring fractions of a second, which some engines drop.
+func sameTime(a time.Time, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
+
+// Email returns the email for a page, as it would be sent.
+// In digest mode, it returns the digest that contains the page.
+func (g *EmailGenerator) Email(name page.Name) (*email.Email, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+	p, ok := g.contents.Pages[name]
+	if !ok {
+		return nil, fmt.Errorf("page not found: %s", name)
+	}
+	if p.Header.Language != g.language {
+		return nil, fmt.Errorf("page %s is in %s, not %s", name, p.Header.Language.Code(), g.language.Code())
+	}
+	if g.digest != nil {
+		return g.digestFor(p)
+	}
+	return g.makeEmail(p)
+}
+
+// NextPage returns the name of the first page that will be sent after the given time, or the newest page
+// if there is none. It returns an empty name if there are no pages.
+func (g *EmailGenerator) NextPage(after time.Time) page.Name {
+	var next page.Name
+	for _, p := range g.selectedPages() {
+		if !g.filter(p) {
+			continue
+		}
+		if next == "" || p.Header.PublishDate.After(after) {
+			next = p.Name
+		}
+	}
+	return next
+}
+
+// CampaignPages returns the names of the pages in the generator's language, by the names of their campaigns.
+func (g *EmailGenerator) CampaignPages() map[string]page.Name {
+	out := map[string]page.Name{}
+	for _, name := range g.contents.Toc[g.language].All {
+		out[g.makeName(g.contents.Pages[name])] = name
+	}
+	return out
+}
+
+func (g *EmailGenerator) makeEmail(page *page.Page) (*email.Email, error) {
+	var e email.Email
+	e.Name = g.makeName(page)
+	e.Subject = g.makeSubject(page)
+	e.Language = page.Header.Language
+	e.Date = page.Header.PublishDate
+	{
+		sb := strings.Builder{}
+		err := g.contents.OutputAsEmail(&sb, page)
+		if err != nil {
+			return nil, err
+		}
+		e.Html = sb.String()
+	}
+	{
+		sb := strings.Builder{}
+		err := g.contents.OutputAsPlainEmail(&sb, page)
+		if err != nil {
+			return nil, err
+		}
+		e.Plaintext = sb.String()
+	}
+	return &e, nil
+}
+
+// SendMails creates and schedules the campaigns for the pages, and updates the existing campaigns if needed.
+// The planned changes are logged before they are made.
+func (g *EmailGenerator) SendMails() error {
+	changes, err := g.Plan()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Planned %d changes to the %s campaigns", len(changes), g.engine.Name())
+	for _, change := range changes {
+		log.Printf("Planned change: %s", change)
 	}
 
-	for _, email := range emails {
-		campaign, err := g.engine.CreateCampaign(email)
+	for _, change := range changes {
+		err := g.apply(change)
 		if err != nil {
 			return err
 		}
-		err = campaign.Schedule()
+	}
+
+	return nil
+}
+
+func (g *EmailGenerator) apply(change *Change) error {
+	e := change.Email
+	switch change.Action {
+	case Reschedule:
+		err := g.engine.RescheduleCampaign(change.Campaign.Id, e.Date)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rescheduled [%s] id %s for %s", e.Name, change.Campaign.Id, e.Date.String())
+		return nil
+	case Update:
+		err := g.engine.(email.CampaignUpdater).UpdateCampaign(change.Campaign.Id, e)
 		if err != nil {
 			return err
 		}
-		log.Printf("Scheduled [%s] as id %s for %s", email.Name, campaign.Id(), email.Date.String())
+		log.Printf("Updated [%s] id %s for %s", e.Name, change.Campaign.Id, e.Date.String())
+		return nil
+	case Recreate:
+		err := g.engine.CancelCampaign(change.Campaign.Id)
+		if err != nil {
+			return err
+		}
+		log.Printf("Cancelled [%s] id %s", e.Name, change.Campaign.Id)
 	}
 
+	campaign, err := g.engine.CreateCampaign(e)
+	if err != nil {
+		return err
+	}
+	err = campaign.Schedule()
+	if err != nil {
+		return err
+	}
+	log.Printf("Scheduled [%s] as id %s for %s", e.Name, campaign.Id(), e.Date.String())
 	return nil
 }
 
@@ -139,6 +462,10 @@ func defaultFilter(*page.Page) bool {
 	return true
 }
 
+func defaultRules(p *page.Page) bool {
+	return !p.Header.NoEmail
+}
+
 func defaultMakeName(p *page.Page) string {
 	return string(p.Name)
 }
@@ -0,0 +1,248 @@
+package generator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	configtesting "jacobo.tarrio.org/jtweb/config/testing"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/languages"
+	"jacobo.tarrio.org/jtweb/page"
+	"jacobo.tarrio.org/jtweb/site"
+)
+
+type fakeEngine struct {
+	scheduled []email.ScheduledCampaign
+	actions   []string
+}
+
+func (e *fakeEngine) Name() string {
+	return "fake"
+}
+
+func (e *fakeEngine) ScheduledCampaigns() ([]email.ScheduledCampaign, error) {
+	return e.scheduled, nil
+}
+
+func (e *fakeEngine) CreateCampaign(em *email.Email) (email.Campaign, error) {
+	e.actions = append(e.actions, "create "+em.Name)
+	return &fakeCampaign{e: e, email: em}, nil
+}
+
+func (e *fakeEngine) ScheduleTime(date time.Time) time.Time {
+	return date.Truncate(15 * time.Minute)
+}
+
+func (e *fakeEngine) RescheduleCampaign(id string, date time.Time) error {
+	e.actions = append(e.actions, fmt.Sprintf("reschedule %s %s", id, date.UTC().Format(time.RFC3339)))
+	return nil
+}
+
+func (e *fakeEngine) CancelCampaign(id string) error {
+	e.actions = append(e.actions, "cancel "+id)
+	return nil
+}
+
+type fakeUpdaterEngine struct {
+	fakeEngine
+}
+
+func (e *fakeUpdaterEngine) UpdateCampaign(id string, em *email.Email) error {
+	e.actions = append(e.actions, "update "+id+" "+em.Name)
+	return nil
+}
+
+type fakeCampaign struct {
+	e     *fakeEngine
+	email *email.Email
+}
+
+func (c *fakeCampaign) Id() string {
+	return "new"
+}
+
+func (c *fakeCampaign) Send() error {
+	c.e.actions = append(c.e.actions, "send "+c.email.Name)
+	return nil
+}
+
+func (c *fakeCampaign) Schedule() error {
+	c.e.actions = append(c.e.actions, fmt.Sprintf("schedule %s %s", c.email.Name, c.email.Date.UTC().Format(time.RFC3339)))
+	return nil
+}
+
+var now = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+func makeContents(t *testing.T, pages map[string]string) *site.Contents {
+	headers := map[string]string{}
+	for name, date := range pages {
+		headers[name] = "publish_date: \"" + date + "\"\n"
+	}
+	return makeContentsWithHeaders(t, headers)
+}
+
+func makeContentsWithHeaders(t *testing.T, pages map[string]string) *site.Contents {
+	config := configtesting.NewFakeConfig()
+	assert.Nil(t, config.TemplateBase.GoTo("email-en.tmpl").CreateBytes([]byte("<p>{{.Title}}</p>{{.Content}}")))
+	assert.Nil(t, config.TemplateBase.GoTo("email-plain-en.tmpl").CreateBytes([]byte("{{.Title}}")))
+	assert.Nil(t, config.TemplateBase.GoTo("digest-en.tmpl").CreateBytes([]byte("{{range .Stories}}<p>{{.Title}}</p>{{end}}")))
+	assert.Nil(t, config.TemplateBase.GoTo("digest-plain-en.tmpl").CreateBytes([]byte("{{range .Stories}}{{.Title}};{{end}}")))
+	for name, header := range pages {
+		assert.Nil(t, config.InputBase.GoTo(name+".md").CreateBytes([]byte("<!--HEADER\n"+
+			"title: Title of "+name+"\n"+
+			header+
+			"-->\n"+
+			"The content\n")))
+	}
+	rawContent, err := site.Read(config)
+	assert.Nil(t, err)
+	content, err := rawContent.Index(nil, nil)
+	assert.Nil(t, err)
+	return content
+}
+
+func TestPlanAndSend(t *testing.T) {
+	contents := makeContents(t, map[string]string{
+		"new":         "2024-05-10 10:00 +0000",
+		"unchanged":   "2024-05-11 10:00 +0000",
+		"rescheduled": "2024-05-12 10:00 +0000",
+		"rounded":     "2024-05-13 10:05 +0000",
+		"edited":      "2024-05-14 10:00 +0000",
+		"past":        "2024-05-15 10:00 +0000",
+	})
+	g := NewEmailGenerator(contents, languages.LanguageEn, &fakeEngine{})
+	hashes := map[string]string{}
+	for _, name := range []string{"unchanged", "rescheduled", "rounded", "edited"} {
+		e, err := g.makeEmail(contents.Pages[page.Name(name)])
+		assert.Nil(t, err)
+		hashes[name] = email.ContentHash(e)
+	}
+
+	plain := &fakeEngine{}
+	updater := &fakeUpdaterEngine{}
+	for _, tc := range []struct {
+		engine     email.Engine
+		fake       *fakeEngine
+		editAction Action
+		actions    []string
+	}{
+		{plain, plain, Recreate, []string{
+			"create new", "schedule new 2024-05-10T10:00:00Z",
+			"reschedule 2 2024-05-12T10:00:00Z",
+			"cancel 4", "create edited", "schedule edited 2024-05-14T10:00:00Z",
+		}},
+		{updater, &updater.fakeEngine, Update, []string{
+			"create new", "schedule new 2024-05-10T10:00:00Z",
+			"reschedule 2 2024-05-12T10:00:00Z",
+			"update 4 edited",
+		}},
+	} {
+		tc.fake.scheduled = []email.ScheduledCampaign{
+			{Id: "1", Name: "unchanged", When: time.Date(2024, 5, 11, 10, 0, 0, 0, time.UTC), Hash: hashes["unchanged"]},
+			{Id: "2", Name: "rescheduled", When: time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC), Hash: hashes["rescheduled"]},
+			{Id: "3", Name: "rounded", When: time.Date(2024, 5, 13, 10, 0, 0, 0, time.UTC)},
+			{Id: "4", Name: "edited", When: time.Date(2024, 5, 14, 10, 0, 0, 0, time.UTC), Hash: "old"},
+			// Already being sent.
+			{Id: "5", Name: "past", When: time.Date(2024, 4, 30, 10, 0, 0, 0, time.UTC), Hash: "old"},
+		}
+		g := NewEmailGenerator(contents, languages.LanguageEn, tc.engine).WithOptions(UpdateScheduled())
+		g.now = func() time.Time { return now }
+
+		changes, err := g.Plan()
+		assert.Nil(t, err)
+		actions := map[string]Action{}
+		for _, c := range changes {
+			actions[c.Email.Name] = c.Action
+		}
+		assert.Equal(t, map[string]Action{"new": Create, "rescheduled": Reschedule, "edited": tc.editAction}, actions)
+
+		assert.Nil(t, g.SendMails())
+		assert.ElementsMatch(t, tc.actions, tc.fake.actions)
+	}
+}
+
+func TestPlanWithoutHashes(t *testing.T) {
+	contents := makeContents(t, map[string]string{
+		"edited":    "2024-05-10 10:00 +0000",
+		"moved":     "2024-05-11 10:00 +0000",
+		"unchanged": "2024-05-12 10:00 +0000",
+		"stale":     "2024-05-13 10:00 +0000",
+	})
+	g := NewEmailGenerator(contents, languages.LanguageEn, &fakeEngine{})
+	e, err := g.makeEmail(contents.Pages[page.Name("unchanged")])
+	assert.Nil(t, err)
+	shortHash := email.ContentHash(e)[:16]
+
+	engine := &fakeEngine{scheduled: []email.ScheduledCampaign{
+		// The engine can't tell if these campaigns' content is out of date, so only their dates are checked.
+		{Id: "1", Name: "edited", When: time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC)},
+		{Id: "2", Name: "moved", When: time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC)},
+		// The engine only knows a prefix of these campaigns' content hashes.
+		{Id: "3", Name: "unchanged", When: time.Date(2024, 5, 12, 10, 0, 0, 0, time.UTC), Hash: shortHash},
+		{Id: "4", Name: "stale", When: time.Date(2024, 5, 13, 10, 0, 0, 0, time.UTC), Hash: "0123456789abcdef"},
+	}}
+	g = NewEmailGenerator(contents, languages.LanguageEn, engine).WithOptions(UpdateScheduled())
+	g.now = func() time.Time { return now }
+
+	changes, err := g.Plan()
+	assert.Nil(t, err)
+	actions := map[string]Action{}
+	for _, c := range changes {
+		actions[c.Email.Name] = c.Action
+	}
+	assert.Equal(t, map[string]Action{"moved": Reschedule, "stale": Recreate}, actions)
+}
+
+func TestEmailAndNextPage(t *testing.T) {
+	contents := makeContents(t, map[string]string{
+		"first":  "2024-05-10 10:00 +0000",
+		"second": "2024-05-11 10:00 +0000",
+		"third":  "2024-05-12 10:00 +0000",
+	})
+	g := NewEmailGenerator(contents, languages.LanguageEn, &fakeEngine{}).WithOptions(SubjectPrefix("Prefix"))
+
+	assert.Equal(t, page.Name("second"), g.NextPage(time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, page.Name("first"), g.NextPage(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, page.Name("third"), g.NextPage(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+
+	e, err := g.Email("second")
+	assert.Nil(t, err)
+	assert.Equal(t, "Prefix: Title of second", e.Subject)
+	assert.Equal(t, "Title of second", e.Plaintext)
+
+	_, err = g.Email("missing")
+	assert.NotNil(t, err)
+	_, err = NewEmailGenerator(contents, languages.LanguageEs, &fakeEngine{}).Email("second")
+	assert.NotNil(t, err)
+}
+
+func TestSelectionRules(t *testing.T) {
+	contents := makeContentsWithHeaders(t, map[string]string{
+		"news1":     "publish_date: \"2024-05-10 10:00 +0000\"\ntags: [News]\n",
+		"news2":     "publish_date: \"2024-05-10 20:00 +0000\"\ntags: [news]\n",
+		"news3":     "publish_date: \"2024-05-11 11:00 +0000\"\ntags: [news, private]\n",
+		"news4":     "publish_date: \"2024-05-12 10:00 +0000\"\ntags: [news]\nno_email: true\n",
+		"news5":     "publish_date: \"2024-05-12 12:00 +0000\"\ntags: [news]\n",
+		"story1":    "publish_date: \"2024-05-10 12:00 +0000\"\nseries: story\n",
+		"story2":    "publish_date: \"2024-05-11 12:00 +0000\"\nseries: story\n",
+		"unrelated": "publish_date: \"2024-05-11 13:00 +0000\"\n",
+	})
+	selected := func(options ...EmailGeneratorOption) []page.Name {
+		g := NewEmailGenerator(contents, languages.LanguageEn, &fakeEngine{}).WithOptions(options...)
+		var names []page.Name
+		for _, p := range g.pagesToSend() {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []page.Name{"news5", "unrelated", "story2", "news3", "news2", "story1", "news1"}, selected())
+	assert.Equal(t, []page.Name{"news5", "news3", "news2", "news1"}, selected(WithTags("news")))
+	assert.Equal(t, []page.Name{"news5", "news2", "news1"}, selected(WithTags("news"), WithoutTags("private")))
+	assert.Equal(t, []page.Name{"story2", "story1"}, selected(InSeries("story")))
+	assert.Equal(t, []page.Name{"news5", "news3", "news1"}, selected(WithTags("news"), MinInterval(24*time.Hour)))
+	assert.Equal(t, []page.Name{"news5", "news3"},
+		selected(WithTags("news"), MinInterval(24*time.Hour), NotBefore(time.Date(2024, 5, 10, 15, 0, 0, 0, time.UTC))))
+}
@@ -0,0 +1,26 @@
+package generator
+
+type messages struct {
+	// The subject of a digest email, with the dates of the first and last days of the period.
+	digestSubject string
+}
+
+var allMessages = map[string]messages{
+	"en": {
+		digestSubject: "Posts from %s to %s",
+	},
+	"es": {
+		digestSubject: "Artículos del %s al %s",
+	},
+	"gl": {
+		digestSubject: "Artigos do %s ao %s",
+	},
+}
+
+func getMessages(language string) messages {
+	msg, ok := allMessages[language]
+	if !ok {
+		return allMessages["en"]
+	}
+	return msg
+}
@@ -0,0 +1,171 @@
+// The httpapi package is a small client for the JSON APIs of email services.
+//
+// A Client sends requests relative to a base URI, adds the service's
+// authentication headers, encodes the request and decodes the response as
+// JSON, and turns error responses into a StatusError. It can also retry the
+// requests that were rejected because of rate limiting.
+
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned when the service responds with a status other than 2xx.
+type StatusError struct {
+	Method string
+	Uri    string
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s request for %s returned status %d: %s", e.Method, e.Uri, e.Status, e.Body)
+}
+
+// IsNotFound returns whether the error is a StatusError with a 404 status.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsRateLimited returns whether the error is a StatusError with a 429 status.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+func hasStatus(err error, status int) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Status == status
+}
+
+// Client sends requests to a JSON API.
+type Client struct {
+	baseUri      string
+	httpClient   *http.Client
+	authorize    func(req *http.Request)
+	maxRetries   int
+	maxRetryWait time.Duration
+}
+
+type ClientOption func(*Client)
+
+// RetryRateLimited makes the client retry the requests that receive a 429 status up to maxRetries times.
+// The client waits for the time in the Retry-After header, or for an increasing delay if the header is missing,
+// but never for longer than maxWait.
+func RetryRateLimited(maxRetries int, maxWait time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.maxRetryWait = maxWait
+	}
+}
+
+// NewClient returns a Client for the API at baseUri. The authorize function adds the authentication headers to each request.
+func NewClient(baseUri string, httpClient *http.Client, authorize func(req *http.Request), options ...ClientOption) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Client{baseUri: baseUri, httpClient: httpClient, authorize: authorize}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Do sends a request to the path, relative to the base URI. If request is not nil, it is sent as JSON.
+// If response is not nil, the response body is decoded into it.
+func (c *Client) Do(ctx context.Context, method string, path string, request any, response any) error {
+	base, err := url.Parse(c.baseUri)
+	if err != nil {
+		return err
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return err
+	}
+	uri := base.ResolveReference(ref).String()
+
+	var payload []byte
+	if request != nil {
+		payload, err = json.Marshal(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	for retry := 0; ; retry++ {
+		wait, err := c.do(ctx, method, uri, payload, response)
+		if !IsRateLimited(err) || retry >= c.maxRetries {
+			return err
+		}
+		if wait < 0 {
+			wait = time.Second << retry
+		}
+		if c.maxRetryWait > 0 && wait > c.maxRetryWait {
+			wait = c.maxRetryWait
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// do sends a request once. If the request fails, it also returns the time to wait before retrying,
+// or a negative duration if the response didn't say.
+func (c *Client) do(ctx context.Context, method string, uri string, payload []byte, response any) (time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.authorize != nil {
+		c.authorize(req)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return -1, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return retryAfter(res.Header.Get("Retry-After")), &StatusError{Method: method, Uri: uri, Status: res.StatusCode, Body: string(resBody)}
+	}
+	if response == nil || len(resBody) == 0 {
+		return 0, nil
+	}
+	return 0, json.Unmarshal(resBody, response)
+}
+
+// retryAfter parses the value of a Retry-After header, which contains either a number of seconds or a date.
+// It returns a negative duration if the header is missing or invalid.
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return -1
+}
@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email/httpapi/httpapitest"
+)
+
+func authorize(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer key")
+}
+
+func TestDo(t *testing.T) {
+	server, requests := httpapitest.NewServer(t, map[string][]httpapitest.Response{
+		"POST /api/v1/items":  {{Status: http.StatusCreated, Body: `{"id":"42"}`}},
+		"GET /api/v1/items/7": {{Status: http.StatusNotFound, Body: `{"error":"not found"}`}},
+	}, httpapitest.CheckRequest(func(req *http.Request) {
+		assert.Equal(t, "Bearer key", req.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", req.Header.Get("Accept"))
+		if req.Method == http.MethodPost {
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		} else {
+			assert.Equal(t, "", req.Header.Get("Content-Type"))
+		}
+	}))
+	c := NewClient(server.URL+"/api/v1/", server.Client(), authorize)
+
+	var resp struct {
+		Id string `json:"id"`
+	}
+	assert.Nil(t, c.Do(context.Background(), http.MethodPost, "items", map[string]string{"name": "item"}, &resp))
+	assert.Equal(t, "42", resp.Id)
+
+	// Responses without a body leave the response untouched.
+	assert.Nil(t, c.Do(context.Background(), http.MethodDelete, "items/42?force=true", nil, &resp))
+	assert.Equal(t, "42", resp.Id)
+
+	err := c.Do(context.Background(), http.MethodGet, "items/7", nil, &resp)
+	assert.True(t, IsNotFound(err))
+	assert.False(t, IsRateLimited(err))
+	var se *StatusError
+	assert.ErrorAs(t, err, &se)
+	assert.Equal(t, http.MethodGet, se.Method)
+	assert.Equal(t, server.URL+"/api/v1/items/7", se.Uri)
+	assert.Equal(t, `{"error":"not found"}`, se.Body)
+
+	// Absolute paths, like the pagination links some services return, replace the base URI's path.
+	assert.Nil(t, c.Do(context.Background(), http.MethodGet, "/other?page=2", nil, nil))
+
+	assert.Equal(t, []httpapitest.Request{
+		{Method: "POST", Path: "/api/v1/items", Body: map[string]any{"name": "item"}},
+		{Method: "DELETE", Path: "/api/v1/items/42?force=true"},
+		{Method: "GET", Path: "/api/v1/items/7"},
+		{Method: "GET", Path: "/other?page=2"},
+	}, *requests)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryAfter("30"))
+	assert.Equal(t, time.Duration(0), retryAfter("0"))
+	assert.Equal(t, time.Duration(-1), retryAfter(""))
+	assert.Equal(t, time.Duration(-1), retryAfter("-5"))
+	assert.Equal(t, time.Duration(-1), retryAfter("soon"))
+	// Dates in the past mean that the request can be retried right away.
+	assert.Equal(t, time.Duration(0), retryAfter("Wed, 21 Oct 2015 07:28:00 GMT"))
+	wait := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Greater(t, wait, 58*time.Minute)
+	assert.LessOrEqual(t, wait, time.Hour)
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	tooMany := httpapitest.Response{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "0"}}
+	server, requests := httpapitest.NewServer(t, map[string][]httpapitest.Response{
+		"GET /items": {tooMany, tooMany, {Body: `{}`}},
+	})
+	c := NewClient(server.URL, server.Client(), nil, RetryRateLimited(2, time.Minute))
+	assert.Nil(t, c.Do(context.Background(), http.MethodGet, "items", nil, nil))
+	assert.Len(t, *requests, 3)
+
+	// The client gives up after the maximum number of retries.
+	server, requests = httpapitest.NewServer(t, map[string][]httpapitest.Response{
+		"GET /items": {tooMany},
+	})
+	c = NewClient(server.URL, server.Client(), nil, RetryRateLimited(2, time.Minute))
+	assert.True(t, IsRateLimited(c.Do(context.Background(), http.MethodGet, "items", nil, nil)))
+	assert.Len(t, *requests, 3)
+
+	// Without retries, the error is returned right away.
+	c = NewClient(server.URL, server.Client(), nil)
+	assert.True(t, IsRateLimited(c.Do(context.Background(), http.MethodGet, "items", nil, nil)))
+	assert.Len(t, *requests, 4)
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	// Without a Retry-After header, the client waits for an increasing delay, capped at the maximum wait.
+	server, requests := httpapitest.NewServer(t, map[string][]httpapitest.Response{
+		"GET /items": {{Status: http.StatusTooManyRequests}, {Status: http.StatusTooManyRequests}, {Body: `{}`}},
+	})
+	c := NewClient(server.URL, server.Client(), nil, RetryRateLimited(2, 50*time.Millisecond))
+	start := time.Now()
+	assert.Nil(t, c.Do(context.Background(), http.MethodGet, "items", nil, nil))
+	elapsed := time.Since(start)
+	assert.Len(t, *requests, 3)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+
+	// The Retry-After header is also capped at the maximum wait.
+	server, _ = httpapitest.NewServer(t, map[string][]httpapitest.Response{
+		"GET /items": {{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "3600"}}, {Body: `{}`}},
+	})
+	c = NewClient(server.URL, server.Client(), nil, RetryRateLimited(1, 50*time.Millisecond))
+	start = time.Now()
+	assert.Nil(t, c.Do(context.Background(), http.MethodGet, "items", nil, nil))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The client stops waiting when the context is cancelled.
+	server, _ = httpapitest.NewServer(t, map[string][]httpapitest.Response{
+		"GET /items": {{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "3600"}}},
+	})
+	c = NewClient(server.URL, server.Client(), nil, RetryRateLimited(1, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Do(ctx, http.MethodGet, "items", nil, nil), context.DeadlineExceeded)
+}
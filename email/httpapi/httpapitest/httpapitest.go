@@ -0,0 +1,108 @@
+// The httpapitest package provides a fake JSON API server to test the clients of email services.
+
+package httpapitest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	// The path and query of the request.
+	Path string
+	// The request's JSON body, or nil if it didn't have one.
+	Body map[string]any
+}
+
+// Response is a canned response returned by the server.
+type Response struct {
+	// The HTTP status. The default is 200.
+	Status int
+	// Additional response headers.
+	Header map[string]string
+	Body   string
+}
+
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	check     func(req *http.Request)
+	unmatched Response
+}
+
+// CheckRequest calls the check function for every request, to verify its authentication headers.
+func CheckRequest(check func(req *http.Request)) ServerOption {
+	return func(o *serverOptions) {
+		o.check = check
+	}
+}
+
+// Unmatched sets the response for the requests that have no canned responses. The default has a 204 status.
+func Unmatched(response Response) ServerOption {
+	return func(o *serverOptions) {
+		o.unmatched = response
+	}
+}
+
+// Bodies returns canned responses with a 200 status and the given bodies, by method and path.
+func Bodies(bodies map[string]string) map[string][]Response {
+	out := map[string][]Response{}
+	for key, body := range bodies {
+		out[key] = []Response{{Body: body}}
+	}
+	return out
+}
+
+// NewServer returns a server that records the requests it receives and replies with the canned responses.
+// The responses are keyed by the method and the path and query of the request, as in "GET /api/campaigns?page=2";
+// if there are none, they are keyed by the method and the path only. Each request gets the next response in
+// the list, and the last response is repeated once the others have been used.
+func NewServer(t *testing.T, responses map[string][]Response, options ...ServerOption) (*httptest.Server, *[]Request) {
+	opts := &serverOptions{unmatched: Response{Status: http.StatusNoContent}}
+	for _, option := range options {
+		option(opts)
+	}
+	requests := &[]Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if opts.check != nil {
+			opts.check(req)
+		}
+		r := Request{Method: req.Method, Path: req.URL.RequestURI()}
+		body, _ := io.ReadAll(req.Body)
+		if len(body) > 0 {
+			assert.Nil(t, json.Unmarshal(body, &r.Body))
+		}
+		*requests = append(*requests, r)
+
+		key := req.Method + " " + req.URL.RequestURI()
+		if _, ok := responses[key]; !ok {
+			key = req.Method + " " + req.URL.Path
+		}
+		res := opts.unmatched
+		if queue := responses[key]; len(queue) > 0 {
+			res = queue[0]
+			if len(queue) > 1 {
+				responses[key] = queue[1:]
+			}
+		}
+		for name, value := range res.Header {
+			rw.Header().Set(name, value)
+		}
+		if res.Body != "" {
+			rw.Header().Set("Content-Type", "application/json")
+		}
+		if res.Status != 0 {
+			rw.WriteHeader(res.Status)
+		}
+		rw.Write([]byte(res.Body))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
@@ -0,0 +1,254 @@
+// The listmonk package is an email engine for Listmonk, a self-hosted
+// newsletter manager.
+//
+// Campaigns are sent to a fixed set of Listmonk lists, usually one per
+// language, and are tagged with the language they are written in and the
+// hash of their content.
+
+package listmonk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi"
+)
+
+type listmonk struct {
+	api       *httpapi.Client
+	lists     []int
+	fromEmail string
+}
+
+type ListmonkOption func(*listmonkOptions)
+
+type listmonkOptions struct {
+	httpClient *http.Client
+	fromEmail  string
+}
+
+// WithHttpClient sets the HTTP client used to talk to Listmonk.
+func WithHttpClient(client *http.Client) ListmonkOption {
+	return func(o *listmonkOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithFromEmail sets the campaigns' sender address, instead of Listmonk's default.
+func WithFromEmail(from string) ListmonkOption {
+	return func(o *listmonkOptions) {
+		o.fromEmail = from
+	}
+}
+
+// ConnectListmonk returns an engine that talks to the Listmonk instance at baseUri, authenticating as the
+// given API user, and sends campaigns to the given lists.
+func ConnectListmonk(baseUri string, user string, token string, lists []int, options ...ListmonkOption) (email.Engine, error) {
+	if len(lists) == 0 {
+		return nil, fmt.Errorf("no Listmonk lists were specified")
+	}
+	opts := &listmonkOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	if !strings.HasSuffix(baseUri, "/") {
+		baseUri += "/"
+	}
+	api := httpapi.NewClient(baseUri+"api/", opts.httpClient, func(req *http.Request) {
+		req.SetBasicAuth(user, token)
+	})
+	return &listmonk{api: api, lists: lists, fromEmail: opts.fromEmail}, nil
+}
+
+type campaign struct {
+	id int
+	lm *listmonk
+}
+
+// hashTag is the prefix of the tag that contains the campaign's content hash.
+const hashTag = "jtweb-hash:"
+
+type campaignReq struct {
+	Name        string   `json:"name"`
+	Subject     string   `json:"subject"`
+	Lists       []int    `json:"lists"`
+	FromEmail   string   `json:"from_email,omitempty"`
+	Type        string   `json:"type"`
+	ContentType string   `json:"content_type"`
+	Body        string   `json:"body"`
+	Altbody     string   `json:"altbody"`
+	Messenger   string   `json:"messenger"`
+	Tags        []string `json:"tags"`
+	SendAt      string   `json:"send_at"`
+}
+
+type campaignResp struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Subject     string   `json:"subject"`
+	FromEmail   string   `json:"from_email"`
+	Type        string   `json:"type"`
+	ContentType string   `json:"content_type"`
+	Body        string   `json:"body"`
+	Altbody     string   `json:"altbody"`
+	Messenger   string   `json:"messenger"`
+	Tags        []string `json:"tags"`
+	SendAt      string   `json:"send_at"`
+	Lists       []struct {
+		Id int `json:"id"`
+	} `json:"lists"`
+}
+
+func (l *listmonk) newCampaignReq(e *email.Email) *campaignReq {
+	return &campaignReq{
+		Name:        e.Name,
+		Subject:     e.Subject,
+		Lists:       l.lists,
+		FromEmail:   l.fromEmail,
+		Type:        "regular",
+		ContentType: "html",
+		Body:        e.Html,
+		Altbody:     e.Plaintext,
+		Messenger:   "email",
+		Tags:        []string{e.Language.Code(), hashTag + email.ContentHash(e)},
+		SendAt:      e.Date.UTC().Format(time.RFC3339),
+	}
+}
+
+func (l *listmonk) Name() string {
+	return "listmonk"
+}
+
+func (l *listmonk) ScheduledCampaigns() ([]email.ScheduledCampaign, error) {
+	var resp struct {
+		Data struct {
+			Results []campaignResp `json:"results"`
+		} `json:"data"`
+	}
+	err := l.api.Do(context.Background(), "GET", "campaigns?status=scheduled&per_page=all", nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []email.ScheduledCampaign
+	for _, c := range resp.Data.Results {
+		when, err := time.Parse(time.RFC3339Nano, c.SendAt)
+		if err != nil {
+			return nil, err
+		}
+		sc := email.ScheduledCampaign{Id: strconv.Itoa(c.Id), Name: c.Name, When: when}
+		for _, tag := range c.Tags {
+			if hash, found := strings.CutPrefix(tag, hashTag); found {
+				sc.Hash = hash
+			}
+		}
+		ret = append(ret, sc)
+	}
+	return ret, nil
+}
+
+func (l *listmonk) CreateCampaign(e *email.Email) (email.Campaign, error) {
+	req := l.newCampaignReq(e)
+	var resp struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	err := l.api.Do(context.Background(), "POST", "campaigns", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign{id: resp.Data.Id, lm: l}, nil
+}
+
+func (l *listmonk) ScheduleTime(date time.Time) time.Time {
+	return date
+}
+
+// updateCampaign changes a scheduled campaign. Listmonk campaigns are unscheduled while they are changed.
+func (l *listmonk) updateCampaign(id string, update func(req *campaignReq)) error {
+	campaignId, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data campaignResp `json:"data"`
+	}
+	err = l.api.Do(context.Background(), "GET", fmt.Sprintf("campaigns/%d", campaignId), nil, &resp)
+	if err != nil {
+		return err
+	}
+	cur := resp.Data
+	req := &campaignReq{
+		Name:        cur.Name,
+		Subject:     cur.Subject,
+		FromEmail:   cur.FromEmail,
+		Type:        cur.Type,
+		ContentType: cur.ContentType,
+		Body:        cur.Body,
+		Altbody:     cur.Altbody,
+		Messenger:   cur.Messenger,
+		Tags:        cur.Tags,
+		SendAt:      cur.SendAt,
+	}
+	for _, list := range cur.Lists {
+		req.Lists = append(req.Lists, list.Id)
+	}
+	update(req)
+
+	c := &campaign{id: campaignId, lm: l}
+	if err := c.setStatus("draft"); err != nil {
+		return err
+	}
+	if err := l.api.Do(context.Background(), "PUT", fmt.Sprintf("campaigns/%d", campaignId), req, nil); err != nil {
+		return err
+	}
+	return c.setStatus("scheduled")
+}
+
+func (l *listmonk) RescheduleCampaign(id string, date time.Time) error {
+	return l.updateCampaign(id, func(req *campaignReq) {
+		req.SendAt = date.UTC().Format(time.RFC3339)
+	})
+}
+
+// UpdateCampaign implements email.CampaignUpdater.
+func (l *listmonk) UpdateCampaign(id string, e *email.Email) error {
+	return l.updateCampaign(id, func(req *campaignReq) {
+		*req = *l.newCampaignReq(e)
+	})
+}
+
+func (l *listmonk) CancelCampaign(id string) error {
+	campaignId, err := strconv.Atoi(id)
+	if err != nil {
+		return err
+	}
+	return l.api.Do(context.Background(), "DELETE", fmt.Sprintf("campaigns/%d", campaignId), nil, nil)
+}
+
+func (c *campaign) setStatus(status string) error {
+	type statusReq struct {
+		Status string `json:"status"`
+	}
+
+	return c.lm.api.Do(context.Background(), "PUT", fmt.Sprintf("campaigns/%d/status", c.id), &statusReq{Status: status}, nil)
+}
+
+func (c *campaign) Id() string {
+	return strconv.Itoa(c.id)
+}
+
+// Schedule schedules the campaign for the send date it was created with.
+func (c *campaign) Schedule() error {
+	return c.setStatus("scheduled")
+}
+
+func (c *campaign) Send() error {
+	return c.setStatus("running")
+}
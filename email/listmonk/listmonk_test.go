@@ -0,0 +1,118 @@
+package listmonk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi/httpapitest"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+func newTestServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]httpapitest.Request) {
+	return httpapitest.NewServer(t, httpapitest.Bodies(responses), httpapitest.CheckRequest(func(req *http.Request) {
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "api", user)
+		assert.Equal(t, "token", pass)
+	}))
+}
+
+func TestScheduledCampaigns(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{
+		"GET /api/campaigns": `{"data":{"results":[{"id":1,"name":"post-1","send_at":"2024-05-01T10:00:00.123+02:00","status":"scheduled","tags":["en","jtweb-hash:abc"]}]}}`,
+	})
+	e, err := ConnectListmonk(server.URL, "api", "token", []int{3})
+	assert.Nil(t, err)
+	campaigns, err := e.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/campaigns?status=scheduled&per_page=all", (*requests)[0].Path)
+	assert.Len(t, campaigns, 1)
+	assert.Equal(t, "1", campaigns[0].Id)
+	assert.Equal(t, "post-1", campaigns[0].Name)
+	assert.Equal(t, "abc", campaigns[0].Hash)
+	assert.True(t, time.Date(2024, 5, 1, 8, 0, 0, 123000000, time.UTC).Equal(campaigns[0].When))
+}
+
+func TestCreateAndScheduleCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{
+		"POST /api/campaigns":          `{"data":{"id":42}}`,
+		"PUT /api/campaigns/42/status": `{"data":{"id":42}}`,
+	})
+	l, err := ConnectListmonk(server.URL+"/", "api", "token", []int{3, 4}, WithFromEmail("News <news@example.com>"))
+	assert.Nil(t, err)
+	e := &email.Email{
+		Name:      "post-1",
+		Language:  languages.LanguageGl,
+		Date:      time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Subject:   "Subject",
+		Html:      "<p>Hello</p>",
+		Plaintext: "Hello",
+	}
+	c, err := l.CreateCampaign(e)
+	assert.Nil(t, err)
+	assert.Equal(t, "42", c.Id())
+	assert.Nil(t, c.Schedule())
+	assert.Nil(t, c.Send())
+
+	assert.Len(t, *requests, 3)
+	create := (*requests)[0]
+	assert.Equal(t, "POST", create.Method)
+	assert.Equal(t, "post-1", create.Body["name"])
+	assert.Equal(t, []any{3.0, 4.0}, create.Body["lists"])
+	assert.Equal(t, "News <news@example.com>", create.Body["from_email"])
+	assert.Equal(t, "<p>Hello</p>", create.Body["body"])
+	assert.Equal(t, "Hello", create.Body["altbody"])
+	assert.Equal(t, []any{"gl", "jtweb-hash:" + email.ContentHash(e)}, create.Body["tags"])
+	assert.Equal(t, "2024-05-01T10:00:00Z", create.Body["send_at"])
+	assert.Equal(t, httpapitest.Request{Method: "PUT", Path: "/api/campaigns/42/status", Body: map[string]any{"status": "scheduled"}}, (*requests)[1])
+	assert.Equal(t, httpapitest.Request{Method: "PUT", Path: "/api/campaigns/42/status", Body: map[string]any{"status": "running"}}, (*requests)[2])
+}
+
+func TestErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte(`{"message":"permission denied"}`))
+	}))
+	defer server.Close()
+	e, err := ConnectListmonk(server.URL, "api", "token", []int{3})
+	assert.Nil(t, err)
+	_, err = e.ScheduledCampaigns()
+	assert.ErrorContains(t, err, "403")
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestRescheduleAndCancelCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{
+		"GET /api/campaigns/42": `{"data":{"id":42,"name":"post-1","subject":"Subject","from_email":"news@example.com","type":"regular",` +
+			`"content_type":"html","body":"<p>Hello</p>","altbody":"Hello","messenger":"email","tags":["en"],` +
+			`"send_at":"2024-05-01T10:00:00Z","lists":[{"id":3,"name":"News"}]}}`,
+	})
+	l, err := ConnectListmonk(server.URL, "api", "token", []int{3})
+	assert.Nil(t, err)
+	assert.Nil(t, l.RescheduleCampaign("42", time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)))
+	assert.Nil(t, l.CancelCampaign("42"))
+
+	assert.Equal(t, []httpapitest.Request{
+		{Method: "GET", Path: "/api/campaigns/42"},
+		{Method: "PUT", Path: "/api/campaigns/42/status", Body: map[string]any{"status": "draft"}},
+		{Method: "PUT", Path: "/api/campaigns/42", Body: map[string]any{
+			"name":         "post-1",
+			"subject":      "Subject",
+			"lists":        []any{3.0},
+			"from_email":   "news@example.com",
+			"type":         "regular",
+			"content_type": "html",
+			"body":         "<p>Hello</p>",
+			"altbody":      "Hello",
+			"messenger":    "email",
+			"tags":         []any{"en"},
+			"send_at":      "2024-05-02T10:00:00Z",
+		}},
+		{Method: "PUT", Path: "/api/campaigns/42/status", Body: map[string]any{"status": "scheduled"}},
+		{Method: "DELETE", Path: "/api/campaigns/42"},
+	}, *requests)
+}
@@ -0,0 +1,266 @@
+// The mailchimp package is an email engine for Mailchimp.
+//
+// Campaigns are sent to one audience. If the audience has subscribers in
+// several languages, the campaigns can be restricted to the subscribers whose
+// language matches the email's.
+//
+// Mailchimp only schedules campaigns on the quarter hour, so campaigns are
+// scheduled for the first quarter hour at or after the email's publish date.
+//
+// Mailchimp can't change the content of a scheduled campaign, so campaigns
+// are cancelled and created again when their content changes. To find out if
+// it has changed, a shortened content hash is added to the end of the
+// campaign's title.
+
+package mailchimp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+type mailchimp struct {
+	api              *httpapi.Client
+	listId           string
+	fromName         string
+	replyTo          string
+	filterByLanguage bool
+}
+
+type MailchimpOption func(*mailchimpOptions)
+
+type mailchimpOptions struct {
+	httpClient       *http.Client
+	baseUri          string
+	filterByLanguage bool
+}
+
+// WithHttpClient sets the HTTP client used to talk to Mailchimp.
+func WithHttpClient(client *http.Client) MailchimpOption {
+	return func(o *mailchimpOptions) {
+		o.httpClient = client
+	}
+}
+
+// WithBaseUri sets the URI of the API, instead of the one for the API key's data center.
+func WithBaseUri(uri string) MailchimpOption {
+	return func(o *mailchimpOptions) {
+		o.baseUri = uri
+	}
+}
+
+// FilterByLanguage makes campaigns go only to the subscribers whose language is the email's.
+func FilterByLanguage(filter bool) MailchimpOption {
+	return func(o *mailchimpOptions) {
+		o.filterByLanguage = filter
+	}
+}
+
+// ConnectMailchimp returns an engine that sends campaigns to an audience, with the given sender name and reply-to address.
+func ConnectMailchimp(apikey string, listId string, fromName string, replyTo string, options ...MailchimpOption) (email.Engine, error) {
+	opts := &mailchimpOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	if opts.baseUri == "" {
+		// API keys end in the data center, like "-us21".
+		_, dc, found := strings.Cut(apikey, "-")
+		if !found {
+			return nil, fmt.Errorf("the Mailchimp API key doesn't contain a data center")
+		}
+		opts.baseUri = fmt.Sprintf("https://%s.api.mailchimp.com/3.0/", dc)
+	}
+	if !strings.HasSuffix(opts.baseUri, "/") {
+		opts.baseUri += "/"
+	}
+	api := httpapi.NewClient(opts.baseUri, opts.httpClient, func(req *http.Request) {
+		req.SetBasicAuth("jtweb", apikey)
+	})
+	return &mailchimp{api: api, listId: listId, fromName: fromName, replyTo: replyTo, filterByLanguage: opts.filterByLanguage}, nil
+}
+
+type campaign struct {
+	id   string
+	when time.Time
+	mc   *mailchimp
+}
+
+// mapLanguage returns the language code Mailchimp uses for a language.
+// Mailchimp doesn't support Galician, so Portuguese is used instead.
+func mapLanguage(language languages.Language) string {
+	switch language.Code() {
+	case "es":
+		return "es"
+	case "gl":
+		return "pt"
+	default:
+		return "en"
+	}
+}
+
+// quarterHour returns the first quarter hour at or after the given time.
+func quarterHour(t time.Time) time.Time {
+	q := t.Truncate(15 * time.Minute)
+	if q.Before(t) {
+		q = q.Add(15 * time.Minute)
+	}
+	return q
+}
+
+func (m *mailchimp) Name() string {
+	return "mailchimp"
+}
+
+// The number of campaigns to retrieve in each request.
+const campaignsPageSize = 100
+
+func (m *mailchimp) ScheduledCampaigns() ([]email.ScheduledCampaign, error) {
+	type campaignResp struct {
+		Id       string `json:"id"`
+		SendTime string `json:"send_time"`
+		Settings struct {
+			Title string `json:"title"`
+		} `json:"settings"`
+	}
+
+	var ret []email.ScheduledCampaign
+	for offset := 0; ; {
+		var resp struct {
+			Campaigns  []campaignResp `json:"campaigns"`
+			TotalItems int            `json:"total_items"`
+		}
+		path := fmt.Sprintf("campaigns?status=schedule&list_id=%s&count=%d&offset=%d&fields=campaigns.id,campaigns.send_time,campaigns.settings.title,total_items",
+			url.QueryEscape(m.listId), campaignsPageSize, offset)
+		err := m.api.Do(context.Background(), "GET", path, nil, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range resp.Campaigns {
+			when, err := time.Parse(time.RFC3339, c.SendTime)
+			if err != nil {
+				return nil, err
+			}
+			name, hash := email.SplitNameHash(c.Settings.Title)
+			ret = append(ret, email.ScheduledCampaign{Id: c.Id, Name: name, When: when, Hash: hash})
+		}
+		offset += len(resp.Campaigns)
+		if len(resp.Campaigns) == 0 || offset >= resp.TotalItems {
+			return ret, nil
+		}
+	}
+}
+
+func (m *mailchimp) CreateCampaign(e *email.Email) (email.Campaign, error) {
+	type condition struct {
+		ConditionType string `json:"condition_type"`
+		Field         string `json:"field"`
+		Op            string `json:"op"`
+		Value         string `json:"value"`
+	}
+
+	type segmentOpts struct {
+		Match      string      `json:"match"`
+		Conditions []condition `json:"conditions"`
+	}
+
+	type recipients struct {
+		ListId      string       `json:"list_id"`
+		SegmentOpts *segmentOpts `json:"segment_opts,omitempty"`
+	}
+
+	type settings struct {
+		SubjectLine string `json:"subject_line"`
+		Title       string `json:"title"`
+		FromName    string `json:"from_name"`
+		ReplyTo     string `json:"reply_to"`
+	}
+
+	type campaignReq struct {
+		Type       string     `json:"type"`
+		Recipients recipients `json:"recipients"`
+		Settings   settings   `json:"settings"`
+	}
+
+	req := campaignReq{
+		Type:       "regular",
+		Recipients: recipients{ListId: m.listId},
+		Settings: settings{
+			SubjectLine: e.Subject,
+			Title:       email.NameWithHash(e),
+			FromName:    m.fromName,
+			ReplyTo:     m.replyTo,
+		},
+	}
+	if m.filterByLanguage {
+		req.Recipients.SegmentOpts = &segmentOpts{
+			Match: "all",
+			Conditions: []condition{
+				{ConditionType: "Language", Field: "language", Op: "is", Value: mapLanguage(e.Language)},
+			},
+		}
+	}
+	var resp struct {
+		Id string `json:"id"`
+	}
+	err := m.api.Do(context.Background(), "POST", "campaigns", &req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	type contentReq struct {
+		Html      string `json:"html"`
+		PlainText string `json:"plain_text"`
+	}
+
+	err = m.api.Do(context.Background(), "PUT", fmt.Sprintf("campaigns/%s/content", resp.Id), &contentReq{Html: e.Html, PlainText: e.Plaintext}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &campaign{id: resp.Id, when: e.Date, mc: m}, nil
+}
+
+func (m *mailchimp) ScheduleTime(date time.Time) time.Time {
+	return quarterHour(date)
+}
+
+func (m *mailchimp) RescheduleCampaign(id string, date time.Time) error {
+	err := m.api.Do(context.Background(), "POST", fmt.Sprintf("campaigns/%s/actions/unschedule", url.PathEscape(id)), nil, nil)
+	if err != nil {
+		return err
+	}
+	return (&campaign{id: id, when: date, mc: m}).Schedule()
+}
+
+func (m *mailchimp) CancelCampaign(id string) error {
+	err := m.api.Do(context.Background(), "POST", fmt.Sprintf("campaigns/%s/actions/unschedule", url.PathEscape(id)), nil, nil)
+	if err != nil {
+		return err
+	}
+	return m.api.Do(context.Background(), "DELETE", fmt.Sprintf("campaigns/%s", url.PathEscape(id)), nil, nil)
+}
+
+func (c *campaign) Id() string {
+	return c.id
+}
+
+func (c *campaign) Schedule() error {
+	type scheduleReq struct {
+		ScheduleTime string `json:"schedule_time"`
+	}
+
+	req := scheduleReq{ScheduleTime: quarterHour(c.when).UTC().Format(time.RFC3339)}
+	return c.mc.api.Do(context.Background(), "POST", fmt.Sprintf("campaigns/%s/actions/schedule", c.id), &req, nil)
+}
+
+func (c *campaign) Send() error {
+	return c.mc.api.Do(context.Background(), "POST", fmt.Sprintf("campaigns/%s/actions/send", c.id), nil, nil)
+}
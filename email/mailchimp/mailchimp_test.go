@@ -0,0 +1,113 @@
+package mailchimp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi/httpapitest"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+func newTestServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]httpapitest.Request) {
+	return httpapitest.NewServer(t, httpapitest.Bodies(responses), httpapitest.CheckRequest(func(req *http.Request) {
+		_, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "key-us1", pass)
+	}))
+}
+
+func TestDataCenter(t *testing.T) {
+	_, err := ConnectMailchimp("key", "list", "News", "news@example.com")
+	assert.NotNil(t, err)
+	e, err := ConnectMailchimp("key-us21", "list", "News", "news@example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "mailchimp", e.Name())
+}
+
+func TestScheduledCampaigns(t *testing.T) {
+	const query = "/3.0/campaigns?status=schedule&list_id=list+1&count=100&offset=%d&fields=campaigns.id,campaigns.send_time,campaigns.settings.title,total_items"
+	server, requests := newTestServer(t, map[string]string{
+		"GET " + fmt.Sprintf(query, 0): `{"total_items":3,"campaigns":[{"id":"abc","send_time":"2024-05-01T10:15:00+00:00","settings":{"title":"post-1 #0123456789abcdef"}},` +
+			`{"id":"def","send_time":"2024-05-08T10:15:00+00:00","settings":{"title":"post-2"}}]}`,
+		"GET " + fmt.Sprintf(query, 2): `{"total_items":3,"campaigns":[{"id":"ghi","send_time":"2024-05-15T10:15:00+00:00","settings":{"title":"post-3"}}]}`,
+	})
+	e, err := ConnectMailchimp("key-us1", "list 1", "News", "news@example.com", WithBaseUri(server.URL+"/3.0"))
+	assert.Nil(t, err)
+	campaigns, err := e.ScheduledCampaigns()
+	assert.Nil(t, err)
+	// The campaigns are retrieved in pages, until all of them have been returned.
+	assert.Len(t, *requests, 2)
+	assert.Equal(t, fmt.Sprintf(query, 0), (*requests)[0].Path)
+	assert.Equal(t, fmt.Sprintf(query, 2), (*requests)[1].Path)
+	assert.Len(t, campaigns, 3)
+	assert.Equal(t, "abc", campaigns[0].Id)
+	assert.Equal(t, "post-1", campaigns[0].Name)
+	assert.Equal(t, "0123456789abcdef", campaigns[0].Hash)
+	assert.True(t, time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC).Equal(campaigns[0].When))
+	// Created without a content hash.
+	assert.Equal(t, "post-2", campaigns[1].Name)
+	assert.Equal(t, "", campaigns[1].Hash)
+	assert.Equal(t, "ghi", campaigns[2].Id)
+}
+
+func TestCreateAndScheduleCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{
+		"POST /3.0/campaigns": `{"id":"abc"}`,
+	})
+	e, err := ConnectMailchimp("key-us1", "list", "News", "news@example.com", WithBaseUri(server.URL+"/3.0/"), FilterByLanguage(true))
+	assert.Nil(t, err)
+	c, err := e.CreateCampaign(&email.Email{
+		Name:      "post-1",
+		Language:  languages.LanguageEs,
+		Date:      time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC),
+		Subject:   "Subject",
+		Html:      "<p>Hola</p>",
+		Plaintext: "Hola",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", c.Id())
+	assert.Nil(t, c.Schedule())
+	assert.Nil(t, c.Send())
+
+	assert.Len(t, *requests, 4)
+	create := (*requests)[0]
+	assert.Equal(t, map[string]any{
+		"type": "regular",
+		"recipients": map[string]any{
+			"list_id": "list",
+			"segment_opts": map[string]any{
+				"match":      "all",
+				"conditions": []any{map[string]any{"condition_type": "Language", "field": "language", "op": "is", "value": "es"}},
+			},
+		},
+		"settings": map[string]any{"subject_line": "Subject", "title": "post-1 #3712383b25d616d5", "from_name": "News", "reply_to": "news@example.com"},
+	}, create.Body)
+	assert.Equal(t, httpapitest.Request{Method: "PUT", Path: "/3.0/campaigns/abc/content", Body: map[string]any{"html": "<p>Hola</p>", "plain_text": "Hola"}}, (*requests)[1])
+	// Scheduled for the next quarter hour.
+	assert.Equal(t, httpapitest.Request{Method: "POST", Path: "/3.0/campaigns/abc/actions/schedule", Body: map[string]any{"schedule_time": "2024-05-01T10:15:00Z"}}, (*requests)[2])
+	assert.Equal(t, httpapitest.Request{Method: "POST", Path: "/3.0/campaigns/abc/actions/send"}, (*requests)[3])
+}
+
+func TestRescheduleAndCancelCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{})
+	e, err := ConnectMailchimp("key-us1", "list", "News", "news@example.com", WithBaseUri(server.URL+"/3.0/"))
+	assert.Nil(t, err)
+	when := time.Date(2024, 5, 2, 10, 50, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 5, 2, 11, 0, 0, 0, time.UTC), e.ScheduleTime(when))
+	assert.Nil(t, e.RescheduleCampaign("abc", when))
+	assert.Nil(t, e.CancelCampaign("abc"))
+	_, canUpdate := e.(email.CampaignUpdater)
+	assert.False(t, canUpdate)
+
+	assert.Equal(t, []httpapitest.Request{
+		{Method: "POST", Path: "/3.0/campaigns/abc/actions/unschedule"},
+		{Method: "POST", Path: "/3.0/campaigns/abc/actions/schedule", Body: map[string]any{"schedule_time": "2024-05-02T11:00:00Z"}},
+		{Method: "POST", Path: "/3.0/campaigns/abc/actions/unschedule"},
+		{Method: "DELETE", Path: "/3.0/campaigns/abc"},
+	}, *requests)
+}
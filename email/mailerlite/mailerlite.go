@@ -1,272 +1,453 @@
+// The mailerlite package is an email engine for MailerLite, using its Connect API.
+//
+// Campaigns are sent to one subscriber group. They are scheduled in an
+// explicit timezone (UTC by default), so that the schedule doesn't depend on
+// the account's settings.
+//
+// The Connect API doesn't accept a plain text version of the campaigns, so
+// MailerLite generates it from the HTML version.
+//
+// Scheduled campaigns can't be changed, so campaigns are cancelled and
+// created again when their content changes. To find out if it has changed,
+// a shortened content hash is added to the end of the campaign's name.
+//
+// The engine reports the number of unique opens and clicks of the sent
+// campaigns.
+
 package mailerlite
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi"
 	"jacobo.tarrio.org/jtweb/languages"
 )
 
-func ConnectMailerlite(apikey string, group int, dryRun bool) (*mailerlite, error) {
-	return &mailerlite{apikey: apikey, group: group, dryRun: dryRun, utcTz: -1}, nil
+const defaultBaseUri = "https://connect.mailerlite.com/api/"
+
+// MailerLite reports the campaigns' dates in UTC with this format.
+const dateFormat = "2006-01-02 15:04:05"
+
+// Error is returned when MailerLite rejects a request.
+type Error struct {
+	// The HTTP status of the response.
+	Status int
+	// The error message returned by MailerLite.
+	Message string
+	// For validation errors, the messages for each invalid field.
+	Errors map[string][]string
+	// The original error.
+	Err *httpapi.StatusError
 }
 
-const baseUri = "https://api.mailerlite.com/api/"
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("MailerLite returned status %d: %s", e.Status, e.Message)
+	for field, errs := range e.Errors {
+		msg += fmt.Sprintf("; %s: %s", field, strings.Join(errs, ", "))
+	}
+	return msg
+}
 
-type mailerlite struct {
-	apikey string
-	group  int
-	dryRun bool
-	utcTz  int
+func (e *Error) Unwrap() error {
+	return e.Err
 }
 
-type campaign struct {
-	id   int
-	when time.Time
-	ml   *mailerlite
+// IsNotFound returns whether MailerLite didn't find the requested object.
+func IsNotFound(err error) bool {
+	return httpapi.IsNotFound(err)
 }
 
-func mapLanguage(language languages.Language) string {
-	switch language.Code() {
-	case "en":
-	case "es":
-		return language.Code()
-	case "gl":
-		return "pt"
-	default:
-		return "en"
+// IsRateLimited returns whether MailerLite rejected the request because too many requests were made.
+func IsRateLimited(err error) bool {
+	return httpapi.IsRateLimited(err)
+}
+
+// id is an object ID. MailerLite returns IDs as strings, but they are numbers in some places.
+type id string
+
+func (i *id) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*i = id(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
 	}
-	return "en"
+	*i = id(n)
+	return nil
 }
 
-func query[R any](m *mailerlite, path string, method string, response *R) error {
-	var dummy *string = nil
+type MailerliteOption func(*mailerliteOptions)
 
-	return queryPayload(m, path, method, dummy, response)
+type mailerliteOptions struct {
+	httpClient *http.Client
+	baseUri    string
+	timezone   string
+	maxRetries int
 }
 
-func queryPayload[Q any, R any](m *mailerlite, path string, method string, request *Q, response *R) error {
-	url := baseUri + path
+// WithHttpClient sets the HTTP client used to talk to MailerLite.
+func WithHttpClient(client *http.Client) MailerliteOption {
+	return func(o *mailerliteOptions) {
+		o.httpClient = client
+	}
+}
 
-	var payload io.Reader
-	if request == nil {
-		payload = nil
-	} else {
-		j, err := json.Marshal(request)
-		if err != nil {
-			return err
+// WithBaseUri sets the URI of the API.
+func WithBaseUri(uri string) MailerliteOption {
+	return func(o *mailerliteOptions) {
+		if !strings.HasSuffix(uri, "/") {
+			uri += "/"
 		}
-		payload = bytes.NewReader(j)
+		o.baseUri = uri
 	}
+}
 
-	req, err := http.NewRequest(method, url, payload)
-	if err != nil {
-		return err
+// WithTimezone sets the timezone the campaigns are scheduled in, by its IANA name. The default is UTC.
+func WithTimezone(name string) MailerliteOption {
+	return func(o *mailerliteOptions) {
+		o.timezone = name
 	}
+}
+
+// WithMaxRetries sets how many times to retry a request that was rejected because of rate limiting. The default is 3.
+func WithMaxRetries(retries int) MailerliteOption {
+	return func(o *mailerliteOptions) {
+		o.maxRetries = retries
+	}
+}
 
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("X-MailerLite-ApiKey", m.apikey)
+type mailerlite struct {
+	api      *httpapi.Client
+	group    string
+	fromName string
+	from     string
+	location *time.Location
+	timezone string
+
+	mu          sync.Mutex
+	timezoneId  id
+	languageIds map[string]id
+}
+
+type campaign struct {
+	id   id
+	when time.Time
+	ml   *mailerlite
+}
 
-	res, err := http.DefaultClient.Do(req)
+// ConnectMailerlite returns an engine that sends campaigns to a subscriber group, from the given sender name and address.
+func ConnectMailerlite(apikey string, group string, fromName string, from string, options ...MailerliteOption) (*mailerlite, error) {
+	opts := &mailerliteOptions{baseUri: defaultBaseUri, timezone: "UTC", maxRetries: 3}
+	for _, option := range options {
+		option(opts)
+	}
+	location, err := time.LoadLocation(opts.timezone)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	api := httpapi.NewClient(opts.baseUri, opts.httpClient, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+apikey)
+	}, httpapi.RetryRateLimited(opts.maxRetries, time.Minute))
+	return &mailerlite{
+		api:      api,
+		group:    group,
+		fromName: fromName,
+		from:     from,
+		location: location,
+		timezone: opts.timezone,
+	}, nil
+}
 
-	defer res.Body.Close()
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
+// do sends a request to MailerLite and turns its error responses into an Error.
+func (m *mailerlite) do(ctx context.Context, method string, path string, request any, response any) error {
+	err := m.api.Do(ctx, method, path, request, response)
+	var se *httpapi.StatusError
+	if !errors.As(err, &se) {
 		return err
 	}
-
-	if res.StatusCode != 200 {
-		return fmt.Errorf("request for %s returned status \"%s\": %s", req.URL, res.Status, string(body))
+	out := &Error{Status: se.Status, Message: se.Body, Err: se}
+	var body struct {
+		Message string              `json:"message"`
+		Errors  map[string][]string `json:"errors"`
 	}
-
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		return err
+	if json.Unmarshal([]byte(se.Body), &body) == nil && body.Message != "" {
+		out.Message = body.Message
+		out.Errors = body.Errors
 	}
-
-	return nil
+	return out
 }
 
-func (m *mailerlite) parseUtcTime(t string) (time.Time, error) {
-	return time.Parse("2006-01-02 15:04:05", t)
+func mapLanguage(language languages.Language) string {
+	switch language.Code() {
+	case "en", "es":
+		return language.Code()
+	case "gl":
+		return "pt"
+	default:
+		return "en"
+	}
 }
 
-func (m *mailerlite) getUtcTimezone() (int, error) {
-	if m.utcTz >= 0 {
-		return m.utcTz, nil
+// utcAliases are the names of the timezones that are the same as UTC.
+var utcAliases = []string{"UTC", "Etc/UTC", "Etc/UCT", "Etc/Universal", "Etc/Zulu", "Etc/GMT", "GMT", "Universal", "Zulu"}
+
+// getTimezoneId returns MailerLite's ID for the configured timezone.
+func (m *mailerlite) getTimezoneId(ctx context.Context) (id, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timezoneId != "" {
+		return m.timezoneId, nil
 	}
 
-	type tzdata struct {
-		Id     int    `json:"id"`
-		Offset int    `json:"time"`
-		Name   string `json:"title"`
+	var resp struct {
+		Data []struct {
+			Id   id     `json:"id"`
+			Name string `json:"name"`
+		} `json:"data"`
+	}
+	if err := m.do(ctx, http.MethodGet, "timezones", nil, &resp); err != nil {
+		return "", err
 	}
 
-	var tzs []tzdata
-	err := query(m, "master/timezones", "GET", &tzs)
-	if err != nil {
-		return -1, err
+	names := []string{m.timezone}
+	if m.location.String() == "UTC" {
+		names = utcAliases
+	}
+	for _, name := range names {
+		for _, tz := range resp.Data {
+			if tz.Name == name {
+				m.timezoneId = tz.Id
+				return tz.Id, nil
+			}
+		}
 	}
+	return "", fmt.Errorf("timezone %s not found in MailerLite", m.timezone)
+}
 
-	for _, tz := range tzs {
-		if tz.Offset != 0 {
-			continue
+// getLanguageId returns MailerLite's ID for a language.
+func (m *mailerlite) getLanguageId(ctx context.Context, language languages.Language) (id, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.languageIds == nil {
+		var resp struct {
+			Data []struct {
+				Id        id     `json:"id"`
+				Shortcode string `json:"shortcode"`
+			} `json:"data"`
 		}
-		if strings.Contains(tz.Name, "Universal") ||
-			strings.Contains(tz.Name, "UTC") ||
-			strings.Contains(tz.Name, "Greenwich") ||
-			strings.Contains(tz.Name, "GMT") {
-			m.utcTz = tz.Id
-			return tz.Id, nil
+		if err := m.do(ctx, http.MethodGet, "campaigns/languages", nil, &resp); err != nil {
+			return "", err
+		}
+		m.languageIds = map[string]id{}
+		for _, l := range resp.Data {
+			m.languageIds[l.Shortcode] = l.Id
 		}
 	}
 
-	return -1, fmt.Errorf("UTC timezone not found")
+	code := mapLanguage(language)
+	languageId, ok := m.languageIds[code]
+	if !ok {
+		return "", fmt.Errorf("language %s not found in MailerLite", code)
+	}
+	return languageId, nil
 }
 
 func (m *mailerlite) Name() string {
 	return "mailerlite"
 }
 
-func (m *mailerlite) ScheduledCampaigns() ([]email.ScheduledCampaign, error) {
-	type campaign struct {
-		DateSend string `json:"date_send"`
-		Name     string `json:"name"`
+type campaignResp struct {
+	Id           id     `json:"id"`
+	Name         string `json:"name"`
+	ScheduledFor string `json:"scheduled_for"`
+	FinishedAt   string `json:"finished_at"`
+	Stats        struct {
+		Sent              int `json:"sent"`
+		UniqueOpensCount  int `json:"unique_opens_count"`
+		UniqueClicksCount int `json:"unique_clicks_count"`
+		UnsubscribesCount int `json:"unsubscribes_count"`
+	} `json:"stats"`
+}
+
+// listCampaigns returns the campaigns with the given status, following the pagination links.
+func (m *mailerlite) listCampaigns(ctx context.Context, status string) ([]campaignResp, error) {
+	type listResp struct {
+		Data  []campaignResp `json:"data"`
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	}
+
+	var ret []campaignResp
+	next := "campaigns?" + url.Values{"filter[status]": {status}, "limit": {"100"}}.Encode()
+	for next != "" {
+		var resp listResp
+		if err := m.do(ctx, http.MethodGet, next, nil, &resp); err != nil {
+			return nil, err
+		}
+		ret = append(ret, resp.Data...)
+		next = resp.Links.Next
 	}
+	return ret, nil
+}
 
-	var campaigns []campaign
-	err := query(m, "v2/campaigns/outbox", "GET", &campaigns)
+func (m *mailerlite) ScheduledCampaigns() ([]email.ScheduledCampaign, error) {
+	campaigns, err := m.listCampaigns(context.Background(), "ready")
 	if err != nil {
 		return nil, err
 	}
-
 	var ret []email.ScheduledCampaign
 	for _, c := range campaigns {
-		dt, err := m.parseUtcTime(c.DateSend)
+		when, err := time.Parse(dateFormat, c.ScheduledFor)
 		if err != nil {
 			return nil, err
 		}
-		ret = append(ret, email.ScheduledCampaign{
-			Name: c.Name,
-			When: dt,
-		})
+		name, hash := email.SplitNameHash(c.Name)
+		ret = append(ret, email.ScheduledCampaign{Id: string(c.Id), Name: name, When: when, Hash: hash})
 	}
-
 	return ret, nil
 }
 
-var fakeId int
+// CampaignStats implements email.StatsReporter.
+func (m *mailerlite) CampaignStats() ([]email.CampaignStats, error) {
+	campaigns, err := m.listCampaigns(context.Background(), "sent")
+	if err != nil {
+		return nil, err
+	}
+	var ret []email.CampaignStats
+	for _, c := range campaigns {
+		var sent time.Time
+		if c.FinishedAt != "" {
+			sent, err = time.Parse(dateFormat, c.FinishedAt)
+			if err != nil {
+				return nil, err
+			}
+		}
+		name, _ := email.SplitNameHash(c.Name)
+		ret = append(ret, email.CampaignStats{
+			Id:           string(c.Id),
+			Name:         name,
+			Sent:         sent,
+			Recipients:   c.Stats.Sent,
+			Opens:        c.Stats.UniqueOpensCount,
+			Clicks:       c.Stats.UniqueClicksCount,
+			Unsubscribes: c.Stats.UnsubscribesCount,
+		})
+	}
+	return ret, nil
+}
 
 func (m *mailerlite) CreateCampaign(e *email.Email) (email.Campaign, error) {
-	if m.dryRun {
-		fakeId++
-		return &campaign{id: fakeId, when: e.Date, ml: m}, nil
+	type emailReq struct {
+		Subject  string `json:"subject"`
+		FromName string `json:"from_name"`
+		From     string `json:"from"`
+		Content  string `json:"content"`
 	}
 
-	var id int
-
-	{
-		type campaignReq struct {
-			Type     string `json:"type"`
-			Name     string `json:"name"`
-			Groups   []int  `json:"groups"`
-			Subject  string `json:"subject"`
-			Language string `json:"language"`
-		}
+	type campaignReq struct {
+		Name       string     `json:"name"`
+		LanguageId id         `json:"language_id"`
+		Type       string     `json:"type"`
+		Emails     []emailReq `json:"emails"`
+		Groups     []string   `json:"groups"`
+	}
 
-		type campaignResp struct {
-			Id int `json:"id"`
-		}
+	type campaignResp struct {
+		Data struct {
+			Id id `json:"id"`
+		} `json:"data"`
+	}
 
-		req := campaignReq{
-			Type:     "regular",
-			Name:     e.Name,
-			Groups:   []int{m.group},
-			Subject:  e.Subject,
-			Language: mapLanguage(e.Language),
-		}
-		var resp campaignResp
-		err := queryPayload(m, "v2/campaigns", "POST", &req, &resp)
-		if err != nil {
-			return nil, err
-		}
-		id = resp.Id
+	ctx := context.Background()
+	languageId, err := m.getLanguageId(ctx, e.Language)
+	if err != nil {
+		return nil, err
+	}
+	req := campaignReq{
+		Name:       email.NameWithHash(e),
+		LanguageId: languageId,
+		Type:       "regular",
+		Emails:     []emailReq{{Subject: e.Subject, FromName: m.fromName, From: m.from, Content: e.Html}},
+		Groups:     []string{m.group},
 	}
+	var resp campaignResp
+	if err := m.do(ctx, http.MethodPost, "campaigns", &req, &resp); err != nil {
+		return nil, err
+	}
+	return &campaign{id: resp.Data.Id, when: e.Date, ml: m}, nil
+}
 
-	{
-		type contentReq struct {
-			Plain      string `json:"plain"`
-			Html       string `json:"html"`
-			AutoInline bool   `json:"auto_inline"`
-		}
+// MailerLite schedules campaigns with a precision of a minute.
+func (m *mailerlite) ScheduleTime(date time.Time) time.Time {
+	return date.Truncate(time.Minute)
+}
 
-		type contentResp struct {
-			Success bool `json:"success"`
-		}
+// cancel returns a scheduled campaign to the draft state.
+func (m *mailerlite) cancel(campaignId string) error {
+	return m.do(context.Background(), http.MethodPost, fmt.Sprintf("campaigns/%s/cancel", url.PathEscape(campaignId)), nil, nil)
+}
 
-		req := contentReq{
-			Plain:      e.Plaintext,
-			Html:       e.Html,
-			AutoInline: false,
-		}
-		var resp contentResp
-		err := queryPayload(m, fmt.Sprintf("v2/campaigns/%d/content", id), "PUT", &req, &resp)
-		if err != nil {
-			return nil, err
-		}
-		if !resp.Success {
-			return nil, fmt.Errorf("non-success state returned by Mailerlite for id %d", id)
-		}
+func (m *mailerlite) RescheduleCampaign(campaignId string, date time.Time) error {
+	if err := m.cancel(campaignId); err != nil {
+		return err
 	}
+	return (&campaign{id: id(campaignId), when: date, ml: m}).Schedule()
+}
 
-	return &campaign{id: id, when: e.Date, ml: m}, nil
+func (m *mailerlite) CancelCampaign(campaignId string) error {
+	if err := m.cancel(campaignId); err != nil {
+		return err
+	}
+	return m.do(context.Background(), http.MethodDelete, "campaigns/"+url.PathEscape(campaignId), nil, nil)
 }
 
 func (c *campaign) sendOrSchedule(date *time.Time) error {
-	if c.ml.dryRun {
-		return nil
+	type scheduleReq struct {
+		Date       string `json:"date"`
+		Hours      string `json:"hours"`
+		Minutes    string `json:"minutes"`
+		TimezoneId id     `json:"timezone_id"`
 	}
 
 	type actionReq struct {
-		Type      int    `json:"type"`
-		Analytics int    `json:"analytics"`
-		Date      string `json:"date,omitempty"`
-		TzId      int    `json:"timezone_id,omitempty"`
-	}
-
-	type actionResp struct {
-		Id int `json:"id"`
+		Delivery string       `json:"delivery"`
+		Schedule *scheduleReq `json:"schedule,omitempty"`
 	}
 
-	tzid, err := c.ml.getUtcTimezone()
-	if err != nil {
-		return err
-	}
-
-	req := actionReq{Analytics: 1}
-	if date == nil {
-		req.Type = 1
-	} else {
-		req.Type = 2
-		req.Date = date.UTC().Format("2006-01-02 15:04")
-		req.TzId = tzid
+	ctx := context.Background()
+	req := actionReq{Delivery: "instant"}
+	if date != nil {
+		tzid, err := c.ml.getTimezoneId(ctx)
+		if err != nil {
+			return err
+		}
+		local := date.In(c.ml.location)
+		req.Delivery = "scheduled"
+		req.Schedule = &scheduleReq{
+			Date:       local.Format("2006-01-02"),
+			Hours:      local.Format("15"),
+			Minutes:    local.Format("04"),
+			TimezoneId: tzid,
+		}
 	}
-	var resp actionResp
-	return queryPayload(c.ml, fmt.Sprintf("v2/campaigns/%d/actions/send", c.id), "POST", &req, &resp)
+	return c.ml.do(ctx, http.MethodPost, fmt.Sprintf("campaigns/%s/schedule", url.PathEscape(string(c.id))), &req, nil)
 }
 
 func (c *campaign) Id() string {
-	return fmt.Sprint(c.id)
+	return string(c.id)
 }
 
 func (c *campaign) Schedule() error {
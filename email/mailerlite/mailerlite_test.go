@@ -0,0 +1,202 @@
+package mailerlite
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/httpapi/httpapitest"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+// newTestServer returns a server that replies to each request with the next response for its method and path.
+func newTestServer(t *testing.T, responses map[string][]httpapitest.Response) (*httptest.Server, *[]httpapitest.Request) {
+	return httpapitest.NewServer(t, responses,
+		httpapitest.Unmatched(httpapitest.Response{Status: http.StatusNotFound, Body: `{"message":"Resource not found."}`}),
+		httpapitest.CheckRequest(func(req *http.Request) {
+			assert.Equal(t, "Bearer key", req.Header.Get("Authorization"))
+		}))
+}
+
+func connect(t *testing.T, server *httptest.Server, options ...MailerliteOption) *mailerlite {
+	options = append([]MailerliteOption{WithBaseUri(server.URL + "/api"), WithHttpClient(server.Client())}, options...)
+	m, err := ConnectMailerlite("key", "123456789012345678", "News", "news@example.com", options...)
+	assert.Nil(t, err)
+	return m
+}
+
+func TestScheduledCampaigns(t *testing.T) {
+	server, _ := newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/campaigns?filter%5Bstatus%5D=ready&limit=100": {{Body: `{"data":[{"id":"1","name":"post-1 #0123456789abcdef","scheduled_for":"2024-05-01 10:00:00"}],` +
+			`"links":{"next":"/api/campaigns?filter%5Bstatus%5D=ready&limit=100&page=2"}}`}},
+		"GET /api/campaigns?filter%5Bstatus%5D=ready&limit=100&page=2": {{Body: `{"data":[{"id":"2","name":"post-2","scheduled_for":"2024-05-08 10:00:00"}],"links":{"next":null}}`}},
+	})
+	campaigns, err := connect(t, server).ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Equal(t, []email.ScheduledCampaign{
+		{Id: "1", Name: "post-1", When: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Hash: "0123456789abcdef"},
+		// Created without a content hash.
+		{Id: "2", Name: "post-2", When: time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC)},
+	}, campaigns)
+}
+
+func TestCampaignStats(t *testing.T) {
+	server, _ := newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/campaigns?filter%5Bstatus%5D=sent&limit=100": {{Body: `{"data":[{"id":"1","name":"post-1 #0123456789abcdef","finished_at":"2024-05-01 10:00:05",` +
+			`"stats":{"sent":100,"opens_count":80,"unique_opens_count":60,"clicks_count":30,"unique_clicks_count":20,"unsubscribes_count":1}}],"links":{"next":null}}`}},
+	})
+	stats, err := connect(t, server).CampaignStats()
+	assert.Nil(t, err)
+	assert.Equal(t, []email.CampaignStats{
+		{Id: "1", Name: "post-1", Sent: time.Date(2024, 5, 1, 10, 0, 5, 0, time.UTC), Recipients: 100, Opens: 60, Clicks: 20, Unsubscribes: 1},
+	}, stats)
+}
+
+func TestCreateAndScheduleCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/campaigns/languages":                   {{Body: `{"data":[{"id":1,"shortcode":"en"},{"id":"23","shortcode":"pt"}]}`}},
+		"GET /api/timezones":                             {{Body: `{"data":[{"id":"1","name":"Europe/London"},{"id":"7","name":"Europe/Madrid"}]}`}},
+		"POST /api/campaigns":                            {{Status: http.StatusCreated, Body: `{"data":{"id":"98765432109876543"}}`}},
+		"POST /api/campaigns/98765432109876543/schedule": {{Body: `{"data":{}}`}, {Body: `{"data":{}}`}},
+	})
+	m := connect(t, server, WithTimezone("Europe/Madrid"))
+	c, err := m.CreateCampaign(&email.Email{
+		Name:      "post-1",
+		Language:  languages.LanguageGl,
+		Date:      time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		Subject:   "Asunto",
+		Html:      "<p>Ola</p>",
+		Plaintext: "Ola",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "98765432109876543", c.Id())
+	assert.Nil(t, c.Schedule())
+	assert.Nil(t, c.Send())
+
+	assert.Equal(t, []httpapitest.Request{
+		{Method: "GET", Path: "/api/campaigns/languages"},
+		{Method: "POST", Path: "/api/campaigns", Body: map[string]any{
+			"name":        "post-1 #efeb83df70dc1082",
+			"language_id": "23",
+			"type":        "regular",
+			"emails":      []any{map[string]any{"subject": "Asunto", "from_name": "News", "from": "news@example.com", "content": "<p>Ola</p>"}},
+			"groups":      []any{"123456789012345678"},
+		}},
+		{Method: "GET", Path: "/api/timezones"},
+		// 08:30 UTC is 10:30 in Madrid in summer.
+		{Method: "POST", Path: "/api/campaigns/98765432109876543/schedule", Body: map[string]any{
+			"delivery": "scheduled",
+			"schedule": map[string]any{"date": "2024-05-01", "hours": "10", "minutes": "30", "timezone_id": "7"},
+		}},
+		{Method: "POST", Path: "/api/campaigns/98765432109876543/schedule", Body: map[string]any{"delivery": "instant"}},
+	}, *requests)
+}
+
+func TestRescheduleAndCancelCampaign(t *testing.T) {
+	server, requests := newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/timezones":             {{Body: `{"data":[{"id":"2","name":"UTC"}]}`}},
+		"POST /api/campaigns/1/cancel":   {{Body: `{"data":{}}`}, {Body: `{"data":{}}`}},
+		"POST /api/campaigns/1/schedule": {{Body: `{"data":{}}`}},
+		"DELETE /api/campaigns/1":        {{Status: http.StatusNoContent}},
+	})
+	m := connect(t, server)
+	assert.Nil(t, m.RescheduleCampaign("1", time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)))
+	assert.Nil(t, m.CancelCampaign("1"))
+
+	assert.Equal(t, []httpapitest.Request{
+		{Method: "POST", Path: "/api/campaigns/1/cancel"},
+		{Method: "GET", Path: "/api/timezones"},
+		{Method: "POST", Path: "/api/campaigns/1/schedule", Body: map[string]any{
+			"delivery": "scheduled",
+			"schedule": map[string]any{"date": "2024-05-02", "hours": "10", "minutes": "00", "timezone_id": "2"},
+		}},
+		{Method: "POST", Path: "/api/campaigns/1/cancel"},
+		{Method: "DELETE", Path: "/api/campaigns/1"},
+	}, *requests)
+}
+
+func TestUtcTimezone(t *testing.T) {
+	server, _ := newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/timezones": {{Body: `{"data":[{"id":"1","name":"Europe/London"},{"id":"2","name":"Etc/UTC"}]}`}},
+	})
+	tzid, err := connect(t, server).getTimezoneId(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, id("2"), tzid)
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	server, requests := newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/timezones": {
+			{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "0"}, Body: `{"message":"Too Many Attempts."}`},
+			{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "0"}, Body: `{"message":"Too Many Attempts."}`},
+			{Body: `{"data":[{"id":"2","name":"UTC"}]}`},
+		},
+	})
+	tzid, err := connect(t, server).getTimezoneId(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, id("2"), tzid)
+	assert.Len(t, *requests, 3)
+
+	server, requests = newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/timezones": {
+			{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "0"}, Body: `{"message":"Too Many Attempts."}`},
+			{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "0"}, Body: `{"message":"Too Many Attempts."}`},
+		},
+	})
+	_, err = connect(t, server, WithMaxRetries(1)).getTimezoneId(context.Background())
+	assert.True(t, IsRateLimited(err))
+	assert.Len(t, *requests, 2)
+}
+
+func TestValidationError(t *testing.T) {
+	server, _ := newTestServer(t, map[string][]httpapitest.Response{
+		"GET /api/campaigns/languages": {{Body: `{"data":[{"id":"4","shortcode":"en"}]}`}},
+		"POST /api/campaigns": {{Status: http.StatusUnprocessableEntity,
+			Body: `{"message":"The emails.0.from must be a verified domain.","errors":{"emails.0.from":["The emails.0.from must be a verified domain."]}}`}},
+	})
+	_, err := connect(t, server).CreateCampaign(&email.Email{Name: "post-1", Language: languages.LanguageEn})
+	var mlErr *Error
+	assert.True(t, errors.As(err, &mlErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, mlErr.Status)
+	assert.Equal(t, "The emails.0.from must be a verified domain.", mlErr.Message)
+	assert.Equal(t, map[string][]string{"emails.0.from": {"The emails.0.from must be a verified domain."}}, mlErr.Errors)
+	assert.False(t, IsNotFound(err))
+}
+
+func TestSubscribers(t *testing.T) {
+	ctx := context.Background()
+	server, requests := newTestServer(t, map[string][]httpapitest.Response{
+		"POST /api/subscribers": {{Body: `{"data":{"id":"55"}}`}},
+		"GET /api/subscribers/ann@example.com": {
+			{Body: `{"data":{"id":"55","status":"active","groups":[{"id":"1"},{"id":"123456789012345678"}]}}`},
+			{Body: `{"data":{"id":"55","status":"active","groups":[{"id":"1"},{"id":"123456789012345678"}]}}`},
+		},
+		"DELETE /api/subscribers/55/groups/123456789012345678": {{Status: http.StatusNoContent}},
+	})
+	m := connect(t, server)
+
+	assert.Nil(t, m.Subscribe(ctx, "ann@example.com", "Ann"))
+	assert.Equal(t, map[string]any{
+		"email":       "ann@example.com",
+		"fields":      map[string]any{"name": "Ann"},
+		"groups":      []any{"123456789012345678"},
+		"status":      "active",
+		"resubscribe": true,
+	}, (*requests)[0].Body)
+
+	subscribed, err := m.IsSubscribed(ctx, "ann@example.com")
+	assert.Nil(t, err)
+	assert.True(t, subscribed)
+	subscribed, err = m.IsSubscribed(ctx, "bob@example.com")
+	assert.Nil(t, err)
+	assert.False(t, subscribed)
+
+	assert.Nil(t, m.Unsubscribe(ctx, "ann@example.com"))
+	assert.Equal(t, httpapitest.Request{Method: "DELETE", Path: "/api/subscribers/55/groups/123456789012345678"}, (*requests)[len(*requests)-1])
+	assert.Nil(t, m.Unsubscribe(ctx, "bob@example.com"))
+}
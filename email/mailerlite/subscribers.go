@@ -0,0 +1,78 @@
+package mailerlite
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type subscriberResp struct {
+	Data struct {
+		Id     id     `json:"id"`
+		Status string `json:"status"`
+		Groups []struct {
+			Id id `json:"id"`
+		} `json:"groups"`
+	} `json:"data"`
+}
+
+// Subscribe implements email.SubscriberManager.
+func (m *mailerlite) Subscribe(ctx context.Context, email string, name string) error {
+	type subscriberReq struct {
+		Email       string            `json:"email"`
+		Fields      map[string]string `json:"fields"`
+		Groups      []string          `json:"groups"`
+		Status      string            `json:"status"`
+		Resubscribe bool              `json:"resubscribe"`
+	}
+
+	req := subscriberReq{
+		Email:       email,
+		Fields:      map[string]string{"name": name},
+		Groups:      []string{m.group},
+		Status:      "active",
+		Resubscribe: true,
+	}
+	return m.do(ctx, http.MethodPost, "subscribers", &req, nil)
+}
+
+// Unsubscribe implements email.SubscriberManager. It removes the subscriber from the group,
+// but not from other groups in the same account.
+func (m *mailerlite) Unsubscribe(ctx context.Context, email string) error {
+	var subscriber subscriberResp
+	err := m.do(ctx, http.MethodGet, "subscribers/"+url.PathEscape(email), nil, &subscriber)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = m.do(ctx, http.MethodDelete, fmt.Sprintf("subscribers/%s/groups/%s", url.PathEscape(string(subscriber.Data.Id)), url.PathEscape(m.group)), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// IsSubscribed implements email.SubscriberManager.
+func (m *mailerlite) IsSubscribed(ctx context.Context, email string) (bool, error) {
+	var subscriber subscriberResp
+	err := m.do(ctx, http.MethodGet, "subscribers/"+url.PathEscape(email), nil, &subscriber)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if subscriber.Data.Status != "active" {
+		return false, nil
+	}
+	for _, g := range subscriber.Data.Groups {
+		if string(g.Id) == m.group {
+			return true, nil
+		}
+	}
+	return false, nil
+}
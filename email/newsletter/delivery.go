@@ -0,0 +1,97 @@
+package newsletter
+
+import (
+	"context"
+	"html"
+	"log"
+	"net/mail"
+	"strings"
+
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
+)
+
+// SendDue delivers the campaigns that are due. Messages that can't be sent are retried the next time
+// SendDue is called, up to the maximum number of attempts.
+func (n *Newsletter) SendDue(ctx context.Context) error {
+	campaigns, err := n.store.activeCampaigns(ctx, n.language.Code(), n.now())
+	if err != nil {
+		return err
+	}
+	for _, c := range campaigns {
+		if err := n.sendCampaign(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Newsletter) sendCampaign(ctx context.Context, c *storedCampaign) error {
+	if err := n.store.startCampaign(ctx, c); err != nil {
+		return err
+	}
+	start := n.now()
+	for {
+		subscribers, err := n.store.pendingDeliveries(ctx, c.id, n.maxAttempts, start, n.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(subscribers) == 0 {
+			break
+		}
+		for _, sub := range subscribers {
+			if err := n.limiter.Wait(ctx); err != nil {
+				return err
+			}
+			sendErr := n.sendTo(c, sub)
+			if sendErr != nil {
+				log.Printf("Error sending campaign %s to subscriber %d: %s", c.name, sub.Id, sendErr)
+			}
+			if err := n.store.recordDelivery(ctx, c.id, sub.Id, n.now(), sendErr); err != nil {
+				return err
+			}
+		}
+	}
+	finished, err := n.store.finishCampaign(ctx, c.id, n.maxAttempts, n.now())
+	if err != nil {
+		return err
+	}
+	if finished {
+		log.Printf("Finished sending campaign %s", c.name)
+	}
+	return nil
+}
+
+// sendTo sends a campaign to a subscriber, personalized with their name, email address and unsubscribe link.
+func (n *Newsletter) sendTo(c *storedCampaign, sub *Subscriber) error {
+	unsubscribe, err := n.links.UnsubscribeUri(&subscriptions.Preferences{Email: sub.Email, Name: sub.Name, Language: sub.Language})
+	if err != nil {
+		return err
+	}
+	to := (&mail.Address{Name: sub.Name, Address: sub.Email}).String()
+	headers := map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribe + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	return n.sender.SendHtmlMail(
+		to,
+		personalize(c.subject, sub, unsubscribe, noEscape),
+		personalize(c.html, sub, unsubscribe, html.EscapeString),
+		personalize(c.plaintext, sub, unsubscribe, noEscape),
+		headers)
+}
+
+func noEscape(s string) string {
+	return s
+}
+
+// personalize replaces the placeholders {$name}, {$email} and {$unsubscribe} in the text.
+// These are the same placeholders Mailerlite uses, so templates work with both engines.
+func personalize(text string, sub *Subscriber, unsubscribe string, escape func(string) string) string {
+	return strings.NewReplacer(
+		"{$name}", escape(sub.Name),
+		"{$email}", escape(sub.Email),
+		"{$unsubscribe}", escape(unsubscribe),
+		// html/template escapes the placeholder when it appears in an href attribute.
+		"%7b$unsubscribe%7d", escape(unsubscribe),
+	).Replace(text)
+}
@@ -0,0 +1,36 @@
+CREATE TABLE `Subscribers` (
+  `SubscriberId` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Email` varchar(255) NOT NULL,
+  `Name` varchar(255) NOT NULL,
+  `Language` varchar(8) NOT NULL,
+  `Status` tinyint(1) NOT NULL,
+  `Created` datetime NOT NULL,
+  PRIMARY KEY (`SubscriberId`),
+  UNIQUE KEY `EmailLanguage` (`Email`, `Language`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `Campaigns` (
+  `CampaignId` bigint(20) NOT NULL AUTO_INCREMENT,
+  `Name` varchar(255) NOT NULL,
+  `Language` varchar(8) NOT NULL,
+  `Subject` varchar(255) NOT NULL,
+  `Html` mediumtext NOT NULL,
+  `Plaintext` mediumtext NOT NULL,
+  `Scheduled` datetime NOT NULL,
+  `Status` tinyint(1) NOT NULL,
+  `Finished` datetime DEFAULT NULL,
+  PRIMARY KEY (`CampaignId`),
+  KEY `CampaignsByStatus` (`Language`, `Status`, `Scheduled`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE `Deliveries` (
+  `CampaignId` bigint(20) NOT NULL,
+  `SubscriberId` bigint(20) NOT NULL,
+  `Attempts` int(11) NOT NULL,
+  `LastAttempt` datetime DEFAULT NULL,
+  `Sent` datetime DEFAULT NULL,
+  `Error` text DEFAULT NULL,
+  PRIMARY KEY (`CampaignId`, `SubscriberId`),
+  CONSTRAINT `Deliveries_ibfk_1` FOREIGN KEY (`CampaignId`) REFERENCES `Campaigns` (`CampaignId`),
+  CONSTRAINT `Deliveries_ibfk_2` FOREIGN KEY (`SubscriberId`) REFERENCES `Subscribers` (`SubscriberId`)
+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -0,0 +1,187 @@
+// The newsletter package is a self-hosted email engine. It keeps the
+// subscribers and campaigns in a SQL database and delivers the campaigns
+// itself through an SMTP server.
+//
+// Campaigns are not sent when they are scheduled; instead, SendDue must be
+// called periodically to deliver the campaigns that are due. Messages are
+// sent in batches and throttled to a maximum rate, and every message is
+// personalized for its recipient.
+//
+// The unsubscribe links in the messages point to the subscriptions service,
+// which unsubscribes the recipient from all the newsletters in their language.
+
+package newsletter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+// Sender delivers a message with HTML and plain text bodies.
+type Sender interface {
+	SendHtmlMail(to string, subject string, html string, plaintext string, headers map[string]string) error
+}
+
+// Newsletter is an email.Engine that delivers campaigns to the subscribers of one language.
+type Newsletter struct {
+	store       *Store
+	language    languages.Language
+	sender      Sender
+	links       *subscriptions.Links
+	batchSize   int
+	maxAttempts int
+	limiter     *rate.Limiter
+	now         func() time.Time
+}
+
+type NewsletterOption func(*Newsletter)
+
+// BatchSize sets how many messages are sent before checking the progress of a campaign.
+func BatchSize(size int) NewsletterOption {
+	return func(n *Newsletter) {
+		n.batchSize = size
+	}
+}
+
+// MaxAttempts sets how many times the delivery of a message is attempted before giving up.
+func MaxAttempts(attempts int) NewsletterOption {
+	return func(n *Newsletter) {
+		n.maxAttempts = attempts
+	}
+}
+
+// MessagesPerMinute sets the maximum rate at which messages are sent. Zero means no limit.
+func MessagesPerMinute(messages int) NewsletterOption {
+	return func(n *Newsletter) {
+		if messages <= 0 {
+			n.limiter = rate.NewLimiter(rate.Inf, 1)
+		} else {
+			n.limiter = rate.NewLimiter(rate.Limit(float64(messages)/60), 1)
+		}
+	}
+}
+
+// NewNewsletter returns a Newsletter that stores its data in the store, sends messages with the sender, and
+// adds the subscriptions service's unsubscribe links, created with links.
+func NewNewsletter(store *Store, language languages.Language, sender Sender, links *subscriptions.Links, options ...NewsletterOption) *Newsletter {
+	n := &Newsletter{
+		store:       store,
+		language:    language,
+		sender:      sender,
+		links:       links,
+		batchSize:   50,
+		maxAttempts: 3,
+		limiter:     rate.NewLimiter(rate.Inf, 1),
+		now:         time.Now,
+	}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// Store returns the store where the subscribers and campaigns are kept.
+func (n *Newsletter) Store() *Store {
+	return n.store
+}
+
+func (n *Newsletter) Name() string {
+	return "Newsletter"
+}
+
+func (n *Newsletter) ScheduledCampaigns() ([]email.ScheduledCampaign, error) {
+	campaigns, err := n.store.activeCampaigns(context.Background(), n.language.Code(), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]email.ScheduledCampaign, 0, len(campaigns))
+	for _, c := range campaigns {
+		out = append(out, email.ScheduledCampaign{
+			Id:   strconv.FormatInt(c.id, 10),
+			Name: c.name,
+			When: c.scheduled,
+			Hash: email.ContentHash(&email.Email{Subject: c.subject, Html: c.html, Plaintext: c.plaintext}),
+		})
+	}
+	return out, nil
+}
+
+func (n *Newsletter) CreateCampaign(e *email.Email) (email.Campaign, error) {
+	if e.Language != n.language {
+		return nil, fmt.Errorf("the email is in %s but the newsletter is in %s", e.Language.Code(), n.language.Code())
+	}
+	c := &storedCampaign{
+		name:      e.Name,
+		language:  e.Language.Code(),
+		subject:   e.Subject,
+		html:      e.Html,
+		plaintext: e.Plaintext,
+		scheduled: e.Date,
+		status:    campaignDraft,
+	}
+	id, err := n.store.createCampaign(context.Background(), c)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign{id: id, when: e.Date, n: n}, nil
+}
+
+func (n *Newsletter) ScheduleTime(date time.Time) time.Time {
+	return date
+}
+
+func (n *Newsletter) RescheduleCampaign(id string, date time.Time) error {
+	campaignId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	return n.store.scheduleCampaign(context.Background(), campaignId, date)
+}
+
+func (n *Newsletter) CancelCampaign(id string) error {
+	campaignId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	return n.store.deleteCampaign(context.Background(), campaignId)
+}
+
+// UpdateCampaign implements email.CampaignUpdater.
+func (n *Newsletter) UpdateCampaign(id string, e *email.Email) error {
+	campaignId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return err
+	}
+	return n.store.updateCampaign(context.Background(), campaignId, &storedCampaign{
+		subject:   e.Subject,
+		html:      e.Html,
+		plaintext: e.Plaintext,
+		scheduled: e.Date,
+	})
+}
+
+type campaign struct {
+	id   int64
+	when time.Time
+	n    *Newsletter
+}
+
+func (c *campaign) Id() string {
+	return strconv.FormatInt(c.id, 10)
+}
+
+// Send schedules the campaign for right now; it goes out the next time SendDue is called.
+func (c *campaign) Send() error {
+	return c.n.store.scheduleCampaign(context.Background(), c.id, c.n.now())
+}
+
+func (c *campaign) Schedule() error {
+	return c.n.store.scheduleCampaign(context.Background(), c.id, c.when)
+}
@@ -0,0 +1,32 @@
+CREATE TABLE Subscribers (
+    SubscriberId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    Email TEXT NOT NULL,
+    Name TEXT NOT NULL,
+    Language TEXT NOT NULL,
+    Status INTEGER NOT NULL,
+    Created DATETIME NOT NULL,
+    UNIQUE (Email, Language));
+
+CREATE TABLE Campaigns (
+    CampaignId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    Name TEXT NOT NULL,
+    Language TEXT NOT NULL,
+    Subject TEXT NOT NULL,
+    Html TEXT NOT NULL,
+    Plaintext TEXT NOT NULL,
+    Scheduled DATETIME NOT NULL,
+    Status INTEGER NOT NULL,
+    Finished DATETIME NULL);
+
+CREATE INDEX CampaignsByStatus ON Campaigns (Language, Status, Scheduled);
+
+CREATE TABLE Deliveries (
+    CampaignId INTEGER NOT NULL,
+    SubscriberId INTEGER NOT NULL,
+    Attempts INTEGER NOT NULL,
+    LastAttempt DATETIME NULL,
+    Sent DATETIME NULL,
+    Error TEXT NULL,
+    PRIMARY KEY (CampaignId, SubscriberId),
+    FOREIGN KEY (CampaignId) REFERENCES Campaigns(CampaignId),
+    FOREIGN KEY (SubscriberId) REFERENCES Subscribers(SubscriberId));
@@ -0,0 +1,16 @@
+package sqlite3
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+	"jacobo.tarrio.org/jtweb/email/newsletter"
+)
+
+func NewSqlite3Store(connString string) (*newsletter.Store, error) {
+	db, err := sql.Open("sqlite3", connString)
+	if err != nil {
+		return nil, err
+	}
+	return newsletter.NewStore(db), nil
+}
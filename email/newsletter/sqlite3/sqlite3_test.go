@@ -0,0 +1,195 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/email/newsletter"
+	"jacobo.tarrio.org/jtweb/email/subscriptions"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+type sentMessage struct {
+	to        string
+	subject   string
+	html      string
+	plaintext string
+	headers   map[string]string
+}
+
+type fakeSender struct {
+	sent []sentMessage
+	fail map[string]bool
+}
+
+func (s *fakeSender) SendHtmlMail(to string, subject string, html string, plaintext string, headers map[string]string) error {
+	if s.fail[to] {
+		return fmt.Errorf("cannot send to %s", to)
+	}
+	s.sent = append(s.sent, sentMessage{to: to, subject: subject, html: html, plaintext: plaintext, headers: headers})
+	return nil
+}
+
+func newTestNewsletter(t *testing.T, sender *fakeSender) *newsletter.Newsletter {
+	schema, err := os.ReadFile("schema.sql")
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "newsletter.db")
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	_, err = db.Exec(string(schema))
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+	store, err := NewSqlite3Store(path)
+	assert.Nil(t, err)
+	links := subscriptions.NewLinks("key", "https://example.com/newsletter/")
+	return newsletter.NewNewsletter(store, languages.LanguageEn, sender, links, newsletter.BatchSize(2))
+}
+
+func addSubscriber(t *testing.T, n *newsletter.Newsletter, email string, name string, language string, status newsletter.SubscriberStatus) int64 {
+	id, err := n.Store().AddSubscriber(context.Background(), email, name, language, status)
+	assert.Nil(t, err)
+	return id
+}
+
+func testEmail(name string, date time.Time) *email.Email {
+	return &email.Email{
+		Name:      name,
+		Language:  languages.LanguageEn,
+		Date:      date,
+		Subject:   "News for {$name}",
+		Html:      `<p>Hello {$name}</p><a href="%7b$unsubscribe%7d">Unsubscribe</a>`,
+		Plaintext: "Hello {$name}. Unsubscribe: {$unsubscribe}",
+	}
+}
+
+func TestScheduleAndSend(t *testing.T) {
+	sender := &fakeSender{}
+	n := newTestNewsletter(t, sender)
+	addSubscriber(t, n, "a@example.com", "Ann <&>", "en", newsletter.SubscriberActive)
+	addSubscriber(t, n, "b@example.com", "Bob", "en", newsletter.SubscriberActive)
+	addSubscriber(t, n, "c@example.com", "Cat", "en", newsletter.SubscriberActive)
+	addSubscriber(t, n, "d@example.com", "Dan", "en", newsletter.SubscriberPending)
+	addSubscriber(t, n, "e@example.com", "Eve", "en", newsletter.SubscriberUnsubscribed)
+	addSubscriber(t, n, "f@example.com", "Fay", "es", newsletter.SubscriberActive)
+
+	future := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	later, err := n.CreateCampaign(testEmail("later", future))
+	assert.Nil(t, err)
+	assert.Nil(t, later.Schedule())
+	now, err := n.CreateCampaign(testEmail("now", future))
+	assert.Nil(t, err)
+	assert.Nil(t, now.Send())
+	_, err = n.CreateCampaign(testEmail("draft", future))
+	assert.Nil(t, err)
+
+	scheduled, err := n.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Len(t, scheduled, 2)
+	assert.Equal(t, "now", scheduled[0].Name)
+	assert.Equal(t, "later", scheduled[1].Name)
+	assert.True(t, future.Equal(scheduled[1].When))
+
+	assert.Nil(t, n.SendDue(context.Background()))
+	assert.Len(t, sender.sent, 3)
+	assert.Equal(t, `"Ann <&>" <a@example.com>`, sender.sent[0].to)
+	assert.Equal(t, "News for Ann <&>", sender.sent[0].subject)
+	assert.Contains(t, sender.sent[0].html, "<p>Hello Ann &lt;&amp;&gt;</p>")
+	assert.Contains(t, sender.sent[0].html, `<a href="https://example.com/newsletter/unsubscribe?token=`)
+	assert.Contains(t, sender.sent[0].plaintext, "Hello Ann <&>. Unsubscribe: https://example.com/newsletter/unsubscribe?token=")
+	assert.Equal(t, "List-Unsubscribe=One-Click", sender.sent[0].headers["List-Unsubscribe-Post"])
+	assert.Equal(t, `"Bob" <b@example.com>`, sender.sent[1].to)
+	assert.Equal(t, `"Cat" <c@example.com>`, sender.sent[2].to)
+
+	scheduled, err = n.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Len(t, scheduled, 1)
+	assert.Equal(t, "later", scheduled[0].Name)
+
+	// Nothing else is due.
+	assert.Nil(t, n.SendDue(context.Background()))
+	assert.Len(t, sender.sent, 3)
+}
+
+func TestRetryFailedDeliveries(t *testing.T) {
+	sender := &fakeSender{fail: map[string]bool{"<b@example.com>": true}}
+	n := newTestNewsletter(t, sender)
+	addSubscriber(t, n, "a@example.com", "", "en", newsletter.SubscriberActive)
+	addSubscriber(t, n, "b@example.com", "", "en", newsletter.SubscriberActive)
+
+	c, err := n.CreateCampaign(testEmail("campaign", time.Now()))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Send())
+
+	assert.Nil(t, n.SendDue(context.Background()))
+	assert.Len(t, sender.sent, 1)
+	scheduled, err := n.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Len(t, scheduled, 1)
+
+	sender.fail = nil
+	assert.Nil(t, n.SendDue(context.Background()))
+	assert.Len(t, sender.sent, 2)
+	assert.Equal(t, "<b@example.com>", sender.sent[1].to)
+	scheduled, err = n.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Len(t, scheduled, 0)
+}
+
+func TestUnsubscribeLink(t *testing.T) {
+	sender := &fakeSender{}
+	n := newTestNewsletter(t, sender)
+	addSubscriber(t, n, "a@example.com", "Ann", "en", newsletter.SubscriberActive)
+	c, err := n.CreateCampaign(testEmail("campaign", time.Now()))
+	assert.Nil(t, err)
+	assert.Nil(t, c.Send())
+	assert.Nil(t, n.SendDue(context.Background()))
+	assert.Len(t, sender.sent, 1)
+
+	// The link is the subscriptions service's, which unsubscribes from all the newsletters in the language.
+	header := sender.sent[0].headers["List-Unsubscribe"]
+	assert.Regexp(t, "^<https://example.com/newsletter/unsubscribe\\?token=[^>]+>$", header)
+	uri, err := url.Parse(header[1 : len(header)-1])
+	assert.Nil(t, err)
+	prefs, err := subscriptions.NewLinks("key", "https://example.com/newsletter/").ParsePreferences(uri.Query().Get("token"))
+	assert.Nil(t, err)
+	assert.Equal(t, &subscriptions.Preferences{Email: "a@example.com", Name: "Ann", Language: "en"}, prefs)
+}
+
+func TestChangeScheduledCampaign(t *testing.T) {
+	sender := &fakeSender{}
+	n := newTestNewsletter(t, sender)
+	addSubscriber(t, n, "a@example.com", "Ann", "en", newsletter.SubscriberActive)
+
+	future := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	e := testEmail("post", future)
+	c, err := n.CreateCampaign(e)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Schedule())
+	scheduled, err := n.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Equal(t, []email.ScheduledCampaign{{Id: c.Id(), Name: "post", When: future.UTC(), Hash: email.ContentHash(e)}}, scheduled)
+
+	assert.Nil(t, n.RescheduleCampaign(c.Id(), future.Add(time.Hour)))
+	e.Subject = "Changed"
+	e.Date = future.Add(2 * time.Hour)
+	assert.Nil(t, n.UpdateCampaign(c.Id(), e))
+	scheduled, err = n.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Len(t, scheduled, 1)
+	assert.True(t, e.Date.Equal(scheduled[0].When))
+	assert.Equal(t, email.ContentHash(e), scheduled[0].Hash)
+
+	assert.Nil(t, n.CancelCampaign(c.Id()))
+	scheduled, err = n.ScheduledCampaigns()
+	assert.Nil(t, err)
+	assert.Empty(t, scheduled)
+	assert.ErrorIs(t, n.CancelCampaign(c.Id()), newsletter.ErrNotFound)
+}
@@ -0,0 +1,301 @@
+package newsletter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SubscriberStatus is the state of a subscription.
+type SubscriberStatus int
+
+const (
+	// The subscriber hasn't confirmed their address yet.
+	SubscriberPending SubscriberStatus = 0
+	// The subscriber receives the newsletter.
+	SubscriberActive SubscriberStatus = 1
+	// The subscriber asked not to receive the newsletter anymore.
+	SubscriberUnsubscribed SubscriberStatus = 2
+)
+
+// Subscriber contains the data for a subscriber.
+type Subscriber struct {
+	Id       int64
+	Email    string
+	Name     string
+	Language string
+	Status   SubscriberStatus
+}
+
+type campaignStatus int
+
+const (
+	campaignDraft     campaignStatus = 0
+	campaignScheduled campaignStatus = 1
+	campaignSending   campaignStatus = 2
+	campaignSent      campaignStatus = 3
+)
+
+type storedCampaign struct {
+	id        int64
+	name      string
+	language  string
+	subject   string
+	html      string
+	plaintext string
+	scheduled time.Time
+	status    campaignStatus
+}
+
+// ErrNotFound is returned when a subscriber or campaign doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Store keeps subscribers, campaigns and deliveries in a SQL database.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore returns a Store that uses the given database.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// AddSubscriber adds a subscriber for the language, or updates their name and status if they were already there.
+func (s *Store) AddSubscriber(ctx context.Context, email string, name string, language string, status SubscriberStatus) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, "SELECT SubscriberId FROM Subscribers WHERE Email = ? AND Language = ?", email, language).Scan(&id)
+	if err == sql.ErrNoRows {
+		result, err := tx.ExecContext(ctx, "INSERT INTO Subscribers (Email, Name, Language, Status, Created) VALUES (?, ?, ?, ?, ?)", email, name, language, status, time.Now().UTC())
+		if err != nil {
+			return 0, err
+		}
+		id, err = result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+	} else if err != nil {
+		return 0, err
+	} else {
+		_, err = tx.ExecContext(ctx, "UPDATE Subscribers SET Name = ?, Status = ? WHERE SubscriberId = ?", name, status, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// GetSubscriber returns the subscriber with the given id.
+func (s *Store) GetSubscriber(ctx context.Context, id int64) (*Subscriber, error) {
+	sub := &Subscriber{}
+	err := s.db.QueryRowContext(ctx, "SELECT SubscriberId, Email, Name, Language, Status FROM Subscribers WHERE SubscriberId = ?", id).
+		Scan(&sub.Id, &sub.Email, &sub.Name, &sub.Language, &sub.Status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// FindSubscriber returns the subscriber with the given address and language.
+func (s *Store) FindSubscriber(ctx context.Context, email string, language string) (*Subscriber, error) {
+	sub := &Subscriber{}
+	err := s.db.QueryRowContext(ctx, "SELECT SubscriberId, Email, Name, Language, Status FROM Subscribers WHERE Email = ? AND Language = ?", email, language).
+		Scan(&sub.Id, &sub.Email, &sub.Name, &sub.Language, &sub.Status)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// SetSubscriberStatus changes the status of the subscriber with the given id.
+func (s *Store) SetSubscriberStatus(ctx context.Context, id int64, status SubscriberStatus) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE Subscribers SET Status = ? WHERE SubscriberId = ?", status, id)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) createCampaign(ctx context.Context, c *storedCampaign) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO Campaigns (Name, Language, Subject, Html, Plaintext, Scheduled, Status) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		c.name, c.language, c.subject, c.html, c.plaintext, c.scheduled.UTC(), c.status)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// scheduleCampaign marks a campaign to be sent at the given time.
+func (s *Store) scheduleCampaign(ctx context.Context, id int64, when time.Time) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE Campaigns SET Scheduled = ?, Status = ? WHERE CampaignId = ? AND Status IN (?, ?)",
+		when.UTC(), campaignScheduled, id, campaignDraft, campaignScheduled)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// updateCampaign replaces the content and the send time of a campaign that hasn't started to be sent.
+func (s *Store) updateCampaign(ctx context.Context, id int64, c *storedCampaign) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE Campaigns SET Subject = ?, Html = ?, Plaintext = ?, Scheduled = ? WHERE CampaignId = ? AND Status IN (?, ?)",
+		c.subject, c.html, c.plaintext, c.scheduled.UTC(), id, campaignDraft, campaignScheduled)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// deleteCampaign deletes a campaign that hasn't started to be sent.
+func (s *Store) deleteCampaign(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM Campaigns WHERE CampaignId = ? AND Status IN (?, ?)", id, campaignDraft, campaignScheduled)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// activeCampaigns returns the campaigns in the language that are scheduled before the given time and haven't been sent yet.
+func (s *Store) activeCampaigns(ctx context.Context, language string, before time.Time) ([]*storedCampaign, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT CampaignId, Name, Language, Subject, Html, Plaintext, Scheduled, Status FROM Campaigns "+
+			"WHERE Language = ? AND Scheduled <= ? AND Status IN (?, ?) ORDER BY Scheduled, CampaignId",
+		language, before.UTC(), campaignScheduled, campaignSending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []*storedCampaign{}
+	for rows.Next() {
+		c := &storedCampaign{}
+		if err := rows.Scan(&c.id, &c.name, &c.language, &c.subject, &c.html, &c.plaintext, &c.scheduled, &c.status); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// startCampaign marks a scheduled campaign as being sent and creates a delivery for every active subscriber.
+// It does nothing if the campaign was already started.
+func (s *Store) startCampaign(ctx context.Context, c *storedCampaign) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE Campaigns SET Status = ? WHERE CampaignId = ? AND Status = ?", campaignSending, c.id, campaignScheduled)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO Deliveries (CampaignId, SubscriberId, Attempts) SELECT ?, SubscriberId, 0 FROM Subscribers WHERE Language = ? AND Status = ?",
+		c.id, c.language, SubscriberActive)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pendingDeliveries returns up to limit subscribers who haven't received the campaign yet, skipping those
+// whose delivery was already attempted maxAttempts times or since the given time.
+func (s *Store) pendingDeliveries(ctx context.Context, campaignId int64, maxAttempts int, since time.Time, limit int) ([]*Subscriber, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT s.SubscriberId, s.Email, s.Name, s.Language, s.Status FROM Deliveries d "+
+			"JOIN Subscribers s ON d.SubscriberId = s.SubscriberId "+
+			"WHERE d.CampaignId = ? AND d.Sent IS NULL AND d.Attempts < ? AND (d.LastAttempt IS NULL OR d.LastAttempt < ?) AND s.Status = ? "+
+			"ORDER BY s.SubscriberId LIMIT ?",
+		campaignId, maxAttempts, since.UTC(), SubscriberActive, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []*Subscriber{}
+	for rows.Next() {
+		sub := &Subscriber{}
+		if err := rows.Scan(&sub.Id, &sub.Email, &sub.Name, &sub.Language, &sub.Status); err != nil {
+			return nil, err
+		}
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+// recordDelivery records an attempt to send the campaign to a subscriber. If sendErr is nil, the attempt succeeded.
+func (s *Store) recordDelivery(ctx context.Context, campaignId int64, subscriberId int64, when time.Time, sendErr error) error {
+	var err error
+	if sendErr == nil {
+		_, err = s.db.ExecContext(ctx, "UPDATE Deliveries SET Sent = ?, LastAttempt = ?, Attempts = Attempts + 1, Error = NULL WHERE CampaignId = ? AND SubscriberId = ?",
+			when.UTC(), when.UTC(), campaignId, subscriberId)
+	} else {
+		_, err = s.db.ExecContext(ctx, "UPDATE Deliveries SET LastAttempt = ?, Attempts = Attempts + 1, Error = ? WHERE CampaignId = ? AND SubscriberId = ?",
+			when.UTC(), sendErr.Error(), campaignId, subscriberId)
+	}
+	return err
+}
+
+// finishCampaign marks the campaign as sent if there are no deliveries left to attempt.
+// It returns whether the campaign was finished.
+func (s *Store) finishCampaign(ctx context.Context, campaignId int64, maxAttempts int, when time.Time) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE Campaigns SET Status = ?, Finished = ? WHERE CampaignId = ? AND Status = ? AND NOT EXISTS "+
+			"(SELECT 1 FROM Deliveries d JOIN Subscribers s ON d.SubscriberId = s.SubscriberId "+
+			"WHERE d.CampaignId = ? AND d.Sent IS NULL AND d.Attempts < ? AND s.Status = ?)",
+		campaignSent, when.UTC(), campaignId, campaignSending, campaignId, maxAttempts, SubscriberActive)
+	if err != nil {
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,35 @@
+package newsletter
+
+import (
+	"context"
+)
+
+// Subscribe implements email.SubscriberManager.
+func (n *Newsletter) Subscribe(ctx context.Context, email string, name string) error {
+	_, err := n.store.AddSubscriber(ctx, email, name, n.language.Code(), SubscriberActive)
+	return err
+}
+
+// Unsubscribe implements email.SubscriberManager.
+func (n *Newsletter) Unsubscribe(ctx context.Context, email string) error {
+	sub, err := n.store.FindSubscriber(ctx, email, n.language.Code())
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return n.store.SetSubscriberStatus(ctx, sub.Id, SubscriberUnsubscribed)
+}
+
+// IsSubscribed implements email.SubscriberManager.
+func (n *Newsletter) IsSubscribed(ctx context.Context, email string) (bool, error) {
+	sub, err := n.store.FindSubscriber(ctx, email, n.language.Code())
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return sub.Status == SubscriberActive, nil
+}
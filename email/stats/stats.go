@@ -0,0 +1,117 @@
+// The stats package keeps the engagement statistics of the campaigns sent
+// for each page in a JSON file, and reports which pages were most engaging.
+//
+// The JSON file contains an object with a "Pages" object, whose keys are the
+// pages' names. Each page is an object whose keys are the names of the mailers
+// that sent it, and whose values are objects with the following fields:
+//
+//   - "Id": the campaign's identifier in the email service.
+//   - "Name": the campaign's name.
+//   - "Sent": the date the campaign was sent in RFC 3339 format.
+//   - "Recipients": how many subscribers the campaign was sent to.
+//   - "Opens": how many recipients opened the email.
+//   - "Clicks": how many recipients clicked on a link in the email.
+//   - "Unsubscribes": how many recipients unsubscribed from the email.
+
+package stats
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"jacobo.tarrio.org/jtweb/email"
+)
+
+// Stats contains the campaign statistics for a set of pages.
+type Stats struct {
+	// The statistics for each page, by page name and mailer name.
+	Pages map[string]map[string]email.CampaignStats
+}
+
+// Engagement summarizes the statistics of all the campaigns sent for a page.
+type Engagement struct {
+	Page         string
+	Recipients   int
+	Opens        int
+	Clicks       int
+	Unsubscribes int
+}
+
+// New returns an empty set of statistics.
+func New() *Stats {
+	return &Stats{Pages: map[string]map[string]email.CampaignStats{}}
+}
+
+// Read parses a set of statistics in JSON format.
+func Read(r io.Reader) (*Stats, error) {
+	s := New()
+	err := json.NewDecoder(r).Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if s.Pages == nil {
+		s.Pages = map[string]map[string]email.CampaignStats{}
+	}
+	return s, nil
+}
+
+// Write outputs the statistics in JSON format.
+func (s *Stats) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// Set stores the statistics of the campaign that a mailer sent for a page, replacing any previous ones.
+func (s *Stats) Set(page string, mailer string, stats email.CampaignStats) {
+	mailers, ok := s.Pages[page]
+	if !ok {
+		mailers = map[string]email.CampaignStats{}
+		s.Pages[page] = mailers
+	}
+	mailers[mailer] = stats
+}
+
+// Report returns the engagement of each page, from the highest to the lowest open rate.
+// Pages with the same open rate are sorted by click rate.
+func (s *Stats) Report() []*Engagement {
+	var out []*Engagement
+	for page, mailers := range s.Pages {
+		e := &Engagement{Page: page}
+		for _, stats := range mailers {
+			e.Recipients += stats.Recipients
+			e.Opens += stats.Opens
+			e.Clicks += stats.Clicks
+			e.Unsubscribes += stats.Unsubscribes
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].OpenRate() != out[j].OpenRate() {
+			return out[i].OpenRate() > out[j].OpenRate()
+		}
+		if out[i].ClickRate() != out[j].ClickRate() {
+			return out[i].ClickRate() > out[j].ClickRate()
+		}
+		return out[i].Page < out[j].Page
+	})
+	return out
+}
+
+// OpenRate returns the fraction of the recipients that opened the page's emails.
+func (e *Engagement) OpenRate() float64 {
+	return rate(e.Opens, e.Recipients)
+}
+
+// ClickRate returns the fraction of the recipients that clicked on a link in the page's emails.
+func (e *Engagement) ClickRate() float64 {
+	return rate(e.Clicks, e.Recipients)
+}
+
+func rate(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
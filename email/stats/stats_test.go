@@ -0,0 +1,46 @@
+package stats
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/email"
+)
+
+func TestReport(t *testing.T) {
+	s := New()
+	s.Set("post-1", "en", email.CampaignStats{Id: "1", Recipients: 100, Opens: 40, Clicks: 10})
+	s.Set("post-1", "breaking", email.CampaignStats{Id: "2", Recipients: 100, Opens: 60, Clicks: 10})
+	s.Set("post-2", "en", email.CampaignStats{Id: "3", Recipients: 100, Opens: 50, Clicks: 20})
+	s.Set("post-3", "en", email.CampaignStats{Id: "4", Recipients: 100, Opens: 60, Clicks: 5})
+	s.Set("post-3", "en", email.CampaignStats{Id: "4", Recipients: 100, Opens: 70, Clicks: 5})
+	s.Set("post-4", "en", email.CampaignStats{Id: "5"})
+
+	report := s.Report()
+	assert.Equal(t, []*Engagement{
+		{Page: "post-3", Recipients: 100, Opens: 70, Clicks: 5},
+		{Page: "post-2", Recipients: 100, Opens: 50, Clicks: 20},
+		{Page: "post-1", Recipients: 200, Opens: 100, Clicks: 20},
+		{Page: "post-4"},
+	}, report)
+	assert.Equal(t, 0.5, report[2].OpenRate())
+	assert.Equal(t, 0.1, report[2].ClickRate())
+	assert.Equal(t, 0.0, report[3].OpenRate())
+}
+
+func TestReadWrite(t *testing.T) {
+	s := New()
+	s.Set("post-1", "en", email.CampaignStats{Id: "1", Name: "post-1", Sent: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Recipients: 100, Opens: 40, Clicks: 10, Unsubscribes: 1})
+
+	var buf bytes.Buffer
+	assert.Nil(t, s.Write(&buf))
+	read, err := Read(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, s, read)
+
+	empty, err := Read(bytes.NewReader([]byte("{}")))
+	assert.Nil(t, err)
+	assert.Equal(t, New(), empty)
+}
@@ -0,0 +1,267 @@
+package subscriptions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"jacobo.tarrio.org/jtweb/ratelimit"
+)
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="{{.Language}}">
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"></head>
+<body><p>{{.Message}}</p>
+{{- if .Button}}
+<form method="post">
+{{- range .Lists}}
+<p><label><input type="checkbox" name="list" value="{{.Name}}"{{if .Checked}} checked{{end}}> {{.Name}}</label></p>
+{{- end}}
+<button type="submit">{{.Button}}</button></form>
+{{- end}}
+{{- if .Link}}
+<p><a href="{{.Link}}">{{.LinkText}}</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+type pageList struct {
+	Name    string
+	Checked bool
+}
+
+type page struct {
+	Language string
+	Message  string
+	Lists    []pageList
+	Button   string
+	Link     string
+	LinkText string
+}
+
+type handler struct {
+	service *Service
+	// Limits the subscription requests from each client.
+	subscribeLimiter *ratelimit.Limiter
+	// Limits the confirmation emails sent to each address, whoever asks for them.
+	addressLimiter *ratelimit.Limiter
+	// Reverse proxies whose X-Forwarded-For headers identify the clients.
+	proxies *ratelimit.Proxies
+}
+
+type HandlerOption func(*handler)
+
+// WithTrustedProxies identifies the clients behind the given reverse proxies by their X-Forwarded-For headers.
+func WithTrustedProxies(proxies *ratelimit.Proxies) HandlerOption {
+	return func(h *handler) {
+		h.proxies = proxies
+	}
+}
+
+// Handler returns an http.Handler that serves the subscription endpoints:
+//
+//   - POST /subscribe, with the form fields email, name, language and, optionally, one or more list,
+//     sends the confirmation email.
+//   - /confirm?token=... confirms the subscription.
+//   - /preferences?token=... lets the reader choose their newsletters.
+//   - /unsubscribe?token=... unsubscribes from all the newsletters in the reader's language.
+//
+// Opening a link shows a button to confirm, so that link scanners don't change any subscriptions.
+// POST requests to /unsubscribe take effect right away, which supports one-click unsubscription (RFC 8058).
+func (s *Service) Handler(options ...HandlerOption) http.Handler {
+	h := &handler{
+		service:          s,
+		subscribeLimiter: ratelimit.NewLimiter(0.1, 5),
+		addressLimiter:   ratelimit.NewLimiter(rate.Every(10*time.Minute), 3),
+	}
+	for _, option := range options {
+		option(h)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/subscribe", h.subscribe)
+	mux.HandleFunc("/confirm", h.confirm)
+	mux.HandleFunc("/preferences", h.preferences)
+	mux.HandleFunc("/unsubscribe", h.unsubscribe)
+	return mux
+}
+
+func (h *handler) subscribe(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := req.ParseForm(); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	language := req.PostForm.Get("language")
+	msg := getMessages(language)
+	email := req.PostForm.Get("email")
+	if !h.subscribeLimiter.Get(h.proxies.ClientAddress(req)).Allow() || !h.addressLimiter.Get(limitKey(email)).Allow() {
+		h.respond(req, rw, http.StatusTooManyRequests, language, msg.tryLater)
+		return
+	}
+	err := h.service.RequestSubscription(email, req.PostForm.Get("name"), language, req.PostForm["list"])
+	var invalid ErrInvalidRequest
+	if errors.As(err, &invalid) {
+		h.respond(req, rw, http.StatusBadRequest, language, msg.invalidRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error sending a subscription confirmation: %s", err)
+		h.respond(req, rw, http.StatusInternalServerError, language, msg.internalError)
+		return
+	}
+	h.respond(req, rw, http.StatusOK, language, msg.checkEmail)
+}
+
+// limitKey returns the key for an email address in the address limiter, so that
+// different ways to write the same address share their limit.
+func limitKey(email string) string {
+	if parsed, err := mail.ParseAddress(email); err == nil {
+		email = parsed.Address
+	}
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// respond sends a message as JSON to scripts, which ask for it in the Accept header, or as a web page otherwise.
+func (h *handler) respond(req *http.Request, rw http.ResponseWriter, status int, language string, message string) {
+	if strings.Contains(req.Header.Get("Accept"), "application/json") {
+		out, _ := json.Marshal(struct{ Message string }{Message: message})
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(status)
+		rw.Write(out)
+		return
+	}
+	writePage(rw, status, &page{Language: language, Message: message})
+}
+
+func (h *handler) confirm(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	confirmation, err := h.service.ParseConfirmation(req.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg := getMessages(confirmation.Language)
+	if req.Method == http.MethodGet {
+		writePage(rw, http.StatusOK, &page{
+			Language: confirmation.Language,
+			Message:  fmt.Sprintf(msg.confirmPrompt, strings.Join(confirmation.Lists, ", "), confirmation.Email),
+			Button:   msg.confirmButton,
+		})
+		return
+	}
+	if err := h.service.Confirm(req.Context(), confirmation); err != nil {
+		h.internalError(rw, confirmation.Language, err)
+		return
+	}
+	link, err := h.service.PreferencesUri(&Preferences{Email: confirmation.Email, Name: confirmation.Name, Language: confirmation.Language})
+	if err != nil {
+		h.internalError(rw, confirmation.Language, err)
+		return
+	}
+	writePage(rw, http.StatusOK, &page{
+		Language: confirmation.Language,
+		Message:  msg.subscribed,
+		Link:     link,
+		LinkText: msg.preferencesLink,
+	})
+}
+
+func (h *handler) preferences(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	token := req.URL.Query().Get("token")
+	prefs, err := h.service.ParsePreferences(token)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := req.Context()
+	msg := getMessages(prefs.Language)
+	message := fmt.Sprintf(msg.preferencesPrompt, prefs.Email)
+	if req.Method == http.MethodPost {
+		if err := req.ParseForm(); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.service.SetSubscriptions(ctx, prefs, req.PostForm["list"]); err != nil {
+			h.internalError(rw, prefs.Language, err)
+			return
+		}
+		message = msg.saved
+	}
+	subscribed, err := h.service.Subscriptions(ctx, prefs)
+	if err != nil {
+		h.internalError(rw, prefs.Language, err)
+		return
+	}
+	unsubscribe, err := h.service.links.uri("unsubscribe", token)
+	if err != nil {
+		h.internalError(rw, prefs.Language, err)
+		return
+	}
+	p := &page{
+		Language: prefs.Language,
+		Message:  message,
+		Button:   msg.saveButton,
+		Link:     unsubscribe,
+		LinkText: msg.unsubscribeLink,
+	}
+	for _, list := range h.service.Lists(prefs.Language) {
+		p.Lists = append(p.Lists, pageList{Name: list.Name, Checked: subscribed[list.Name]})
+	}
+	writePage(rw, http.StatusOK, p)
+}
+
+func (h *handler) unsubscribe(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	prefs, err := h.service.ParsePreferences(req.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msg := getMessages(prefs.Language)
+	if req.Method == http.MethodGet {
+		writePage(rw, http.StatusOK, &page{
+			Language: prefs.Language,
+			Message:  fmt.Sprintf(msg.unsubscribePrompt, prefs.Email),
+			Button:   msg.unsubscribeButton,
+		})
+		return
+	}
+	if err := h.service.SetSubscriptions(req.Context(), prefs, nil); err != nil {
+		h.internalError(rw, prefs.Language, err)
+		return
+	}
+	writePage(rw, http.StatusOK, &page{Language: prefs.Language, Message: msg.unsubscribed})
+}
+
+func (h *handler) internalError(rw http.ResponseWriter, language string, err error) {
+	log.Printf("Error updating a newsletter subscription: %s", err)
+	writePage(rw, http.StatusInternalServerError, &page{Language: language, Message: getMessages(language).internalError})
+}
+
+func writePage(rw http.ResponseWriter, status int, p *page) {
+	rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+	pageTemplate.Execute(rw, p)
+}
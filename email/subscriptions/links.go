@@ -0,0 +1,65 @@
+package subscriptions
+
+import (
+	"net/url"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/tokens"
+)
+
+// Links creates and checks the signed links that the Service sends to readers.
+// Email engines that send their own messages use it to add unsubscribe links to them.
+type Links struct {
+	signer         *tokens.Signer
+	baseUri        string
+	preferencesTtl time.Duration
+}
+
+// NewLinks creates a Links that signs links with the given key and builds them from baseUri.
+func NewLinks(key string, baseUri string) *Links {
+	return &Links{
+		signer:         tokens.NewSigner(key),
+		baseUri:        baseUri,
+		preferencesTtl: 5 * 365 * 24 * time.Hour,
+	}
+}
+
+// PreferencesUri returns a link where the reader can manage their subscriptions.
+func (l *Links) PreferencesUri(p *Preferences) (string, error) {
+	token, err := l.signer.Sign(preferencesPurpose, p, time.Now().Add(l.preferencesTtl))
+	if err != nil {
+		return "", err
+	}
+	return l.uri("preferences", token)
+}
+
+// UnsubscribeUri returns a link that unsubscribes the reader from all the newsletters in their language.
+// The link supports one-click unsubscription (RFC 8058), so it can be used in a List-Unsubscribe header.
+func (l *Links) UnsubscribeUri(p *Preferences) (string, error) {
+	token, err := l.signer.Sign(preferencesPurpose, p, time.Now().Add(l.preferencesTtl))
+	if err != nil {
+		return "", err
+	}
+	return l.uri("unsubscribe", token)
+}
+
+// ParsePreferences checks a token from a preferences or unsubscribe link and returns its data.
+func (l *Links) ParsePreferences(token string) (*Preferences, error) {
+	var p Preferences
+	if err := l.signer.Verify(preferencesPurpose, token, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (l *Links) uri(path string, token string) (string, error) {
+	uri, err := url.Parse(l.baseUri)
+	if err != nil {
+		return "", err
+	}
+	uri = uri.JoinPath(path)
+	query := uri.Query()
+	query.Set("token", token)
+	uri.RawQuery = query.Encode()
+	return uri.String(), nil
+}
@@ -0,0 +1,124 @@
+package subscriptions
+
+type messages struct {
+	confirmSubject string
+	// The body receives the names of the newsletters and the confirmation link.
+	confirmBody string
+	// Shown after requesting a subscription.
+	checkEmail string
+	// Shown when a subscription request is invalid.
+	invalidRequest string
+	// Shown when there are too many subscription requests.
+	tryLater string
+	// Shown when there was an error.
+	internalError string
+	// Shown when opening a confirmation link. Receives the names of the newsletters and the address.
+	confirmPrompt string
+	confirmButton string
+	// Shown after confirming a subscription.
+	subscribed      string
+	preferencesLink string
+	// Shown above the list of newsletters. Receives the address.
+	preferencesPrompt string
+	saveButton        string
+	// Shown after saving the preferences.
+	saved           string
+	unsubscribeLink string
+	// Shown when opening an unsubscribe link. Receives the address.
+	unsubscribePrompt string
+	unsubscribeButton string
+	// Shown after unsubscribing.
+	unsubscribed string
+}
+
+var allMessages = map[string]messages{
+	"en": {
+		confirmSubject: "Confirm your subscription",
+		confirmBody: `Hello,
+
+Somebody, hopefully you, asked to subscribe this address to %[1]s.
+
+To confirm the subscription, open this link:
+%[2]s
+
+If it wasn't you, you can ignore this message.
+`,
+		checkEmail:        "We sent you an email with a link to confirm your subscription.",
+		invalidRequest:    "Please check your email address.",
+		tryLater:          "There are too many requests right now. Please try again later.",
+		internalError:     "There was an error while updating your subscription.",
+		confirmPrompt:     "Do you want to subscribe to %[1]s at %[2]s?",
+		confirmButton:     "Subscribe",
+		subscribed:        "Your subscription is confirmed.",
+		preferencesLink:   "Manage your subscriptions",
+		preferencesPrompt: "Choose the newsletters you want to receive at %s.",
+		saveButton:        "Save",
+		saved:             "Your preferences were saved.",
+		unsubscribeLink:   "Unsubscribe from all the newsletters",
+		unsubscribePrompt: "Do you want to stop receiving all the newsletters at %s?",
+		unsubscribeButton: "Unsubscribe",
+		unsubscribed:      "You won't receive the newsletters anymore.",
+	},
+	"es": {
+		confirmSubject: "Confirma tu suscripción",
+		confirmBody: `Hola:
+
+Alguien, esperamos que tú, pidió suscribir esta dirección a %[1]s.
+
+Para confirmar la suscripción, abre este enlace:
+%[2]s
+
+Si no fuiste tú, puedes ignorar este mensaje.
+`,
+		checkEmail:        "Te enviamos un correo con un enlace para confirmar tu suscripción.",
+		invalidRequest:    "Por favor, revisa tu dirección de correo.",
+		tryLater:          "Hay demasiadas peticiones en este momento. Por favor, inténtalo más tarde.",
+		internalError:     "Hubo un error actualizando tu suscripción.",
+		confirmPrompt:     "¿Quieres suscribirte a %[1]s en %[2]s?",
+		confirmButton:     "Suscribirse",
+		subscribed:        "Tu suscripción está confirmada.",
+		preferencesLink:   "Gestionar tus suscripciones",
+		preferencesPrompt: "Elige los boletines que quieres recibir en %s.",
+		saveButton:        "Guardar",
+		saved:             "Tus preferencias se guardaron.",
+		unsubscribeLink:   "Darse de baja de todos los boletines",
+		unsubscribePrompt: "¿Quieres dejar de recibir todos los boletines en %s?",
+		unsubscribeButton: "Darse de baja",
+		unsubscribed:      "Ya no recibirás más los boletines.",
+	},
+	"gl": {
+		confirmSubject: "Confirma a túa subscrición",
+		confirmBody: `Ola:
+
+Alguén, agardamos que ti, pediu subscribir este enderezo a %[1]s.
+
+Para confirmar a subscrición, abre esta ligazón:
+%[2]s
+
+Se non fuches ti, podes ignorar esta mensaxe.
+`,
+		checkEmail:        "Mandámosche un correo cunha ligazón para confirmar a túa subscrición.",
+		invalidRequest:    "Por favor, revisa o teu enderezo de correo.",
+		tryLater:          "Hai demasiadas peticións neste momento. Por favor, téntao máis tarde.",
+		internalError:     "Houbo un erro actualizando a túa subscrición.",
+		confirmPrompt:     "Queres subscribirte a %[1]s en %[2]s?",
+		confirmButton:     "Subscribirse",
+		subscribed:        "A túa subscrición está confirmada.",
+		preferencesLink:   "Xestionar as túas subscricións",
+		preferencesPrompt: "Escolle os boletíns que queres recibir en %s.",
+		saveButton:        "Gardar",
+		saved:             "As túas preferencias gardáronse.",
+		unsubscribeLink:   "Darse de baixa de todos os boletíns",
+		unsubscribePrompt: "Queres deixar de recibir todos os boletíns en %s?",
+		unsubscribeButton: "Darse de baixa",
+		unsubscribed:      "Xa non vas recibir máis os boletíns.",
+	},
+}
+
+func getMessages(language string) messages {
+	msg, ok := allMessages[language]
+	if !ok {
+		return allMessages["en"]
+	}
+	return msg
+}
@@ -0,0 +1,238 @@
+// The subscriptions package lets readers subscribe to the newsletters and
+// manage their subscriptions, for every email engine that supports it.
+//
+// Subscriptions are confirmed with a double opt-in: subscribing sends an email
+// with a signed link, and the reader is only added to the newsletters after
+// following it. Afterwards, a signed preferences link lets the reader choose
+// which newsletters in their language they receive, or unsubscribe from all
+// of them, without an account.
+
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/email"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+const confirmPurpose = "newsletter-confirm"
+const preferencesPurpose = "newsletter-preferences"
+
+// List is a newsletter that readers can subscribe to.
+type List struct {
+	// The mailer's name.
+	Name string
+	// The language the newsletter is written in.
+	Language languages.Language
+	// The engine that keeps the newsletter's subscribers.
+	Subscribers email.SubscriberManager
+}
+
+// Mailer sends the confirmation emails.
+type Mailer interface {
+	SendMail(to string, subject string, body string) error
+}
+
+// Confirmation contains the data carried in a confirmation link.
+type Confirmation struct {
+	Email    string
+	Name     string
+	Language string
+	Lists    []string
+}
+
+// Preferences contains the data carried in a preferences link.
+type Preferences struct {
+	Email    string
+	Name     string
+	Language string
+}
+
+// Service subscribes and unsubscribes readers.
+type Service struct {
+	links      *Links
+	mailer     Mailer
+	lists      []*List
+	confirmTtl time.Duration
+}
+
+type ServiceOption func(*Service)
+
+// ConfirmationTtl sets for how long confirmation links are valid.
+func ConfirmationTtl(ttl time.Duration) ServiceOption {
+	return func(s *Service) {
+		s.confirmTtl = ttl
+	}
+}
+
+// NewService creates a Service that creates and checks its links with links,
+// and sends confirmation emails with the mailer.
+func NewService(links *Links, mailer Mailer, lists []*List, options ...ServiceOption) *Service {
+	s := &Service{
+		links:      links,
+		mailer:     mailer,
+		lists:      lists,
+		confirmTtl: 7 * 24 * time.Hour,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// ErrInvalidRequest is returned when a subscription request has an invalid address, language or list.
+type ErrInvalidRequest struct {
+	reason string
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return e.reason
+}
+
+// Lists returns the newsletters available in a language.
+func (s *Service) Lists(language string) []*List {
+	out := []*List{}
+	for _, list := range s.lists {
+		if list.Language.Code() == language {
+			out = append(out, list)
+		}
+	}
+	return out
+}
+
+// selectLists returns the named lists in the language, or all of them if no names were given.
+func (s *Service) selectLists(language string, names []string) ([]*List, error) {
+	available := s.Lists(language)
+	if len(available) == 0 {
+		return nil, ErrInvalidRequest{fmt.Sprintf("no newsletters are available in language %s", language)}
+	}
+	if len(names) == 0 {
+		return available, nil
+	}
+	out := []*List{}
+	for _, name := range names {
+		found := false
+		for _, list := range available {
+			if list.Name == name {
+				out = append(out, list)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrInvalidRequest{fmt.Sprintf("unknown newsletter %s in language %s", name, language)}
+		}
+	}
+	return out, nil
+}
+
+// RequestSubscription sends an email with a link to confirm the subscription to the given lists.
+// If no lists are given, the subscription is to all the lists in the language.
+func (s *Service) RequestSubscription(address string, name string, language string, listNames []string) error {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Name != "" {
+		return ErrInvalidRequest{"invalid email address"}
+	}
+	lists, err := s.selectLists(language, listNames)
+	if err != nil {
+		return err
+	}
+	confirmation := &Confirmation{
+		Email:    parsed.Address,
+		Name:     strings.TrimSpace(name),
+		Language: language,
+	}
+	for _, list := range lists {
+		confirmation.Lists = append(confirmation.Lists, list.Name)
+	}
+	token, err := s.links.signer.Sign(confirmPurpose, confirmation, time.Now().Add(s.confirmTtl))
+	if err != nil {
+		return err
+	}
+	link, err := s.links.uri("confirm", token)
+	if err != nil {
+		return err
+	}
+	msg := getMessages(language)
+	return s.mailer.SendMail(
+		confirmation.Email,
+		msg.confirmSubject,
+		fmt.Sprintf(msg.confirmBody, strings.Join(confirmation.Lists, ", "), link))
+}
+
+// ParseConfirmation checks a token from a confirmation link and returns its data.
+func (s *Service) ParseConfirmation(token string) (*Confirmation, error) {
+	var c Confirmation
+	if err := s.links.signer.Verify(confirmPurpose, token, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Confirm subscribes the reader to the lists in the confirmation.
+func (s *Service) Confirm(ctx context.Context, c *Confirmation) error {
+	lists, err := s.selectLists(c.Language, c.Lists)
+	if err != nil {
+		return err
+	}
+	for _, list := range lists {
+		if err := list.Subscribers.Subscribe(ctx, c.Email, c.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreferencesUri returns a link where the reader can manage their subscriptions.
+func (s *Service) PreferencesUri(p *Preferences) (string, error) {
+	return s.links.PreferencesUri(p)
+}
+
+// ParsePreferences checks a token from a preferences or unsubscribe link and returns its data.
+func (s *Service) ParsePreferences(token string) (*Preferences, error) {
+	return s.links.ParsePreferences(token)
+}
+
+// Subscriptions returns whether the reader is subscribed to each of the lists in their language.
+func (s *Service) Subscriptions(ctx context.Context, p *Preferences) (map[string]bool, error) {
+	out := map[string]bool{}
+	for _, list := range s.Lists(p.Language) {
+		subscribed, err := list.Subscribers.IsSubscribed(ctx, p.Email)
+		if err != nil {
+			return nil, err
+		}
+		out[list.Name] = subscribed
+	}
+	return out, nil
+}
+
+// SetSubscriptions subscribes the reader to the named lists in their language, and unsubscribes them from the rest.
+func (s *Service) SetSubscriptions(ctx context.Context, p *Preferences, listNames []string) error {
+	wanted := map[string]bool{}
+	for _, name := range listNames {
+		wanted[name] = true
+	}
+	current, err := s.Subscriptions(ctx, p)
+	if err != nil {
+		return err
+	}
+	for _, list := range s.Lists(p.Language) {
+		if wanted[list.Name] == current[list.Name] {
+			continue
+		}
+		if wanted[list.Name] {
+			err = list.Subscribers.Subscribe(ctx, p.Email, p.Name)
+		} else {
+			err = list.Subscribers.Unsubscribe(ctx, p.Email)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
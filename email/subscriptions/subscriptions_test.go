@@ -0,0 +1,225 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"jacobo.tarrio.org/jtweb/languages"
+)
+
+type fakeManager struct {
+	subscribers map[string]string
+}
+
+func (m *fakeManager) Subscribe(ctx context.Context, email string, name string) error {
+	m.subscribers[email] = name
+	return nil
+}
+
+func (m *fakeManager) Unsubscribe(ctx context.Context, email string) error {
+	delete(m.subscribers, email)
+	return nil
+}
+
+func (m *fakeManager) IsSubscribed(ctx context.Context, email string) (bool, error) {
+	_, ok := m.subscribers[email]
+	return ok, nil
+}
+
+type fakeMailer struct {
+	to      []string
+	subject []string
+	body    []string
+}
+
+func (m *fakeMailer) SendMail(to string, subject string, body string) error {
+	m.to = append(m.to, to)
+	m.subject = append(m.subject, subject)
+	m.body = append(m.body, body)
+	return nil
+}
+
+type testLists struct {
+	weekly   *fakeManager
+	breaking *fakeManager
+	galego   *fakeManager
+}
+
+func newTestService() (*Service, *fakeMailer, *testLists) {
+	lists := &testLists{
+		weekly:   &fakeManager{subscribers: map[string]string{}},
+		breaking: &fakeManager{subscribers: map[string]string{}},
+		galego:   &fakeManager{subscribers: map[string]string{}},
+	}
+	mailer := &fakeMailer{}
+	s := NewService(NewLinks("key", "https://example.com/newsletter/"), mailer, []*List{
+		{Name: "weekly", Language: languages.LanguageEn, Subscribers: lists.weekly},
+		{Name: "breaking", Language: languages.LanguageEn, Subscribers: lists.breaking},
+		{Name: "galego", Language: languages.LanguageGl, Subscribers: lists.galego},
+	})
+	return s, mailer, lists
+}
+
+func serve(h http.Handler, method string, path string, form url.Values) *httptest.ResponseRecorder {
+	var req *http.Request
+	if form == nil {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// localPath returns the path and query of a link, relative to the service's base URI.
+func localPath(t *testing.T, link string) string {
+	uri, err := url.Parse(link)
+	assert.Nil(t, err)
+	return strings.TrimPrefix(uri.Path, "/newsletter") + "?" + uri.RawQuery
+}
+
+func mustQuery(t *testing.T, link string) url.Values {
+	uri, err := url.Parse(link)
+	assert.Nil(t, err)
+	return uri.Query()
+}
+
+var linkRegexp = regexp.MustCompile(`https://example.com/newsletter/\S+`)
+
+func TestSubscribeAndConfirm(t *testing.T) {
+	s, mailer, lists := newTestService()
+	h := s.Handler()
+
+	rec := serve(h, http.MethodPost, "/subscribe", url.Values{
+		"email": {"ann@example.com"}, "name": {"Ann"}, "language": {"en"}, "list": {"weekly"},
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "We sent you an email")
+	assert.Equal(t, []string{"ann@example.com"}, mailer.to)
+	assert.Contains(t, mailer.body[0], "subscribe this address to weekly")
+	assert.Empty(t, lists.weekly.subscribers)
+
+	confirm := localPath(t, linkRegexp.FindString(mailer.body[0]))
+	rec = serve(h, http.MethodGet, confirm, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `<form method="post">`)
+	assert.Empty(t, lists.weekly.subscribers)
+
+	rec = serve(h, http.MethodPost, confirm, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]string{"ann@example.com": "Ann"}, lists.weekly.subscribers)
+	assert.Empty(t, lists.breaking.subscribers)
+	assert.Empty(t, lists.galego.subscribers)
+	assert.Contains(t, rec.Body.String(), "Manage your subscriptions")
+}
+
+func TestSubscribeToAllListsInLanguage(t *testing.T) {
+	s, mailer, lists := newTestService()
+	assert.Nil(t, s.RequestSubscription("bob@example.com", " Bob ", "en", nil))
+	c, err := s.ParseConfirmation(mustQuery(t, linkRegexp.FindString(mailer.body[0])).Get("token"))
+	assert.Nil(t, err)
+	assert.Equal(t, &Confirmation{Email: "bob@example.com", Name: "Bob", Language: "en", Lists: []string{"weekly", "breaking"}}, c)
+	assert.Nil(t, s.Confirm(context.Background(), c))
+	assert.Contains(t, lists.weekly.subscribers, "bob@example.com")
+	assert.Contains(t, lists.breaking.subscribers, "bob@example.com")
+	assert.Empty(t, lists.galego.subscribers)
+}
+
+func TestInvalidSubscriptions(t *testing.T) {
+	s, mailer, _ := newTestService()
+	h := s.Handler()
+	for _, form := range []url.Values{
+		{"email": {"not an address"}, "language": {"en"}},
+		{"email": {"Ann <ann@example.com>"}, "language": {"en"}},
+		{"email": {"ann@example.com"}, "language": {"es"}},
+		{"email": {"ann@example.com"}, "language": {"en"}, "list": {"galego"}},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, form)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	}
+	assert.Empty(t, mailer.to)
+
+	rec := serve(h, http.MethodPost, "/confirm?token=bad", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	// Preference tokens can't be used to confirm, and vice versa.
+	link, err := s.PreferencesUri(&Preferences{Email: "ann@example.com", Language: "en"})
+	assert.Nil(t, err)
+	rec = serve(h, http.MethodPost, strings.Replace(localPath(t, link), "/preferences", "/confirm", 1), nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSubscribeLimits(t *testing.T) {
+	s, mailer, _ := newTestService()
+	h := s.Handler()
+	subscribe := func(remoteAddr string, email string) int {
+		req := httptest.NewRequest(http.MethodPost, "/subscribe", strings.NewReader(url.Values{"email": {email}, "language": {"en"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Nobody can send too many confirmation emails to the same address, even from several clients.
+	assert.Equal(t, http.StatusOK, subscribe("192.0.2.1:1234", "victim@example.com"))
+	assert.Equal(t, http.StatusOK, subscribe("192.0.2.2:1234", "Victim@example.com"))
+	assert.Equal(t, http.StatusOK, subscribe("192.0.2.3:1234", "<victim@example.com>"))
+	assert.Equal(t, http.StatusTooManyRequests, subscribe("192.0.2.4:1234", "victim@example.com"))
+	assert.Len(t, mailer.to, 3)
+
+	// A client that sends too many requests is stopped, but other clients are not.
+	limited := false
+	for i := 0; i < 10 && !limited; i++ {
+		limited = subscribe("192.0.2.5:1234", fmt.Sprintf("reader%d@example.com", i)) == http.StatusTooManyRequests
+	}
+	assert.True(t, limited)
+	assert.Equal(t, http.StatusOK, subscribe("192.0.2.6:1234", "reader@example.com"))
+}
+
+func TestPreferencesAndUnsubscribe(t *testing.T) {
+	s, _, lists := newTestService()
+	h := s.Handler()
+	lists.weekly.subscribers["ann@example.com"] = "Ann"
+	lists.galego.subscribers["ann@example.com"] = "Ann"
+	link, err := s.PreferencesUri(&Preferences{Email: "ann@example.com", Name: "Ann", Language: "en"})
+	assert.Nil(t, err)
+	preferences := localPath(t, link)
+
+	rec := serve(h, http.MethodGet, preferences, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `value="weekly" checked>`)
+	assert.Contains(t, rec.Body.String(), `value="breaking">`)
+	assert.NotContains(t, rec.Body.String(), `galego`)
+
+	rec = serve(h, http.MethodPost, preferences, url.Values{"list": {"breaking"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Your preferences were saved.")
+	assert.Empty(t, lists.weekly.subscribers)
+	assert.Equal(t, map[string]string{"ann@example.com": "Ann"}, lists.breaking.subscribers)
+
+	unsubscribe := strings.Replace(preferences, "/preferences", "/unsubscribe", 1)
+	rec = serve(h, http.MethodGet, unsubscribe, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, lists.breaking.subscribers)
+
+	rec = serve(h, http.MethodPost, unsubscribe, url.Values{"List-Unsubscribe": {"One-Click"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, lists.weekly.subscribers)
+	assert.Empty(t, lists.breaking.subscribers)
+	// Other languages are left alone.
+	assert.NotEmpty(t, lists.galego.subscribers)
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests served.", "path", "code")
+	latency := r.Histogram("latency_seconds", "Request latency.", []float64{0.1, 1}, "path")
+	requests.Inc("/list", "200")
+	requests.Inc("/list", "200")
+	requests.Inc("/add", "500")
+	latency.Observe(0.05, "/list")
+	latency.Observe(0.5, "/list")
+
+	var out strings.Builder
+	assert.Nil(t, r.Write(&out))
+	assert.Equal(t, `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{path="/add",code="500"} 1
+requests_total{path="/list",code="200"} 2
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/list",le="0.1"} 1
+latency_seconds_bucket{path="/list",le="1"} 2
+latency_seconds_bucket{path="/list",le="+Inf"} 2
+latency_seconds_sum{path="/list"} 0.55
+latency_seconds_count{path="/list"} 2
+`, out.String())
+}
+
+func TestEscapeLabels(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("things_total", "Things.", "name")
+	c.Inc("a \"quoted\"\nvalue\\")
+	var out strings.Builder
+	assert.Nil(t, r.Write(&out))
+	assert.Contains(t, out.String(), `things_total{name="a \"quoted\"\nvalue\\"} 1`)
+}
@@ -30,6 +30,7 @@ type HeaderData struct {
 	Language        languages.Language
 	Summary         string
 	Episode         string
+	Series          string
 	PublishDate     time.Time
 	HidePublishDate bool
 	AuthorName      string
@@ -42,6 +43,7 @@ type HeaderData struct {
 	TranslationOf   Name
 	Comments        *CommentConfig
 	Draft           bool
+	NoEmail         bool
 }
 
 type CommentConfig struct {
@@ -89,6 +91,11 @@ func (p *Page) Render(w io.Writer) error {
 	return renderer.RenderMarkdown(w, p.Source, p.Root)
 }
 
+// RenderForEmail renders the page in HTML format for email clients.
+func (p *Page) RenderForEmail(w io.Writer) error {
+	return renderer.RenderMarkdownForEmail(w, p.Source, p.Root)
+}
+
 // parseHeader parses the raw header and returns a HeaderData object.
 func parseHeader(hdr []byte, imgs []string) (HeaderData, error) {
 	var out HeaderData
@@ -99,6 +106,7 @@ func parseHeader(hdr []byte, imgs []string) (HeaderData, error) {
 		Language        string
 		Summary         string
 		Episode         string
+		Series          string
 		PublishDate     string  `yaml:"publish_date"`
 		HidePublishDate bool    `yaml:"no_publish_date"`
 		AuthorName      string  `yaml:"author_name"`
@@ -111,6 +119,7 @@ func parseHeader(hdr []byte, imgs []string) (HeaderData, error) {
 		TranslationOf   string   `yaml:"translation_of"`
 		Comments        string
 		Draft           bool
+		NoEmail         bool `yaml:"no_email"`
 	}
 
 	rawHeader := headerYaml{}
@@ -144,6 +153,7 @@ func parseHeader(hdr []byte, imgs []string) (HeaderData, error) {
 		out.PublishDate = d
 	}
 	out.Episode = rawHeader.Episode
+	out.Series = rawHeader.Series
 	out.HidePublishDate = rawHeader.HidePublishDate
 	out.AuthorName = rawHeader.AuthorName
 	out.AuthorURI = rawHeader.AuthorURI
@@ -165,6 +175,7 @@ func parseHeader(hdr []byte, imgs []string) (HeaderData, error) {
 	}
 	out.Comments = cmtCfg
 	out.Draft = rawHeader.Draft
+	out.NoEmail = rawHeader.NoEmail
 	return out, nil
 }
 
@@ -43,6 +43,7 @@ func TestParseFullHeader(t *testing.T) {
 		"language: \"gl\"\n" +
 		"summary: \"The summary\"\n" +
 		"episode: \"The episode\"\n" +
+		"series: \"The series\"\n" +
 		"publish_date: \"2021-08-15 01:23 +0100\"\n" +
 		"no_publish_date: true\n" +
 		"author_name: \"The author\"\n" +
@@ -53,6 +54,7 @@ func TestParseFullHeader(t *testing.T) {
 		"old_uris: [\"old_uri1\", \"old_uri2\"]\n" +
 		"translation_of: \"other_article\"\n" +
 		"draft: true\n" +
+		"no_email: true\n" +
 		"-->")
 	p, err := Parse("test", &buf)
 
@@ -64,6 +66,7 @@ func TestParseFullHeader(t *testing.T) {
 		Language:        languages.LanguageGl,
 		Summary:         "The summary",
 		Episode:         "The episode",
+		Series:          "The series",
 		PublishDate:     time.Date(2021, 8, 15, 1, 23, 0, 0, time.FixedZone("", 3600)),
 		HidePublishDate: true,
 		AuthorName:      "The author",
@@ -74,6 +77,7 @@ func TestParseFullHeader(t *testing.T) {
 		OldURI:          []string{"old_uri1", "old_uri2"},
 		TranslationOf:   "other_article",
 		Draft:           true,
+		NoEmail:         true,
 	}, p.Header)
 }
 
@@ -0,0 +1,138 @@
+// The ratelimit package limits how often each client can make requests, and finds out the clients' addresses
+// when the requests go through reverse proxies.
+
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter keeps a separate rate limiter for each client, so that a client that
+// sends too many requests doesn't use up everybody else's allowance.
+type Limiter struct {
+	limit rate.Limit
+	burst int
+	// How often the limiters that have been idle long enough to fill up are removed.
+	purgeInterval time.Duration
+
+	mu        sync.Mutex
+	limiters  map[string]*rate.Limiter
+	lastPurge time.Time
+}
+
+// NewLimiter creates a Limiter whose clients get the given rate and burst size.
+func NewLimiter(limit rate.Limit, burst int) *Limiter {
+	return &Limiter{
+		limit:         limit,
+		burst:         burst,
+		purgeInterval: time.Minute,
+		limiters:      map[string]*rate.Limiter{},
+		lastPurge:     time.Now(),
+	}
+}
+
+// Get returns the rate limiter for the given client.
+func (l *Limiter) Get(client string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastPurge) >= l.purgeInterval {
+		for key, limiter := range l.limiters {
+			if limiter.TokensAt(now) >= float64(l.burst) {
+				delete(l.limiters, key)
+			}
+		}
+		l.lastPurge = now
+	}
+	limiter, ok := l.limiters[client]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[client] = limiter
+	}
+	return limiter
+}
+
+// Proxies is the set of reverse proxies whose X-Forwarded-For headers are trusted.
+// A nil Proxies trusts no proxies other than those connected through a Unix domain socket.
+type Proxies struct {
+	networks []*net.IPNet
+}
+
+// ParseProxies parses a comma-separated list of IP addresses and networks in CIDR notation,
+// such as "127.0.0.1,::1,10.0.0.0/8".
+func ParseProxies(list string) (*Proxies, error) {
+	p := &Proxies{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address: %q", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy network: %q", item)
+		}
+		p.networks = append(p.networks, network)
+	}
+	return p, nil
+}
+
+func (p *Proxies) trusts(ip net.IP) bool {
+	if p == nil {
+		return false
+	}
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAddress returns the address of the client that sent the request, or an empty string if it is unknown.
+//
+// If the request came from a trusted proxy, the address is taken from the X-Forwarded-For header, skipping
+// the entries added by other trusted proxies; the entries to their left could have been made up by the client.
+// Requests received through a Unix domain socket can only come from a local proxy, so it is always trusted.
+func (p *Proxies) ClientAddress(req *http.Request) string {
+	address := ""
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return host
+		}
+		if !p.trusts(ip) {
+			return ip.String()
+		}
+		address = ip.String()
+	}
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if ip == nil {
+			break
+		}
+		address = ip.String()
+		if !p.trusts(ip) {
+			break
+		}
+	}
+	return address
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(1, 2)
+	assert.True(t, l.Get("a").Allow())
+	assert.True(t, l.Get("a").Allow())
+	assert.False(t, l.Get("a").Allow())
+	// Other clients are not affected.
+	assert.True(t, l.Get("b").Allow())
+}
+
+func TestLimiterPurge(t *testing.T) {
+	l := NewLimiter(1, 1)
+	l.purgeInterval = 0
+	assert.True(t, l.Get("a").Allow())
+	// "b" is full, so it is removed when "c" is requested, but "a" is not.
+	l.Get("b")
+	l.Get("c")
+	assert.Contains(t, l.limiters, "a")
+	assert.NotContains(t, l.limiters, "b")
+	assert.Contains(t, l.limiters, "c")
+}
+
+func TestParseProxies(t *testing.T) {
+	p, err := ParseProxies("127.0.0.1, ::1,,10.0.0.0/8")
+	assert.Nil(t, err)
+	assert.Len(t, p.networks, 3)
+
+	_, err = ParseProxies("localhost")
+	assert.NotNil(t, err)
+	_, err = ParseProxies("10.0.0.0/33")
+	assert.NotNil(t, err)
+}
+
+func request(remoteAddr string, forwarded ...string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = remoteAddr
+	for _, f := range forwarded {
+		req.Header.Add("X-Forwarded-For", f)
+	}
+	return req
+}
+
+func TestClientAddress(t *testing.T) {
+	p, err := ParseProxies("127.0.0.1,10.0.0.0/8")
+	assert.Nil(t, err)
+
+	// Direct requests use the peer's address, and their X-Forwarded-For headers are ignored.
+	assert.Equal(t, "192.0.2.1", p.ClientAddress(request("192.0.2.1:1234")))
+	assert.Equal(t, "192.0.2.1", p.ClientAddress(request("192.0.2.1:1234", "198.51.100.1")))
+	assert.Equal(t, "2001:db8::1", p.ClientAddress(request("[2001:db8::1]:1234")))
+
+	// Requests from trusted proxies use the rightmost untrusted address.
+	assert.Equal(t, "198.51.100.1", p.ClientAddress(request("127.0.0.1:1234", "198.51.100.1")))
+	assert.Equal(t, "198.51.100.1", p.ClientAddress(request("127.0.0.1:1234", "203.0.113.1, 198.51.100.1, 10.1.2.3")))
+	assert.Equal(t, "198.51.100.1", p.ClientAddress(request("127.0.0.1:1234", "203.0.113.1", "198.51.100.1")))
+	// Made-up entries don't hide the address that the proxy added.
+	assert.Equal(t, "198.51.100.1", p.ClientAddress(request("127.0.0.1:1234", "garbage, 198.51.100.1")))
+	// Without a usable header, the proxy's address is used.
+	assert.Equal(t, "127.0.0.1", p.ClientAddress(request("127.0.0.1:1234")))
+	assert.Equal(t, "127.0.0.1", p.ClientAddress(request("127.0.0.1:1234", "garbage")))
+
+	// Requests through Unix domain sockets always come from a trusted proxy.
+	assert.Equal(t, "198.51.100.1", p.ClientAddress(request("@", "198.51.100.1")))
+	assert.Equal(t, "", p.ClientAddress(request("@")))
+
+	// Without trusted proxies, only the peer's address is used.
+	var none *Proxies
+	assert.Equal(t, "127.0.0.1", none.ClientAddress(request("127.0.0.1:1234", "198.51.100.1")))
+	assert.Equal(t, "198.51.100.1", none.ClientAddress(request("", "198.51.100.1")))
+}
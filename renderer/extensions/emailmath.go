@@ -0,0 +1,58 @@
+package extensions
+
+import (
+	mathjax "github.com/litao91/goldmark-mathjax"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+type emailMathExtension struct{}
+
+// EmailMathExtension renders the formulas parsed by the MathJax extension as plain text, for email clients.
+var EmailMathExtension = &emailMathExtension{}
+
+func (e *emailMathExtension) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(newEmailMathRenderer(), 10)))
+}
+
+type emailMathRenderer struct{}
+
+func newEmailMathRenderer() *emailMathRenderer {
+	return &emailMathRenderer{}
+}
+
+func (r *emailMathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(mathjax.KindInlineMath, r.renderInlineMath)
+	reg.Register(mathjax.KindMathBlock, r.renderMathBlock)
+}
+
+func (r *emailMathRenderer) renderInlineMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString("<code>")
+	for c := node.FirstChild(); c != nil; c = c.NextSibling() {
+		value := util.TrimRightSpace(c.(*ast.Text).Segment.Value(source))
+		_, _ = w.Write(util.EscapeHTML(value))
+		if c != node.LastChild() {
+			_, _ = w.WriteString(" ")
+		}
+	}
+	_, _ = w.WriteString("</code>")
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *emailMathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString("<pre>")
+	for i := 0; i < node.Lines().Len(); i++ {
+		line := node.Lines().At(i)
+		_, _ = w.Write(util.EscapeHTML(line.Value(source)))
+	}
+	_, _ = w.WriteString("</pre>\n")
+	return ast.WalkSkipChildren, nil
+}
@@ -5,18 +5,28 @@ import (
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
 )
 
-type multipleImageExtension struct{}
+type multipleImageExtension struct {
+	email bool
+}
 
 // MultipleImageExtension lets us render multiple images together.
 var MultipleImageExtension = &multipleImageExtension{}
 
+// MultipleImageEmailExtension lets us render multiple images together in a table, for email clients.
+var MultipleImageEmailExtension = &multipleImageExtension{email: true}
+
 func (e *multipleImageExtension) Extend(m goldmark.Markdown) {
 	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(newMultipleImageASTTransformer(), 20)))
-	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(newMultipleImageRenderer(), 20)))
+	if e.email {
+		m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(newMultipleImageEmailRenderer(), 20)))
+	} else {
+		m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(newMultipleImageRenderer(), 20)))
+	}
 }
 
 // KindMultipleImage designates a multiple image's node.
@@ -119,3 +129,57 @@ func (r *multipleImageRenderer) renderMultipleImage(w util.BufWriter, source []b
 	}
 	return ast.WalkContinue, nil
 }
+
+// multipleImageEmailRenderer renders multiple images as the cells of a table.
+// It takes over the rendering of images and links to put those in a multiple image in their own cells.
+type multipleImageEmailRenderer struct {
+	html renderer.NodeRenderer
+}
+
+func newMultipleImageEmailRenderer() *multipleImageEmailRenderer {
+	return &multipleImageEmailRenderer{html: html.NewRenderer()}
+}
+
+// SetOption passes the renderer options to the HTML renderer that renders the images and links.
+func (r *multipleImageEmailRenderer) SetOption(name renderer.OptionName, value interface{}) {
+	r.html.(renderer.SetOptioner).SetOption(name, value)
+}
+
+// nodeRendererFuncs collects the functions that a renderer registers.
+type nodeRendererFuncs map[ast.NodeKind]renderer.NodeRendererFunc
+
+func (f nodeRendererFuncs) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	f[kind] = fn
+}
+
+func (r *multipleImageEmailRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	funcs := nodeRendererFuncs{}
+	r.html.RegisterFuncs(funcs)
+	reg.Register(KindMultipleImage, r.renderMultipleImage)
+	reg.Register(ast.KindImage, renderInCell(funcs[ast.KindImage]))
+	reg.Register(ast.KindLink, renderInCell(funcs[ast.KindLink]))
+}
+
+func (r *multipleImageEmailRenderer) renderMultipleImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString("<table style=\"border-collapse: collapse\"><tr>")
+	} else {
+		w.WriteString("</tr></table>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// renderInCell wraps a node in a table cell if it is part of a multiple image.
+func renderInCell(fn renderer.NodeRendererFunc) renderer.NodeRendererFunc {
+	return func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		inCell := node.Parent() != nil && node.Parent().Kind() == KindMultipleImage
+		if inCell && entering {
+			w.WriteString("<td style=\"padding: 2px; vertical-align: top\">")
+		}
+		status, err := fn(w, source, node, entering)
+		if inCell && !entering {
+			w.WriteString("</td>")
+		}
+		return status, err
+	}
+}
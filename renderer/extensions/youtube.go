@@ -3,6 +3,7 @@ package extensions
 import (
 	"bytes"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/yuin/goldmark"
@@ -13,17 +14,22 @@ import (
 	"github.com/yuin/goldmark/util"
 )
 
-type youTubeExtension struct{}
+type youTubeExtension struct {
+	email bool
+}
 
 // YouTubeExtension lets you parse !youtube() blocks
 var YouTubeExtension = &youTubeExtension{}
 
+// YouTubeEmailExtension lets you parse !youtube() blocks and render them as linked thumbnails for email clients.
+var YouTubeEmailExtension = &youTubeExtension{email: true}
+
 func (h *youTubeExtension) Extend(m goldmark.Markdown) {
 	m.Parser().AddOptions(parser.WithBlockParsers(
 		util.Prioritized(newYouTubeBlockParser(), 10),
 	))
 	m.Renderer().AddOptions(renderer.WithNodeRenderers(
-		util.Prioritized(newYouTubeBlockRenderer(), 10),
+		util.Prioritized(newYouTubeBlockRenderer(h.email), 10),
 	))
 }
 
@@ -124,10 +130,12 @@ func (p *youTubeBlockParser) CanAcceptIndentedLine() bool {
 	return false
 }
 
-type youTubeBlockRenderer struct{}
+type youTubeBlockRenderer struct {
+	email bool
+}
 
-func newYouTubeBlockRenderer() *youTubeBlockRenderer {
-	return &youTubeBlockRenderer{}
+func newYouTubeBlockRenderer(email bool) *youTubeBlockRenderer {
+	return &youTubeBlockRenderer{email: email}
 }
 
 func (r *youTubeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
@@ -139,6 +147,11 @@ func (r *youTubeBlockRenderer) renderYouTube(w util.BufWriter, source []byte, no
 		return ast.WalkContinue, nil
 	}
 	n := node.(*YouTubeBlock)
+	if r.email {
+		_, _ = w.WriteString(fmt.Sprintf("<a href=\"https://www.youtube.com/watch?v=%s\">", url.QueryEscape(n.VideoID)))
+		_, _ = w.WriteString(fmt.Sprintf("<img src=\"https://img.youtube.com/vi/%s/hqdefault.jpg\" width=\"480\" height=\"360\" alt=\"YouTube\"></a>", url.PathEscape(n.VideoID)))
+		return ast.WalkSkipChildren, nil
+	}
 	width := 560
 	height := 9 * width / 16
 	_, _ = w.WriteString(fmt.Sprintf("<iframe class=\"youtube\" width=\"%d\" height=\"%d\" src=\"https://www.youtube.com/embed/%s\"", width, height, n.VideoID))
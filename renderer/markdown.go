@@ -6,6 +6,7 @@ import (
 
 	"jacobo.tarrio.org/jtweb/renderer/extensions"
 
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
 	mathjax "github.com/litao91/goldmark-mathjax"
 	"github.com/yuin/goldmark"
 	highlighting "github.com/yuin/goldmark-highlighting/v2"
@@ -34,6 +35,29 @@ var markdown goldmark.Markdown = goldmark.New(
 	),
 )
 
+// emailMarkdown renders the pages for email clients, which don't support iframes, scripts or most CSS.
+// It only renders documents; they are parsed with the markdown parser.
+var emailMarkdown goldmark.Markdown = goldmark.New(
+	goldmark.WithParserOptions(parser.WithAttribute()),
+	goldmark.WithRendererOptions(
+		html.WithUnsafe(),
+	),
+	goldmark.WithExtensions(
+		extensions.HeaderExtension,
+		extensions.YouTubeEmailExtension,
+		extensions.MultipleImageEmailExtension,
+		extensions.ImageCaptionExtension,
+		extension.Footnote,
+		extension.GFM,
+		extension.Typographer,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle("igor"),
+			highlighting.WithFormatOptions(chromahtml.WithClasses(false)),
+		),
+		extensions.EmailMathExtension,
+	),
+)
+
 type PageMarkdown struct {
 	Root   ast.Node
 	Header []byte
@@ -78,3 +102,10 @@ func getImages(root ast.Node, src []byte) []string {
 func RenderMarkdown(w io.Writer, source []byte, root ast.Node) error {
 	return markdown.Renderer().Render(w, source, root)
 }
+
+// RenderMarkdownForEmail renders the page in HTML format for email clients.
+// Videos are rendered as linked thumbnails, math as plain text, multiple images as tables,
+// and code is highlighted with inline styles.
+func RenderMarkdownForEmail(w io.Writer, source []byte, root ast.Node) error {
+	return emailMarkdown.Renderer().Render(w, source, root)
+}
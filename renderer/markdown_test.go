@@ -0,0 +1,43 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func renderForEmail(t *testing.T, src string) string {
+	md := ParseMarkdown([]byte(src))
+	sb := strings.Builder{}
+	assert.Nil(t, RenderMarkdownForEmail(&sb, []byte(src), md.Root))
+	return sb.String()
+}
+
+func TestRenderYouTubeForEmail(t *testing.T) {
+	out := renderForEmail(t, "!youtube(https://youtu.be/abc123)\n")
+	assert.Contains(t, out, `<a href="https://www.youtube.com/watch?v=abc123">`)
+	assert.Contains(t, out, `<img src="https://img.youtube.com/vi/abc123/hqdefault.jpg"`)
+	assert.NotContains(t, out, "<iframe")
+}
+
+func TestRenderMultipleImagesForEmail(t *testing.T) {
+	out := renderForEmail(t, "![a](a.jpg)\n![b](b.jpg)\n[![c](c.jpg)](c.html)\n")
+	assert.Contains(t, out, `<table style="border-collapse: collapse"><tr><td`)
+	assert.Contains(t, out, `<td style="padding: 2px; vertical-align: top"><img src="a.jpg" alt="a"></td>`)
+	assert.Contains(t, out, `<td style="padding: 2px; vertical-align: top"><a href="c.html"><img src="c.jpg" alt="c"></a></td>`)
+	assert.NotContains(t, out, "multipleImgs")
+}
+
+func TestRenderMathForEmail(t *testing.T) {
+	out := renderForEmail(t, "Inline $x^2 < y$ math.\n\n$$\na < b\n$$\n")
+	assert.Contains(t, out, `<code>x^2 &lt; y</code>`)
+	assert.Contains(t, out, "<pre>a &lt; b\n</pre>")
+	assert.NotContains(t, out, `class="math inline"`)
+}
+
+func TestRenderCodeForEmail(t *testing.T) {
+	out := renderForEmail(t, "```go\nfunc main() {}\n```\n")
+	assert.Contains(t, out, `<span style="color:#00f">func</span>`)
+	assert.NotContains(t, out, "class=")
+}
@@ -46,6 +46,9 @@ type PageData struct {
 	OlderPage    LinkData
 	Translations []*TranslationData
 	Draft        bool
+	Comments     []*CommentData
+	// The number of published comments; only available when comments are prerendered.
+	CommentCount int
 }
 
 // TranslationData holds information about a translation.
@@ -55,6 +58,17 @@ type TranslationData struct {
 	Language string
 }
 
+// CommentData holds information about a published comment.
+type CommentData struct {
+	Id       string
+	Anchor   string
+	Author   string
+	When     time.Time
+	Text     template.HTML
+	Verified bool
+	Badge    string
+}
+
 // TocData holds table-of-contents information to be rendered.
 type TocData struct {
 	Tag        string
@@ -62,6 +76,15 @@ type TocData struct {
 	Stories    []*PageData
 }
 
+// DigestData holds the information for a digest email to be rendered.
+type DigestData struct {
+	// The start of the period the digest covers.
+	Start time.Time
+	// The end of the period the digest covers, which is when it is sent.
+	End     time.Time
+	Stories []*PageData
+}
+
 // GetTemplates returns a loader for templates for a particular language.
 func GetTemplates(c config.Config, lang languages.Language) *Templates {
 	return &Templates{
@@ -93,6 +116,16 @@ func (t Templates) PlainEmail() (*textTemplate.Template, error) {
 	return t.getTextTemplate("email-plain")
 }
 
+// Digest loads the digest email template.
+func (t *Templates) Digest() (*template.Template, error) {
+	return t.getTemplate("digest")
+}
+
+// PlainDigest loads the plain-text digest email template.
+func (t *Templates) PlainDigest() (*textTemplate.Template, error) {
+	return t.getTextTemplate("digest-plain")
+}
+
 // IndexToc loads the story index template.
 func (t Templates) IndexToc() (*template.Template, error) {
 	return t.getTemplate("index-toc")
@@ -0,0 +1,41 @@
+package site
+
+import (
+	"context"
+	"html/template"
+
+	"jacobo.tarrio.org/jtweb/comments"
+	"jacobo.tarrio.org/jtweb/page"
+	"jacobo.tarrio.org/jtweb/renderer/templates"
+)
+
+// LoadComments retrieves the published comments for every page that has them enabled,
+// so they can be rendered into the generated pages.
+func (c *Contents) LoadComments(ctx context.Context) error {
+	service := c.Config.Comments().Service()
+	c.Comments = make(map[page.Name][]*templates.CommentData)
+	for name, p := range c.Pages {
+		if p.Header.Comments == nil || !p.Header.Comments.Enabled {
+			continue
+		}
+		list, err := service.List(ctx, comments.PostId(name), false, "", "")
+		if err != nil {
+			return err
+		}
+		for _, cmt := range list.List {
+			if !cmt.Visible {
+				continue
+			}
+			c.Comments[name] = append(c.Comments[name], &templates.CommentData{
+				Id:       string(cmt.Id),
+				Anchor:   "comment_" + string(cmt.Id),
+				Author:   cmt.Author,
+				When:     cmt.When,
+				Text:     template.HTML(cmt.Text),
+				Verified: cmt.Verified,
+				Badge:    cmt.Badge,
+			})
+		}
+	}
+	return nil
+}
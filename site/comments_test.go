@@ -0,0 +1,88 @@
+package site
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"testing"
+	"time"
+
+	"jacobo.tarrio.org/jtweb/comments/service"
+	configtesting "jacobo.tarrio.org/jtweb/config/testing"
+	"jacobo.tarrio.org/jtweb/page"
+	"jacobo.tarrio.org/jtweb/renderer/templates"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCommentsService only implements List, and records the posts it was asked about.
+type fakeCommentsService struct {
+	service.CommentsService
+	lists     map[service.PostId][]*service.Comment
+	requested []service.PostId
+}
+
+func (s *fakeCommentsService) List(ctx context.Context, postId service.PostId, seeDrafts bool, viewer service.IdentityId, client string) (*service.CommentList, error) {
+	s.requested = append(s.requested, postId)
+	list, ok := s.lists[postId]
+	if !ok {
+		return nil, fmt.Errorf("unknown post [%s]", postId)
+	}
+	return &service.CommentList{PostId: postId, List: list}, nil
+}
+
+func writePage(t *testing.T, config *configtesting.FakeConfig, name string, comments string) {
+	header := "<!--HEADER\ntitle: " + name + "\n"
+	if comments != "" {
+		header += "comments: " + comments + "\n"
+	}
+	err := config.InputBase.GoTo(name + ".md").CreateBytes([]byte(header + "-->\nThe content\n"))
+	assert.Nil(t, err)
+}
+
+func TestLoadComments(t *testing.T) {
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	comments := &fakeCommentsService{lists: map[service.PostId][]*service.Comment{
+		"open": {
+			{Id: "1", Visible: true, Author: "Alice", When: when, Text: "<p>Hello</p>", Verified: true, Badge: "Author"},
+			{Id: "2", Visible: false, Author: "Bob", When: when, Text: "<p>Draft</p>"},
+		},
+		"closed": {
+			{Id: "3", Visible: true, Author: "Carol", When: when, Text: "<p>Hi</p>"},
+		},
+	}}
+	config := configtesting.NewFakeConfig()
+	config.CommentsService = comments
+	writePage(t, config, "open", "open")
+	writePage(t, config, "closed", "closed")
+	writePage(t, config, "disabled", "disabled")
+	writePage(t, config, "default", "")
+
+	rawContent, err := Read(config)
+	assert.Nil(t, err)
+	content, err := rawContent.Index(nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, content.LoadComments(context.Background()))
+
+	assert.ElementsMatch(t, []service.PostId{"open", "closed"}, comments.requested)
+	assert.Equal(t, map[page.Name][]*templates.CommentData{
+		"open": {
+			{Id: "1", Anchor: "comment_1", Author: "Alice", When: when, Text: template.HTML("<p>Hello</p>"), Verified: true, Badge: "Author"},
+		},
+		"closed": {
+			{Id: "3", Anchor: "comment_3", Author: "Carol", When: when, Text: template.HTML("<p>Hi</p>")},
+		},
+	}, content.Comments)
+}
+
+func TestLoadCommentsError(t *testing.T) {
+	config := configtesting.NewFakeConfig()
+	config.CommentsService = &fakeCommentsService{}
+	writePage(t, config, "open", "open")
+
+	rawContent, err := Read(config)
+	assert.Nil(t, err)
+	content, err := rawContent.Index(nil, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, content.LoadComments(context.Background()))
+}
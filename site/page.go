@@ -6,9 +6,11 @@ import (
 	goio "io"
 	"strings"
 	textTemplate "text/template"
+	"time"
 
 	"github.com/aymerick/douceur/inliner"
 	"jacobo.tarrio.org/jtweb/io"
+	"jacobo.tarrio.org/jtweb/languages"
 	"jacobo.tarrio.org/jtweb/page"
 	"jacobo.tarrio.org/jtweb/renderer"
 	"jacobo.tarrio.org/jtweb/renderer/templates"
@@ -31,7 +33,7 @@ func (c *Contents) OutputAsPage(w goio.Writer, page *page.Page) error {
 	if err != nil {
 		return err
 	}
-	return c.outputPageFromTemplate(w, tmpl, page)
+	return c.outputPageFromTemplate(w, tmpl, page, false)
 }
 
 func (c *Contents) OutputAsEmail(w goio.Writer, page *page.Page) error {
@@ -40,7 +42,7 @@ func (c *Contents) OutputAsEmail(w goio.Writer, page *page.Page) error {
 		return err
 	}
 	sb := strings.Builder{}
-	err = c.outputPageFromTemplate(&sb, tmpl, page)
+	err = c.outputPageFromTemplate(&sb, tmpl, page, true)
 	if err != nil {
 		return err
 	}
@@ -57,11 +59,64 @@ func (c *Contents) OutputAsPlainEmail(w goio.Writer, page *page.Page) error {
 	if err != nil {
 		return err
 	}
-	return c.outputPageFromTextTemplate(w, tmpl, page)
+	return c.outputPageFromTextTemplate(w, tmpl, page, true)
 }
 
-func (c *Contents) outputPageFromTemplate(w goio.Writer, tmpl *template.Template, page *page.Page) error {
-	pageData, err := c.makePageData(page)
+// OutputAsDigest renders a digest email containing several pages in the given language.
+func (c *Contents) OutputAsDigest(w goio.Writer, lang languages.Language, start time.Time, end time.Time, pages []*page.Page) error {
+	tmpl, err := templates.GetTemplates(c.Config, lang).Digest()
+	if err != nil {
+		return err
+	}
+	digestData, err := c.makeDigestData(start, end, pages)
+	if err != nil {
+		return err
+	}
+	buf := bytes.Buffer{}
+	err = tmpl.Execute(&buf, digestData)
+	if err != nil {
+		return err
+	}
+	sb := strings.Builder{}
+	err = renderer.NormalizeOutput(&sb, &buf)
+	if err != nil {
+		return err
+	}
+	out, err := inliner.Inline(sb.String())
+	if err != nil {
+		return err
+	}
+	_, err = strings.NewReader(out).WriteTo(w)
+	return err
+}
+
+// OutputAsPlainDigest renders the plain-text version of a digest email containing several pages in the given language.
+func (c *Contents) OutputAsPlainDigest(w goio.Writer, lang languages.Language, start time.Time, end time.Time, pages []*page.Page) error {
+	tmpl, err := templates.GetTemplates(c.Config, lang).PlainDigest()
+	if err != nil {
+		return err
+	}
+	digestData, err := c.makeDigestData(start, end, pages)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, digestData)
+}
+
+func (c *Contents) makeDigestData(start time.Time, end time.Time, pages []*page.Page) (*templates.DigestData, error) {
+	digestData := &templates.DigestData{Start: start, End: end}
+	for _, p := range pages {
+		pageData, err := c.makePageData(p, true)
+		if err != nil {
+			return nil, err
+		}
+		digestData.Stories = append(digestData.Stories, pageData)
+	}
+	return digestData, nil
+}
+
+func (c *Contents) outputPageFromTemplate(w goio.Writer, tmpl *template.Template, page *page.Page, forEmail bool) error {
+	pageData, err := c.makePageData(page, forEmail)
 	if err != nil {
 		return err
 	}
@@ -73,17 +128,24 @@ func (c *Contents) outputPageFromTemplate(w goio.Writer, tmpl *template.Template
 	return renderer.NormalizeOutput(w, &buf)
 }
 
-func (c *Contents) outputPageFromTextTemplate(w goio.Writer, tmpl *textTemplate.Template, page *page.Page) error {
-	pageData, err := c.makePageData(page)
+func (c *Contents) outputPageFromTextTemplate(w goio.Writer, tmpl *textTemplate.Template, page *page.Page, forEmail bool) error {
+	pageData, err := c.makePageData(page, forEmail)
 	if err != nil {
 		return err
 	}
 	return tmpl.Execute(w, pageData)
 }
 
-func (c *Contents) makePageData(page *page.Page) (*templates.PageData, error) {
+// makePageData returns the data to render a page's template. If forEmail is true, the page's content is rendered
+// in a way that email clients can display.
+func (c *Contents) makePageData(page *page.Page, forEmail bool) (*templates.PageData, error) {
 	buf := bytes.Buffer{}
-	err := page.Render(&buf)
+	var err error
+	if forEmail {
+		err = page.RenderForEmail(&buf)
+	} else {
+		err = page.Render(&buf)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +166,9 @@ func (c *Contents) makePageData(page *page.Page) (*templates.PageData, error) {
 		Tags:       page.Header.Tags,
 		Content:    template.HTML(content.String()),
 		Draft:      page.Header.Draft,
+		Comments:   c.Comments[page.Name],
 	}
+	pageData.CommentCount = len(pageData.Comments)
 	if !page.Header.HidePublishDate {
 		pageData.PublishDate = page.Header.PublishDate
 	}
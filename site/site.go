@@ -12,6 +12,7 @@ import (
 	"jacobo.tarrio.org/jtweb/io"
 	"jacobo.tarrio.org/jtweb/languages"
 	"jacobo.tarrio.org/jtweb/page"
+	"jacobo.tarrio.org/jtweb/renderer/templates"
 	"jacobo.tarrio.org/jtweb/uri"
 )
 
@@ -35,6 +36,7 @@ type Contents struct {
 	Tags         map[TagId]string
 	Toc          GlobalTableOfContents
 	Translations map[page.Name][]Translation
+	Comments     map[page.Name][]*templates.CommentData
 }
 
 // GlobalTableOfContents contains the tables of contents for every language.
@@ -16,7 +16,7 @@ func (c *Contents) outputToc(w goio.Writer, lang languages.Language, names []pag
 
 	stories := make([]*templates.PageData, len(names))
 	for i, name := range names {
-		pageData, err := c.makePageData(c.Pages[name])
+		pageData, err := c.makePageData(c.Pages[name], false)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,82 @@
+// The tokens package creates and verifies signed tokens that carry a small
+// payload and an expiration date.
+//
+// Tokens are meant to be handed out to users (in cookies, links or emails)
+// and returned later. A token is only accepted if it was signed with the same
+// key and for the same purpose, and if it hasn't expired yet.
+
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Signer creates and verifies tokens using a secret key.
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+type tokenData struct {
+	Purpose string          `json:"p"`
+	Expires int64           `json:"e"`
+	Payload json.RawMessage `json:"d"`
+}
+
+// NewSigner returns a Signer that uses the given secret key.
+func NewSigner(key string) *Signer {
+	return &Signer{key: []byte(key), now: time.Now}
+}
+
+// Sign returns a token for the given purpose that carries the payload and expires at the given time.
+func (s *Signer) Sign(purpose string, payload any, expires time.Time) (string, error) {
+	d, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(&tokenData{Purpose: purpose, Expires: expires.Unix(), Payload: d})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + s.signature(encoded), nil
+}
+
+// Verify checks that the token is valid for the given purpose and decodes its payload.
+func (s *Signer) Verify(purpose string, token string, payload any) error {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return fmt.Errorf("malformed token")
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return fmt.Errorf("invalid token signature")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	var td tokenData
+	err = json.Unmarshal(data, &td)
+	if err != nil {
+		return err
+	}
+	if td.Purpose != purpose {
+		return fmt.Errorf("token was issued for a different purpose")
+	}
+	if s.now().Unix() > td.Expires {
+		return fmt.Errorf("token has expired")
+	}
+	return json.Unmarshal(td.Payload, payload)
+}
+
+func (s *Signer) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
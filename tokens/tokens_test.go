@@ -0,0 +1,71 @@
+package tokens
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type payload struct {
+	Id   string
+	Name string
+}
+
+func newTestSigner(key string, now time.Time) *Signer {
+	s := NewSigner(key)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSigner("key", now)
+	token, err := s.Sign("test", &payload{Id: "12", Name: "John"}, now.Add(time.Hour))
+	assert.Nil(t, err)
+
+	var actual payload
+	err = s.Verify("test", token, &actual)
+	assert.Nil(t, err)
+	assert.Equal(t, payload{Id: "12", Name: "John"}, actual)
+}
+
+func TestVerifyRejectsOtherPurpose(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSigner("key", now)
+	token, err := s.Sign("test", &payload{Id: "12"}, now.Add(time.Hour))
+	assert.Nil(t, err)
+
+	var actual payload
+	assert.NotNil(t, s.Verify("other", token, &actual))
+}
+
+func TestVerifyRejectsOtherKey(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	token, err := newTestSigner("key", now).Sign("test", &payload{Id: "12"}, now.Add(time.Hour))
+	assert.Nil(t, err)
+
+	var actual payload
+	assert.NotNil(t, newTestSigner("other key", now).Verify("test", token, &actual))
+}
+
+func TestVerifyRejectsExpired(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	token, err := newTestSigner("key", now).Sign("test", &payload{Id: "12"}, now.Add(time.Hour))
+	assert.Nil(t, err)
+
+	var actual payload
+	assert.NotNil(t, newTestSigner("key", now.Add(2*time.Hour)).Verify("test", token, &actual))
+}
+
+func TestVerifyRejectsTampered(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newTestSigner("key", now)
+	token, err := s.Sign("test", &payload{Id: "12"}, now.Add(time.Hour))
+	assert.Nil(t, err)
+
+	var actual payload
+	assert.NotNil(t, s.Verify("test", "x"+token, &actual))
+	assert.NotNil(t, s.Verify("test", token+"x", &actual))
+	assert.NotNil(t, s.Verify("test", "garbage", &actual))
+}
@@ -13,9 +13,15 @@ var commentsJs string
 //go:embed html/admin.html
 var adminHtml string
 
+//go:embed html/login.html
+var loginHtml string
+
 //go:embed generated/admin.js
 var adminJs string
 
+//go:embed generated/subscribe.js
+var subscribeJs string
+
 func ServeCommentsJs() http.HandlerFunc {
 	return serveContent("comments.js", commentsJs)
 }
@@ -24,10 +30,18 @@ func ServeAdminHtml() http.HandlerFunc {
 	return serveContent("admin.html", adminHtml)
 }
 
+func ServeLoginHtml() http.HandlerFunc {
+	return serveContent("login.html", loginHtml)
+}
+
 func ServeAdminJs() http.HandlerFunc {
 	return serveContent("admin.js", adminJs)
 }
 
+func ServeSubscribeJs() http.HandlerFunc {
+	return serveContent("subscribe.js", subscribeJs)
+}
+
 func serveContent(name string, content string) http.HandlerFunc {
 	now := time.Now()
 	return func(rw http.ResponseWriter, req *http.Request) {
@@ -3,9 +3,11 @@
 
     class AdminPage {
         root;
+        // The cursors for the pages before the current one, to go back.
+        previousCursors = [];
         constructor(root) {
             this.root = root;
-            this.wireEvent('input[name=ApplyFilter]', 'click', _ => this.loadList(0));
+            this.wireEvent('input[name=ApplyFilter]', 'click', _ => this.loadList());
             let list = this.getListTableElement();
             if (list) {
                 this.wireEventFromRoot(list, 'thead input[type=checkbox]', 'change', e => this.toggleSelectAll(e));
@@ -46,12 +48,20 @@
             row.innerHTML = rowHtml;
             return row;
         }
-        async loadList(start) {
+        async loadList() {
+            this.previousCursors = [];
+            await this.showPage('');
+        }
+        async showPage(cursor) {
             let filter = this.getFilter();
-            let items = await this.getItems(filter, this.getItemsPerPage(), start);
+            let items = await this.getItems(filter, this.getItemsPerPage(), cursor);
             let listName = this.getListNameElement();
-            if (listName)
-                listName.textContent = this.getFilterTitle(filter);
+            if (listName) {
+                let title = this.getFilterTitle(filter);
+                if (items.Total !== undefined)
+                    title += ` (${items.Total})`;
+                listName.textContent = title;
+            }
             let listTable = this.getListTableElement()?.querySelector('tbody');
             if (listTable) {
                 while (listTable.firstChild)
@@ -74,21 +84,23 @@
             if (listLinks) {
                 while (listLinks.firstChild)
                     listLinks.firstChild.remove();
-                if (items.More) {
+                if (items.More && items.Next !== undefined) {
+                    let next = items.Next;
                     let link = document.createElement('a');
                     link.href = "javascript:0";
                     link.textContent = "Next";
-                    link.addEventListener('click', _ => this.loadList(start + this.getItemsPerPage()));
+                    link.addEventListener('click', _ => {
+                        this.previousCursors.push(cursor);
+                        this.showPage(next);
+                    });
                     listLinks.appendChild(link);
-                    if (items.More) {
-                        listLinks.insertAdjacentText('beforeend', ' ');
-                    }
+                    listLinks.insertAdjacentText('beforeend', ' ');
                 }
-                if (start > 0) {
+                if (this.previousCursors.length > 0) {
                     let link = document.createElement('a');
                     link.href = "javascript:0";
                     link.textContent = "Previous";
-                    link.addEventListener('click', _ => this.loadList(start - this.getItemsPerPage()));
+                    link.addEventListener('click', _ => this.showPage(this.previousCursors.pop()));
                     listLinks.appendChild(link);
                 }
             }
@@ -118,38 +130,51 @@
     var Sort;
     (function (Sort) {
         Sort[Sort["NewestFirst"] = 0] = "NewestFirst";
+        Sort[Sort["OldestFirst"] = 1] = "OldestFirst";
+        Sort[Sort["ByPost"] = 2] = "ByPost";
     })(Sort || (Sort = {}));
+    var NotificationKind;
+    (function (NotificationKind) {
+        NotificationKind[NotificationKind["Admin"] = 0] = "Admin";
+        NotificationKind[NotificationKind["Subscriber"] = 1] = "Subscriber";
+    })(NotificationKind || (NotificationKind = {}));
     class AdminApi {
-        async findComments(filter, sort, limit, start) {
+        async findComments(filter, sort, limit, cursor) {
             let params = {
                 'Filter': filter,
                 'Sort': sort,
                 'Limit': limit,
-                'Start': start,
+                'Cursor': cursor,
             };
-            return post('/findComments', params);
+            return adminPost('/findComments', params);
         }
         async deleteComments(ids) {
             let params = {
                 'Ids': Object.fromEntries(ids)
             };
-            await post('/deleteComments', params);
+            await adminPost('/deleteComments', params);
         }
-        async findPosts(filter, sort, limit, start) {
+        async restoreComments(ids) {
+            let params = {
+                'Ids': Object.fromEntries(ids)
+            };
+            await adminPost('/restoreComments', params);
+        }
+        async findPosts(filter, sort, limit, cursor) {
             let params = {
                 'Filter': filter,
                 'Sort': sort,
                 'Limit': limit,
-                'Start': start,
+                'Cursor': cursor,
             };
-            return post('/findPosts', params);
+            return adminPost('/findPosts', params);
         }
         async bulkSetVisible(ids, visible) {
             let params = {
                 'Ids': Object.fromEntries(ids),
                 'Visible': visible
             };
-            await post('/bulkSetVisible', params);
+            await adminPost('/bulkSetVisible', params);
         }
         async bulkUpdatePostConfigs(postIds, writable, readable) {
             let params = {
@@ -159,16 +184,63 @@
                     'IsReadable': readable,
                 },
             };
-            await post('/bulkUpdatePostConfigs', params);
+            await adminPost('/bulkUpdatePostConfigs', params);
+        }
+        async findNotifications(filter, limit, start) {
+            let params = {
+                'Filter': filter,
+                'Limit': limit,
+                'Start': start,
+            };
+            return adminPost('/findNotifications', params);
+        }
+        async retryNotifications(ids) {
+            await adminPost('/retryNotifications', { 'Ids': ids });
+        }
+        async deleteNotifications(ids) {
+            await adminPost('/deleteNotifications', { 'Ids': ids });
+        }
+        async findAuditEntries(filter, limit, cursor) {
+            let params = {
+                'Filter': filter,
+                'Limit': limit,
+                'Cursor': cursor,
+            };
+            return adminPost('/findAuditEntries', params);
+        }
+        async whoami() {
+            return adminPost('/whoami', {});
+        }
+        async signOut() {
+            await adminPost('/signOutAdmin', {});
         }
     }
-    async function post(url, data) {
-        let response = await fetch(apiUrl + url, { method: 'POST', mode: 'cors', body: JSON.stringify(data) });
+    async function post(url, data, headers = {}) {
+        // The server only accepts JSON requests, so that other sites can't make them with a form.
+        headers['Content-Type'] = 'application/json';
+        let response = await fetch(apiUrl + url, { method: 'POST', mode: 'cors', credentials: 'include', headers: headers, body: JSON.stringify(data) });
         if (response.status != 200) {
             throw `Error ${response.status}: ${await response.text()}`;
         }
         return response.json();
     }
+    // Returns the CSRF token for administrators who signed in with a password.
+    function getCsrfToken() {
+        for (let cookie of document.cookie.split(';')) {
+            let [name, value] = cookie.trim().split('=');
+            if (name == 'jtcomments_csrf')
+                return value;
+        }
+        return undefined;
+    }
+    // Administrators who signed in with a password must send back the CSRF token from their cookie.
+    async function adminPost(url, data) {
+        let headers = {};
+        let token = getCsrfToken();
+        if (token !== undefined)
+            headers['X-CSRF-Token'] = token;
+        return post(url, data, headers);
+    }
     const apiUrl = (() => {
         let scripts = document.getElementsByTagName('script');
         let baseUrl = new URL(scripts[scripts.length - 1].attributes['src'].value, window.location.toString());
@@ -183,6 +255,44 @@
         return baseUrl.toString();
     })();
 
+    function doAdminAudit(rootElement) {
+        new AdminAudit(rootElement);
+    }
+    class AdminAudit extends AdminPage {
+        constructor(root) {
+            super(root);
+            this.api = new AdminApi();
+            this.loadList();
+        }
+        api;
+        getFilter() {
+            let form = this.root.querySelector('#filters');
+            if (!form)
+                throw "Could not find filters box";
+            return {
+                PostId: form.querySelector('[name=PostId]').value.trim(),
+                Actor: form.querySelector('[name=Actor]').value.trim(),
+            };
+        }
+        getFilterTitle(filter) {
+            return 'Audit log';
+        }
+        getItems(filter, itemsPerPage, cursor) {
+            return this.api.findAuditEntries(filter, itemsPerPage, cursor);
+        }
+        getRowContents(item) {
+            return [
+                item.When,
+                item.Actor,
+                item.Action,
+                item.PostId,
+                item.CommentId,
+                item.OldState,
+                item.NewState
+            ];
+        }
+    }
+
     function doAdminComments(rootElement) {
         new AdminComments(rootElement);
     }
@@ -193,38 +303,62 @@
             this.wireEvent('input[name=MakeVisible]', 'click', _ => this.changeVisible(true));
             this.wireEvent('input[name=MakeNonVisible]', 'click', _ => this.changeVisible(false));
             this.wireEvent('input[name=Delete]', 'click', _ => this.deleteComments());
-            this.loadList(0);
+            this.wireEvent('input[name=Restore]', 'click', _ => this.restoreComments());
+            this.loadList();
         }
         api;
         getFilter() {
-            let out = { Visible: null };
-            let form = this.root.querySelector('#filters');
-            if (!form)
-                throw "Could not find filters box";
+            let out = { Visible: null, PostId: '', Author: '', NotBefore: null, NotAfter: null, Text: '', Deleted: false };
+            let form = this.getFiltersElement();
             let visible = form.querySelector('[name=Visible]').value;
             if (visible == 'true')
                 out.Visible = true;
             if (visible == 'false')
                 out.Visible = false;
+            if (visible == 'deleted')
+                out.Deleted = true;
+            out.PostId = form.querySelector('[name=PostId]').value.trim();
+            out.Author = form.querySelector('[name=Author]').value.trim();
+            out.Text = form.querySelector('[name=Text]').value.trim();
+            let notBefore = form.querySelector('[name=NotBefore]').value;
+            if (notBefore)
+                out.NotBefore = new Date(notBefore).toISOString();
+            let notAfter = form.querySelector('[name=NotAfter]').value;
+            if (notAfter) {
+                // The filter excludes the end date, but the form includes it.
+                let date = new Date(notAfter);
+                date.setUTCDate(date.getUTCDate() + 1);
+                out.NotAfter = date.toISOString();
+            }
             return out;
         }
         getFilterTitle(filter) {
-            if (filter.Visible === null) {
-                return 'Latest comments';
+            if (filter.Deleted) {
+                return 'Deleted comments';
+            }
+            else if (filter.Visible === null) {
+                return 'Comments';
             }
             else if (filter.Visible) {
-                return 'Latest visible comments';
+                return 'Visible comments';
             }
             else {
-                return 'Latest non-visible comments';
+                return 'Non-visible comments';
             }
         }
-        getItems(filter, itemsPerPage, start) {
-            return this.api.findComments(filter, Sort.NewestFirst, itemsPerPage, start);
+        getItems(filter, itemsPerPage, cursor) {
+            let sort = Number(this.getFiltersElement().querySelector('[name=Sort]').value);
+            return this.api.findComments(filter, sort, itemsPerPage, cursor);
+        }
+        getFiltersElement() {
+            let form = this.root.querySelector('#filters');
+            if (!form)
+                throw "Could not find filters box";
+            return form;
         }
         getRowContents(item) {
             return [
-                item.Visible ? 'Yes' : 'No',
+                item.Deleted ? 'Deleted' : item.Visible ? 'Yes' : 'No',
                 item.PostId,
                 item.Author,
                 item.When,
@@ -236,14 +370,21 @@
             if (ids.size == 0)
                 return;
             await this.api.bulkSetVisible(ids, visible);
-            this.loadList(0);
+            this.loadList();
         }
         async deleteComments() {
             let ids = this.gatherSelectedIds();
             if (ids.size == 0)
                 return;
             await this.api.deleteComments(ids);
-            this.loadList(0);
+            this.loadList();
+        }
+        async restoreComments() {
+            let ids = this.gatherSelectedIds();
+            if (ids.size == 0)
+                return;
+            await this.api.restoreComments(ids);
+            this.loadList();
         }
         gatherSelectedIds() {
             let items = this.gatherSelectedItems();
@@ -315,6 +456,78 @@
         return true;
     }
 
+    function doAdminNotifications(rootElement) {
+        new AdminNotifications(rootElement);
+    }
+    class AdminNotifications extends AdminPage {
+        constructor(root) {
+            super(root);
+            this.api = new AdminApi();
+            this.wireEvent('input[name=Retry]', 'click', _ => this.retryNotifications());
+            this.wireEvent('input[name=Delete]', 'click', _ => this.deleteNotifications());
+            this.loadList();
+        }
+        api;
+        getFilter() {
+            let out = { Failed: null };
+            let form = this.root.querySelector('#filters');
+            if (!form)
+                throw "Could not find filters box";
+            let status = form.querySelector('[name=Status]').value;
+            if (status == 'failed')
+                out.Failed = true;
+            if (status == 'pending')
+                out.Failed = false;
+            return out;
+        }
+        getFilterTitle(filter) {
+            if (filter.Failed === null) {
+                return 'Queued notifications';
+            }
+            else if (filter.Failed) {
+                return 'Failed notifications';
+            }
+            else {
+                return 'Pending notifications';
+            }
+        }
+        async getItems(filter, itemsPerPage, cursor) {
+            // Notifications are paginated by offset; the cursor holds the offset of the page.
+            let start = Number(cursor || 0);
+            let found = await this.api.findNotifications(filter, itemsPerPage, start);
+            return { ...found, Next: String(start + itemsPerPage) };
+        }
+        getRowContents(item) {
+            return [
+                item.Failed ? 'Failed' : 'Pending',
+                item.Kind == NotificationKind.Admin ? 'Admin ' + (item.Notifier + 1) : 'Subscriber ' + item.Recipient,
+                item.PostId,
+                item.CommentId,
+                item.Created,
+                String(item.Attempts),
+                item.Failed ? '' : item.NextAttempt,
+                item.LastError
+            ];
+        }
+        async retryNotifications() {
+            let ids = this.gatherSelectedIds();
+            if (ids.length == 0)
+                return;
+            await this.api.retryNotifications(ids);
+            this.loadList();
+        }
+        async deleteNotifications() {
+            let ids = this.gatherSelectedIds();
+            if (ids.length == 0)
+                return;
+            await this.api.deleteNotifications(ids);
+            this.loadList();
+        }
+        gatherSelectedIds() {
+            return this.gatherSelectedItems().map(item => item.NotificationId);
+        }
+    }
+
     function doAdminPosts(rootElement) {
         new AdminPosts(rootElement);
     }
@@ -325,7 +538,7 @@
             this.wireEvent('input[name=MakeOpen]', 'click', _ => this.changeState(true, true));
             this.wireEvent('input[name=MakeClosed]', 'click', _ => this.changeState(false, true));
             this.wireEvent('input[name=MakeDisabled]', 'click', _ => this.changeState(false, false));
-            this.loadList(0);
+            this.loadList();
         }
         api;
         getFilter() {
@@ -361,8 +574,8 @@
                 return 'Latest posts';
             }
         }
-        getItems(filter, itemsPerPage, start) {
-            return this.api.findPosts(filter, Sort.NewestFirst, itemsPerPage, start);
+        getItems(filter, itemsPerPage, cursor) {
+            return this.api.findPosts(filter, Sort.NewestFirst, itemsPerPage, cursor);
         }
         getRowContents(item) {
             return [
@@ -373,7 +586,7 @@
         async changeState(writable, readable) {
             let ids = this.gatherSelectedIds();
             await this.api.bulkUpdatePostConfigs(ids, writable, readable);
-            this.loadList(0);
+            this.loadList();
         }
         gatherSelectedIds() {
             let items = this.gatherSelectedItems();
@@ -453,6 +666,28 @@
         initTabs('tabTitle', 'tabPage');
         doAdminComments(document.getElementById('tabPage-0'));
         doAdminPosts(document.getElementById('tabPage-1'));
+        doAdminNotifications(document.getElementById('tabPage-2'));
+        doAdminAudit(document.getElementById('tabPage-3'));
+        showAdmin();
+    }
+    // Shows who is signed in, and hides the controls that need a role they don't have.
+    async function showAdmin() {
+        let api = new AdminApi();
+        let admin = await api.whoami();
+        document.getElementById('adminName').textContent = `${admin.Name} (${admin.Role})`;
+        if (admin.Role != 'admin') {
+            for (let element of document.querySelectorAll('[data-role=admin]')) {
+                element.hidden = true;
+            }
+        }
+        if (getCsrfToken() !== undefined) {
+            let signOut = document.getElementById('signOut');
+            signOut.hidden = false;
+            signOut.addEventListener('click', async (_) => {
+                await api.signOut();
+                window.location.href = 'login.html';
+            });
+        }
     }
 
 })();
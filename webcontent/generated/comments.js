@@ -5,19 +5,51 @@
     (function (MessageType) {
         MessageType[MessageType["ErrorPostingComment"] = 0] = "ErrorPostingComment";
         MessageType[MessageType["CommentPostedAsDraft"] = 1] = "CommentPostedAsDraft";
+        MessageType[MessageType["VerificationSent"] = 2] = "VerificationSent";
+        MessageType[MessageType["ErrorSendingVerification"] = 3] = "ErrorSendingVerification";
+        MessageType[MessageType["ErrorModifyingComment"] = 4] = "ErrorModifyingComment";
+        MessageType[MessageType["ConfirmDeleteComment"] = 5] = "ConfirmDeleteComment";
+        MessageType[MessageType["SaveComment"] = 6] = "SaveComment";
+        MessageType[MessageType["CancelEdit"] = 7] = "CancelEdit";
+        MessageType[MessageType["ErrorSubscribing"] = 8] = "ErrorSubscribing";
+        MessageType[MessageType["ErrorReacting"] = 9] = "ErrorReacting";
     })(MessageType || (MessageType = {}));
     const Messages = {
         'en': {
             [MessageType.ErrorPostingComment]: 'There was an error while submitting the comment.',
             [MessageType.CommentPostedAsDraft]: 'Your comment was submitted and will become visible when it is approved.',
+            [MessageType.VerificationSent]: 'We sent you an email with a link to verify your address.',
+            [MessageType.ErrorSendingVerification]: 'There was an error while sending the verification email.',
+            [MessageType.ErrorModifyingComment]: 'There was an error while modifying the comment.',
+            [MessageType.ConfirmDeleteComment]: 'Do you want to delete this comment?',
+            [MessageType.SaveComment]: 'Save',
+            [MessageType.CancelEdit]: 'Cancel',
+            [MessageType.ErrorSubscribing]: 'There was an error while updating your subscription.',
+            [MessageType.ErrorReacting]: 'There was an error while saving your reaction.',
         },
         'es': {
             [MessageType.ErrorPostingComment]: 'Hubo un error enviando el comentario.',
             [MessageType.CommentPostedAsDraft]: 'Se ha recibido tu comentario y será publicado cuando se apruebe.',
+            [MessageType.VerificationSent]: 'Te hemos enviado un correo con un enlace para verificar tu dirección.',
+            [MessageType.ErrorSendingVerification]: 'Hubo un error enviando el correo de verificación.',
+            [MessageType.ErrorModifyingComment]: 'Hubo un error modificando el comentario.',
+            [MessageType.ConfirmDeleteComment]: '¿Quieres borrar este comentario?',
+            [MessageType.SaveComment]: 'Guardar',
+            [MessageType.CancelEdit]: 'Cancelar',
+            [MessageType.ErrorSubscribing]: 'Hubo un error actualizando tu suscripción.',
+            [MessageType.ErrorReacting]: 'Hubo un error guardando tu reacción.',
         },
         'gl': {
             [MessageType.ErrorPostingComment]: 'Houbo un erro ao enviar o comentario.',
             [MessageType.CommentPostedAsDraft]: 'Recibiuse o teu comentario e vai ser publicado cando se aprobe.',
+            [MessageType.VerificationSent]: 'Enviámosche un correo cunha ligazón para verificar o teu enderezo.',
+            [MessageType.ErrorSendingVerification]: 'Houbo un erro ao enviar o correo de verificación.',
+            [MessageType.ErrorModifyingComment]: 'Houbo un erro ao modificar o comentario.',
+            [MessageType.ConfirmDeleteComment]: 'Queres borrar este comentario?',
+            [MessageType.SaveComment]: 'Gardar',
+            [MessageType.CancelEdit]: 'Cancelar',
+            [MessageType.ErrorSubscribing]: 'Houbo un erro ao actualizar a túa subscrición.',
+            [MessageType.ErrorReacting]: 'Houbo un erro ao gardar a túa reacción.',
         }
     };
     const Templates = {
@@ -25,15 +57,21 @@
     <jv-if cond="has_none_count"><h1>No comments</h1></jv-if>
     <jv-if cond="has_singular_count"><h1>1 comment</h1></jv-if>
     <jv-if cond="has_plural_count"><h1><jv>count</jv> comments</h1></jv-if>
+    <jv-if cond="post_reactions"><div class="commentReactions"><jv-for items="post_reactions" item="reaction"><button type="button" class="jtReaction" data-comment-id="" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     <jv-for items="comments" item="comment">
-        <div class="commentByline">By <jv>comment.author</jv> on <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
-        <div class="commentText><jv html>comment.text</jv></div>
+        <div class="commentByline">By <jv>comment.author</jv><jv-if cond="comment.verified"> <span class="commentVerified" title="Verified">&#x2713;</span></jv-if><jv-if cond="comment.badge"> <span class="commentBadge"><jv>comment.badge</jv></span></jv-if> on <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
+        <div class="commentText" jv-data-comment-id="comment.id"><jv html>comment.text</jv></div>
+        <jv-if cond="comment.editable"><div class="commentActions"><a href="javascript:0" class="jtEditComment" jv-data-comment-id="comment.id">Edit</a> <a href="javascript:0" class="jtDeleteComment" jv-data-comment-id="comment.id">Delete</a></div></jv-if>
+        <jv-if cond="comment.reactions"><div class="commentReactions"><jv-for items="comment.reactions" item="reaction"><button type="button" class="jtReaction" jv-data-comment-id="comment.id" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     </jv-for>
     <jv-if cond="can_add_comment">
         <form id="commentform">
             <h1>Would you like to write a comment?</h1>
             <div class="commentForm">
-                <div>Your name or nickname: <input type="text" name="author"> (it will be published)</div>
+                <jv-if cond="signed_in"><div class="commentIdentity">Posting as <jv>identity.Name</jv>. <a href="javascript:0" id="jtSignOut">Sign out</a></div></jv-if>
+                <jv-if cond="can_subscribe"><div class="commentIdentity"><label><input type="checkbox" id="jtSubscribe" jv-checked="subscribed"> Email me when new comments are posted here</label></div></jv-if>
+                <jv-if not cond="signed_in"><div>Your name or nickname: <input type="text" name="author"> (it will be published)</div></jv-if>
+                <jv-if cond="can_verify"><div class="commentIdentity">Optional: your email address <input type="email" name="email"> <input type="button" value="Verify" id="jtVerifyButton"> (it won't be published; verify it to get a badge and edit your comments)</div></jv-if>
                 <div>Your comment:</div>
                 <textarea name="text" id="jtComment"></textarea>
                 <div id="jtPreviewContainer"><div id="jtPreviewBox"></div></div>
@@ -48,15 +86,21 @@
     <jv-if cond="has_none_count"><h1>Ningún comentario</h1></jv-if>
     <jv-if cond="has_singular_count"><h1>1 comentario</h1></jv-if>
     <jv-if cond="has_plural_count"><h1><jv>count</jv> comentarios</h1></jv-if>
+    <jv-if cond="post_reactions"><div class="commentReactions"><jv-for items="post_reactions" item="reaction"><button type="button" class="jtReaction" data-comment-id="" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     <jv-for items="comments" item="comment">
-        <div class="commentByline">Por <jv>comment.author</jv> o <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
-        <div class="commentText><jv html>comment.text</jv></div>
+        <div class="commentByline">Por <jv>comment.author</jv><jv-if cond="comment.verified"> <span class="commentVerified" title="Verificado">&#x2713;</span></jv-if><jv-if cond="comment.badge"> <span class="commentBadge"><jv>comment.badge</jv></span></jv-if> o <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
+        <div class="commentText" jv-data-comment-id="comment.id"><jv html>comment.text</jv></div>
+        <jv-if cond="comment.editable"><div class="commentActions"><a href="javascript:0" class="jtEditComment" jv-data-comment-id="comment.id">Editar</a> <a href="javascript:0" class="jtDeleteComment" jv-data-comment-id="comment.id">Borrar</a></div></jv-if>
+        <jv-if cond="comment.reactions"><div class="commentReactions"><jv-for items="comment.reactions" item="reaction"><button type="button" class="jtReaction" jv-data-comment-id="comment.id" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     </jv-for>
     <jv-if cond="can_add_comment">
         <form id="commentform">
             <h1>Queres escribir un comentario?</h1>
             <div class="commentForm">
-                <div>O teu nome ou sobrenome: <input type="text" name="author"> (hase publicar)</div>
+                <jv-if cond="signed_in"><div class="commentIdentity">Comentas como <jv>identity.Name</jv>. <a href="javascript:0" id="jtSignOut">Saír</a></div></jv-if>
+                <jv-if cond="can_subscribe"><div class="commentIdentity"><label><input type="checkbox" id="jtSubscribe" jv-checked="subscribed"> Avísame por correo cando se publiquen novos comentarios aquí</label></div></jv-if>
+                <jv-if not cond="signed_in"><div>O teu nome ou sobrenome: <input type="text" name="author"> (hase publicar)</div></jv-if>
+                <jv-if cond="can_verify"><div class="commentIdentity">Opcional: o teu enderezo de correo <input type="email" name="email"> <input type="button" value="Verificar" id="jtVerifyButton"> (non se ha publicar; verifícao para ter unha insignia e editar os teus comentarios)</div></jv-if>
                 <div>O teu comentario:</div>
                 <textarea name="text" id="jtComment"></textarea>
                 <div id="jtPreviewContainer"><div id="jtPreviewBox"></div></div>
@@ -71,15 +115,21 @@
     <jv-if cond="has_none_count"><h1>Ningún comentario</h1></jv-if>
     <jv-if cond="has_singular_count"><h1>1 comentario</h1></jv-if>
     <jv-if cond="has_plural_count"><h1><jv>count</jv> comentarios</h1></jv-if>
+    <jv-if cond="post_reactions"><div class="commentReactions"><jv-for items="post_reactions" item="reaction"><button type="button" class="jtReaction" data-comment-id="" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     <jv-for items="comments" item="comment">
-        <div class="commentByline">Por <jv>comment.author</jv> el <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
-        <div class="commentText><jv html>comment.text</jv></div>
+        <div class="commentByline">Por <jv>comment.author</jv><jv-if cond="comment.verified"> <span class="commentVerified" title="Verificado">&#x2713;</span></jv-if><jv-if cond="comment.badge"> <span class="commentBadge"><jv>comment.badge</jv></span></jv-if> el <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
+        <div class="commentText" jv-data-comment-id="comment.id"><jv html>comment.text</jv></div>
+        <jv-if cond="comment.editable"><div class="commentActions"><a href="javascript:0" class="jtEditComment" jv-data-comment-id="comment.id">Editar</a> <a href="javascript:0" class="jtDeleteComment" jv-data-comment-id="comment.id">Borrar</a></div></jv-if>
+        <jv-if cond="comment.reactions"><div class="commentReactions"><jv-for items="comment.reactions" item="reaction"><button type="button" class="jtReaction" jv-data-comment-id="comment.id" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     </jv-for>
     <jv-if cond="can_add_comment">
         <form id="commentform">
             <h1>¿Quieres escribir un comentario?</h1>
             <div class="commentForm">
-                <div>Tu nombre o sobrenombre: <input type="text" name="author"> (se publicará)</div>
+                <jv-if cond="signed_in"><div class="commentIdentity">Comentas como <jv>identity.Name</jv>. <a href="javascript:0" id="jtSignOut">Salir</a></div></jv-if>
+                <jv-if cond="can_subscribe"><div class="commentIdentity"><label><input type="checkbox" id="jtSubscribe" jv-checked="subscribed"> Avísame por correo cuando se publiquen nuevos comentarios aquí</label></div></jv-if>
+                <jv-if not cond="signed_in"><div>Tu nombre o sobrenombre: <input type="text" name="author"> (se publicará)</div></jv-if>
+                <jv-if cond="can_verify"><div class="commentIdentity">Opcional: tu dirección de correo <input type="email" name="email"> <input type="button" value="Verificar" id="jtVerifyButton"> (no se publicará; verifícala para tener una insignia y editar tus comentarios)</div></jv-if>
                 <div>Tu comentario:</div>
                 <textarea name="text" id="jtComment"></textarea>
                 <div id="jtPreviewContainer"><div id="jtPreviewBox"></div></div>
@@ -349,22 +399,64 @@
     }
 
     class UserApi {
-        async list(postId) {
-            return post('/list', { 'PostId': postId });
+        async list(postId, client) {
+            return post('/list', { 'PostId': postId, 'Client': client });
         }
         async add(newComment) {
             return post('/add', newComment);
         }
+        async counts(postIds) {
+            return post('/counts', { 'PostIds': postIds });
+        }
         async render(text) {
             return post('/render', { 'Text': text });
         }
+        async identity() {
+            return post('/identity', {});
+        }
+        async requestVerification(email, name, returnUri, language) {
+            let params = {
+                'Email': email,
+                'Name': name,
+                'ReturnUri': returnUri,
+                'Language': language,
+            };
+            await post('/requestVerification', params);
+        }
+        async signOut() {
+            await post('/signOut', {});
+        }
+        async editOwn(postId, commentId, text) {
+            return post('/editOwn', { 'PostId': postId, 'CommentId': commentId, 'Text': text });
+        }
+        async deleteOwn(postId, commentId) {
+            await post('/deleteOwn', { 'PostId': postId, 'CommentId': commentId });
+        }
+        async subscribe(postId, pageUri, language) {
+            await post('/subscribe', { 'PostId': postId, 'PageUri': pageUri, 'Language': language });
+        }
+        async unsubscribe(postId) {
+            await post('/unsubscribe', { 'PostId': postId });
+        }
+        async react(postId, commentId, reaction, add, client) {
+            await post('/react', { 'PostId': postId, 'CommentId': commentId, 'Reaction': reaction, 'Add': add, 'Client': client });
+        }
     }
     var Sort;
     (function (Sort) {
         Sort[Sort["NewestFirst"] = 0] = "NewestFirst";
+        Sort[Sort["OldestFirst"] = 1] = "OldestFirst";
+        Sort[Sort["ByPost"] = 2] = "ByPost";
     })(Sort || (Sort = {}));
-    async function post(url, data) {
-        let response = await fetch(apiUrl + url, { method: 'POST', mode: 'cors', body: JSON.stringify(data) });
+    var NotificationKind;
+    (function (NotificationKind) {
+        NotificationKind[NotificationKind["Admin"] = 0] = "Admin";
+        NotificationKind[NotificationKind["Subscriber"] = 1] = "Subscriber";
+    })(NotificationKind || (NotificationKind = {}));
+    async function post(url, data, headers = {}) {
+        // The server only accepts JSON requests, so that other sites can't make them with a form.
+        headers['Content-Type'] = 'application/json';
+        let response = await fetch(apiUrl + url, { method: 'POST', mode: 'cors', credentials: 'include', headers: headers, body: JSON.stringify(data) });
         if (response.status != 200) {
             throw `Error ${response.status}: ${await response.text()}`;
         }
@@ -384,14 +476,75 @@
         return baseUrl.toString();
     })();
 
+    // <jt-comment-count post-id="..."></jt-comment-count> shows the number of published comments for a post.
+    // All the counts in a page are retrieved with a single request.
+    class JtCommentCountElement extends HTMLElement {
+        connectedCallback() {
+            let postId = this.getAttribute('post-id');
+            if (postId !== null)
+                requestCount(postId, this);
+        }
+    }
+    let pending = new Map();
+    function requestCount(postId, element) {
+        if (pending.size == 0)
+            setTimeout(fetchCounts, 0);
+        let elements = pending.get(postId);
+        if (elements) {
+            elements.push(element);
+        }
+        else {
+            pending.set(postId, [element]);
+        }
+    }
+    async function fetchCounts() {
+        let requested = pending;
+        pending = new Map();
+        let counts = await new UserApi().counts([...requested.keys()]);
+        for (let [postId, elements] of requested) {
+            let count = counts[postId];
+            for (let element of elements) {
+                if (count === undefined) {
+                    // Comments are disabled for this post.
+                    element.remove();
+                    continue;
+                }
+                element.textContent = String(count);
+                element.setAttribute('count', String(count));
+            }
+        }
+    }
+    function defineCommentCount() {
+        customElements.define('jt-comment-count', JtCommentCountElement);
+    }
+
     const AnchorPrefix = 'comment_';
+    const ClientTokenKey = 'jtcomments_client';
+    // Returns a random token that identifies this browser, so that each reader's reactions are counted only once.
+    function getClientToken() {
+        try {
+            let token = localStorage.getItem(ClientTokenKey);
+            if (!token) {
+                token = crypto.randomUUID();
+                localStorage.setItem(ClientTokenKey, token);
+            }
+            return token;
+        }
+        catch {
+            // Local storage is not available.
+            return '';
+        }
+    }
     class JtCommentsElement extends HTMLElement {
         api;
         postId;
         allTemplate;
+        comments;
+        client;
         constructor() {
             super();
             this.api = new UserApi();
+            this.client = getClientToken();
         }
         connectedCallback() {
             this.postId = this.getAttribute('post-id');
@@ -399,25 +552,42 @@
             this.refresh();
         }
         async refresh() {
+            if (this.postId === null)
+                return;
+            // Any comments that were rendered into the page stay in place until the fresh list arrives.
+            let [comments, identity] = await Promise.all([this.api.list(this.postId, this.client), this.getIdentity()]);
             while (this.firstChild != null) {
                 this.removeChild(this.firstChild);
             }
-            if (this.postId === null)
-                return;
-            this.render(await this.api.list(this.postId));
+            this.render(comments, identity);
         }
-        render(comments) {
+        async getIdentity() {
+            try {
+                return await this.api.identity();
+            }
+            catch {
+                // Commenter identities are not enabled on the server.
+                return undefined;
+            }
+        }
+        render(comments, identity) {
             if (!comments.Config.IsReadable || (!comments.Config.IsWritable && comments.List.length == 0)) {
                 this.remove();
                 return;
             }
+            this.comments = comments.List;
             let numComments = comments.List.length;
             let renderedComments = comments.List.map(c => ({
+                id: c.Id,
                 author: c.Author,
                 when: c.When,
                 url: new URL('#' + AnchorPrefix + c.Id, window.location.toString()).toString(),
                 anchor: AnchorPrefix + c.Id,
                 text: c.Text,
+                verified: c.Verified,
+                badge: c.Badge,
+                editable: c.Editable,
+                reactions: c.Reactions,
             }));
             let block = this.allTemplate.cloneNode(true);
             applyTemplate(block, {
@@ -428,10 +598,18 @@
                 'might_have_comments': comments.Config.IsReadable && (comments.Config.IsWritable || comments.List.length > 0),
                 'count': String(numComments),
                 'comments': renderedComments,
+                'signed_in': Boolean(identity),
+                'can_verify': identity === null,
+                'identity': identity,
+                'can_subscribe': Boolean(identity) && comments.Subscribed !== undefined,
+                'subscribed': Boolean(comments.Subscribed),
+                'post_reactions': comments.Reactions,
+                'cannot_react': !comments.Config.IsWritable,
             });
             if (comments.Config.IsWritable) {
                 this.attachFormEvents(block);
             }
+            this.attachCommentEvents(block);
             this.appendChild(block);
             if (window.location.hash.startsWith('#' + AnchorPrefix)) {
                 let anchor = window.location.hash.substring(1);
@@ -458,6 +636,107 @@
                     api: this.api
                 });
             }
+            form.querySelector('#jtVerifyButton')?.addEventListener('click', e => {
+                this.requestVerification(e.target.closest('form'));
+            });
+            form.querySelector('#jtSignOut')?.addEventListener('click', async (e) => {
+                e.preventDefault();
+                await this.api.signOut();
+                this.refresh();
+            });
+            form.querySelector('#jtSubscribe')?.addEventListener('change', e => {
+                this.setSubscribed(e.target);
+            });
+        }
+        async setSubscribed(checkbox) {
+            try {
+                if (checkbox.checked) {
+                    let pageUri = new URL(window.location.toString());
+                    pageUri.hash = '';
+                    await this.api.subscribe(this.postId, pageUri.toString(), getLanguage());
+                }
+                else {
+                    await this.api.unsubscribe(this.postId);
+                }
+            }
+            catch {
+                checkbox.checked = !checkbox.checked;
+                this.showMessage(checkbox.closest('form'), MessageType.ErrorSubscribing);
+            }
+        }
+        attachCommentEvents(block) {
+            block.querySelectorAll('.jtEditComment').forEach(link => link.addEventListener('click', e => {
+                e.preventDefault();
+                this.editComment(block, link.getAttribute('data-comment-id'));
+            }));
+            block.querySelectorAll('.jtDeleteComment').forEach(link => link.addEventListener('click', e => {
+                e.preventDefault();
+                this.deleteComment(link.getAttribute('data-comment-id'));
+            }));
+            block.querySelectorAll('.jtReaction').forEach(button => button.addEventListener('click', e => {
+                e.preventDefault();
+                this.react(button);
+            }));
+        }
+        async react(button) {
+            try {
+                await this.api.react(this.postId, button.getAttribute('data-comment-id') || '', button.getAttribute('data-reaction'), !button.hasAttribute('data-mine'), this.client);
+                this.refresh();
+            }
+            catch {
+                alert(getMessage(MessageType.ErrorReacting));
+            }
+        }
+        editComment(block, commentId) {
+            let comment = this.comments.find(c => c.Id == commentId);
+            let textBox = block.querySelector(`.commentText[data-comment-id="${commentId}"]`);
+            if (!comment || !textBox)
+                return;
+            let editor = document.createElement('div');
+            editor.classList.add('commentEditor');
+            let input = document.createElement('textarea');
+            input.value = comment.Source || '';
+            let save = document.createElement('input');
+            save.type = 'button';
+            save.value = getMessage(MessageType.SaveComment);
+            let cancel = document.createElement('input');
+            cancel.type = 'button';
+            cancel.value = getMessage(MessageType.CancelEdit);
+            editor.append(input, save, cancel);
+            textBox.replaceWith(editor);
+            cancel.addEventListener('click', _ => editor.replaceWith(textBox));
+            save.addEventListener('click', async (_) => {
+                try {
+                    await this.api.editOwn(this.postId, commentId, input.value);
+                    this.refresh();
+                }
+                catch {
+                    this.showMessage(editor, MessageType.ErrorModifyingComment);
+                }
+            });
+        }
+        async deleteComment(commentId) {
+            if (!confirm(getMessage(MessageType.ConfirmDeleteComment)))
+                return;
+            try {
+                await this.api.deleteOwn(this.postId, commentId);
+                this.refresh();
+            }
+            catch {
+                alert(getMessage(MessageType.ErrorModifyingComment));
+            }
+        }
+        async requestVerification(form) {
+            let msg;
+            let formData = new FormData(form);
+            try {
+                await this.api.requestVerification(formData.get('email'), formData.get('author'), window.location.toString(), getLanguage());
+                msg = MessageType.VerificationSent;
+            }
+            catch {
+                msg = MessageType.ErrorSendingVerification;
+            }
+            this.showMessage(form, msg);
         }
         async submitComment(form) {
             let msg;
@@ -478,10 +757,13 @@
             catch {
                 msg = MessageType.ErrorPostingComment;
             }
+            this.showMessage(form, msg);
+        }
+        showMessage(element, msg) {
             let p = document.createElement('p');
             p.classList.add("jtSubmitMessage");
             p.textContent = getMessage(msg);
-            form.insertAdjacentElement("beforebegin", p);
+            element.insertAdjacentElement("beforebegin", p);
             p.scrollIntoView();
         }
         getTemplate() {
@@ -495,6 +777,9 @@
             return template.content;
         }
     }
-    window.addEventListener('DOMContentLoaded', _ => customElements.define('jt-comments', JtCommentsElement));
+    window.addEventListener('DOMContentLoaded', _ => {
+        customElements.define('jt-comments', JtCommentsElement);
+        defineCommentCount();
+    });
 
 })();
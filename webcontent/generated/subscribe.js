@@ -0,0 +1,49 @@
+(function () {
+    'use strict';
+
+    // Submits newsletter subscription forms without leaving the page.
+    //
+    // A subscription form is a <form class="jt-subscribe"> that posts to the server's /newsletter/subscribe.
+    // The form works without this script too, but then the browser shows the result in a new page.
+    // The result is shown in the form's .jt-subscribe-message element, if there is one.
+    async function submit(form) {
+        let message = form.querySelector('.jt-subscribe-message');
+        let buttons = form.querySelectorAll('button');
+        buttons.forEach(b => b.disabled = true);
+        try {
+            let response = await fetch(form.action, {
+                method: 'POST',
+                mode: 'cors',
+                headers: { 'Accept': 'application/json' },
+                body: new URLSearchParams(new FormData(form)),
+            });
+            let result = await response.json();
+            if (message)
+                message.textContent = result.Message;
+            if (response.ok)
+                form.reset();
+        }
+        catch {
+            if (message)
+                message.textContent = 'Error';
+        }
+        finally {
+            buttons.forEach(b => b.disabled = false);
+        }
+    }
+    function setUpForms() {
+        document.querySelectorAll('form.jt-subscribe').forEach(form => {
+            form.addEventListener('submit', e => {
+                e.preventDefault();
+                submit(form);
+            });
+        });
+    }
+    if (document.readyState == 'loading') {
+        document.addEventListener('DOMContentLoaded', setUpForms);
+    }
+    else {
+        setUpForms();
+    }
+
+})();
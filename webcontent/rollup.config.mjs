@@ -24,5 +24,17 @@ export default [
                 target: 'esnext',
             }
         })],
+    },
+    {
+        input: 'ts/subscribe.ts',
+        output: {
+            file: 'generated/subscribe.js',
+            format: 'iife',
+        },
+        plugins: [typescript({
+            compilerOptions: {
+                target: 'esnext',
+            }
+        })],
     }
 ]
\ No newline at end of file
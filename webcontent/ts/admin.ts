@@ -1,6 +1,9 @@
+import { doAdminAudit } from './admin_audit';
 import { doAdminComments } from './admin_comments';
 import { doMailApprovals } from './admin_mail';
+import { doAdminNotifications } from './admin_notifications';
 import { doAdminPosts } from './admin_posts';
+import { AdminApi, getCsrfToken } from './api';
 import { initTabs } from './tabs';
 
 window.addEventListener('load', _ => doAdmin());
@@ -11,4 +14,27 @@ async function doAdmin() {
     initTabs('tabTitle', 'tabPage');
     doAdminComments(document.getElementById('tabPage-0')!);
     doAdminPosts(document.getElementById('tabPage-1')!);
+    doAdminNotifications(document.getElementById('tabPage-2')!);
+    doAdminAudit(document.getElementById('tabPage-3')!);
+    showAdmin();
+}
+
+// Shows who is signed in, and hides the controls that need a role they don't have.
+async function showAdmin() {
+    let api = new AdminApi();
+    let admin = await api.whoami();
+    document.getElementById('adminName')!.textContent = `${admin.Name} (${admin.Role})`;
+    if (admin.Role != 'admin') {
+        for (let element of document.querySelectorAll('[data-role=admin]')) {
+            (element as HTMLElement).hidden = true;
+        }
+    }
+    if (getCsrfToken() !== undefined) {
+        let signOut = document.getElementById('signOut')!;
+        signOut.hidden = false;
+        signOut.addEventListener('click', async _ => {
+            await api.signOut();
+            window.location.href = 'login.html';
+        });
+    }
 }
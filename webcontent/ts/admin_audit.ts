@@ -0,0 +1,45 @@
+import { AdminPage } from './adminpage';
+import { AdminApi, AuditEntry, AuditFilter, FoundAuditEntries } from './api';
+
+export function doAdminAudit(rootElement: HTMLElement) {
+    new AdminAudit(rootElement);
+}
+
+class AdminAudit extends AdminPage<AuditEntry, AuditFilter> {
+    constructor(root: HTMLElement) {
+        super(root);
+        this.api = new AdminApi();
+        this.loadList();
+    }
+
+    api: AdminApi;
+
+    protected getFilter(): AuditFilter {
+        let form = this.root.querySelector('#filters');
+        if (!form) throw "Could not find filters box";
+        return {
+            PostId: (form.querySelector('[name=PostId]') as HTMLInputElement).value.trim(),
+            Actor: (form.querySelector('[name=Actor]') as HTMLInputElement).value.trim(),
+        };
+    }
+
+    protected getFilterTitle(filter: AuditFilter): string {
+        return 'Audit log';
+    }
+
+    protected getItems(filter: AuditFilter, itemsPerPage: number, cursor: string): Promise<FoundAuditEntries> {
+        return this.api.findAuditEntries(filter, itemsPerPage, cursor);
+    }
+
+    protected getRowContents(item: AuditEntry): string[] {
+        return [
+            item.When,
+            item.Actor,
+            item.Action,
+            item.PostId,
+            item.CommentId,
+            item.OldState,
+            item.NewState
+        ];
+    }
+}
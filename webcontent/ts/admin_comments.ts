@@ -12,38 +12,60 @@ class AdminComments extends AdminPage<RawComment, CommentFilter> {
         this.wireEvent('input[name=MakeVisible]', 'click', _ => this.changeVisible(true));
         this.wireEvent('input[name=MakeNonVisible]', 'click', _ => this.changeVisible(false));
         this.wireEvent('input[name=Delete]', 'click', _ => this.deleteComments());
-        this.loadList(0);
+        this.wireEvent('input[name=Restore]', 'click', _ => this.restoreComments());
+        this.loadList();
     }
 
     api: AdminApi;
 
     protected getFilter(): CommentFilter {
-        let out: CommentFilter = { Visible: null };
-        let form = this.root.querySelector('#filters');
-        if (!form) throw "Could not find filters box";
+        let out: CommentFilter = { Visible: null, PostId: '', Author: '', NotBefore: null, NotAfter: null, Text: '', Deleted: false };
+        let form = this.getFiltersElement();
         let visible = (form.querySelector('[name=Visible]') as HTMLSelectElement).value;
         if (visible == 'true') out.Visible = true;
         if (visible == 'false') out.Visible = false;
+        if (visible == 'deleted') out.Deleted = true;
+        out.PostId = (form.querySelector('[name=PostId]') as HTMLInputElement).value.trim();
+        out.Author = (form.querySelector('[name=Author]') as HTMLInputElement).value.trim();
+        out.Text = (form.querySelector('[name=Text]') as HTMLInputElement).value.trim();
+        let notBefore = (form.querySelector('[name=NotBefore]') as HTMLInputElement).value;
+        if (notBefore) out.NotBefore = new Date(notBefore).toISOString();
+        let notAfter = (form.querySelector('[name=NotAfter]') as HTMLInputElement).value;
+        if (notAfter) {
+            // The filter excludes the end date, but the form includes it.
+            let date = new Date(notAfter);
+            date.setUTCDate(date.getUTCDate() + 1);
+            out.NotAfter = date.toISOString();
+        }
         return out;
     }
 
     protected getFilterTitle(filter: CommentFilter): string {
-        if (filter.Visible === null) {
-            return 'Latest comments';
+        if (filter.Deleted) {
+            return 'Deleted comments';
+        } else if (filter.Visible === null) {
+            return 'Comments';
         } else if (filter.Visible) {
-            return 'Latest visible comments';
+            return 'Visible comments';
         } else {
-            return 'Latest non-visible comments';
+            return 'Non-visible comments';
         }
     }
 
-    protected getItems(filter: CommentFilter, itemsPerPage: number, start: number): Promise<FoundComments> {
-        return this.api.findComments(filter, Sort.NewestFirst, itemsPerPage, start);
+    protected getItems(filter: CommentFilter, itemsPerPage: number, cursor: string): Promise<FoundComments> {
+        let sort = Number((this.getFiltersElement().querySelector('[name=Sort]') as HTMLSelectElement).value) as Sort;
+        return this.api.findComments(filter, sort, itemsPerPage, cursor);
+    }
+
+    private getFiltersElement(): Element {
+        let form = this.root.querySelector('#filters');
+        if (!form) throw "Could not find filters box";
+        return form;
     }
 
     protected getRowContents(item: RawComment): string[] {
         return [
-            item.Visible ? 'Yes' : 'No',
+            item.Deleted ? 'Deleted' : item.Visible ? 'Yes' : 'No',
             item.PostId,
             item.Author,
             item.When,
@@ -55,14 +77,21 @@ class AdminComments extends AdminPage<RawComment, CommentFilter> {
         let ids = this.gatherSelectedIds();
         if (ids.size == 0) return;
         await this.api.bulkSetVisible(ids, visible);
-        this.loadList(0);
+        this.loadList();
     }
 
     private async deleteComments() {
         let ids = this.gatherSelectedIds();
         if (ids.size == 0) return;
         await this.api.deleteComments(ids);
-        this.loadList(0);
+        this.loadList();
+    }
+
+    private async restoreComments() {
+        let ids = this.gatherSelectedIds();
+        if (ids.size == 0) return;
+        await this.api.restoreComments(ids);
+        this.loadList();
     }
 
     private gatherSelectedIds(): Map<string, string[]> {
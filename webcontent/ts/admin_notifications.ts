@@ -0,0 +1,76 @@
+import { AdminPage, ItemList } from './adminpage';
+import { AdminApi, Notification, NotificationFilter, NotificationKind } from './api';
+
+export function doAdminNotifications(rootElement: HTMLElement) {
+    new AdminNotifications(rootElement);
+}
+
+class AdminNotifications extends AdminPage<Notification, NotificationFilter> {
+    constructor(root: HTMLElement) {
+        super(root);
+        this.api = new AdminApi();
+        this.wireEvent('input[name=Retry]', 'click', _ => this.retryNotifications());
+        this.wireEvent('input[name=Delete]', 'click', _ => this.deleteNotifications());
+        this.loadList();
+    }
+
+    api: AdminApi;
+
+    protected getFilter(): NotificationFilter {
+        let out: NotificationFilter = { Failed: null };
+        let form = this.root.querySelector('#filters');
+        if (!form) throw "Could not find filters box";
+        let status = (form.querySelector('[name=Status]') as HTMLSelectElement).value;
+        if (status == 'failed') out.Failed = true;
+        if (status == 'pending') out.Failed = false;
+        return out;
+    }
+
+    protected getFilterTitle(filter: NotificationFilter): string {
+        if (filter.Failed === null) {
+            return 'Queued notifications';
+        } else if (filter.Failed) {
+            return 'Failed notifications';
+        } else {
+            return 'Pending notifications';
+        }
+    }
+
+    protected async getItems(filter: NotificationFilter, itemsPerPage: number, cursor: string): Promise<ItemList<Notification>> {
+        // Notifications are paginated by offset; the cursor holds the offset of the page.
+        let start = Number(cursor || 0);
+        let found = await this.api.findNotifications(filter, itemsPerPage, start);
+        return { ...found, Next: String(start + itemsPerPage) };
+    }
+
+    protected getRowContents(item: Notification): string[] {
+        return [
+            item.Failed ? 'Failed' : 'Pending',
+            item.Kind == NotificationKind.Admin ? 'Admin ' + (item.Notifier + 1) : 'Subscriber ' + item.Recipient,
+            item.PostId,
+            item.CommentId,
+            item.Created,
+            String(item.Attempts),
+            item.Failed ? '' : item.NextAttempt,
+            item.LastError
+        ];
+    }
+
+    private async retryNotifications() {
+        let ids = this.gatherSelectedIds();
+        if (ids.length == 0) return;
+        await this.api.retryNotifications(ids);
+        this.loadList();
+    }
+
+    private async deleteNotifications() {
+        let ids = this.gatherSelectedIds();
+        if (ids.length == 0) return;
+        await this.api.deleteNotifications(ids);
+        this.loadList();
+    }
+
+    private gatherSelectedIds(): string[] {
+        return this.gatherSelectedItems().map(item => item.NotificationId);
+    }
+}
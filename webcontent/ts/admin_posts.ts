@@ -12,7 +12,7 @@ class AdminPosts extends AdminPage<FoundPost, PostFilter> {
         this.wireEvent('input[name=MakeOpen]', 'click', _ => this.changeState(true, true));
         this.wireEvent('input[name=MakeClosed]', 'click', _ => this.changeState(false, true));
         this.wireEvent('input[name=MakeDisabled]', 'click', _ => this.changeState(false, false));
-        this.loadList(0);
+        this.loadList();
     }
 
     private api: AdminApi;
@@ -48,8 +48,8 @@ class AdminPosts extends AdminPage<FoundPost, PostFilter> {
         }
     }
 
-    protected getItems(filter: PostFilter, itemsPerPage: number, start: number): Promise<FoundPosts> {
-        return this.api.findPosts(filter, Sort.NewestFirst, itemsPerPage, start);
+    protected getItems(filter: PostFilter, itemsPerPage: number, cursor: string): Promise<FoundPosts> {
+        return this.api.findPosts(filter, Sort.NewestFirst, itemsPerPage, cursor);
     }
 
     protected getRowContents(item: FoundPost): string[] {
@@ -62,7 +62,7 @@ class AdminPosts extends AdminPage<FoundPost, PostFilter> {
     private async changeState(writable: boolean, readable: boolean) {
         let ids = this.gatherSelectedIds();
         await this.api.bulkUpdatePostConfigs(ids, writable, readable);
-        this.loadList(0);
+        this.loadList();
     }
 
     private gatherSelectedIds(): string[] {
@@ -1,11 +1,16 @@
-interface ItemList<T> {
+export interface ItemList<T> {
     List: T[],
     More: boolean,
+    Next?: string,
+    Total?: number,
 }
 
 export abstract class AdminPage<Type, Filter> {
+    // The cursors for the pages before the current one, to go back.
+    private previousCursors: string[] = [];
+
     constructor(protected root: HTMLElement) {
-        this.wireEvent('input[name=ApplyFilter]', 'click', _ => this.loadList(0));
+        this.wireEvent('input[name=ApplyFilter]', 'click', _ => this.loadList());
         let list = this.getListTableElement();
         if (list) {
             this.wireEventFromRoot(list, 'thead input[type=checkbox]', 'change', e => this.toggleSelectAll(e));
@@ -23,7 +28,7 @@ export abstract class AdminPage<Type, Filter> {
 
     protected abstract getFilter(): Filter;
     protected abstract getFilterTitle(filter: Filter): string;
-    protected abstract getItems(filter: Filter, itemsPerPage: number, start: number): Promise<ItemList<Type>>;
+    protected abstract getItems(filter: Filter, itemsPerPage: number, cursor: string): Promise<ItemList<Type>>;
     protected abstract getRowContents(item: Type): string[];
 
     protected getListNameElement(): HTMLElement | null {
@@ -57,11 +62,20 @@ export abstract class AdminPage<Type, Filter> {
         return row;
     }
 
-    async loadList(start: number) {
+    async loadList() {
+        this.previousCursors = [];
+        await this.showPage('');
+    }
+
+    private async showPage(cursor: string) {
         let filter = this.getFilter();
-        let items = await this.getItems(filter, this.getItemsPerPage(), start);
+        let items = await this.getItems(filter, this.getItemsPerPage(), cursor);
         let listName = this.getListNameElement();
-        if (listName) listName.textContent = this.getFilterTitle(filter);
+        if (listName) {
+            let title = this.getFilterTitle(filter);
+            if (items.Total !== undefined) title += ` (${items.Total})`;
+            listName.textContent = title;
+        }
         let listTable = this.getListTableElement()?.querySelector('tbody');
         if (listTable) {
             while (listTable.firstChild) listTable.firstChild.remove();
@@ -83,21 +97,23 @@ export abstract class AdminPage<Type, Filter> {
         let listLinks = this.getListLinksElement();
         if (listLinks) {
             while (listLinks.firstChild) listLinks.firstChild.remove();
-            if (items.More) {
+            if (items.More && items.Next !== undefined) {
+                let next = items.Next;
                 let link = document.createElement('a');
                 link.href = "javascript:0";
                 link.textContent = "Next";
-                link.addEventListener('click', _ => this.loadList(start + this.getItemsPerPage()));
+                link.addEventListener('click', _ => {
+                    this.previousCursors.push(cursor);
+                    this.showPage(next);
+                });
                 listLinks.appendChild(link);
-                if (items.More) {
-                    listLinks.insertAdjacentText('beforeend', ' ');
-                }
+                listLinks.insertAdjacentText('beforeend', ' ');
             }
-            if (start > 0) {
+            if (this.previousCursors.length > 0) {
                 let link = document.createElement('a');
                 link.href = "javascript:0";
                 link.textContent = "Previous";
-                link.addEventListener('click', _ => this.loadList(start - this.getItemsPerPage()));
+                link.addEventListener('click', _ => this.showPage(this.previousCursors.pop()!));
                 listLinks.appendChild(link);
             }
         }
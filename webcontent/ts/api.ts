@@ -5,6 +5,14 @@ export type Comments = {
         IsWritable: boolean,
     },
     List: Comment[],
+    Subscribed?: boolean,
+    Reactions?: ReactionCount[],
+};
+
+export type ReactionCount = {
+    Reaction: string,
+    Count: number,
+    Mine: boolean,
 };
 
 export type Comment = {
@@ -13,6 +21,17 @@ export type Comment = {
     Author: string,
     When: string,
     Text: string,
+    Verified: boolean,
+    Badge?: string,
+    Editable: boolean,
+    Source?: string,
+    Reactions?: ReactionCount[],
+};
+
+export type Identity = {
+    Id: string,
+    Name: string,
+    Badge?: string,
 };
 
 export type NewComment = {
@@ -22,25 +41,75 @@ export type NewComment = {
 }
 
 export class UserApi {
-    async list(postId: string): Promise<Comments> {
-        return post('/list', { 'PostId': postId });
+    async list(postId: string, client: string): Promise<Comments> {
+        return post('/list', { 'PostId': postId, 'Client': client });
     }
 
     async add(newComment: NewComment): Promise<Comment> {
         return post('/add', newComment);
     }
 
+    async counts(postIds: string[]): Promise<{ [postId: string]: number }> {
+        return post('/counts', { 'PostIds': postIds });
+    }
+
     async render(text: string): Promise<string> {
         return post('/render', { 'Text': text });
     }
+
+    async identity(): Promise<Identity | null> {
+        return post('/identity', {});
+    }
+
+    async requestVerification(email: string, name: string, returnUri: string, language: string) {
+        let params = {
+            'Email': email,
+            'Name': name,
+            'ReturnUri': returnUri,
+            'Language': language,
+        };
+        await post('/requestVerification', params);
+    }
+
+    async signOut() {
+        await post('/signOut', {});
+    }
+
+    async editOwn(postId: string, commentId: string, text: string): Promise<Comment> {
+        return post('/editOwn', { 'PostId': postId, 'CommentId': commentId, 'Text': text });
+    }
+
+    async deleteOwn(postId: string, commentId: string) {
+        await post('/deleteOwn', { 'PostId': postId, 'CommentId': commentId });
+    }
+
+    async subscribe(postId: string, pageUri: string, language: string) {
+        await post('/subscribe', { 'PostId': postId, 'PageUri': pageUri, 'Language': language });
+    }
+
+    async unsubscribe(postId: string) {
+        await post('/unsubscribe', { 'PostId': postId });
+    }
+
+    async react(postId: string, commentId: string, reaction: string, add: boolean, client: string) {
+        await post('/react', { 'PostId': postId, 'CommentId': commentId, 'Reaction': reaction, 'Add': add, 'Client': client });
+    }
 }
 
 export type CommentFilter = {
     Visible: boolean | null,
+    PostId: string,
+    Author: string,
+    NotBefore: string | null,
+    NotAfter: string | null,
+    Text: string,
+    Deleted: boolean,
 }
 
 export enum Sort {
     NewestFirst,
+    OldestFirst,
+    ByPost,
 }
 
 export type RawComment = {
@@ -50,11 +119,14 @@ export type RawComment = {
     Author: string,
     When: string,
     Text: string,
+    Deleted?: string,
 }
 
 export type FoundComments = {
     List: RawComment[],
     More: boolean,
+    Next?: string,
+    Total: number,
 }
 
 export type PostFilter = {
@@ -65,6 +137,8 @@ export type PostFilter = {
 export type FoundPosts = {
     List: FoundPost[],
     More: boolean,
+    Next?: string,
+    Total: number,
 }
 
 export type FoundPost = {
@@ -77,32 +151,94 @@ export type CommentConfig = {
     IsWritable: boolean,
 }
 
+export enum NotificationKind {
+    Admin,
+    Subscriber,
+}
+
+export type NotificationFilter = {
+    Failed: boolean | null,
+}
+
+export type Notification = {
+    NotificationId: string,
+    Kind: NotificationKind,
+    PostId: string,
+    CommentId: string,
+    Recipient: string,
+    Notifier: number,
+    Created: string,
+    Attempts: number,
+    NextAttempt: string,
+    LastError: string,
+    Failed: boolean,
+}
+
+export type FoundNotifications = {
+    List: Notification[],
+    More: boolean,
+}
+
+export type AuditFilter = {
+    PostId: string,
+    Actor: string,
+}
+
+export type AuditEntry = {
+    AuditId: string,
+    When: string,
+    Actor: string,
+    Action: string,
+    PostId: string,
+    CommentId: string,
+    OldState: string,
+    NewState: string,
+}
+
+export type FoundAuditEntries = {
+    List: AuditEntry[],
+    More: boolean,
+    Next?: string,
+}
+
+export type Admin = {
+    Name: string,
+    Role: string,
+}
+
 export class AdminApi {
-    async findComments(filter: CommentFilter, sort: Sort, limit: number, start: number): Promise<FoundComments> {
+    async findComments(filter: CommentFilter, sort: Sort, limit: number, cursor: string): Promise<FoundComments> {
         let params = {
             'Filter': filter,
             'Sort': sort,
             'Limit': limit,
-            'Start': start,
+            'Cursor': cursor,
         };
-        return post('/findComments', params);
+        return adminPost('/findComments', params);
     }
 
     async deleteComments(ids: Map<string, string[]>) {
         let params = {
             'Ids': Object.fromEntries(ids)
         };
-        await post('/deleteComments', params);
+        await adminPost('/deleteComments', params);
     }
 
-    async findPosts(filter: PostFilter, sort: Sort, limit: number, start: number): Promise<FoundPosts> {
+    async restoreComments(ids: Map<string, string[]>) {
+        let params = {
+            'Ids': Object.fromEntries(ids)
+        };
+        await adminPost('/restoreComments', params);
+    }
+
+    async findPosts(filter: PostFilter, sort: Sort, limit: number, cursor: string): Promise<FoundPosts> {
         let params = {
             'Filter': filter,
             'Sort': sort,
             'Limit': limit,
-            'Start': start,
+            'Cursor': cursor,
         };
-        return post('/findPosts', params);
+        return adminPost('/findPosts', params);
     }
 
     async bulkSetVisible(ids: Map<string, string[]>, visible: boolean) {
@@ -110,7 +246,7 @@ export class AdminApi {
             'Ids': Object.fromEntries(ids),
             'Visible': visible
         };
-        await post('/bulkSetVisible', params);
+        await adminPost('/bulkSetVisible', params);
     }
 
     async bulkUpdatePostConfigs(postIds: string[], writable: boolean, readable: boolean) {
@@ -121,18 +257,71 @@ export class AdminApi {
                 'IsReadable': readable,
             },
         }
-        await post('/bulkUpdatePostConfigs', params);
+        await adminPost('/bulkUpdatePostConfigs', params);
+    }
+
+    async findNotifications(filter: NotificationFilter, limit: number, start: number): Promise<FoundNotifications> {
+        let params = {
+            'Filter': filter,
+            'Limit': limit,
+            'Start': start,
+        };
+        return adminPost('/findNotifications', params);
+    }
+
+    async retryNotifications(ids: string[]) {
+        await adminPost('/retryNotifications', { 'Ids': ids });
+    }
+
+    async deleteNotifications(ids: string[]) {
+        await adminPost('/deleteNotifications', { 'Ids': ids });
+    }
+
+    async findAuditEntries(filter: AuditFilter, limit: number, cursor: string): Promise<FoundAuditEntries> {
+        let params = {
+            'Filter': filter,
+            'Limit': limit,
+            'Cursor': cursor,
+        };
+        return adminPost('/findAuditEntries', params);
+    }
+
+    async whoami(): Promise<Admin> {
+        return adminPost('/whoami', {});
+    }
+
+    async signOut() {
+        await adminPost('/signOutAdmin', {});
     }
 }
 
-async function post<R, M>(url: string, data: M): Promise<R> {
-    let response = await fetch(apiUrl + url, { method: 'POST', mode: 'cors', body: JSON.stringify(data) });
+async function post<R, M>(url: string, data: M, headers: Record<string, string> = {}): Promise<R> {
+    // The server only accepts JSON requests, so that other sites can't make them with a form.
+    headers['Content-Type'] = 'application/json';
+    let response = await fetch(apiUrl + url, { method: 'POST', mode: 'cors', credentials: 'include', headers: headers, body: JSON.stringify(data) });
     if (response.status != 200) {
         throw `Error ${response.status}: ${await response.text()}`;
     }
     return response.json();
 }
 
+// Returns the CSRF token for administrators who signed in with a password.
+export function getCsrfToken(): string | undefined {
+    for (let cookie of document.cookie.split(';')) {
+        let [name, value] = cookie.trim().split('=');
+        if (name == 'jtcomments_csrf') return value;
+    }
+    return undefined;
+}
+
+// Administrators who signed in with a password must send back the CSRF token from their cookie.
+async function adminPost<R, M>(url: string, data: M): Promise<R> {
+    let headers: Record<string, string> = {};
+    let token = getCsrfToken();
+    if (token !== undefined) headers['X-CSRF-Token'] = token;
+    return post(url, data, headers);
+}
+
 const apiUrl = (() => {
     let scripts = document.getElementsByTagName('script');
     let baseUrl = new URL(scripts[scripts.length - 1].attributes['src'].value, window.location.toString());
@@ -1,18 +1,38 @@
 import applyTemplate from "./templates";
 import * as Lang from "./languages";
 import * as Preview from "./preview";
-import { Comments, UserApi } from "./api";
+import { Comment, Comments, Identity, UserApi } from "./api";
+import { defineCommentCount } from "./counts";
 
 const AnchorPrefix = 'comment_';
+const ClientTokenKey = 'jtcomments_client';
+
+// Returns a random token that identifies this browser, so that each reader's reactions are counted only once.
+function getClientToken(): string {
+    try {
+        let token = localStorage.getItem(ClientTokenKey);
+        if (!token) {
+            token = crypto.randomUUID();
+            localStorage.setItem(ClientTokenKey, token);
+        }
+        return token;
+    } catch {
+        // Local storage is not available.
+        return '';
+    }
+}
 
 class JtCommentsElement extends HTMLElement {
     private api: UserApi;
     private postId: string | null;
     private allTemplate: DocumentFragment;
+    private comments: Comment[];
+    private client: string;
 
     constructor() {
         super();
         this.api = new UserApi();
+        this.client = getClientToken();
     }
 
     connectedCallback() {
@@ -22,26 +42,43 @@ class JtCommentsElement extends HTMLElement {
     }
 
     private async refresh() {
+        if (this.postId === null) return;
+        // Any comments that were rendered into the page stay in place until the fresh list arrives.
+        let [comments, identity] = await Promise.all([this.api.list(this.postId, this.client), this.getIdentity()]);
         while (this.firstChild != null) {
             this.removeChild(this.firstChild);
         }
-        if (this.postId === null) return;
-        this.render(await this.api.list(this.postId));
+        this.render(comments, identity);
+    }
+
+    private async getIdentity(): Promise<Identity | null | undefined> {
+        try {
+            return await this.api.identity();
+        } catch {
+            // Commenter identities are not enabled on the server.
+            return undefined;
+        }
     }
 
-    private render(comments: Comments) {
+    private render(comments: Comments, identity: Identity | null | undefined) {
         if (!comments.Config.IsReadable || (!comments.Config.IsWritable && comments.List.length == 0)) {
             this.remove();
             return;
         }
 
+        this.comments = comments.List;
         let numComments = comments.List.length;
         let renderedComments = comments.List.map(c => ({
+            id: c.Id,
             author: c.Author,
             when: c.When,
             url: new URL('#' + AnchorPrefix + c.Id, window.location.toString()).toString(),
             anchor: AnchorPrefix + c.Id,
             text: c.Text,
+            verified: c.Verified,
+            badge: c.Badge,
+            editable: c.Editable,
+            reactions: c.Reactions,
         }));
         let block = this.allTemplate.cloneNode(true) as Element;
         applyTemplate(block, {
@@ -52,10 +89,18 @@ class JtCommentsElement extends HTMLElement {
             'might_have_comments': comments.Config.IsReadable && (comments.Config.IsWritable || comments.List.length > 0),
             'count': String(numComments),
             'comments': renderedComments,
+            'signed_in': Boolean(identity),
+            'can_verify': identity === null,
+            'identity': identity,
+            'can_subscribe': Boolean(identity) && comments.Subscribed !== undefined,
+            'subscribed': Boolean(comments.Subscribed),
+            'post_reactions': comments.Reactions,
+            'cannot_react': !comments.Config.IsWritable,
         });
         if (comments.Config.IsWritable) {
             this.attachFormEvents(block);
         }
+        this.attachCommentEvents(block);
         this.appendChild(block);
 
         if (window.location.hash.startsWith('#' + AnchorPrefix)) {
@@ -83,6 +128,114 @@ class JtCommentsElement extends HTMLElement {
                 api: this.api
             });
         }
+        form.querySelector('#jtVerifyButton')?.addEventListener('click', e => {
+            this.requestVerification((e.target as HTMLElement).closest('form') as HTMLFormElement);
+        });
+        form.querySelector('#jtSignOut')?.addEventListener('click', async e => {
+            e.preventDefault();
+            await this.api.signOut();
+            this.refresh();
+        });
+        form.querySelector('#jtSubscribe')?.addEventListener('change', e => {
+            this.setSubscribed(e.target as HTMLInputElement);
+        });
+    }
+
+    private async setSubscribed(checkbox: HTMLInputElement) {
+        try {
+            if (checkbox.checked) {
+                let pageUri = new URL(window.location.toString());
+                pageUri.hash = '';
+                await this.api.subscribe(this.postId!, pageUri.toString(), Lang.getLanguage());
+            } else {
+                await this.api.unsubscribe(this.postId!);
+            }
+        } catch {
+            checkbox.checked = !checkbox.checked;
+            this.showMessage(checkbox.closest('form')!, Lang.MessageType.ErrorSubscribing);
+        }
+    }
+
+    private attachCommentEvents(block: Element) {
+        block.querySelectorAll('.jtEditComment').forEach(link => link.addEventListener('click', e => {
+            e.preventDefault();
+            this.editComment(block, link.getAttribute('data-comment-id')!);
+        }));
+        block.querySelectorAll('.jtDeleteComment').forEach(link => link.addEventListener('click', e => {
+            e.preventDefault();
+            this.deleteComment(link.getAttribute('data-comment-id')!);
+        }));
+        block.querySelectorAll('.jtReaction').forEach(button => button.addEventListener('click', e => {
+            e.preventDefault();
+            this.react(button);
+        }));
+    }
+
+    private async react(button: Element) {
+        try {
+            await this.api.react(
+                this.postId!,
+                button.getAttribute('data-comment-id') || '',
+                button.getAttribute('data-reaction')!,
+                !button.hasAttribute('data-mine'),
+                this.client);
+            this.refresh();
+        } catch {
+            alert(Lang.getMessage(Lang.MessageType.ErrorReacting));
+        }
+    }
+
+    private editComment(block: Element, commentId: string) {
+        let comment = this.comments.find(c => c.Id == commentId);
+        let textBox = block.querySelector(`.commentText[data-comment-id="${commentId}"]`);
+        if (!comment || !textBox) return;
+        let editor = document.createElement('div');
+        editor.classList.add('commentEditor');
+        let input = document.createElement('textarea');
+        input.value = comment.Source || '';
+        let save = document.createElement('input');
+        save.type = 'button';
+        save.value = Lang.getMessage(Lang.MessageType.SaveComment);
+        let cancel = document.createElement('input');
+        cancel.type = 'button';
+        cancel.value = Lang.getMessage(Lang.MessageType.CancelEdit);
+        editor.append(input, save, cancel);
+        textBox.replaceWith(editor);
+        cancel.addEventListener('click', _ => editor.replaceWith(textBox!));
+        save.addEventListener('click', async _ => {
+            try {
+                await this.api.editOwn(this.postId!, commentId, input.value);
+                this.refresh();
+            } catch {
+                this.showMessage(editor, Lang.MessageType.ErrorModifyingComment);
+            }
+        });
+    }
+
+    private async deleteComment(commentId: string) {
+        if (!confirm(Lang.getMessage(Lang.MessageType.ConfirmDeleteComment))) return;
+        try {
+            await this.api.deleteOwn(this.postId!, commentId);
+            this.refresh();
+        } catch {
+            alert(Lang.getMessage(Lang.MessageType.ErrorModifyingComment));
+        }
+    }
+
+    private async requestVerification(form: HTMLFormElement) {
+        let msg: Lang.MessageType;
+        let formData = new FormData(form);
+        try {
+            await this.api.requestVerification(
+                formData.get('email') as string,
+                formData.get('author') as string,
+                window.location.toString(),
+                Lang.getLanguage());
+            msg = Lang.MessageType.VerificationSent;
+        } catch {
+            msg = Lang.MessageType.ErrorSendingVerification;
+        }
+        this.showMessage(form, msg);
     }
 
     private async submitComment(form: HTMLFormElement) {
@@ -103,10 +256,14 @@ class JtCommentsElement extends HTMLElement {
         } catch {
             msg = Lang.MessageType.ErrorPostingComment;
         }
+        this.showMessage(form, msg);
+    }
+
+    private showMessage(element: Element, msg: Lang.MessageType) {
         let p = document.createElement('p');
         p.classList.add("jtSubmitMessage");
         p.textContent = Lang.getMessage(msg);
-        form.insertAdjacentElement("beforebegin", p);
+        element.insertAdjacentElement("beforebegin", p);
         p.scrollIntoView();
     }
 
@@ -122,5 +279,7 @@ class JtCommentsElement extends HTMLElement {
     }
 }
 
-window.addEventListener('DOMContentLoaded', _ =>
-    customElements.define('jt-comments', JtCommentsElement));
+window.addEventListener('DOMContentLoaded', _ => {
+    customElements.define('jt-comments', JtCommentsElement);
+    defineCommentCount();
+});
@@ -0,0 +1,44 @@
+import { UserApi } from "./api";
+
+// <jt-comment-count post-id="..."></jt-comment-count> shows the number of published comments for a post.
+// All the counts in a page are retrieved with a single request.
+class JtCommentCountElement extends HTMLElement {
+    connectedCallback() {
+        let postId = this.getAttribute('post-id');
+        if (postId !== null) requestCount(postId, this);
+    }
+}
+
+let pending = new Map<string, Element[]>();
+
+function requestCount(postId: string, element: Element) {
+    if (pending.size == 0) setTimeout(fetchCounts, 0);
+    let elements = pending.get(postId);
+    if (elements) {
+        elements.push(element);
+    } else {
+        pending.set(postId, [element]);
+    }
+}
+
+async function fetchCounts() {
+    let requested = pending;
+    pending = new Map();
+    let counts = await new UserApi().counts([...requested.keys()]);
+    for (let [postId, elements] of requested) {
+        let count = counts[postId];
+        for (let element of elements) {
+            if (count === undefined) {
+                // Comments are disabled for this post.
+                element.remove();
+                continue;
+            }
+            element.textContent = String(count);
+            element.setAttribute('count', String(count));
+        }
+    }
+}
+
+export function defineCommentCount() {
+    customElements.define('jt-comment-count', JtCommentCountElement);
+}
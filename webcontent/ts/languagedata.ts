@@ -1,7 +1,14 @@
-
 export enum MessageType {
     ErrorPostingComment,
     CommentPostedAsDraft,
+    VerificationSent,
+    ErrorSendingVerification,
+    ErrorModifyingComment,
+    ConfirmDeleteComment,
+    SaveComment,
+    CancelEdit,
+    ErrorSubscribing,
+    ErrorReacting,
 }
 
 export const Messages = {
@@ -10,18 +17,66 @@ export const Messages = {
             'There was an error while submitting the comment.',
         [MessageType.CommentPostedAsDraft]:
             'Your comment was submitted and will become visible when it is approved.',
+        [MessageType.VerificationSent]:
+            'We sent you an email with a link to verify your address.',
+        [MessageType.ErrorSendingVerification]:
+            'There was an error while sending the verification email.',
+        [MessageType.ErrorModifyingComment]:
+            'There was an error while modifying the comment.',
+        [MessageType.ConfirmDeleteComment]:
+            'Do you want to delete this comment?',
+        [MessageType.SaveComment]:
+            'Save',
+        [MessageType.CancelEdit]:
+            'Cancel',
+        [MessageType.ErrorSubscribing]:
+            'There was an error while updating your subscription.',
+        [MessageType.ErrorReacting]:
+            'There was an error while saving your reaction.',
     },
     'es': {
         [MessageType.ErrorPostingComment]:
             'Hubo un error enviando el comentario.',
         [MessageType.CommentPostedAsDraft]:
             'Se ha recibido tu comentario y será publicado cuando se apruebe.',
+        [MessageType.VerificationSent]:
+            'Te hemos enviado un correo con un enlace para verificar tu dirección.',
+        [MessageType.ErrorSendingVerification]:
+            'Hubo un error enviando el correo de verificación.',
+        [MessageType.ErrorModifyingComment]:
+            'Hubo un error modificando el comentario.',
+        [MessageType.ConfirmDeleteComment]:
+            '¿Quieres borrar este comentario?',
+        [MessageType.SaveComment]:
+            'Guardar',
+        [MessageType.CancelEdit]:
+            'Cancelar',
+        [MessageType.ErrorSubscribing]:
+            'Hubo un error actualizando tu suscripción.',
+        [MessageType.ErrorReacting]:
+            'Hubo un error guardando tu reacción.',
     },
     'gl': {
         [MessageType.ErrorPostingComment]:
             'Houbo un erro ao enviar o comentario.',
         [MessageType.CommentPostedAsDraft]:
             'Recibiuse o teu comentario e vai ser publicado cando se aprobe.',
+        [MessageType.VerificationSent]:
+            'Enviámosche un correo cunha ligazón para verificar o teu enderezo.',
+        [MessageType.ErrorSendingVerification]:
+            'Houbo un erro ao enviar o correo de verificación.',
+        [MessageType.ErrorModifyingComment]:
+            'Houbo un erro ao modificar o comentario.',
+        [MessageType.ConfirmDeleteComment]:
+            'Queres borrar este comentario?',
+        [MessageType.SaveComment]:
+            'Gardar',
+        [MessageType.CancelEdit]:
+            'Cancelar',
+        [MessageType.ErrorSubscribing]:
+            'Houbo un erro ao actualizar a túa subscrición.',
+        [MessageType.ErrorReacting]:
+            'Houbo un erro ao gardar a túa reacción.',
     }
 };
 
@@ -30,15 +85,21 @@ export const Templates = {
     <jv-if cond="has_none_count"><h1>No comments</h1></jv-if>
     <jv-if cond="has_singular_count"><h1>1 comment</h1></jv-if>
     <jv-if cond="has_plural_count"><h1><jv>count</jv> comments</h1></jv-if>
+    <jv-if cond="post_reactions"><div class="commentReactions"><jv-for items="post_reactions" item="reaction"><button type="button" class="jtReaction" data-comment-id="" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     <jv-for items="comments" item="comment">
-        <div class="commentByline">By <jv>comment.author</jv> on <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
-        <div class="commentText><jv html>comment.text</jv></div>
+        <div class="commentByline">By <jv>comment.author</jv><jv-if cond="comment.verified"> <span class="commentVerified" title="Verified">&#x2713;</span></jv-if><jv-if cond="comment.badge"> <span class="commentBadge"><jv>comment.badge</jv></span></jv-if> on <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
+        <div class="commentText" jv-data-comment-id="comment.id"><jv html>comment.text</jv></div>
+        <jv-if cond="comment.editable"><div class="commentActions"><a href="javascript:0" class="jtEditComment" jv-data-comment-id="comment.id">Edit</a> <a href="javascript:0" class="jtDeleteComment" jv-data-comment-id="comment.id">Delete</a></div></jv-if>
+        <jv-if cond="comment.reactions"><div class="commentReactions"><jv-for items="comment.reactions" item="reaction"><button type="button" class="jtReaction" jv-data-comment-id="comment.id" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     </jv-for>
     <jv-if cond="can_add_comment">
         <form id="commentform">
             <h1>Would you like to write a comment?</h1>
             <div class="commentForm">
-                <div>Your name or nickname: <input type="text" name="author"> (it will be published)</div>
+                <jv-if cond="signed_in"><div class="commentIdentity">Posting as <jv>identity.Name</jv>. <a href="javascript:0" id="jtSignOut">Sign out</a></div></jv-if>
+                <jv-if cond="can_subscribe"><div class="commentIdentity"><label><input type="checkbox" id="jtSubscribe" jv-checked="subscribed"> Email me when new comments are posted here</label></div></jv-if>
+                <jv-if not cond="signed_in"><div>Your name or nickname: <input type="text" name="author"> (it will be published)</div></jv-if>
+                <jv-if cond="can_verify"><div class="commentIdentity">Optional: your email address <input type="email" name="email"> <input type="button" value="Verify" id="jtVerifyButton"> (it won't be published; verify it to get a badge and edit your comments)</div></jv-if>
                 <div>Your comment:</div>
                 <textarea name="text" id="jtComment"></textarea>
                 <div id="jtPreviewContainer"><div id="jtPreviewBox"></div></div>
@@ -53,15 +114,21 @@ export const Templates = {
     <jv-if cond="has_none_count"><h1>Ningún comentario</h1></jv-if>
     <jv-if cond="has_singular_count"><h1>1 comentario</h1></jv-if>
     <jv-if cond="has_plural_count"><h1><jv>count</jv> comentarios</h1></jv-if>
+    <jv-if cond="post_reactions"><div class="commentReactions"><jv-for items="post_reactions" item="reaction"><button type="button" class="jtReaction" data-comment-id="" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     <jv-for items="comments" item="comment">
-        <div class="commentByline">Por <jv>comment.author</jv> o <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
-        <div class="commentText><jv html>comment.text</jv></div>
+        <div class="commentByline">Por <jv>comment.author</jv><jv-if cond="comment.verified"> <span class="commentVerified" title="Verificado">&#x2713;</span></jv-if><jv-if cond="comment.badge"> <span class="commentBadge"><jv>comment.badge</jv></span></jv-if> o <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
+        <div class="commentText" jv-data-comment-id="comment.id"><jv html>comment.text</jv></div>
+        <jv-if cond="comment.editable"><div class="commentActions"><a href="javascript:0" class="jtEditComment" jv-data-comment-id="comment.id">Editar</a> <a href="javascript:0" class="jtDeleteComment" jv-data-comment-id="comment.id">Borrar</a></div></jv-if>
+        <jv-if cond="comment.reactions"><div class="commentReactions"><jv-for items="comment.reactions" item="reaction"><button type="button" class="jtReaction" jv-data-comment-id="comment.id" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     </jv-for>
     <jv-if cond="can_add_comment">
         <form id="commentform">
             <h1>Queres escribir un comentario?</h1>
             <div class="commentForm">
-                <div>O teu nome ou sobrenome: <input type="text" name="author"> (hase publicar)</div>
+                <jv-if cond="signed_in"><div class="commentIdentity">Comentas como <jv>identity.Name</jv>. <a href="javascript:0" id="jtSignOut">Saír</a></div></jv-if>
+                <jv-if cond="can_subscribe"><div class="commentIdentity"><label><input type="checkbox" id="jtSubscribe" jv-checked="subscribed"> Avísame por correo cando se publiquen novos comentarios aquí</label></div></jv-if>
+                <jv-if not cond="signed_in"><div>O teu nome ou sobrenome: <input type="text" name="author"> (hase publicar)</div></jv-if>
+                <jv-if cond="can_verify"><div class="commentIdentity">Opcional: o teu enderezo de correo <input type="email" name="email"> <input type="button" value="Verificar" id="jtVerifyButton"> (non se ha publicar; verifícao para ter unha insignia e editar os teus comentarios)</div></jv-if>
                 <div>O teu comentario:</div>
                 <textarea name="text" id="jtComment"></textarea>
                 <div id="jtPreviewContainer"><div id="jtPreviewBox"></div></div>
@@ -76,15 +143,21 @@ export const Templates = {
     <jv-if cond="has_none_count"><h1>Ningún comentario</h1></jv-if>
     <jv-if cond="has_singular_count"><h1>1 comentario</h1></jv-if>
     <jv-if cond="has_plural_count"><h1><jv>count</jv> comentarios</h1></jv-if>
+    <jv-if cond="post_reactions"><div class="commentReactions"><jv-for items="post_reactions" item="reaction"><button type="button" class="jtReaction" data-comment-id="" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     <jv-for items="comments" item="comment">
-        <div class="commentByline">Por <jv>comment.author</jv> el <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
-        <div class="commentText><jv html>comment.text</jv></div>
+        <div class="commentByline">Por <jv>comment.author</jv><jv-if cond="comment.verified"> <span class="commentVerified" title="Verificado">&#x2713;</span></jv-if><jv-if cond="comment.badge"> <span class="commentBadge"><jv>comment.badge</jv></span></jv-if> el <a jv-href="comment.url" jv-name="comment.anchor"><jv date>comment.when</jv></a></div>
+        <div class="commentText" jv-data-comment-id="comment.id"><jv html>comment.text</jv></div>
+        <jv-if cond="comment.editable"><div class="commentActions"><a href="javascript:0" class="jtEditComment" jv-data-comment-id="comment.id">Editar</a> <a href="javascript:0" class="jtDeleteComment" jv-data-comment-id="comment.id">Borrar</a></div></jv-if>
+        <jv-if cond="comment.reactions"><div class="commentReactions"><jv-for items="comment.reactions" item="reaction"><button type="button" class="jtReaction" jv-data-comment-id="comment.id" jv-data-reaction="reaction.Reaction" jv-data-mine="reaction.Mine" jv-disabled="cannot_react"><jv>reaction.Reaction</jv> <jv>reaction.Count</jv></button></jv-for></div></jv-if>
     </jv-for>
     <jv-if cond="can_add_comment">
         <form id="commentform">
             <h1>¿Quieres escribir un comentario?</h1>
             <div class="commentForm">
-                <div>Tu nombre o sobrenombre: <input type="text" name="author"> (se publicará)</div>
+                <jv-if cond="signed_in"><div class="commentIdentity">Comentas como <jv>identity.Name</jv>. <a href="javascript:0" id="jtSignOut">Salir</a></div></jv-if>
+                <jv-if cond="can_subscribe"><div class="commentIdentity"><label><input type="checkbox" id="jtSubscribe" jv-checked="subscribed"> Avísame por correo cuando se publiquen nuevos comentarios aquí</label></div></jv-if>
+                <jv-if not cond="signed_in"><div>Tu nombre o sobrenombre: <input type="text" name="author"> (se publicará)</div></jv-if>
+                <jv-if cond="can_verify"><div class="commentIdentity">Opcional: tu dirección de correo <input type="email" name="email"> <input type="button" value="Verificar" id="jtVerifyButton"> (no se publicará; verifícala para tener una insignia y editar tus comentarios)</div></jv-if>
                 <div>Tu comentario:</div>
                 <textarea name="text" id="jtComment"></textarea>
                 <div id="jtPreviewContainer"><div id="jtPreviewBox"></div></div>
@@ -2,7 +2,7 @@ import * as Data from './languagedata';
 
 export { MessageType } from './languagedata';
 
-function getLanguage() {
+export function getLanguage() {
     let elem: HTMLElement | null = document.body;
     while (elem != null && elem.lang == '') {
         elem = elem.parentElement;